@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// keygen writes a new PKCS#8 private key into the JWT key directory. The
+// file name is the kid; the default kid is date based so the newest key
+// sorts last and becomes the signing key when JWT_SIGNING_KID is unset.
+func main() {
+	dir := flag.String("dir", "keys", "directory holding the JWT signing keys")
+	alg := flag.String("alg", "EdDSA", "key algorithm: EdDSA or RS256")
+	kid := flag.String("kid", time.Now().UTC().Format("20060102-150405"), "key id")
+	flag.Parse()
+
+	var private any
+	switch *alg {
+	case "EdDSA":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		private = key
+	case "RS256":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		private = key
+	default:
+		log.Fatalf("Unsupported algorithm %s", *alg)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		log.Fatalf("Failed to encode key: %v", err)
+	}
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		log.Fatalf("Failed to create %s: %v", *dir, err)
+	}
+	path := filepath.Join(*dir, *kid+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		log.Fatalf("Failed to write %s: %v", path, err)
+	}
+	fmt.Printf("Wrote %s key %s\n", *alg, path)
+}
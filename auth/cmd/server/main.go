@@ -12,6 +12,8 @@ import (
 	"github.com/sachinggsingh/e-comm/internal/api"
 	"github.com/sachinggsingh/e-comm/internal/config"
 	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/mailer"
+	"github.com/sachinggsingh/e-comm/internal/oauth"
 	"github.com/sachinggsingh/e-comm/internal/repository"
 	"github.com/sachinggsingh/e-comm/internal/service"
 )
@@ -40,8 +42,33 @@ func main() {
 	server := api.NewServer(env, database, redisCache)
 
 	repo := repository.NewUserRepository(database)
-	userService := service.NewUserService(repo)
-	server.UserRoutes(userService)
+	userService := service.NewUserService(repo, redisCache, env)
+	if err := userService.BootstrapAdmins(); err != nil {
+		log.Printf("Admin bootstrap failed: %v", err)
+	}
+	accountMailer, err := mailer.NewFromEnv(env)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	accountService := service.NewAccountService(repo, redisCache, accountMailer, env)
+	providers, err := oauth.NewProviders(env)
+	if err != nil {
+		log.Fatalf("Failed to configure login providers: %v", err)
+	}
+	oauthService := service.NewOAuthService(repo, redisCache, userService, providers)
+	apiKeyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(database), repo, redisCache)
+	server.UserRoutes(userService, accountService, oauthService, apiKeyService)
+
+	// Remove deleted accounts once their grace period is over
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			if err := userService.PurgeDeletedAccounts(); err != nil {
+				log.Printf("Account purge failed: %v", err)
+			}
+		}
+	}()
 
 	// Start HTTP server in a goroutine
 	go func() {
@@ -51,9 +78,9 @@ func main() {
 	}()
 
 	// Start gRPC server in a goroutine
-	// go func() {
-	// 	api.StartGRPC()
-	// }()
+	go func() {
+		server.StartGRPC(apiKeyService)
+	}()
 
 	// Wait for interrupt signal to gracefully shutdown
 	sigChan := make(chan os.Signal, 1)
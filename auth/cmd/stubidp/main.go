@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/sachinggsingh/e-comm/internal/oauth/oauthtest"
+)
+
+// stubidp serves the stub OpenID Connect provider of package oauthtest for
+// trying social login locally. Point the auth service at it with
+// OIDC_ISSUER_URL, OIDC_CLIENT_ID and OIDC_CLIENT_SECRET.
+func main() {
+	addr := flag.String("addr", ":9999", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9999", "issuer URL, must match OIDC_ISSUER_URL")
+	clientID := flag.String("client-id", "e-comm", "accepted client id")
+	clientSecret := flag.String("client-secret", "secret", "accepted client secret")
+	email := flag.String("email", "user@example.com", "email of the logged in user")
+	verified := flag.Bool("email-verified", true, "whether the email is reported as verified")
+	flag.Parse()
+
+	p, err := oauthtest.NewProvider(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("Failed to start provider: %v", err)
+	}
+	p.Email = *email
+	p.EmailVerified = *verified
+
+	log.Printf("Stub identity provider %s listening on %s", p.Issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, p.Handler()))
+}
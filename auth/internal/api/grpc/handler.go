@@ -2,20 +2,51 @@ package grpc_handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
 	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/service"
 	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type AuthServer struct {
 	proto.UnimplementedValidateTokenServer
+	redisCache *cache.RedisCache
+	apiKeys    *service.APIKeyService
+}
+
+func NewAuthServer(redisCache *cache.RedisCache, apiKeys *service.APIKeyService) *AuthServer {
+	return &AuthServer{
+		redisCache: redisCache,
+		apiKeys:    apiKeys,
+	}
 }
 
 func (a *AuthServer) ValidateToken(ctx context.Context, req *proto.ValidateTokenRequest) (res *proto.ValidateTokenResponse, err error) {
 	fmt.Printf("[gRPC] Received ValidateToken request\n")
 
-	claims, err := helper.ValidateToken(req.Token)
+	if strings.HasPrefix(req.Token, authz.APIKeyPrefix) {
+		principal, err := a.apiKeys.AuthenticateAPIKey(ctx, req.Token)
+		if err != nil {
+			fmt.Printf("[gRPC] API key validation failed: %v\n", err)
+			return &proto.ValidateTokenResponse{
+				Valid: false,
+			}, nil
+		}
+		fmt.Printf("[gRPC] API key %s validated successfully - Authenticated User: %s\n", principal.APIKeyID, principal.UserID)
+		return &proto.ValidateTokenResponse{
+			Valid: true,
+		}, nil
+	}
+
+	claims, err := helper.AuthenticateToken(ctx, a.redisCache, req.Token)
 	if err != nil {
 		fmt.Printf("[gRPC] Token validation failed: %v\n", err)
 		return &proto.ValidateTokenResponse{
@@ -23,8 +54,66 @@ func (a *AuthServer) ValidateToken(ctx context.Context, req *proto.ValidateToken
 		}, nil
 	}
 
-	fmt.Printf("[gRPC] Token validated successfully - Authenticated User: %s (Email: %s)\n", claims.Uid, claims.Email)
+	fmt.Printf("[gRPC] Token validated successfully - Authenticated User: %s (Email: %s)\n", claims.UserID, claims.Email)
 	return &proto.ValidateTokenResponse{
 		Valid: true,
 	}, nil
 }
+
+// Introspect returns who the token belongs to so other services can delegate
+// authentication to auth instead of verifying JWTs themselves
+func (a *AuthServer) Introspect(ctx context.Context, req *proto.IntrospectRequest) (*proto.IntrospectResponse, error) {
+	if req.Token == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "token is required")
+	}
+
+	if strings.HasPrefix(req.Token, authz.APIKeyPrefix) {
+		return a.introspectAPIKey(ctx, req.Token)
+	}
+
+	token, err := helper.AuthenticateToken(ctx, a.redisCache, req.Token)
+	if err != nil {
+		return &proto.IntrospectResponse{
+			Active:  false,
+			Revoked: errors.Is(err, helper.ErrTokenRevoked),
+		}, nil
+	}
+
+	return &proto.IntrospectResponse{
+		Active:    true,
+		UserId:    token.UserID,
+		Email:     token.Email,
+		Roles:     token.Roles,
+		Scopes:    authz.PermissionsFor(token.Roles),
+		IssuedAt:  time.UnixMilli(token.IssuedAt).Unix(),
+		ExpiresAt: token.ExpiresAt,
+		SessionId: token.Family,
+		TokenType: authz.TokenTypeAccess,
+	}, nil
+}
+
+// introspectAPIKey reports the caller of an API key. SessionId carries the
+// key id so callers can tell which key was used.
+func (a *AuthServer) introspectAPIKey(ctx context.Context, key string) (*proto.IntrospectResponse, error) {
+	principal, err := a.apiKeys.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		if !errors.Is(err, service.ErrInvalidAPIKey) && !errors.Is(err, service.ErrAPIKeyRevoked) {
+			return nil, status.Errorf(codes.Unavailable, "failed to validate api key")
+		}
+		return &proto.IntrospectResponse{
+			Active:    false,
+			Revoked:   errors.Is(err, service.ErrAPIKeyRevoked),
+			TokenType: authz.TokenTypeAPIKey,
+		}, nil
+	}
+
+	return &proto.IntrospectResponse{
+		Active:    true,
+		UserId:    principal.UserID,
+		Email:     principal.Email,
+		Roles:     principal.Roles,
+		Scopes:    principal.Scopes,
+		SessionId: principal.APIKeyID,
+		TokenType: authz.TokenTypeAPIKey,
+	}, nil
+}
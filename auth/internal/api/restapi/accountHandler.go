@@ -0,0 +1,119 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/service"
+)
+
+// AccountHandler serves the email verification and password reset flows
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+// VerifyEmail accepts the token as JSON body, or as query parameter so the
+// link from the email can be opened directly
+func (a *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req model.VerifyEmailRequest
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	case http.MethodGet:
+		req.Token = r.URL.Query().Get("token")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := a.accountService.VerifyEmail(ctx, req.Token); err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Email verified successfully"))
+}
+
+func (a *AccountHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	a.handleEmailRequest(w, r, a.accountService.ResendVerificationEmail)
+}
+
+func (a *AccountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	a.handleEmailRequest(w, r, a.accountService.ForgotPassword)
+}
+
+// handleEmailRequest always answers 202 once the input is valid, whether or
+// not the address belongs to an account
+func (a *AccountHandler) handleEmailRequest(w http.ResponseWriter, r *http.Request, send func(context.Context, string) error) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := send(ctx, req.Email); err != nil {
+		http.Error(w, "Failed to send email", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("If the address belongs to an account, an email is on its way"))
+}
+
+func (a *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := a.accountService.ResetPassword(ctx, req.Token, req.Password); err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Password has been reset"))
+}
+
+func writeAccountError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrInvalidAccountToken) || errors.Is(err, service.ErrWeakPassword) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,126 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/service"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+)
+
+// APIKeyHandler manages API keys. The user routes act on the caller's own
+// keys; the admin routes manage service keys and must be guarded with the
+// user:admin permission.
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// Create issues a key for the caller. The key is only ever returned here.
+func (a *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	principal := authz.PrincipalFromContext(r.Context())
+	var req model.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := a.apiKeyService.CreateUserKey(principal.UserID, &req)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeCreatedKey(w, resp)
+}
+
+func (a *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	principal := authz.PrincipalFromContext(r.Context())
+	keys, err := a.apiKeyService.ListUserKeys(principal.UserID)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (a *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	principal := authz.PrincipalFromContext(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := a.apiKeyService.RevokeUserKey(ctx, principal.UserID, r.PathValue("id")); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateServiceKey issues a key for an integration
+func (a *APIKeyHandler) CreateServiceKey(w http.ResponseWriter, r *http.Request) {
+	principal := authz.PrincipalFromContext(r.Context())
+	var req model.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := a.apiKeyService.CreateServiceKey(principal.UserID, &req)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeCreatedKey(w, resp)
+}
+
+func (a *APIKeyHandler) ListServiceKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := a.apiKeyService.ListServiceKeys()
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAny revokes any key, user or service
+func (a *APIKeyHandler) RevokeAny(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := a.apiKeyService.RevokeKey(ctx, r.PathValue("id")); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeCreatedKey(w http.ResponseWriter, resp *model.CreateAPIKeyResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAPIKeyReq):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrTooManyAPIKeys):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrAPIKeyNotFound), errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
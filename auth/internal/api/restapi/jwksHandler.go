@@ -0,0 +1,27 @@
+package restapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sachinggsingh/e-comm/internal/helper"
+)
+
+// JWKS publishes the public keys tokens are signed with so other services can
+// verify them without being able to issue tokens themselves
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	set, err := helper.PublicKeySet()
+	if err != nil {
+		http.Error(w, "Failed to load signing keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(set)
+}
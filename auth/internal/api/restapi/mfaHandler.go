@@ -0,0 +1,120 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/service"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+)
+
+// MFAHandler manages two-factor authentication of the authenticated user.
+// Its routes must sit behind the auth middleware.
+type MFAHandler struct {
+	userService *service.UserService
+}
+
+func NewMFAHandler(userService *service.UserService) *MFAHandler {
+	return &MFAHandler{
+		userService: userService,
+	}
+}
+
+// Enroll starts TOTP enrollment and returns the secret to add to an
+// authenticator app
+func (m *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	principal := authz.PrincipalFromContext(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := m.userService.EnrollTOTP(ctx, principal.UserID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Confirm enables TOTP with a first code from the app and returns the
+// recovery codes
+func (m *MFAHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	m.withCode(w, r, func(ctx context.Context, userID string, code string) (any, error) {
+		codes, err := m.userService.ConfirmTOTP(ctx, userID, code)
+		if err != nil {
+			return nil, err
+		}
+		return model.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+	})
+}
+
+func (m *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	m.withCode(w, r, func(ctx context.Context, userID string, code string) (any, error) {
+		return nil, m.userService.DisableMFA(ctx, userID, code)
+	})
+}
+
+func (m *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	m.withCode(w, r, func(ctx context.Context, userID string, code string) (any, error) {
+		codes, err := m.userService.RegenerateRecoveryCodes(ctx, userID, code)
+		if err != nil {
+			return nil, err
+		}
+		return model.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+	})
+}
+
+// withCode decodes a code request and runs action for the caller. A nil
+// result is answered with an empty 200.
+func (m *MFAHandler) withCode(w http.ResponseWriter, r *http.Request, action func(context.Context, string, string) (any, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	principal := authz.PrincipalFromContext(r.Context())
+
+	var req model.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := action(ctx, principal.UserID, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	if result == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMFACode):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, service.ErrMFAAlreadyEnabled),
+		errors.Is(err, service.ErrMFANotEnabled),
+		errors.Is(err, service.ErrMFAEnrollmentExpired):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,105 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/oauth"
+	"github.com/sachinggsingh/e-comm/internal/service"
+)
+
+// oauthStateCookie binds a social login to the browser that started it, so
+// a callback URL forged by someone else is rejected (login CSRF)
+const oauthStateCookie = "oauth_state"
+
+type OAuthHandler struct {
+	oauthService   *service.OAuthService
+	trustedProxies []*net.IPNet
+}
+
+func NewOAuthHandler(oauthService *service.OAuthService, trustedProxies []*net.IPNet) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService:   oauthService,
+		trustedProxies: trustedProxies,
+	}
+}
+
+// Providers lists the configured login providers
+func (o *OAuthHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"providers": o.oauthService.Providers()})
+}
+
+// Login redirects the browser to the provider's login page
+func (o *OAuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	state, authURL, err := o.oauthService.BeginLogin(ctx, r.PathValue("provider"))
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/oauth/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback is where the provider sends the browser back to. It answers like
+// /login: a token pair, or an MFA challenge.
+func (o *OAuthHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "Login was not completed: "+providerErr, http.StatusBadRequest)
+		return
+	}
+	state, code := query.Get("state"), query.Get("code")
+	if state == "" || code == "" {
+		http.Error(w, "state and code are required", http.StatusBadRequest)
+		return
+	}
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || cookie.Value != state {
+		http.Error(w, service.ErrInvalidOAuthState.Error(), http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/oauth/", MaxAge: -1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	user, mfaToken, err := o.oauthService.CompleteLogin(ctx, r.PathValue("provider"), state, code, clientInfo(r, o.trustedProxies))
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+	writeLoginResult(w, user, mfaToken)
+}
+
+func writeOAuthError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, oauth.ErrUnknownProvider):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidOAuthState):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrProviderEmailUnverified), errors.Is(err, service.ErrAccountEmailUnverified):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, oauth.ErrInvalidIDToken):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		log.Printf("Social login failed: %v", err)
+		http.Error(w, "Login with the provider failed", http.StatusBadGateway)
+	}
+}
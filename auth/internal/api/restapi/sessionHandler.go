@@ -0,0 +1,58 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/service"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+)
+
+// SessionHandler lists and signs out the devices the authenticated user is
+// logged in on. Its routes must sit behind the auth middleware.
+type SessionHandler struct {
+	userService *service.UserService
+}
+
+func NewSessionHandler(userService *service.UserService) *SessionHandler {
+	return &SessionHandler{
+		userService: userService,
+	}
+}
+
+func (s *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	principal := authz.PrincipalFromContext(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessions, err := s.userService.ListSessions(ctx, principal.UserID, principal.SessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// Revoke signs one session out. Its tokens stop working immediately, in
+// every service.
+func (s *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	principal := authz.PrincipalFromContext(r.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.userService.RevokeSession(ctx, principal.UserID, r.PathValue("id")); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
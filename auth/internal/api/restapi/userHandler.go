@@ -3,7 +3,9 @@ package restapi
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -11,46 +13,28 @@ import (
 	cache "github.com/sachinggsingh/e-comm/internal/caches"
 	"github.com/sachinggsingh/e-comm/internal/config"
 	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/middleware"
 	"github.com/sachinggsingh/e-comm/internal/model"
 	"github.com/sachinggsingh/e-comm/internal/service"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/sachinggsingh/e-comm/pb/authz"
 )
 
 type UserHandler struct {
-	userService *service.UserService
-	redisCache  *cache.RedisCache
-	env         *config.Env
+	userService    *service.UserService
+	accountService *service.AccountService
+	redisCache     *cache.RedisCache
+	env            *config.Env
 }
 
-func NewUserHandler(userService *service.UserService, redisCache *cache.RedisCache, env *config.Env) *UserHandler {
+func NewUserHandler(userService *service.UserService, accountService *service.AccountService, redisCache *cache.RedisCache, env *config.Env) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		redisCache:  redisCache,
-		env:         env,
+		userService:    userService,
+		accountService: accountService,
+		redisCache:     redisCache,
+		env:            env,
 	}
 }
 
-// getClientIP extracts the client IP address with proper fallback handling
-func (u *UserHandler) getClientIP(r *http.Request) string {
-	// Try X-Forwarded-For header first (for proxies/load balancers)
-	if xForwardedFor := r.Header.Get("X-Forwarded-For"); xForwardedFor != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		if ips := strings.Split(xForwardedFor, ","); len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-	// Try X-Real-IP header as fallback
-	if xRealIP := r.Header.Get("X-Real-IP"); xRealIP != "" {
-		return strings.TrimSpace(xRealIP)
-	}
-	// Fall back to RemoteAddr and strip port if needed
-	ip := r.RemoteAddr
-	if colon := strings.LastIndex(ip, ":"); colon != -1 {
-		ip = ip[:colon]
-	}
-	return ip
-}
-
 func (u *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var newUser model.User
 	if err := json.NewDecoder(r.Body).Decode(&newUser); err != nil {
@@ -58,68 +42,234 @@ func (u *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := u.userService.RegisterUser(&newUser)
+	registered, err := u.userService.RegisterUser(&newUser, clientInfo(r, u.env.TRUSTED_PROXIES))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := u.accountService.SendVerificationEmail(ctx, registered); err != nil {
+		// The account exists, the user can ask for a new link later
+		log.Printf("Failed to send verification email to %s: %v", *registered.Email, err)
+	}
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte("User registered successfully"))
 }
 
 func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var user model.User
-	ip := u.getClientIP(r)
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		http.Error(w, "Invalid input data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	loggedInUser, mfaToken, err := u.userService.LoginUser(&user, clientInfo(r, u.env.TRUSTED_PROXIES))
+	if err != nil {
+		if writeLockedError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeLoginResult(w, loggedInUser, mfaToken)
+}
+
+// clientInfo describes the client of a request for its session record
+func clientInfo(r *http.Request, trustedProxies []*net.IPNet) model.ClientInfo {
+	return model.ClientInfo{
+		User_agent: r.UserAgent(),
+		Ip:         middleware.ClientIP(r, trustedProxies),
+	}
+}
+
+// writeLoginResult answers a completed first login step with the token pair,
+// or with the MFA challenge when a second factor is required
+func writeLoginResult(w http.ResponseWriter, user *model.User, mfaToken string) {
+	w.Header().Set("Content-Type", "application/json")
+	if mfaToken != "" {
+		json.NewEncoder(w).Encode(model.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresIn:   int(service.MFAChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	resp := model.LoginResponse{
+		ID:           user.User_id,
+		Email:        *user.Email,
+		Token:        *user.Token,
+		RefreshToken: *user.Refresh_Token,
+		User_id:      user.User_id,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// LoginMFA is the second login step for users with two-factor
+// authentication. Wrong codes count towards the account lockout like wrong
+// passwords.
+func (u *UserHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		http.Error(w, "mfa_token and code are required", http.StatusBadRequest)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	attempts, _ := u.redisCache.IncrementLoginAttempt(ctx, ip, u.env.RATE_LIMIT_WINDOW_MINUTES)
-	fmt.Println(attempts)
-	if attempts > 5 {
-		http.Error(w, "Too many attempts need to wait for 2 minutes", http.StatusTooManyRequests)
+	user, err := u.userService.CompleteMFALogin(ctx, req.MFAToken, req.Code, clientInfo(r, u.env.TRUSTED_PROXIES))
+	if err != nil {
+		if writeLockedError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidMFACode) || errors.Is(err, service.ErrInvalidMFAChallenge) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid input data: "+err.Error(), http.StatusBadRequest)
+	resp := model.LoginResponse{
+		ID:           user.User_id,
+		Email:        *user.Email,
+		Token:        *user.Token,
+		RefreshToken: *user.Refresh_Token,
+		User_id:      user.User_id,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeLockedError answers 429 with Retry-After if err is an account lockout
+func writeLockedError(w http.ResponseWriter, err error) bool {
+	var locked *service.AccountLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	middleware.WriteRetryAfter(w, locked.RetryAfter)
+	http.Error(w, locked.Error(), http.StatusTooManyRequests)
+	return true
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh pair
+func (u *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "refresh_token is required", http.StatusBadRequest)
 		return
 	}
-	loggedInUser, err := u.userService.LoginUser(&user)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := u.userService.RefreshTokens(ctx, req.RefreshToken, clientInfo(r, u.env.TRUSTED_PROXIES))
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	resp := model.LoginResponse{
-		ID:           loggedInUser.User_id,
-		Email:        *loggedInUser.Email,
-		Token:        *loggedInUser.Token,
-		RefreshToken: *loggedInUser.Refresh_Token,
-		User_id:      loggedInUser.User_id,
+		ID:           user.User_id,
+		Email:        *user.Email,
+		Token:        *user.Token,
+		RefreshToken: *user.Refresh_Token,
+		User_id:      user.User_id,
 	}
-	u.redisCache.ResetLoginAttempts(ctx, ip)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// Logout revokes the access token of the request and its refresh token
+func (u *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	u.logout(w, r, u.userService.LogoutUser)
+}
+
+// LogoutAll revokes every session of the authenticated user
+func (u *UserHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	u.logout(w, r, u.userService.LogoutAllSessions)
+}
+
+func (u *UserHandler) logout(w http.ResponseWriter, r *http.Request, revoke func(context.Context, string) error) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := revoke(ctx, token); err != nil {
+		if errors.Is(err, helper.ErrInvalidToken) {
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Failed to logout: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Logged out successfully"))
+}
+
+// Profile returns (GET) or updates (PATCH) the profile of the authenticated
+// user
 func (u *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
-	userIDFromRequest := r.URL.Query().Get("user_id")
-	if userIDFromRequest == "" {
-		http.Error(w, "User id is required", http.StatusBadRequest)
+	principal := authz.PrincipalFromContext(r.Context())
+	if principal == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	userID, err := helper.Authorize(r)
-	if err != nil {
-		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+
+	var result *model.PublicProfile
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		result, err = u.userService.Profile(principal.UserID)
+	case http.MethodPatch:
+		var update model.UpdateProfileRequest
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		result, err = u.userService.UpdateProfile(principal.UserID, &update)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	fmt.Println("Authenticated User:", userID.Uid)
-	user := &model.User{User_id: userIDFromRequest}
-	result, err := u.userService.Profile(user)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if errors.Is(err, service.ErrUserNotFound) {
 			http.Error(w, "User not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrInvalidProfile) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to fetch profile: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -127,5 +277,72 @@ func (u *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(result)
+}
+
+// ChangePassword replaces the password of the authenticated user and signs
+// out their other sessions
+func (u *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Current_password == "" || req.New_password == "" {
+		http.Error(w, "current_password and new_password are required", http.StatusBadRequest)
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := u.userService.ChangePassword(ctx, token, req.Current_password, req.New_password); err != nil {
+		writeCredentialError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Password changed, other sessions were signed out"))
+}
 
+// DeleteAccount schedules the account of the authenticated user for deletion
+func (u *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req model.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		http.Error(w, "password is required", http.StatusBadRequest)
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	purgeAt, err := u.userService.DeleteAccount(ctx, token, req.Password)
+	if err != nil {
+		writeCredentialError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(model.DeleteAccountResponse{Purge_at: purgeAt})
+}
+
+func writeCredentialError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, helper.ErrInvalidToken):
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, service.ErrIncorrectPassword):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrWeakPassword):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
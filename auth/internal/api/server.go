@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	grpc_handler "github.com/sachinggsingh/e-comm/internal/api/grpc"
 	"github.com/sachinggsingh/e-comm/internal/api/restapi"
@@ -15,6 +16,7 @@ import (
 	"github.com/sachinggsingh/e-comm/internal/middleware"
 	"github.com/sachinggsingh/e-comm/internal/service"
 	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/authz"
 	"google.golang.org/grpc"
 )
 
@@ -48,14 +50,14 @@ func (s *Server) StartServer() error {
 	return nil
 }
 
-func StartGRPC() {
+func (s *Server) StartGRPC(apiKeyService *service.APIKeyService) {
 	lis, err := net.Listen("tcp", ":9090")
 	if err != nil {
 		log.Fatalf(" gRPC failed to listen on :9090: %v", err)
 	}
 
 	grpcServer := grpc.NewServer()
-	proto.RegisterValidateTokenServer(grpcServer, &grpc_handler.AuthServer{})
+	proto.RegisterValidateTokenServer(grpcServer, grpc_handler.NewAuthServer(s.redisCache, apiKeyService))
 
 	log.Println("=" + strings.Repeat("=", 50) + "=")
 	log.Println("Auth gRPC Server is running on :9090")
@@ -67,24 +69,103 @@ func StartGRPC() {
 	}
 }
 
-func (s *Server) UserRoutes(userService *service.UserService) {
-	// Create auth middleware
-	authMiddleware := &middleware.AuthMiddleware{Redis: s.redisCache}
+func (s *Server) UserRoutes(userService *service.UserService, accountService *service.AccountService, oauthService *service.OAuthService, apiKeyService *service.APIKeyService) {
+	// Create auth middleware, accepting both bearer tokens and API keys
+	authMiddleware := &middleware.AuthMiddleware{Redis: s.redisCache, APIKeys: apiKeyService}
+	// Per IP limit on the routes that take credentials or send email
+	rateLimiter := middleware.NewRateLimiter(s.redisCache, s.env.TRUSTED_PROXIES)
+	ipLimit := cache.RateLimit{
+		Limit:  s.env.RATE_LIMIT_IP_MAX_REQUESTS,
+		Window: time.Duration(s.env.RATE_LIMIT_WINDOW_MINUTES) * time.Minute,
+	}
 
 	// Public routes (no authentication required)
-	http.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
-		userHandler := restapi.NewUserHandler(userService, s.redisCache, s.env)
+	http.HandleFunc("/.well-known/jwks.json", restapi.JWKS)
+	http.Handle("/register", rateLimiter.Limit("register", ipLimit, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userHandler := restapi.NewUserHandler(userService, accountService, s.redisCache, s.env)
 		userHandler.Register(w, r)
-	})
-	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
-		userHandler := restapi.NewUserHandler(userService, s.redisCache, s.env)
+	})))
+	http.Handle("/login", rateLimiter.Limit("login", ipLimit, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userHandler := restapi.NewUserHandler(userService, accountService, s.redisCache, s.env)
 		userHandler.Login(w, r)
-	})
+	})))
+	http.Handle("/login/mfa", rateLimiter.Limit("login:mfa", ipLimit, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userHandler := restapi.NewUserHandler(userService, accountService, s.redisCache, s.env)
+		userHandler.LoginMFA(w, r)
+	})))
+	http.Handle("/token/refresh", rateLimiter.Limit("token:refresh", ipLimit, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userHandler := restapi.NewUserHandler(userService, accountService, s.redisCache, s.env)
+		userHandler.RefreshToken(w, r)
+	})))
+
+	// Social login (authorization code flow with PKCE)
+	oauthHandler := restapi.NewOAuthHandler(oauthService, s.env.TRUSTED_PROXIES)
+	http.HandleFunc("GET /oauth/providers", oauthHandler.Providers)
+	http.Handle("GET /oauth/{provider}/login", rateLimiter.Limit("oauth:login", ipLimit, http.HandlerFunc(oauthHandler.Login)))
+	http.HandleFunc("GET /oauth/{provider}/callback", oauthHandler.Callback)
 
-	// Protected routes (authentication required)
-	// Profile endpoint with caching middleware
+	// Email verification and password reset (public, authorized by the emailed token)
+	accountHandler := restapi.NewAccountHandler(accountService)
+	http.HandleFunc("/verify-email", accountHandler.VerifyEmail)
+	http.Handle("/verify-email/resend", rateLimiter.Limit("verify-email:resend", ipLimit, http.HandlerFunc(accountHandler.ResendVerification)))
+	http.Handle("/password/forgot", rateLimiter.Limit("password:forgot", ipLimit, http.HandlerFunc(accountHandler.ForgotPassword)))
+	http.Handle("/password/reset", rateLimiter.Limit("password:reset", ipLimit, http.HandlerFunc(accountHandler.ResetPassword)))
+
+	// Protected routes (authentication required). Routes managing the account
+	// itself use ValidateSession so API keys cannot reach them.
+	// Profile of the authenticated user (GET, PATCH)
 	http.Handle("/profile", authMiddleware.Validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userHandler := restapi.NewUserHandler(userService, s.redisCache, s.env)
+		userHandler := restapi.NewUserHandler(userService, accountService, s.redisCache, s.env)
 		userHandler.Profile(w, r)
 	})))
+	http.Handle("/password/change", authMiddleware.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userHandler := restapi.NewUserHandler(userService, accountService, s.redisCache, s.env)
+		userHandler.ChangePassword(w, r)
+	})))
+	http.Handle("/account", authMiddleware.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userHandler := restapi.NewUserHandler(userService, accountService, s.redisCache, s.env)
+		userHandler.DeleteAccount(w, r)
+	})))
+	http.Handle("/logout", authMiddleware.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userHandler := restapi.NewUserHandler(userService, accountService, s.redisCache, s.env)
+		userHandler.Logout(w, r)
+	})))
+	http.Handle("/logout/all", authMiddleware.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userHandler := restapi.NewUserHandler(userService, accountService, s.redisCache, s.env)
+		userHandler.LogoutAll(w, r)
+	})))
+
+	// Devices the user is logged in on
+	sessionHandler := restapi.NewSessionHandler(userService)
+	http.Handle("GET /sessions", authMiddleware.ValidateSession(http.HandlerFunc(sessionHandler.List)))
+	http.Handle("DELETE /sessions/{id}", authMiddleware.ValidateSession(http.HandlerFunc(sessionHandler.Revoke)))
+
+	// Two-factor authentication management
+	mfaHandler := restapi.NewMFAHandler(userService)
+	http.Handle("/mfa/totp/enroll", authMiddleware.ValidateSession(http.HandlerFunc(mfaHandler.Enroll)))
+	http.Handle("/mfa/totp/confirm", authMiddleware.ValidateSession(http.HandlerFunc(mfaHandler.Confirm)))
+	http.Handle("/mfa/totp/disable", authMiddleware.ValidateSession(http.HandlerFunc(mfaHandler.Disable)))
+	http.Handle("/mfa/recovery-codes", authMiddleware.ValidateSession(http.HandlerFunc(mfaHandler.RegenerateRecoveryCodes)))
+
+	// API keys of the authenticated user
+	apiKeyHandler := restapi.NewAPIKeyHandler(apiKeyService)
+	http.Handle("POST /api-keys", authMiddleware.ValidateSession(http.HandlerFunc(apiKeyHandler.Create)))
+	http.Handle("GET /api-keys", authMiddleware.ValidateSession(http.HandlerFunc(apiKeyHandler.List)))
+	http.Handle("DELETE /api-keys/{id}", authMiddleware.ValidateSession(http.HandlerFunc(apiKeyHandler.Revoke)))
+
+	// Admin routes (user:admin permission required)
+	http.Handle("/admin/users", authMiddleware.RequirePermission(authz.PermUserAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminHandler := restapi.NewAdminHandler(userService)
+		adminHandler.ListUsers(w, r)
+	})))
+	http.Handle("/admin/users/roles", authMiddleware.RequirePermission(authz.PermUserAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminHandler := restapi.NewAdminHandler(userService)
+		adminHandler.SetRoles(w, r)
+	})))
+
+	// Service keys are managed from a login session too, so a key holding
+	// user:admin cannot mint further keys
+	http.Handle("POST /admin/api-keys", authMiddleware.ValidateSession(authMiddleware.RequirePermission(authz.PermUserAdmin, http.HandlerFunc(apiKeyHandler.CreateServiceKey))))
+	http.Handle("GET /admin/api-keys", authMiddleware.ValidateSession(authMiddleware.RequirePermission(authz.PermUserAdmin, http.HandlerFunc(apiKeyHandler.ListServiceKeys))))
+	http.Handle("DELETE /admin/api-keys/{id}", authMiddleware.ValidateSession(authMiddleware.RequirePermission(authz.PermUserAdmin, http.HandlerFunc(apiKeyHandler.RevokeAny))))
 }
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LockoutPolicy locks an account after MaxFailures failed logins within
+// Window. The first lock lasts Base and every further lock within a day
+// doubles it, up to Max.
+type LockoutPolicy struct {
+	MaxFailures int
+	Window      time.Duration
+	Base        time.Duration
+	Max         time.Duration
+}
+
+// lockoutLevelTTL is how long past lockouts count towards the next one
+const lockoutLevelTTL = 24 * time.Hour
+
+// recordFailureScript counts a failed login and locks the account once the
+// policy is exceeded. It returns the lock duration in ms, 0 if not locked.
+//
+// KEYS[1] failures, KEYS[2] lockout level, KEYS[3] lock
+// ARGV[1] max failures, ARGV[2] window (ms), ARGV[3] base lock (ms),
+// ARGV[4] max lock (ms), ARGV[5] level ttl (ms)
+var recordFailureScript = redis.NewScript(`
+local failures = redis.call('INCR', KEYS[1])
+if failures == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if failures < tonumber(ARGV[1]) then
+	return 0
+end
+
+redis.call('DEL', KEYS[1])
+local level = redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[5])
+local lock = math.min(tonumber(ARGV[3]) * 2 ^ (level - 1), tonumber(ARGV[4]))
+lock = math.floor(lock)
+redis.call('SET', KEYS[3], level, 'PX', lock)
+return lock
+`)
+
+func lockoutKeys(account string) []string {
+	account = strings.ToLower(account)
+	return []string{
+		"lockout:failures:" + account,
+		"lockout:level:" + account,
+		"lockout:locked:" + account,
+	}
+}
+
+// RecordLoginFailure counts a failed login for the account and returns how
+// long it is locked as a result, zero if it is not
+func (rc *RedisCache) RecordLoginFailure(ctx context.Context, account string, policy LockoutPolicy) (time.Duration, error) {
+	lock, err := recordFailureScript.Run(ctx, rc.client, lockoutKeys(account),
+		policy.MaxFailures, policy.Window.Milliseconds(), policy.Base.Milliseconds(),
+		policy.Max.Milliseconds(), lockoutLevelTTL.Milliseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(lock) * time.Millisecond, nil
+}
+
+// AccountLockedFor returns the time left on the account's lock, zero if it
+// is not locked
+func (rc *RedisCache) AccountLockedFor(ctx context.Context, account string) (time.Duration, error) {
+	ttl, err := rc.client.PTTL(ctx, lockoutKeys(account)[2]).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// ResetLoginFailures clears the failures and lockout history after a
+// successful login
+func (rc *RedisCache) ResetLoginFailures(ctx context.Context, account string) error {
+	return rc.client.Del(ctx, lockoutKeys(account)...).Err()
+}
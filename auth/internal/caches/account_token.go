@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrAccountTokenNotFound = errors.New("token not found or already used")
+
+func accountTokenKey(purpose string, idHash string) string {
+	return "token:" + purpose + ":" + idHash
+}
+
+// SaveAccountToken stores a single-use token (email verification, password
+// reset) for the user until ttl elapses
+func (rc *RedisCache) SaveAccountToken(ctx context.Context, purpose string, idHash string, userID string, ttl time.Duration) error {
+	return rc.SetString(ctx, accountTokenKey(purpose, idHash), userID, ttl)
+}
+
+// LookupAccountToken returns the user a single-use token was issued to
+// without consuming it
+func (rc *RedisCache) LookupAccountToken(ctx context.Context, purpose string, idHash string) (string, error) {
+	userID, err := rc.GetString(ctx, accountTokenKey(purpose, idHash))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", ErrAccountTokenNotFound
+		}
+		return "", err
+	}
+	return userID, nil
+}
+
+// ConsumeAccountToken returns the user the token was issued to and deletes it
+// in the same step, so each token works only once
+func (rc *RedisCache) ConsumeAccountToken(ctx context.Context, purpose string, idHash string) (string, error) {
+	userID, err := rc.client.GetDel(ctx, accountTokenKey(purpose, idHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", ErrAccountTokenNotFound
+		}
+		return "", err
+	}
+	return userID, nil
+}
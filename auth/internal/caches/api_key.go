@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CachedAPIKey is a validated API key together with the caller it stands
+// for, so requests do not hit MongoDB every time
+type CachedAPIKey struct {
+	KeyID     string   `json:"key_id"`
+	UserID    string   `json:"user_id"`
+	Email     string   `json:"email"`
+	Scopes    []string `json:"scopes"`
+	Revoked   bool     `json:"revoked"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at"`
+}
+
+func apiKeyCacheKey(hash string) string {
+	return "apikey:valid:" + hash
+}
+
+func (rc *RedisCache) CacheAPIKey(ctx context.Context, hash string, key *CachedAPIKey, ttl time.Duration) error {
+	return rc.Set(ctx, apiKeyCacheKey(hash), key, ttl)
+}
+
+// GetCachedAPIKey returns the cached key, nil if it is not cached
+func (rc *RedisCache) GetCachedAPIKey(ctx context.Context, hash string) (*CachedAPIKey, error) {
+	var key CachedAPIKey
+	if err := rc.Get(ctx, apiKeyCacheKey(hash), &key); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (rc *RedisCache) RemoveCachedAPIKey(ctx context.Context, hash string) error {
+	return rc.Delete(ctx, apiKeyCacheKey(hash))
+}
+
+// MarkAPIKeyUsed returns true at most once per interval per key, which is
+// how often the last use is written to MongoDB
+func (rc *RedisCache) MarkAPIKeyUsed(ctx context.Context, keyID string, interval time.Duration) (bool, error) {
+	return rc.client.SetNX(ctx, "apikey:used:"+keyID, 1, interval).Result()
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrMFAEnrollmentNotFound = errors.New("no pending two-factor enrollment")
+
+func mfaEnrollmentKey(userID string) string {
+	return "mfa:enroll:" + userID
+}
+
+// SavePendingTOTPSecret keeps a freshly generated secret until the user
+// confirms it with a first code
+func (rc *RedisCache) SavePendingTOTPSecret(ctx context.Context, userID string, secret string, ttl time.Duration) error {
+	return rc.SetString(ctx, mfaEnrollmentKey(userID), secret, ttl)
+}
+
+func (rc *RedisCache) GetPendingTOTPSecret(ctx context.Context, userID string) (string, error) {
+	secret, err := rc.GetString(ctx, mfaEnrollmentKey(userID))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", ErrMFAEnrollmentNotFound
+		}
+		return "", err
+	}
+	return secret, nil
+}
+
+func (rc *RedisCache) DeletePendingTOTPSecret(ctx context.Context, userID string) error {
+	return rc.Delete(ctx, mfaEnrollmentKey(userID))
+}
+
+// MarkTOTPStepUsed records that the user's code for a time step was accepted.
+// It returns false if the step was already used, which stops a code seen by
+// someone else from being replayed while it is still valid.
+func (rc *RedisCache) MarkTOTPStepUsed(ctx context.Context, userID string, step int64, ttl time.Duration) (bool, error) {
+	key := fmt.Sprintf("mfa:totp:used:%s:%d", userID, step)
+	return rc.client.SetNX(ctx, key, 1, ttl).Result()
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrOAuthStateNotFound = errors.New("login state not found or already used")
+
+// OAuthState is what the callback of a social login needs from the request
+// that started it
+type OAuthState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func oauthStateKey(state string) string {
+	return "oauth:state:" + state
+}
+
+func (rc *RedisCache) SaveOAuthState(ctx context.Context, state string, value *OAuthState, ttl time.Duration) error {
+	return rc.Set(ctx, oauthStateKey(state), value, ttl)
+}
+
+// ConsumeOAuthState returns the stored state and deletes it, so a callback
+// cannot be replayed
+func (rc *RedisCache) ConsumeOAuthState(ctx context.Context, state string) (*OAuthState, error) {
+	data, err := rc.client.GetDel(ctx, oauthStateKey(state)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrOAuthStateNotFound
+		}
+		return nil, err
+	}
+	var value OAuthState
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
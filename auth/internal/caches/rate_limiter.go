@@ -2,56 +2,81 @@ package cache
 
 import (
 	"context"
-	"log"
+	"crypto/rand"
+	"encoding/hex"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
-// IncrementLoginAttempt increments the login attempt counter for a given IP
-// and sets expiration if it's the first attempt
-func (rc *RedisCache) IncrementLoginAttempt(ctx context.Context, ip string, windowMinutes int) (int, error) {
-	key := "ratelimit:login:" + ip
-	count, err := rc.client.Incr(ctx, key).Result()
-	if err != nil {
-		log.Printf("Error incrementing rate limit for IP %s: %v", ip, err)
-		return 0, err
-	}
-	if count == 1 {
-		// Set expiration only on first increment
-		rc.client.Expire(ctx, key, time.Duration(windowMinutes)*time.Minute)
-	}
-	return int(count), nil
+// RateLimit allows Limit requests per sliding Window
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
 }
 
-// ResetLoginAttempts resets the login attempt counter for a given IP
-func (rc *RedisCache) ResetLoginAttempts(ctx context.Context, ip string) error {
-	key := "ratelimit:login:" + ip
-	if err := rc.client.Del(ctx, key).Err(); err != nil {
-		log.Printf("Error resetting rate limit for IP %s: %v", ip, err)
-		return err
-	}
-	return nil
+// RateLimitResult describes the state of a limit after a request was counted
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the oldest counted request leaves the window.
+	// For a rejected request it is how long the caller has to wait.
+	Reset time.Duration
 }
 
-// IncrementRegisterAttempt increments the register attempt counter for a given IP
-func (rc *RedisCache) IncrementRegisterAttempt(ctx context.Context, ip string, windowMinutes int) (int, error) {
-	key := "ratelimit:register:" + ip
-	count, err := rc.client.Incr(ctx, key).Result()
-	if err != nil {
-		log.Printf("Error incrementing register rate limit for IP %s: %v", ip, err)
-		return 0, err
+// slidingWindowScript keeps one sorted set member per request, scored by its
+// time in milliseconds. Requests older than the window are dropped before
+// counting, so the limit holds over any window-long period, not just
+// aligned ones.
+//
+// KEYS[1] limiter key
+// ARGV[1] now (ms), ARGV[2] window (ms), ARGV[3] limit, ARGV[4] unique member
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = 0
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// AllowRequest counts a request against the limit stored under key and
+// reports whether it may proceed
+func (rc *RedisCache) AllowRequest(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error) {
+	member := make([]byte, 8)
+	if _, err := rand.Read(member); err != nil {
+		return nil, err
 	}
-	if count == 1 {
-		rc.client.Expire(ctx, key, time.Duration(windowMinutes)*time.Minute)
+	now := time.Now().UnixMilli()
+	res, err := slidingWindowScript.Run(ctx, rc.client, []string{"ratelimit:" + key},
+		now, limit.Window.Milliseconds(), limit.Limit, hex.EncodeToString(member)).Int64Slice()
+	if err != nil {
+		return nil, err
 	}
-	return int(count), nil
+	return &RateLimitResult{
+		Allowed:   res[0] == 1,
+		Limit:     limit.Limit,
+		Remaining: int(res[1]),
+		Reset:     time.Duration(res[2]) * time.Millisecond,
+	}, nil
 }
 
-// ResetRegisterAttempts resets the register attempt counter for a given IP
-func (rc *RedisCache) ResetRegisterAttempts(ctx context.Context, ip string) error {
-	key := "ratelimit:register:" + ip
-	if err := rc.client.Del(ctx, key).Err(); err != nil {
-		log.Printf("Error resetting register rate limit for IP %s: %v", ip, err)
-		return err
-	}
-	return nil
+// ResetRateLimit forgets every request counted under key
+func (rc *RedisCache) ResetRateLimit(ctx context.Context, key string) error {
+	return rc.client.Del(ctx, "ratelimit:"+key).Err()
 }
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrRefreshFamilyNotFound = errors.New("refresh token family not found")
+	ErrRefreshTokenReused    = errors.New("refresh token reuse detected")
+)
+
+// rotateRefreshScript swaps the current refresh token hash of a family only if
+// the caller presented the current one. Presenting any other token of the
+// family means it was replayed, so the whole family is dropped.
+var rotateRefreshScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return -1
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+func refreshFamilyKey(familyID string) string {
+	return "token:refresh:family:" + familyID
+}
+
+// SaveRefreshFamily starts a new token family with its first refresh token
+func (rc *RedisCache) SaveRefreshFamily(ctx context.Context, familyID string, tokenHash string, ttl time.Duration) error {
+	return rc.SetString(ctx, refreshFamilyKey(familyID), tokenHash, ttl)
+}
+
+// RotateRefreshToken atomically replaces oldHash with newHash as the current
+// refresh token of the family. It returns ErrRefreshTokenReused (and revokes
+// the family) when oldHash is not the current token.
+func (rc *RedisCache) RotateRefreshToken(ctx context.Context, familyID string, oldHash string, newHash string, ttl time.Duration) error {
+	res, err := rotateRefreshScript.Run(ctx, rc.client, []string{refreshFamilyKey(familyID)}, oldHash, newHash, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case 0:
+		return ErrRefreshFamilyNotFound
+	case -1:
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// RevokeRefreshFamily invalidates every refresh token issued in the family
+func (rc *RedisCache) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	return rc.Delete(ctx, refreshFamilyKey(familyID))
+}
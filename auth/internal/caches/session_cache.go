@@ -3,25 +3,152 @@ package cache
 import (
 	"context"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
+// UserSession is one login of a user on a device. Its id is the refresh
+// token family of the login, which access and refresh tokens carry.
 type UserSession struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	ID         string `redis:"id" json:"session_id"`
+	UserID     string `redis:"user_id" json:"user_id"`
+	Device     string `redis:"device" json:"device"`
+	UserAgent  string `redis:"user_agent" json:"user_agent"`
+	IP         string `redis:"ip" json:"ip"`
+	CreatedAt  int64  `redis:"created_at" json:"created_at"`
+	LastSeenAt int64  `redis:"last_seen_at" json:"last_seen_at"`
+}
+
+// touchSessionScript updates when and from where a session was last seen,
+// at most once per ARGV[3] seconds. A session that was removed is not
+// brought back.
+var touchSessionScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local last = tonumber(redis.call('HGET', KEYS[1], 'last_seen_at') or '0')
+if tonumber(ARGV[1]) - last < tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'last_seen_at', ARGV[1])
+if ARGV[2] ~= '' then
+	redis.call('HSET', KEYS[1], 'ip', ARGV[2])
+end
+if tonumber(ARGV[4]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+end
+return 1
+`)
+
+func sessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+func userSessionsKey(userID string) string {
+	return "user:sessions:" + userID
+}
+
+func sessionRevokedKey(sessionID string) string {
+	return "session:revoked:" + sessionID
 }
 
-func (rc *RedisCache) SaveUserSession(ctx context.Context, userID string, userSession UserSession) error {
-	key := "user:session:" + userID
-	return rc.Set(ctx, key, userSession, 30*time.Minute)
+// SaveUserSession records a new session. ttl should match the lifetime of
+// its refresh token.
+func (rc *RedisCache) SaveUserSession(ctx context.Context, session *UserSession, ttl time.Duration) error {
+	pipe := rc.client.TxPipeline()
+	pipe.HSet(ctx, sessionKey(session.ID), session)
+	pipe.Expire(ctx, sessionKey(session.ID), ttl)
+	pipe.SAdd(ctx, userSessionsKey(session.UserID), session.ID)
+	pipe.Expire(ctx, userSessionsKey(session.UserID), ttl)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-func (rc *RedisCache) GetSavedUserSession(ctx context.Context, userID string) (*UserSession, error) {
-	key := "user:session:" + userID
+// TouchUserSession marks the session as seen now from ip. Calls within
+// interval of the last update are ignored; ttl > 0 extends the session.
+func (rc *RedisCache) TouchUserSession(ctx context.Context, sessionID string, ip string, interval time.Duration, ttl time.Duration) error {
+	return touchSessionScript.Run(ctx, rc.client, []string{sessionKey(sessionID)},
+		time.Now().Unix(), ip, int64(interval.Seconds()), ttl.Milliseconds()).Err()
+}
 
+// GetSavedUserSession returns the session, nil if it does not exist
+func (rc *RedisCache) GetSavedUserSession(ctx context.Context, sessionID string) (*UserSession, error) {
+	res := rc.client.HGetAll(ctx, sessionKey(sessionID))
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+	if len(res.Val()) == 0 {
+		return nil, nil
+	}
 	var session UserSession
-	err := rc.Get(ctx, key, &session)
-	if err != nil {
+	if err := res.Scan(&session); err != nil {
 		return nil, err
 	}
 	return &session, nil
 }
+
+// ListUserSessions returns the live sessions of the user. Expired sessions
+// are dropped from the user's index on the way.
+func (rc *RedisCache) ListUserSessions(ctx context.Context, userID string) ([]*UserSession, error) {
+	ids, err := rc.client.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []*UserSession{}
+	for _, id := range ids {
+		session, err := rc.GetSavedUserSession(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			rc.client.SRem(ctx, userSessionsKey(userID), id)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// DeleteUserSession removes the record of the session. It does not revoke
+// its tokens, see RevokeSession.
+func (rc *RedisCache) DeleteUserSession(ctx context.Context, userID string, sessionID string) error {
+	pipe := rc.client.TxPipeline()
+	pipe.Del(ctx, sessionKey(sessionID))
+	pipe.SRem(ctx, userSessionsKey(userID), sessionID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteUserSessions removes the records of all sessions of the user except
+// keepSession
+func (rc *RedisCache) DeleteUserSessions(ctx context.Context, userID string, keepSession string) error {
+	ids, err := rc.client.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id == keepSession {
+			continue
+		}
+		if err := rc.DeleteUserSession(ctx, userID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeSession rejects every access token of the session until ttl
+// elapses, which callers set to the access token lifetime
+func (rc *RedisCache) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	return rc.SetString(ctx, sessionRevokedKey(sessionID), "1", ttl)
+}
+
+// IsSessionRevoked reports whether RevokeSession was called for the session
+func (rc *RedisCache) IsSessionRevoked(ctx context.Context, sessionID string) bool {
+	if sessionID == "" {
+		return false
+	}
+	n, err := rc.client.Exists(ctx, sessionRevokedKey(sessionID)).Result()
+	return err == nil && n > 0
+}
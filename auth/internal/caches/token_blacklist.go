@@ -3,12 +3,18 @@ package cache
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
-func (rc *RedisCache) BlacklistToken(ctx context.Context, tokenHash string) error {
+// BlacklistToken rejects the token until ttl elapses, which callers set to the
+// remaining lifetime of the token
+func (rc *RedisCache) BlacklistToken(ctx context.Context, tokenHash string, ttl time.Duration) error {
 	key := "token:blacklist:" + tokenHash
-	return rc.SetString(ctx, key, "1", time.Hour*24)
+	return rc.SetString(ctx, key, "1", ttl)
 }
 
 func (rc *RedisCache) IsTokenBlacklisted(ctx context.Context, tokenHash string) error {
@@ -19,3 +25,88 @@ func (rc *RedisCache) IsTokenBlacklisted(ctx context.Context, tokenHash string)
 	}
 	return nil
 }
+
+// revokeUserTokensScript merges a revocation into the cutoff of a user,
+// stored as "cutoff:floor:keepFamily" in milliseconds. Every token issued
+// at or before floor is revoked, and every token issued at or before cutoff
+// except those of keepFamily. The cutoff only ever moves forward, and
+// exempting a family never brings back tokens an earlier revocation covered.
+// KEYS[1] is the cutoff key, ARGV[1] the new cutoff, ARGV[2] the family to
+// keep and ARGV[3] the ttl in milliseconds.
+var revokeUserTokensScript = redis.NewScript(`
+local cutoff, floor, keep = 0, 0, ''
+local current = redis.call('GET', KEYS[1])
+if current then
+	local c, f, k = string.match(current, '^(%d+):(%d+):(.*)$')
+	if c then
+		cutoff, floor, keep = tonumber(c), tonumber(f), k
+	end
+end
+local before, newKeep = tonumber(ARGV[1]), ARGV[2]
+if before >= cutoff then
+	if newKeep == '' then
+		floor = before
+	elseif newKeep ~= keep then
+		floor = cutoff
+	end
+	cutoff, keep = before, newKeep
+elseif newKeep ~= keep and before > floor then
+	floor = before
+end
+redis.call('SET', KEYS[1], cutoff .. ':' .. floor .. ':' .. keep, 'PX', ARGV[3])
+return 1
+`)
+
+func revokedBeforeKey(userID string) string {
+	return "token:revoked_before:" + userID
+}
+
+// RevokeUserTokens invalidates every token of the user issued at or before
+// the given time, compared in milliseconds. ttl should cover the longest
+// token lifetime.
+func (rc *RedisCache) RevokeUserTokens(ctx context.Context, userID string, before time.Time, ttl time.Duration) error {
+	return rc.RevokeOtherUserTokens(ctx, userID, before, "", ttl)
+}
+
+// RevokeOtherUserTokens is RevokeUserTokens except for the tokens of
+// keepFamily, so the session that asked for it stays signed in. Tokens of
+// keepFamily an earlier revocation covered stay revoked. The records of the
+// revoked sessions are removed too.
+func (rc *RedisCache) RevokeOtherUserTokens(ctx context.Context, userID string, before time.Time, keepFamily string, ttl time.Duration) error {
+	err := revokeUserTokensScript.Run(ctx, rc.client, []string{revokedBeforeKey(userID)},
+		before.UnixMilli(), keepFamily, ttl.Milliseconds()).Err()
+	if err != nil {
+		return err
+	}
+	return rc.DeleteUserSessions(ctx, userID, keepFamily)
+}
+
+// IsUserTokenRevoked reports whether a token of the user issued at issuedAt
+// in the given refresh token family was revoked by RevokeUserTokens or
+// RevokeOtherUserTokens
+func (rc *RedisCache) IsUserTokenRevoked(ctx context.Context, userID string, issuedAt time.Time, family string) bool {
+	val, err := rc.GetString(ctx, revokedBeforeKey(userID))
+	if err != nil || val == "" {
+		return false
+	}
+	parts := strings.SplitN(val, ":", 3)
+	if len(parts) != 3 {
+		return false
+	}
+	cutoff, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	floor, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return false
+	}
+	iat := issuedAt.UnixMilli()
+	if iat <= floor {
+		return true
+	}
+	if parts[2] != "" && parts[2] == family {
+		return false
+	}
+	return iat <= cutoff
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestCache(t *testing.T) *RedisCache {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisCache(client)
+}
+
+func TestRevokeUserTokensAtMillisecondPrecision(t *testing.T) {
+	rc := newTestCache(t)
+	ctx := context.Background()
+	logout := time.UnixMilli(1_700_000_000_500)
+
+	if err := rc.RevokeUserTokens(ctx, "u1", logout, time.Hour); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+	if !rc.IsUserTokenRevoked(ctx, "u1", logout, "f1") {
+		t.Error("token issued at the logout is not revoked")
+	}
+	if rc.IsUserTokenRevoked(ctx, "u1", logout.Add(time.Millisecond), "f2") {
+		t.Error("login in the same second right after the logout is revoked")
+	}
+	if rc.IsUserTokenRevoked(ctx, "u2", logout.Add(-time.Hour), "f3") {
+		t.Error("tokens of another user are revoked")
+	}
+}
+
+func TestRevokeOtherUserTokensKeepsEarlierRevocations(t *testing.T) {
+	rc := newTestCache(t)
+	ctx := context.Background()
+	t0 := time.UnixMilli(1_700_000_000_000)
+	logoutAll, logoutOthers := t0.Add(time.Minute), t0.Add(2*time.Minute)
+
+	if err := rc.RevokeUserTokens(ctx, "u1", logoutAll, time.Hour); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+	if err := rc.RevokeOtherUserTokens(ctx, "u1", logoutOthers, "kept", time.Hour); err != nil {
+		t.Fatalf("RevokeOtherUserTokens: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		issuedAt time.Time
+		family   string
+		revoked  bool
+	}{
+		{"kept family before logout of all sessions", t0, "kept", true},
+		{"kept family between the logouts", logoutAll.Add(time.Second), "kept", false},
+		{"other family between the logouts", logoutAll.Add(time.Second), "other", true},
+		{"other family after the logouts", logoutOthers.Add(time.Millisecond), "other", false},
+	} {
+		if got := rc.IsUserTokenRevoked(ctx, "u1", tc.issuedAt, tc.family); got != tc.revoked {
+			t.Errorf("%s: revoked = %v, want %v", tc.name, got, tc.revoked)
+		}
+	}
+}
+
+func TestRevocationCutoffOnlyMovesForward(t *testing.T) {
+	rc := newTestCache(t)
+	ctx := context.Background()
+	t0 := time.UnixMilli(1_700_000_000_000)
+
+	if err := rc.RevokeUserTokens(ctx, "u1", t0.Add(time.Minute), time.Hour); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+	// A revocation that arrives late must not shrink the first one, but
+	// still revokes the kept family up to its own cutoff
+	if err := rc.RevokeOtherUserTokens(ctx, "u1", t0, "kept", time.Hour); err != nil {
+		t.Fatalf("RevokeOtherUserTokens: %v", err)
+	}
+	if !rc.IsUserTokenRevoked(ctx, "u1", t0.Add(30*time.Second), "other") {
+		t.Error("late revocation moved the cutoff back")
+	}
+	if !rc.IsUserTokenRevoked(ctx, "u1", t0.Add(30*time.Second), "kept") {
+		t.Error("late revocation exempted a family the earlier one revoked")
+	}
+
+	if err := rc.RevokeOtherUserTokens(ctx, "u1", t0.Add(2*time.Minute), "kept", time.Hour); err != nil {
+		t.Fatalf("RevokeOtherUserTokens: %v", err)
+	}
+	if err := rc.RevokeUserTokens(ctx, "u1", t0.Add(90*time.Second), time.Hour); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+	if !rc.IsUserTokenRevoked(ctx, "u1", t0.Add(80*time.Second), "kept") {
+		t.Error("late logout of all sessions did not revoke the kept family")
+	}
+	if rc.IsUserTokenRevoked(ctx, "u1", t0.Add(100*time.Second), "kept") {
+		t.Error("kept family revoked past the late logout of all sessions")
+	}
+}
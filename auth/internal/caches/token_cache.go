@@ -5,12 +5,40 @@ import (
 	"time"
 )
 
-func (rc *RedisCache) CacheValidToken(ctx context.Context, tokenHash string, userID string) error {
+// CachedToken is what is kept about an access token once its signature has
+// been verified, so later requests can skip JWT parsing. IssuedAt is in
+// milliseconds, ExpiresAt in seconds.
+type CachedToken struct {
+	UserID    string   `json:"user_id"`
+	Email     string   `json:"email"`
+	Family    string   `json:"family"`
+	Roles     []string `json:"roles"`
+	IssuedAt  int64    `json:"iat_ms"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+func (rc *RedisCache) CacheValidToken(ctx context.Context, tokenHash string, token *CachedToken) error {
+	key := "token:valid:" + tokenHash
+	ttl := time.Hour
+	if remaining := time.Until(time.Unix(token.ExpiresAt, 0)); remaining < ttl {
+		ttl = remaining
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	return rc.Set(ctx, key, token, ttl)
+}
+
+func (rc *RedisCache) IsTokenValid(ctx context.Context, tokenHash string) (*CachedToken, error) {
 	key := "token:valid:" + tokenHash
-	return rc.SetString(ctx, key, userID, time.Hour)
+	var token CachedToken
+	if err := rc.Get(ctx, key, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
 }
 
-func (rc *RedisCache) IsTokenValid(ctx context.Context, tokenHash string) (string, error) {
+func (rc *RedisCache) RemoveValidToken(ctx context.Context, tokenHash string) error {
 	key := "token:valid:" + tokenHash
-	return rc.GetString(ctx, key)
+	return rc.Delete(ctx, key)
 }
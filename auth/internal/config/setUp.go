@@ -5,20 +5,56 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+// OAuthProvider configures a social login provider. Kind "oidc" discovers
+// its endpoints from IssuerURL, kind "github" uses GitHub's OAuth2 API.
+type OAuthProvider struct {
+	Name         string
+	Kind         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+}
+
 type Env struct {
-	PORT           string
-	MONGO_URL      string
-	APP_SECRET     string
-	REDIS_HOST     string
-	REDIS_PORT     string
-	REDIS_PASSWORD string
-	REDIS_DB              int
-	RATE_LIMIT_MAX_ATTEMPTS int
+	PORT                      string
+	MONGO_URL                 string
+	APP_SECRET                string
+	REDIS_HOST                string
+	REDIS_PORT                string
+	REDIS_PASSWORD            string
+	REDIS_DB                  int
+	RATE_LIMIT_MAX_ATTEMPTS   int
 	RATE_LIMIT_WINDOW_MINUTES int
+	ACCESS_TOKEN_TTL_MINUTES  int
+	REFRESH_TOKEN_TTL_HOURS   int
+	JWT_KEYS_DIR              string
+	JWT_SIGNING_KID           string
+	ADMIN_EMAILS              []string
+
+	APP_BASE_URL               string
+	REQUIRE_EMAIL_VERIFICATION bool
+	MAILER                     string
+	MAIL_FROM                  string
+	MAIL_DIR                   string
+	SMTP_HOST                  string
+	SMTP_PORT                  string
+	SMTP_USERNAME              string
+	SMTP_PASSWORD              string
+
+	RATE_LIMIT_IP_MAX_REQUESTS int
+	LOCKOUT_BASE_MINUTES       int
+	LOCKOUT_MAX_MINUTES        int
+	TRUSTED_PROXIES            []*net.IPNet
+
+	ACCOUNT_DELETION_GRACE_DAYS int
+
+	OAUTH_REDIRECT_BASE_URL string
+	OAUTH_PROVIDERS         []OAuthProvider
 }
 
 func GetEnv() *Env {
@@ -86,6 +122,129 @@ func GetEnv() *Env {
 		log.Fatalf("RATE_LIMIT_WINDOW_MINUTES must be a valid integer: %v", err)
 	}
 
+	// Token lifetimes with defaults
+	access_ttl_str := os.Getenv("ACCESS_TOKEN_TTL_MINUTES")
+	if access_ttl_str == "" {
+		access_ttl_str = "15"
+	}
+	access_ttl, err := strconv.Atoi(access_ttl_str)
+	if err != nil {
+		log.Fatalf("ACCESS_TOKEN_TTL_MINUTES must be a valid integer: %v", err)
+	}
+
+	refresh_ttl_str := os.Getenv("REFRESH_TOKEN_TTL_HOURS")
+	if refresh_ttl_str == "" {
+		refresh_ttl_str = "168"
+	}
+	refresh_ttl, err := strconv.Atoi(refresh_ttl_str)
+	if err != nil {
+		log.Fatalf("REFRESH_TOKEN_TTL_HOURS must be a valid integer: %v", err)
+	}
+
+	// Users with these emails are granted the admin role once verified
+	var admin_emails []string
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			admin_emails = append(admin_emails, strings.ToLower(email))
+		}
+	}
+
+	// Account emails
+	base_url := os.Getenv("APP_BASE_URL")
+	if base_url == "" {
+		base_url = "http://localhost:" + port
+	}
+	require_verification := false
+	if v := os.Getenv("REQUIRE_EMAIL_VERIFICATION"); v != "" {
+		require_verification, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("REQUIRE_EMAIL_VERIFICATION must be a boolean: %v", err)
+		}
+	}
+	mailer := os.Getenv("MAILER")
+	if mailer == "" {
+		mailer = "file"
+	}
+	mail_from := os.Getenv("MAIL_FROM")
+	if mail_from == "" {
+		mail_from = "no-reply@localhost"
+	}
+	mail_dir := os.Getenv("MAIL_DIR")
+	if mail_dir == "" {
+		mail_dir = "mail"
+	}
+	smtp_port := os.Getenv("SMTP_PORT")
+	if smtp_port == "" {
+		smtp_port = "587"
+	}
+
+	// Per IP limit on the public auth routes, and the progressive lockout of
+	// accounts after RATE_LIMIT_MAX_ATTEMPTS failed logins
+	ip_max_requests := getIntEnv("RATE_LIMIT_IP_MAX_REQUESTS", 20)
+	lockout_base := getIntEnv("LOCKOUT_BASE_MINUTES", 2)
+	lockout_max := getIntEnv("LOCKOUT_MAX_MINUTES", 60)
+
+	// Proxies allowed to set X-Forwarded-For, as IPs or CIDR ranges
+	var trusted_proxies []*net.IPNet
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.Fatalf("TRUSTED_PROXIES contains an invalid address %q: %v", proxy, err)
+		}
+		trusted_proxies = append(trusted_proxies, network)
+	}
+
+	// Social login providers, enabled by setting their client id
+	var oauth_providers []OAuthProvider
+	if id := os.Getenv("GOOGLE_CLIENT_ID"); id != "" {
+		oauth_providers = append(oauth_providers, OAuthProvider{
+			Name:         "google",
+			Kind:         "oidc",
+			IssuerURL:    "https://accounts.google.com",
+			ClientID:     id,
+			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+		})
+	}
+	if id := os.Getenv("GITHUB_CLIENT_ID"); id != "" {
+		oauth_providers = append(oauth_providers, OAuthProvider{
+			Name:         "github",
+			Kind:         "github",
+			ClientID:     id,
+			ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+		})
+	}
+	if id := os.Getenv("OIDC_CLIENT_ID"); id != "" {
+		issuer := os.Getenv("OIDC_ISSUER_URL")
+		if issuer == "" {
+			log.Fatalf("OIDC_ISSUER_URL is not set")
+		}
+		name := os.Getenv("OIDC_PROVIDER_NAME")
+		if name == "" {
+			name = "oidc"
+		}
+		oauth_providers = append(oauth_providers, OAuthProvider{
+			Name:         name,
+			Kind:         "oidc",
+			IssuerURL:    issuer,
+			ClientID:     id,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		})
+	}
+	oauth_redirect_base := os.Getenv("OAUTH_REDIRECT_BASE_URL")
+	if oauth_redirect_base == "" {
+		oauth_redirect_base = base_url
+	}
+
 	return &Env{
 		PORT:                      port,
 		MONGO_URL:                 mongoURL,
@@ -96,5 +255,43 @@ func GetEnv() *Env {
 		REDIS_DB:                  redis_db,
 		RATE_LIMIT_MAX_ATTEMPTS:   rate_limit_attempts,
 		RATE_LIMIT_WINDOW_MINUTES: rate_limit_window,
+		ACCESS_TOKEN_TTL_MINUTES:  access_ttl,
+		REFRESH_TOKEN_TTL_HOURS:   refresh_ttl,
+		JWT_KEYS_DIR:              os.Getenv("JWT_KEYS_DIR"),
+		JWT_SIGNING_KID:           os.Getenv("JWT_SIGNING_KID"),
+		ADMIN_EMAILS:              admin_emails,
+
+		APP_BASE_URL:               base_url,
+		REQUIRE_EMAIL_VERIFICATION: require_verification,
+		MAILER:                     mailer,
+		MAIL_FROM:                  mail_from,
+		MAIL_DIR:                   mail_dir,
+		SMTP_HOST:                  os.Getenv("SMTP_HOST"),
+		SMTP_PORT:                  smtp_port,
+		SMTP_USERNAME:              os.Getenv("SMTP_USERNAME"),
+		SMTP_PASSWORD:              os.Getenv("SMTP_PASSWORD"),
+
+		RATE_LIMIT_IP_MAX_REQUESTS: ip_max_requests,
+		LOCKOUT_BASE_MINUTES:       lockout_base,
+		LOCKOUT_MAX_MINUTES:        lockout_max,
+		TRUSTED_PROXIES:            trusted_proxies,
+
+		ACCOUNT_DELETION_GRACE_DAYS: getIntEnv("ACCOUNT_DELETION_GRACE_DAYS", 30),
+
+		OAUTH_REDIRECT_BASE_URL: oauth_redirect_base,
+		OAUTH_PROVIDERS:         oauth_providers,
+	}
+}
+
+// getIntEnv reads an integer variable, falling back to def when it is unset
+func getIntEnv(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be a valid integer: %v", name, err)
 	}
+	return n
 }
@@ -0,0 +1,57 @@
+package helper
+
+import "strings"
+
+// browsers and platforms are matched in order, so more specific tokens come
+// first (Edge and Opera also claim to be Chrome, Chrome claims to be Safari)
+var (
+	browsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"okhttp/", "OkHttp"},
+		{"Go-http-client/", "Go client"},
+		{"python-requests/", "Python requests"},
+		{"PostmanRuntime/", "Postman"},
+	}
+	platforms = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DeviceName describes the device of a User-Agent for listing sessions,
+// e.g. "Chrome on Windows"
+func DeviceName(userAgent string) string {
+	browser, platform := "", ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, p := range platforms {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
@@ -0,0 +1,185 @@
+package helper
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sachinggsingh/e-comm/internal/config"
+	"github.com/sachinggsingh/e-comm/pb/jwks"
+)
+
+// signingKey is one private key of the key ring together with the JWT
+// algorithm it signs with
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// keyRing holds every key that tokens may be signed with. Only the signing
+// key issues new tokens; the others are kept so tokens signed before a
+// rotation stay valid until they expire.
+type keyRing struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+}
+
+var (
+	ringOnce sync.Once
+	ring     *keyRing
+	ringErr  error
+)
+
+func loadedKeyRing() (*keyRing, error) {
+	ringOnce.Do(func() {
+		env := config.GetEnv()
+		ring, ringErr = loadKeyRing(env.JWT_KEYS_DIR, env.JWT_SIGNING_KID)
+	})
+	return ring, ringErr
+}
+
+// loadKeyRing reads every <kid>.pem private key (PKCS#8, or PKCS#1 for RSA)
+// from dir. The key named signingKid signs new tokens, defaulting to the last
+// kid in lexical order so date-prefixed kids rotate naturally. Without a
+// directory an ephemeral Ed25519 key is generated, which is only suitable
+// for development since tokens do not survive a restart.
+func loadKeyRing(dir string, signingKid string) (*keyRing, error) {
+	kr := &keyRing{keys: map[string]*signingKey{}}
+
+	if dir == "" {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("JWT_KEYS_DIR is not set, signing tokens with an ephemeral key")
+		key := &signingKey{kid: "ephemeral", method: jwt.SigningMethodEdDSA, private: private}
+		kr.keys[key.kid] = key
+		kr.signing = key
+		return kr, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := readSigningKey(kid, file)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys[kid] = key
+		if signingKid == "" {
+			kr.signing = key
+		}
+	}
+	if len(kr.keys) == 0 {
+		return nil, fmt.Errorf("no signing keys found in %s", dir)
+	}
+	if signingKid != "" {
+		kr.signing = kr.keys[signingKid]
+		if kr.signing == nil {
+			return nil, fmt.Errorf("signing key %s not found in %s", signingKid, dir)
+		}
+	}
+	log.Printf("Loaded %d JWT keys, signing with %s", len(kr.keys), kr.signing.kid)
+	return kr, nil
+}
+
+func readSigningKey(kid string, file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", file)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, private: private}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, private: private}, nil
+	}
+	return nil, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", file)
+}
+
+// signToken signs the claims with the current signing key and records its
+// kid in the token header
+func signToken(claims jwt.Claims) (string, error) {
+	kr, err := loadedKeyRing()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(kr.signing.method, claims)
+	token.Header["kid"] = kr.signing.kid
+	return token.SignedString(kr.signing.private)
+}
+
+// verificationKey is the jwt.Keyfunc resolving a token's kid to the public
+// key it must have been signed with
+func verificationKey(token *jwt.Token) (any, error) {
+	kr, err := loadedKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.private.Public(), nil
+}
+
+// PublicKeySet returns the public half of every key in the ring for the JWKS
+// endpoint
+func PublicKeySet() (*jwks.KeySet, error) {
+	kr, err := loadedKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	kids := make([]string, 0, len(kr.keys))
+	for kid := range kr.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := &jwks.KeySet{Keys: make([]jwks.JSONWebKey, 0, len(kids))}
+	for _, kid := range kids {
+		jwk, err := jwks.NewJSONWebKey(kid, kr.keys[kid].private.Public())
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
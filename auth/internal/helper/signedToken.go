@@ -0,0 +1,55 @@
+package helper
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/sachinggsingh/e-comm/internal/config"
+)
+
+const (
+	PurposeVerifyEmail   = "verify"
+	PurposePasswordReset = "reset"
+	PurposeMFAChallenge  = "mfa"
+)
+
+// NewSignedToken returns an opaque token for single-use account links and the
+// id under which it has to be stored. The token is the id followed by an HMAC
+// over purpose and id, so a token minted for one purpose is useless for
+// another and forged tokens are rejected before any lookup.
+func NewSignedToken(purpose string) (token string, id string, err error) {
+	id, err = NewTokenID()
+	if err != nil {
+		return "", "", err
+	}
+	return id + "." + signature(purpose, id), id, nil
+}
+
+// VerifySignedToken checks the signature of a token minted by NewSignedToken
+// and returns its id
+func VerifySignedToken(purpose string, token string) (string, error) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok || id == "" {
+		return "", ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(purpose, id))) {
+		return "", ErrInvalidToken
+	}
+	return id, nil
+}
+
+func signature(purpose string, id string) string {
+	mac := hmac.New(sha256.New, []byte(config.GetEnv().APP_SECRET))
+	mac.Write([]byte(purpose + ":" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// HashTokenID is how signed token ids are keyed in Redis so a leaked cache does not
+// leak usable tokens
+func HashTokenID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
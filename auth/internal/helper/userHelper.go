@@ -1,6 +1,8 @@
 package helper
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -10,45 +12,110 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
 	"github.com/sachinggsingh/e-comm/internal/config"
+	"github.com/sachinggsingh/e-comm/pb/authz"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
 type JWTAccessClaims struct {
-	Email string `json:"email"`
-	Uid   string `json:"uid"`
+	Email  string   `json:"email"`
+	Uid    string   `json:"uid"`
+	Type   string   `json:"typ"`
+	Family string   `json:"fam"`
+	Roles  []string `json:"roles"`
 	jwt.RegisteredClaims
 }
 
+// JWTRefreshClaims carries the token family so a rotated refresh token can be
+// traced back to the login that started it. The jti (RegisteredClaims.ID)
+// makes every refresh token in a family unique.
 type JWTRefreshClaims struct {
-	Uid string `json:"uid"`
+	Uid    string `json:"uid"`
+	Type   string `json:"typ"`
+	Family string `json:"fam"`
 	jwt.RegisteredClaims
 }
 
+// Logouts revoke the tokens issued before them at millisecond precision, so
+// iat has to carry milliseconds for a login right after a logout to survive it
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
 var (
 	ErrInvalidToken = errors.New("token is invalid")
 	ErrInGenerating = errors.New("error in generating token")
+	ErrTokenRevoked = errors.New("token is expired or blacklisted")
 )
 
-func GenerateToken(id string, email string) (string, string, error) {
+// RefreshTokenTTL returns the configured lifetime of refresh tokens
+func RefreshTokenTTL() time.Duration {
+	return time.Duration(config.GetEnv().REFRESH_TOKEN_TTL_HOURS) * time.Hour
+}
+
+// NewTokenID returns a random identifier used for jti and token families
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewAPIKey returns a random API key and the prefix shown when listing it
+func NewAPIKey() (key string, prefix string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = authz.APIKeyPrefix + hex.EncodeToString(b)
+	return key, key[:len(authz.APIKeyPrefix)+8], nil
+}
+
+// GenerateToken mints an access/refresh token pair. The refresh token is
+// issued as part of familyID, which stays the same across rotations.
+func GenerateToken(id string, email string, roles []string, familyID string) (string, string, error) {
+	env := config.GetEnv()
+	now := time.Now()
+
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", "", ErrInGenerating
+	}
+
 	tokenclaims := &JWTAccessClaims{
-		Email: email,
-		Uid:   id,
+		Email:  email,
+		Uid:    id,
+		Type:   AccessTokenType,
+		Family: familyID,
+		Roles:  roles,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Local().Add(24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(env.ACCESS_TOKEN_TTL_MINUTES) * time.Minute)),
 		},
 	}
 	refreshTokenClaims := &JWTRefreshClaims{
-		Uid: id,
+		Uid:    id,
+		Type:   RefreshTokenType,
+		Family: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Local().Add(24 * time.Hour)),
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(env.REFRESH_TOKEN_TTL_HOURS) * time.Hour)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenclaims)
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshTokenClaims)
-	tokenString, err := token.SignedString([]byte(config.GetEnv().APP_SECRET))
-	refreshTokenString, err := refreshToken.SignedString([]byte(config.GetEnv().APP_SECRET))
+	tokenString, err := signToken(tokenclaims)
+	if err != nil {
+		return "", "", ErrInGenerating
+	}
+	refreshTokenString, err := signToken(refreshTokenClaims)
 	if err != nil {
 		return "", "", ErrInGenerating
 	}
@@ -58,18 +125,77 @@ func GenerateToken(id string, email string) (string, string, error) {
 func ValidateToken(tokenString string) (*JWTAccessClaims, error) {
 	claims := &JWTAccessClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
-		return []byte(config.GetEnv().APP_SECRET), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
 	if err != nil || !token.Valid {
 		return nil, ErrInvalidToken
 	}
-	if claims.Uid == "" {
+	if claims.Uid == "" || claims.Type == RefreshTokenType {
 		return nil, errors.New("uid missing: likely refresh token used instead of access token")
 
 	}
 	return claims, nil
 }
+
+// ValidateRefreshToken checks signature and expiry of a refresh token. Whether
+// the token is still the current one of its family is decided by the caller.
+func ValidateRefreshToken(tokenString string) (*JWTRefreshClaims, error) {
+	claims := &JWTRefreshClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if claims.Type != RefreshTokenType || claims.Uid == "" || claims.Family == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// sessionSeenInterval is how often using a token updates the last seen time
+// of its session
+const sessionSeenInterval = time.Minute
+
+// AuthenticateToken validates an access token and makes sure it was not
+// revoked, either individually through the blacklist, by ending its session
+// or by a logout of all sessions of its user. Verified tokens are cached in
+// Redis.
+func AuthenticateToken(ctx context.Context, redisCache *cache.RedisCache, token string) (*cache.CachedToken, error) {
+	tokenHash, _ := HashToken(token)
+	if err := redisCache.IsTokenBlacklisted(ctx, tokenHash); err != nil {
+		return nil, ErrTokenRevoked
+	}
+
+	cached, err := redisCache.IsTokenValid(ctx, tokenHash)
+	if err != nil || cached == nil || cached.UserID == "" || cached.IssuedAt == 0 {
+		claims, err := ValidateToken(token)
+		if err != nil {
+			return nil, err
+		}
+		cached = &cache.CachedToken{
+			UserID: claims.Uid,
+			Email:  claims.Email,
+			Family: claims.Family,
+			Roles:  claims.Roles,
+		}
+		if claims.IssuedAt != nil {
+			cached.IssuedAt = claims.IssuedAt.UnixMilli()
+		}
+		if claims.ExpiresAt != nil {
+			cached.ExpiresAt = claims.ExpiresAt.Unix()
+		}
+		redisCache.CacheValidToken(ctx, tokenHash, cached)
+	}
+
+	if redisCache.IsUserTokenRevoked(ctx, cached.UserID, time.UnixMilli(cached.IssuedAt), cached.Family) ||
+		redisCache.IsSessionRevoked(ctx, cached.Family) {
+		return nil, ErrTokenRevoked
+	}
+	if cached.Family != "" {
+		redisCache.TouchUserSession(ctx, cached.Family, "", sessionSeenInterval, 0)
+	}
+	return cached, nil
+}
+
 func Authorize(r *http.Request) (*JWTAccessClaims, error) {
 	authHeader := r.Header.Get("Authorization")
 
@@ -97,6 +223,13 @@ func HashPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
+// HashRecoveryCode hashes a two-factor recovery code. The codes are random so
+// the default cost is enough, and keeps checking a user's codes fast.
+func HashRecoveryCode(code string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	return string(bytes), err
+}
+
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
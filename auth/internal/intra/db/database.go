@@ -8,14 +8,16 @@ import (
 	"log"
 
 	"github.com/sachinggsingh/e-comm/internal/config"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Database struct {
-	Client         *mongo.Client
-	Database       *mongo.Database
-	UserCollection *mongo.Collection
+	Client           *mongo.Client
+	Database         *mongo.Database
+	UserCollection   *mongo.Collection
+	APIKeyCollection *mongo.Collection
 }
 
 func NewDB() *Database {
@@ -44,12 +46,31 @@ func (d *Database) Connect() error {
 	d.Database = client.Database("micro-ecomm")
 
 	d.UserCollection = d.Database.Collection("user")
+	d.APIKeyCollection = d.Database.Collection("api_keys")
 	// d.ProductCollection = d.Database.Collection("product")
 
+	if err := d.createIndexes(ctx); err != nil {
+		return err
+	}
+
 	log.Println("Connected to MongoDB!")
 	return nil
 }
 
+// createIndexes creates the indexes the lookups rely on. Creating an
+// existing index is a no-op. API keys are looked up by hash on every
+// request that uses one and by key_id when used or revoked.
+func (d *Database) createIndexes(ctx context.Context) error {
+	_, err := d.APIKeyCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "key_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create api key indexes: %w", err)
+	}
+	return nil
+}
+
 func (d *Database) Disconnect() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
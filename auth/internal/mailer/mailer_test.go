@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemoryMailerKeepsMessages(t *testing.T) {
+	m := NewMemoryMailer()
+	msg := Message{To: "user@example.com", Subject: "Hello", Body: "Hi"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	sent := m.Sent()
+	if len(sent) != 1 || sent[0] != msg {
+		t.Fatalf("Sent() = %v, want [%v]", sent, msg)
+	}
+	sent[0].To = "changed"
+	if m.Sent()[0].To != msg.To {
+		t.Error("Sent() does not return a copy")
+	}
+}
+
+func TestFileMailerWritesEML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("NewFileMailer: %v", err)
+	}
+	msg := Message{To: "a/b@example.com", Subject: "Reset", Body: "line one\nline two"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("mail directory holds %d files (%v), want 1", len(files), err)
+	}
+	if name := files[0].Name(); !strings.HasSuffix(name, "-a_b@example.com.eml") {
+		t.Errorf("file name %q does not carry the sanitized recipient", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "From: no-reply@example.com\r\nTo: a/b@example.com\r\nSubject: Reset\r\n" +
+		"MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nline one\r\nline two"
+	if string(data) != want {
+		t.Errorf("message =\n%q\nwant\n%q", data, want)
+	}
+}
@@ -2,58 +2,91 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	cache "github.com/sachinggsingh/e-comm/internal/caches"
 	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/service"
+	"github.com/sachinggsingh/e-comm/pb/authz"
 )
 
+// APIKeyAuthenticator resolves the caller of an API key
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*authz.Principal, error)
+}
+
 type AuthMiddleware struct {
 	Redis *cache.RedisCache
+	// APIKeys accepts "Authorization: ApiKey" credentials when set
+	APIKeys APIKeyAuthenticator
 }
 
-func (a *AuthMiddleware) Validate(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
+// Authenticate resolves the caller of the request from its bearer token or
+// API key. It implements authz.Authenticator so routes can be guarded with
+// authz.RequirePermission.
+func (a *AuthMiddleware) Authenticate(r *http.Request) (*authz.Principal, error) {
+	token, err := authz.Credential(r)
+	if err != nil {
+		return nil, err
+	}
 
-		if !strings.HasPrefix(token, "Bearer ") {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-		token = strings.TrimPrefix(token, "Bearer ")
-
-		tokenHash, _ := helper.HashToken(token)
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		if err := a.Redis.IsTokenBlacklisted(ctx, tokenHash); err != nil {
-			http.Error(w, "token is expired or blacklisted", http.StatusUnauthorized)
-			return
+	if strings.HasPrefix(token, authz.APIKeyPrefix) {
+		if a.APIKeys == nil {
+			return nil, authz.ErrUnauthenticated
 		}
+		return a.APIKeys.AuthenticateAPIKey(ctx, token)
+	}
 
-		cachedUserID, err := a.Redis.IsTokenValid(ctx, tokenHash)
-		if err == nil && cachedUserID != "" {
-			r.Header.Set("user_id", cachedUserID)
-			next.ServeHTTP(w, r)
-			return
-		}
+	cached, err := helper.AuthenticateToken(ctx, a.Redis, token)
+	if err != nil {
+		return nil, err
+	}
+	return &authz.Principal{
+		UserID:    cached.UserID,
+		Email:     cached.Email,
+		Roles:     cached.Roles,
+		Scopes:    authz.PermissionsFor(cached.Roles),
+		SessionID: cached.Family,
+	}, nil
+}
 
-		claims, err := helper.ValidateToken(token)
+func (a *AuthMiddleware) Validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
 		if err != nil {
+			if errors.Is(err, helper.ErrTokenRevoked) || errors.Is(err, service.ErrAPIKeyRevoked) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		a.Redis.CacheValidToken(ctx, tokenHash, claims.Uid)
+		r.Header.Set("user_id", principal.UserID)
+		next.ServeHTTP(w, r.WithContext(authz.WithPrincipal(r.Context(), principal)))
+	})
+}
 
-		r.Header.Set("user_id", claims.Uid)
+// ValidateSession is Validate for routes managing the account itself, which
+// an API key must not reach: a leaked key should not be able to change the
+// password, second factor or keys of its owner.
+func (a *AuthMiddleware) ValidateSession(next http.Handler) http.Handler {
+	return a.Validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principal := authz.PrincipalFromContext(r.Context()); principal != nil && principal.APIKeyID != "" {
+			authz.WriteForbidden(w, "this route requires a login session, api keys are not accepted", "")
+			return
+		}
 		next.ServeHTTP(w, r)
-	})
+	}))
+}
+
+// RequirePermission guards a route so only callers holding perm reach it
+func (a *AuthMiddleware) RequirePermission(perm string, next http.Handler) http.Handler {
+	return authz.RequirePermission(a, perm)(next)
 }
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
+)
+
+// RateLimiter limits requests per client IP and route with a sliding window
+// kept in Redis
+type RateLimiter struct {
+	Redis *cache.RedisCache
+	// TrustedProxies may set X-Forwarded-For and X-Real-IP. Those headers
+	// are ignored on requests coming from anywhere else.
+	TrustedProxies []*net.IPNet
+}
+
+func NewRateLimiter(redis *cache.RedisCache, trustedProxies []*net.IPNet) *RateLimiter {
+	return &RateLimiter{
+		Redis:          redis,
+		TrustedProxies: trustedProxies,
+	}
+}
+
+// ClientIP returns the address of the client as seen behind TrustedProxies
+func (rl *RateLimiter) ClientIP(r *http.Request) string {
+	return ClientIP(r, rl.TrustedProxies)
+}
+
+func trusted(ip net.IP, trustedProxies []*net.IPNet) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client. Forwarding headers are only
+// believed when the request comes from a trusted proxy, and X-Forwarded-For
+// is read from the right so a client cannot prepend a fake address.
+func ClientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	if remote == nil || !trusted(remote, trustedProxies) {
+		return host
+	}
+
+	if xForwardedFor := r.Header.Get("X-Forwarded-For"); xForwardedFor != "" {
+		hops := strings.Split(xForwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if !trusted(ip, trustedProxies) || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+	if xRealIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); xRealIP != nil {
+		return xRealIP.String()
+	}
+	return host
+}
+
+// Limit guards a route so each client IP can call it limit.Limit times per
+// window. Responses carry X-RateLimit-* headers, rejected ones Retry-After.
+func (rl *RateLimiter) Limit(route string, limit cache.RateLimit, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		ip := rl.ClientIP(r)
+		result, err := rl.Redis.AllowRequest(ctx, route+":ip:"+ip, limit)
+		if err != nil {
+			// Fail open, an unavailable Redis should not take the service down
+			log.Printf("Rate limiter unavailable for %s: %v", route, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		WriteRateLimitHeaders(w, result)
+		if !result.Allowed {
+			WriteRetryAfter(w, result.Reset)
+			http.Error(w, "Too many requests, try again later", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func WriteRateLimitHeaders(w http.ResponseWriter, result *cache.RateLimitResult) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+}
+
+// WriteRetryAfter sets Retry-After in whole seconds, rounded up
+func WriteRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(seconds(wait)))
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	APIKeyOwnerUser    = "user"
+	APIKeyOwnerService = "service"
+)
+
+// APIKey lets a machine client authenticate without the login flow. Only the
+// SHA-256 hash of the key is stored; the key itself is shown once on
+// creation.
+type APIKey struct {
+	ID     primitive.ObjectID `bson:"_id" json:"-"`
+	Key_id string             `json:"key_id"`
+	Name   string             `json:"name"`
+	// Prefix is the start of the key, enough to recognise it in a list
+	Prefix     string `json:"prefix"`
+	Hash       string `json:"-"`
+	Owner_type string `json:"owner_type"`
+	// User_id owns a user key; for service keys it is the admin who created it
+	User_id      string     `json:"user_id"`
+	Service      string     `json:"service,omitempty"`
+	Scopes       []string   `json:"scopes"`
+	Created_at   time.Time  `json:"created_at"`
+	Expires_at   time.Time  `json:"expires_at"`
+	Last_used_at *time.Time `json:"last_used_at,omitempty"`
+	Revoked_at   *time.Time `json:"revoked_at,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name            string   `json:"name"`
+	Scopes          []string `json:"scopes"`
+	Expires_in_days int      `json:"expires_in_days"`
+	// Service names the integration of a service key, unused for user keys
+	Service string `json:"service,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	Key string `json:"key"`
+	*APIKey
+}
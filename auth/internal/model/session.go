@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// ClientInfo describes where a login or token refresh came from
+type ClientInfo struct {
+	User_agent string
+	Ip         string
+}
+
+// Session is a login of the user on one device, as listed to the user
+type Session struct {
+	Session_id   string    `json:"session_id"`
+	Device       string    `json:"device"`
+	User_agent   string    `json:"user_agent"`
+	Ip           string    `json:"ip"`
+	Created_at   time.Time `json:"created_at"`
+	Last_seen_at time.Time `json:"last_seen_at"`
+	// Current marks the session making the request
+	Current bool `json:"current"`
+}
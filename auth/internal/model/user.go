@@ -7,14 +7,70 @@ import (
 )
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	Password      *string            `json:"password" validate:"required,min=6"`
-	Email         *string            `json:"email" validate:"email,required"`
-	Token         *string            `json:"token"`
-	Refresh_Token *string            `json:"refresh_token"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	User_id       string             `json:"user_id"`
+	ID             primitive.ObjectID `bson:"_id"`
+	Password       *string            `json:"password" validate:"required,min=6"`
+	Email          *string            `json:"email" validate:"email,required"`
+	Token          *string            `json:"token"`
+	Refresh_Token  *string            `json:"refresh_token"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	User_id        string             `json:"user_id"`
+	Roles          []string           `json:"roles"`
+	Email_verified bool               `json:"email_verified"`
+	Mfa_enabled    bool               `json:"mfa_enabled"`
+	Totp_secret    *string            `json:"-"`
+	Recovery_codes []string           `json:"-"`
+	First_name     *string            `json:"first_name"`
+	Last_name      *string            `json:"last_name"`
+	Phone          *string            `json:"phone"`
+	// Deleted_at is set while a deleted account waits out its grace period,
+	// Purge_at is when it is removed for good
+	Deleted_at *time.Time `json:"deleted_at,omitempty"`
+	Purge_at   *time.Time `json:"purge_at,omitempty"`
+	// Identities are the social logins linked to the account
+	Identities []ExternalIdentity `json:"identities,omitempty"`
+}
+
+// ExternalIdentity links an account to a user at a login provider
+type ExternalIdentity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Linked_at time.Time `json:"linked_at"`
+}
+
+// PublicProfile is the view of a user returned to the user themselves. It
+// leaves out the password hash, stored tokens and two-factor secrets.
+type PublicProfile struct {
+	User_id        string    `json:"user_id"`
+	Email          string    `json:"email"`
+	First_name     *string   `json:"first_name,omitempty"`
+	Last_name      *string   `json:"last_name,omitempty"`
+	Phone          *string   `json:"phone,omitempty"`
+	Roles          []string  `json:"roles"`
+	Email_verified bool      `json:"email_verified"`
+	Mfa_enabled    bool      `json:"mfa_enabled"`
+	Created_at     time.Time `json:"created_at"`
+	Updated_at     time.Time `json:"updated_at"`
+}
+
+// UpdateProfileRequest is a partial update, fields left out are unchanged
+type UpdateProfileRequest struct {
+	First_name *string `json:"first_name"`
+	Last_name  *string `json:"last_name"`
+	Phone      *string `json:"phone"`
+}
+
+type ChangePasswordRequest struct {
+	Current_password string `json:"current_password"`
+	New_password     string `json:"new_password"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type DeleteAccountResponse struct {
+	Purge_at time.Time `json:"purge_at"`
 }
 
 type LoginResponse struct {
@@ -24,3 +80,60 @@ type LoginResponse struct {
 	RefreshToken string `json:"refresh_token"`
 	User_id      string `json:"user_id"`
 }
+
+// UserSummary is the view of a user exposed to administrators
+type UserSummary struct {
+	User_id    string    `json:"user_id"`
+	Email      string    `json:"email"`
+	Roles      []string  `json:"roles"`
+	Created_at time.Time `json:"created_at"`
+}
+
+type SetRolesRequest struct {
+	User_id string   `json:"user_id"`
+	Roles   []string `json:"roles"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// MFAChallengeResponse is returned by login instead of tokens when the user
+// has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// MFACodeRequest carries a TOTP code or a recovery code
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
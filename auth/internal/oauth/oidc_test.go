@@ -0,0 +1,106 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sachinggsingh/e-comm/internal/config"
+	"github.com/sachinggsingh/e-comm/internal/oauth/oauthtest"
+	"golang.org/x/oauth2"
+)
+
+func newStubLogin(t *testing.T) (*oauthtest.Provider, *OIDCProvider) {
+	t.Helper()
+	idp, server, err := oauthtest.NewServer("e-comm", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	provider := NewOIDCProvider(config.OAuthProvider{
+		Name:         "stub",
+		Kind:         "oidc",
+		IssuerURL:    idp.Issuer,
+		ClientID:     "e-comm",
+		ClientSecret: "secret",
+	}, "http://auth.test/oauth/stub/callback", http.DefaultClient)
+	return idp, provider
+}
+
+// authorize starts a login and returns the code the provider hands back
+func authorize(t *testing.T, idp *oauthtest.Provider, provider *OIDCProvider, nonce string, verifier string) string {
+	t.Helper()
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, state, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+	return code
+}
+
+func TestOIDCLoginAgainstStubProvider(t *testing.T) {
+	idp, provider := newStubLogin(t)
+	idp.Email = "User@Example.com"
+	verifier := oauth2.GenerateVerifier()
+	code := authorize(t, idp, provider, "nonce-1", verifier)
+
+	identity, err := provider.Exchange(context.Background(), code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := Identity{
+		Provider:      "stub",
+		Subject:       "stub-User@Example.com",
+		Email:         "user@example.com",
+		EmailVerified: true,
+		Name:          "Stub User",
+	}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+
+	if _, err := provider.Exchange(context.Background(), code, verifier, "nonce-1"); err == nil {
+		t.Error("code was accepted twice")
+	}
+}
+
+func TestOIDCLoginReportsUnverifiedEmail(t *testing.T) {
+	idp, provider := newStubLogin(t)
+	idp.EmailVerified = false
+	verifier := oauth2.GenerateVerifier()
+
+	identity, err := provider.Exchange(context.Background(), authorize(t, idp, provider, "n", verifier), verifier, "n")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.EmailVerified {
+		t.Error("unverified email reported as verified")
+	}
+}
+
+func TestOIDCLoginRejectsWrongNonce(t *testing.T) {
+	idp, provider := newStubLogin(t)
+	verifier := oauth2.GenerateVerifier()
+	code := authorize(t, idp, provider, "nonce-1", verifier)
+
+	_, err := provider.Exchange(context.Background(), code, verifier, "nonce-2")
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("Exchange with another nonce: got %v, want %v", err, ErrInvalidIDToken)
+	}
+}
+
+func TestOIDCLoginRejectsWrongVerifier(t *testing.T) {
+	idp, provider := newStubLogin(t)
+	code := authorize(t, idp, provider, "n", oauth2.GenerateVerifier())
+
+	if _, err := provider.Exchange(context.Background(), code, oauth2.GenerateVerifier(), "n"); err == nil {
+		t.Error("code was redeemed with another PKCE verifier")
+	}
+}
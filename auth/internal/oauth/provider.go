@@ -0,0 +1,56 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/config"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown login provider")
+	ErrInvalidIDToken  = errors.New("invalid id token")
+)
+
+// Identity is the user as asserted by a login provider
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs the authorization code flow with PKCE against one identity
+// provider
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where the browser is sent to log in. nonce is bound
+	// into the id token by OIDC providers, verifier is the PKCE verifier.
+	AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error)
+	// Exchange redeems the code and returns the verified identity
+	Exchange(ctx context.Context, code string, verifier string, nonce string) (*Identity, error)
+}
+
+// NewProviders builds the providers configured in env. Callbacks go to
+// <OAUTH_REDIRECT_BASE_URL>/oauth/<name>/callback.
+func NewProviders(env *config.Env) (map[string]Provider, error) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	providers := make(map[string]Provider, len(env.OAUTH_PROVIDERS))
+	for _, cfg := range env.OAUTH_PROVIDERS {
+		redirectURL := strings.TrimSuffix(env.OAUTH_REDIRECT_BASE_URL, "/") + "/oauth/" + cfg.Name + "/callback"
+		switch cfg.Kind {
+		case "oidc":
+			providers[cfg.Name] = NewOIDCProvider(cfg, redirectURL, httpClient)
+		case "github":
+			providers[cfg.Name] = NewGitHubProvider(cfg, redirectURL, httpClient)
+		default:
+			return nil, fmt.Errorf("provider %s has unknown kind %q", cfg.Name, cfg.Kind)
+		}
+	}
+	return providers, nil
+}
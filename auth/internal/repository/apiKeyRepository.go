@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type APIKeyRepository interface {
+	Create(key *model.APIKey) error
+	FindByHash(hash string) (*model.APIKey, error)
+	// List returns the keys matching owner type and, when set, the user id
+	List(ownerType string, userID string) ([]*model.APIKey, error)
+	CountActive(userID string, now time.Time) (int64, error)
+	// Revoke marks the key revoked and returns it. filter narrows which keys
+	// may be revoked, e.g. to those of one user.
+	Revoke(keyID string, filter bson.M) (*model.APIKey, error)
+	TouchLastUsed(keyID string, usedAt time.Time) error
+}
+
+type apiKeyRepo struct {
+	keyColl *mongo.Collection
+}
+
+func NewAPIKeyRepository(database *db.Database) APIKeyRepository {
+	return &apiKeyRepo{
+		keyColl: database.APIKeyCollection,
+	}
+}
+
+func (a *apiKeyRepo) Create(key *model.APIKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	_, err := a.keyColl.InsertOne(ctx, key)
+	return err
+}
+
+// FindByHash returns the key with the hash, nil if there is none
+func (a *apiKeyRepo) FindByHash(hash string) (*model.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var key model.APIKey
+	err := a.keyColl.FindOne(ctx, bson.M{"hash": hash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (a *apiKeyRepo) List(ownerType string, userID string) ([]*model.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{"owner_type": ownerType}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := a.keyColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []*model.APIKey{}
+	for cursor.Next(ctx) {
+		var key model.APIKey
+		if err := cursor.Decode(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+	return keys, cursor.Err()
+}
+
+// CountActive counts the user's keys that are neither revoked nor expired
+func (a *apiKeyRepo) CountActive(userID string, now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return a.keyColl.CountDocuments(ctx, bson.M{
+		"owner_type": model.APIKeyOwnerUser,
+		"user_id":    userID,
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": now},
+	})
+}
+
+func (a *apiKeyRepo) Revoke(keyID string, filter bson.M) (*model.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	query := bson.M{"key_id": keyID, "revoked_at": nil}
+	for field, value := range filter {
+		query[field] = value
+	}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var key model.APIKey
+	if err := a.keyColl.FindOneAndUpdate(ctx, query, update, opts).Decode(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (a *apiKeyRepo) TouchLastUsed(keyID string, usedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	_, err := a.keyColl.UpdateOne(ctx, bson.M{"key_id": keyID}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	return err
+}
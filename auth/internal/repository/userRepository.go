@@ -13,10 +13,25 @@ import (
 type UserRepository interface {
 	Register(user *model.User) error
 	Login(user *model.User) (*model.User, error)
-	// Logout(user *model.User) error
+	Logout(user *model.User) error
 	Profile(user *model.User) (*model.User, error)
 	CheckIfEmailExist(email string) (bool, error)
 	FindUserByEmail(email string) (*model.User, error)
+	UpdateTokens(user *model.User) error
+	ListUsers() ([]*model.User, error)
+	SetRoles(userID string, roles []string) error
+	MarkEmailVerified(userID string) error
+	UpdatePassword(userID string, hashedPassword string) error
+	EnableMFA(userID string, secret string, recoveryCodeHashes []string) error
+	DisableMFA(userID string) error
+	SetRecoveryCodes(userID string, recoveryCodeHashes []string) error
+	ConsumeRecoveryCode(userID string, recoveryCodeHash string) error
+	UpdateProfile(userID string, update *model.UpdateProfileRequest) error
+	ScheduleDeletion(userID string, purgeAt time.Time) error
+	CancelDeletion(userID string) error
+	PurgeDeletedUsers(now time.Time) (int64, error)
+	FindUserByIdentity(provider string, subject string) (*model.User, error)
+	LinkIdentity(userID string, identity model.ExternalIdentity) error
 }
 
 type userRepo struct {
@@ -60,9 +75,25 @@ func (u *userRepo) Login(user *model.User) (*model.User, error) {
 	return user, nil
 }
 
-//	func (u *userRepo) Logout(user *model.User) error {
-//		return nil
-//	}
+// Logout clears the tokens stored on the user document
+func (u *userRepo) Logout(user *model.User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id": user.User_id,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"token":         nil,
+			"refresh_token": nil,
+			"updated_at":    time.Now(),
+		},
+	}
+	_, err := u.userColl.UpdateOne(ctx, filter, update)
+	return err
+}
+
 func (u *userRepo) Profile(user *model.User) (*model.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -110,3 +141,197 @@ func (u *userRepo) FindUserByEmail(email string) (*model.User, error) {
 	}
 	return &result, nil
 }
+
+func (u *userRepo) UpdateTokens(user *model.User) error {
+	return u.updateFields(user.User_id, bson.M{
+		"token":         user.Token,
+		"refresh_token": user.Refresh_Token,
+	})
+}
+
+func (u *userRepo) ListUsers() ([]*model.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := u.userColl.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*model.User
+	for cursor.Next(ctx) {
+		var user model.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (u *userRepo) SetRoles(userID string, roles []string) error {
+	return u.updateFields(userID, bson.M{"roles": roles})
+}
+
+func (u *userRepo) MarkEmailVerified(userID string) error {
+	return u.updateFields(userID, bson.M{"email_verified": true})
+}
+
+func (u *userRepo) UpdatePassword(userID string, hashedPassword string) error {
+	return u.updateFields(userID, bson.M{"password": hashedPassword})
+}
+
+func (u *userRepo) EnableMFA(userID string, secret string, recoveryCodeHashes []string) error {
+	return u.updateFields(userID, bson.M{
+		"mfa_enabled":    true,
+		"totp_secret":    secret,
+		"recovery_codes": recoveryCodeHashes,
+	})
+}
+
+func (u *userRepo) DisableMFA(userID string) error {
+	return u.updateFields(userID, bson.M{
+		"mfa_enabled":    false,
+		"totp_secret":    nil,
+		"recovery_codes": nil,
+	})
+}
+
+func (u *userRepo) SetRecoveryCodes(userID string, recoveryCodeHashes []string) error {
+	return u.updateFields(userID, bson.M{"recovery_codes": recoveryCodeHashes})
+}
+
+// ConsumeRecoveryCode removes a recovery code hash from the user. The match
+// on the hash makes the removal atomic, so a code can be used only once even
+// under concurrent logins.
+func (u *userRepo) ConsumeRecoveryCode(userID string, recoveryCodeHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":        userID,
+		"recovery_codes": recoveryCodeHash,
+	}
+	update := bson.M{
+		"$pull": bson.M{"recovery_codes": recoveryCodeHash},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	result, err := u.userColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// UpdateProfile sets the fields present in update
+func (u *userRepo) UpdateProfile(userID string, update *model.UpdateProfileRequest) error {
+	fields := bson.M{}
+	if update.First_name != nil {
+		fields["first_name"] = update.First_name
+	}
+	if update.Last_name != nil {
+		fields["last_name"] = update.Last_name
+	}
+	if update.Phone != nil {
+		fields["phone"] = update.Phone
+	}
+	return u.updateFields(userID, fields)
+}
+
+func (u *userRepo) ScheduleDeletion(userID string, purgeAt time.Time) error {
+	return u.updateFields(userID, bson.M{
+		"deleted_at":    time.Now(),
+		"purge_at":      purgeAt,
+		"token":         nil,
+		"refresh_token": nil,
+	})
+}
+
+func (u *userRepo) CancelDeletion(userID string) error {
+	return u.updateFields(userID, bson.M{
+		"deleted_at": nil,
+		"purge_at":   nil,
+	})
+}
+
+// PurgeDeletedUsers removes the accounts whose deletion grace period ended
+func (u *userRepo) PurgeDeletedUsers(now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"purge_at": bson.M{"$lte": now},
+	}
+	result, err := u.userColl.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// FindUserByIdentity returns the user linked to the provider account, nil if
+// there is none
+func (u *userRepo) FindUserByIdentity(provider string, subject string) (*model.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}},
+	}
+	var user model.User
+	err := u.userColl.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (u *userRepo) LinkIdentity(userID string, identity model.ExternalIdentity) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id": userID,
+	}
+	update := bson.M{
+		"$push": bson.M{"identities": identity},
+		"$set":  bson.M{"email_verified": true, "updated_at": time.Now()},
+	}
+	result, err := u.userColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// updateFields sets fields on the user document and bumps updated_at
+func (u *userRepo) updateFields(userID string, fields bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	fields["updated_at"] = time.Now()
+	filter := bson.M{
+		"user_id": userID,
+	}
+	result, err := u.userColl.UpdateOne(ctx, filter, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
+	"github.com/sachinggsingh/e-comm/internal/config"
+	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/mailer"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	verificationTokenTTL  = 24 * time.Hour
+	passwordResetTokenTTL = 30 * time.Minute
+	minPasswordLength     = 6
+)
+
+var (
+	ErrInvalidAccountToken = errors.New("token is invalid, expired or already used")
+	ErrWeakPassword        = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+)
+
+// AccountService runs the email verification and password reset flows. Both
+// send a single-use signed token by mail that is kept in Redis until used or
+// expired.
+type AccountService struct {
+	repo       repository.UserRepository
+	redisCache *cache.RedisCache
+	mailer     mailer.Mailer
+	env        *config.Env
+}
+
+func NewAccountService(repo repository.UserRepository, redisCache *cache.RedisCache, m mailer.Mailer, env *config.Env) *AccountService {
+	return &AccountService{
+		repo:       repo,
+		redisCache: redisCache,
+		mailer:     m,
+		env:        env,
+	}
+}
+
+// issueToken mints a token for purpose and stores it for the user
+func (a *AccountService) issueToken(ctx context.Context, purpose string, userID string, ttl time.Duration) (string, error) {
+	token, id, err := helper.NewSignedToken(purpose)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	if err := a.redisCache.SaveAccountToken(ctx, purpose, helper.HashTokenID(id), userID, ttl); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	return token, nil
+}
+
+// consumeToken checks the token and returns the user it was issued to. The
+// token cannot be used again afterwards.
+func (a *AccountService) consumeToken(ctx context.Context, purpose string, token string) (string, error) {
+	id, err := helper.VerifySignedToken(purpose, token)
+	if err != nil {
+		return "", ErrInvalidAccountToken
+	}
+	userID, err := a.redisCache.ConsumeAccountToken(ctx, purpose, helper.HashTokenID(id))
+	if err != nil {
+		if errors.Is(err, cache.ErrAccountTokenNotFound) {
+			return "", ErrInvalidAccountToken
+		}
+		return "", err
+	}
+	return userID, nil
+}
+
+func (a *AccountService) link(path string, token string) string {
+	return a.env.APP_BASE_URL + path + "?token=" + url.QueryEscape(token)
+}
+
+// SendVerificationEmail mails the user a link confirming their address
+func (a *AccountService) SendVerificationEmail(ctx context.Context, user *model.User) error {
+	if user.Email == nil {
+		return errors.New("email is not defined")
+	}
+	token, err := a.issueToken(ctx, helper.PurposeVerifyEmail, user.User_id, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(ctx, mailer.Message{
+		To:      *user.Email,
+		Subject: "Verify your email address",
+		Body: "Confirm your email address by opening the link below:\n\n" +
+			a.link("/verify-email", token) + "\n\n" +
+			"The link expires in 24 hours.",
+	})
+}
+
+// ResendVerificationEmail sends a new verification link. Unknown or already
+// verified addresses are silently ignored so the endpoint cannot be used to
+// probe for accounts.
+func (a *AccountService) ResendVerificationEmail(ctx context.Context, email string) error {
+	user, err := a.repo.FindUserByEmail(email)
+	if err != nil {
+		return fmt.Errorf("failed to find user by email: %w", err)
+	}
+	if user == nil || user.Email_verified {
+		return nil
+	}
+	return a.SendVerificationEmail(ctx, user)
+}
+
+func (a *AccountService) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := a.consumeToken(ctx, helper.PurposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+	if err := a.repo.MarkEmailVerified(userID); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrInvalidAccountToken
+		}
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	a.emailVerified(userID)
+	return nil
+}
+
+// emailVerified grants the admin role to a user of ADMIN_EMAILS who just
+// proved the address is theirs. Failing to do so does not undo the
+// verification, BootstrapAdmins catches up on the next start.
+func (a *AccountService) emailVerified(userID string) {
+	user, err := a.repo.Profile(&model.User{User_id: userID})
+	if err == nil {
+		err = grantConfiguredAdmin(a.repo, a.env, user)
+	}
+	if err != nil {
+		log.Printf("Admin grant for %s failed: %v", userID, err)
+	}
+}
+
+// ForgotPassword mails a password reset link if the address belongs to a
+// user. Like ResendVerificationEmail it never reveals whether it does.
+func (a *AccountService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := a.repo.FindUserByEmail(email)
+	if err != nil {
+		return fmt.Errorf("failed to find user by email: %w", err)
+	}
+	if user == nil {
+		log.Printf("Password reset requested for unknown email")
+		return nil
+	}
+	token, err := a.issueToken(ctx, helper.PurposePasswordReset, user.User_id, passwordResetTokenTTL)
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(ctx, mailer.Message{
+		To:      *user.Email,
+		Subject: "Reset your password",
+		Body: "Choose a new password by opening the link below:\n\n" +
+			a.link("/password/reset", token) + "\n\n" +
+			"The link expires in 30 minutes. If you did not ask for a reset you can ignore this email.",
+	})
+}
+
+// ResetPassword sets a new password and signs the user out everywhere. Since
+// the user proved access to the mailbox the address counts as verified too.
+func (a *AccountService) ResetPassword(ctx context.Context, token string, password string) error {
+	if len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	userID, err := a.consumeToken(ctx, helper.PurposePasswordReset, token)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := helper.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := a.repo.UpdatePassword(userID, hashedPassword); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrInvalidAccountToken
+		}
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if err := a.repo.MarkEmailVerified(userID); err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	a.emailVerified(userID)
+	if err := a.redisCache.RevokeUserTokens(ctx, userID, time.Now(), helper.RefreshTokenTTL()); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
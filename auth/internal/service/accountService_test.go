@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/sachinggsingh/e-comm/internal/mailer"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+)
+
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+// mailedToken returns the token of the link in the only message sent
+func mailedToken(t *testing.T, m *mailer.MemoryMailer) string {
+	t.Helper()
+	sent := m.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(sent))
+	}
+	link, err := url.Parse(linkPattern.FindString(sent[0].Body))
+	if err != nil {
+		t.Fatalf("message has no link: %q", sent[0].Body)
+	}
+	return link.Query().Get("token")
+}
+
+func TestRegisterRequiringVerificationIssuesNoTokens(t *testing.T) {
+	env := useTestEnv(t)
+	env.REQUIRE_EMAIL_VERIFICATION = true
+	redisCache, server := newTestCache(t)
+	repo := newFakeUserRepo()
+	users := NewUserService(repo, redisCache, env)
+
+	email, password := "new@example.com", "secret123"
+	registered, err := users.RegisterUser(&model.User{Email: &email, Password: &password}, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	if registered.Token != nil || registered.Refresh_Token != nil {
+		t.Error("registration issued tokens before the address was verified")
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("registration stored %v in Redis, want no refresh family or session", keys)
+	}
+
+	_, _, err = users.LoginUser(&model.User{Email: &email, Password: &password}, model.ClientInfo{})
+	if err != ErrEmailNotVerified {
+		t.Errorf("login before verification: got %v, want %v", err, ErrEmailNotVerified)
+	}
+}
+
+func TestVerifyEmailByMailedLink(t *testing.T) {
+	env := useTestEnv(t)
+	redisCache, _ := newTestCache(t)
+	email := "user@example.com"
+	user := &model.User{User_id: "u1", Email: &email, Roles: []string{authz.RoleCustomer}}
+	repo := newFakeUserRepo(user)
+	outbox := mailer.NewMemoryMailer()
+	accounts := NewAccountService(repo, redisCache, outbox, env)
+	ctx := context.Background()
+
+	if err := accounts.SendVerificationEmail(ctx, user); err != nil {
+		t.Fatalf("SendVerificationEmail: %v", err)
+	}
+	if to := outbox.Sent()[0].To; to != email {
+		t.Errorf("mail sent to %q, want %q", to, email)
+	}
+	token := mailedToken(t, outbox)
+
+	if err := accounts.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if !repo.get("u1").Email_verified {
+		t.Error("address not marked verified")
+	}
+	if err := accounts.VerifyEmail(ctx, token); err != ErrInvalidAccountToken {
+		t.Errorf("reusing the link: got %v, want %v", err, ErrInvalidAccountToken)
+	}
+}
+
+func TestResendVerificationIgnoresUnknownAndVerifiedAddresses(t *testing.T) {
+	env := useTestEnv(t)
+	redisCache, _ := newTestCache(t)
+	email := "done@example.com"
+	repo := newFakeUserRepo(&model.User{User_id: "u1", Email: &email, Email_verified: true})
+	outbox := mailer.NewMemoryMailer()
+	accounts := NewAccountService(repo, redisCache, outbox, env)
+
+	for _, address := range []string{email, "nobody@example.com"} {
+		if err := accounts.ResendVerificationEmail(context.Background(), address); err != nil {
+			t.Fatalf("ResendVerificationEmail(%s): %v", address, err)
+		}
+	}
+	if sent := outbox.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d messages, want none", len(sent))
+	}
+}
+
+func TestVerifyingAdminAddressGrantsAdmin(t *testing.T) {
+	env := useTestEnv(t)
+	env.ADMIN_EMAILS = []string{"admin@example.com"}
+	redisCache, _ := newTestCache(t)
+	email := "admin@example.com"
+	user := &model.User{User_id: "u1", Email: &email, Roles: []string{authz.RoleCustomer}}
+	repo := newFakeUserRepo(user)
+	users := NewUserService(repo, redisCache, env)
+	outbox := mailer.NewMemoryMailer()
+	accounts := NewAccountService(repo, redisCache, outbox, env)
+
+	if err := users.BootstrapAdmins(); err != nil {
+		t.Fatalf("BootstrapAdmins: %v", err)
+	}
+	if slices.Contains(repo.get("u1").Roles, authz.RoleAdmin) {
+		t.Fatal("unverified admin address was granted the admin role")
+	}
+
+	if err := accounts.SendVerificationEmail(context.Background(), user); err != nil {
+		t.Fatalf("SendVerificationEmail: %v", err)
+	}
+	if err := accounts.VerifyEmail(context.Background(), mailedToken(t, outbox)); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if !slices.Contains(repo.get("u1").Roles, authz.RoleAdmin) {
+		t.Error("verified admin address was not granted the admin role")
+	}
+}
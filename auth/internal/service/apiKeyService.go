@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
+	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultAPIKeyDays = 90
+	maxAPIKeyDays     = 365
+	maxAPIKeysPerUser = 20
+	maxAPIKeyName     = 100
+	// apiKeyCacheTTL bounds how long a revoked key or a changed role may
+	// still be honoured by this instance
+	apiKeyCacheTTL = time.Minute
+	// apiKeyUsageInterval is how often the last use of a key is written
+	apiKeyUsageInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey    = errors.New("api key is invalid or expired")
+	ErrAPIKeyRevoked    = errors.New("api key has been revoked")
+	ErrAPIKeyNotFound   = errors.New("api key not found")
+	ErrInvalidAPIKeyReq = errors.New("invalid api key request")
+	ErrTooManyAPIKeys   = errors.New("too many active api keys")
+)
+
+// APIKeyService issues and checks the API keys machine clients use instead of
+// logging in. User keys act for their owner and never hold more than the
+// owner's roles grant; service keys are created by admins for integrations.
+type APIKeyService struct {
+	repo       repository.APIKeyRepository
+	userRepo   repository.UserRepository
+	redisCache *cache.RedisCache
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, userRepo repository.UserRepository, redisCache *cache.RedisCache) *APIKeyService {
+	return &APIKeyService{
+		repo:       repo,
+		userRepo:   userRepo,
+		redisCache: redisCache,
+	}
+}
+
+// CreateUserKey issues a key acting for the user. The scopes must be held by
+// the user; none means all of them.
+func (a *APIKeyService) CreateUserKey(userID string, req *model.CreateAPIKeyRequest) (*model.CreateAPIKeyResponse, error) {
+	user, err := a.findOwner(userID)
+	if err != nil {
+		return nil, err
+	}
+	granted := authz.PermissionsFor(rolesOf(user))
+	if len(req.Scopes) == 0 {
+		req.Scopes = granted
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(granted, scope) {
+			return nil, fmt.Errorf("%w: scope %q is not granted to you", ErrInvalidAPIKeyReq, scope)
+		}
+	}
+
+	active, err := a.repo.CountActive(userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count api keys: %w", err)
+	}
+	if active >= maxAPIKeysPerUser {
+		return nil, fmt.Errorf("%w: revoke one of your %d keys first", ErrTooManyAPIKeys, active)
+	}
+
+	key := &model.APIKey{Owner_type: model.APIKeyOwnerUser, User_id: userID}
+	return a.create(key, req)
+}
+
+// CreateServiceKey issues a key for an integration, with any known scopes.
+// adminID records who created it.
+func (a *APIKeyService) CreateServiceKey(adminID string, req *model.CreateAPIKeyRequest) (*model.CreateAPIKeyResponse, error) {
+	req.Service = strings.TrimSpace(req.Service)
+	if req.Service == "" {
+		return nil, fmt.Errorf("%w: service is required", ErrInvalidAPIKeyReq)
+	}
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyReq)
+	}
+	known := authz.PermissionsFor([]string{authz.RoleAdmin})
+	for _, scope := range req.Scopes {
+		if !slices.Contains(known, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyReq, scope)
+		}
+	}
+
+	key := &model.APIKey{Owner_type: model.APIKeyOwnerService, User_id: adminID, Service: req.Service}
+	return a.create(key, req)
+}
+
+func (a *APIKeyService) create(key *model.APIKey, req *model.CreateAPIKeyRequest) (*model.CreateAPIKeyResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxAPIKeyName {
+		return nil, fmt.Errorf("%w: name is required and limited to %d characters", ErrInvalidAPIKeyReq, maxAPIKeyName)
+	}
+	days := req.Expires_in_days
+	if days == 0 {
+		days = defaultAPIKeyDays
+	}
+	if days < 0 || days > maxAPIKeyDays {
+		return nil, fmt.Errorf("%w: expires_in_days must be between 1 and %d", ErrInvalidAPIKeyReq, maxAPIKeyDays)
+	}
+
+	secret, prefix, err := helper.NewAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	keyID, err := helper.NewTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	hash, _ := helper.HashToken(secret)
+
+	now := time.Now()
+	key.ID = primitive.NewObjectID()
+	key.Key_id = keyID
+	key.Name = name
+	key.Prefix = prefix
+	key.Hash = hash
+	key.Scopes = slices.Clone(req.Scopes)
+	key.Created_at = now
+	key.Expires_at = now.AddDate(0, 0, days)
+
+	if err := a.repo.Create(key); err != nil {
+		return nil, fmt.Errorf("failed to save api key: %w", err)
+	}
+	return &model.CreateAPIKeyResponse{Key: secret, APIKey: key}, nil
+}
+
+func (a *APIKeyService) ListUserKeys(userID string) ([]*model.APIKey, error) {
+	return a.repo.List(model.APIKeyOwnerUser, userID)
+}
+
+func (a *APIKeyService) ListServiceKeys() ([]*model.APIKey, error) {
+	return a.repo.List(model.APIKeyOwnerService, "")
+}
+
+// RevokeUserKey revokes one of the user's own keys
+func (a *APIKeyService) RevokeUserKey(ctx context.Context, userID string, keyID string) error {
+	return a.revoke(ctx, keyID, bson.M{"owner_type": model.APIKeyOwnerUser, "user_id": userID})
+}
+
+// RevokeKey revokes any key, for admins
+func (a *APIKeyService) RevokeKey(ctx context.Context, keyID string) error {
+	return a.revoke(ctx, keyID, nil)
+}
+
+func (a *APIKeyService) revoke(ctx context.Context, keyID string, filter bson.M) error {
+	key, err := a.repo.Revoke(keyID, filter)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if err := a.redisCache.RemoveCachedAPIKey(ctx, key.Hash); err != nil {
+		log.Printf("failed to evict revoked api key %s from cache: %v", key.Key_id, err)
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves the caller of an API key. A user key carries
+// its scopes only as long as the owner still holds them.
+func (a *APIKeyService) AuthenticateAPIKey(ctx context.Context, secret string) (*authz.Principal, error) {
+	if !strings.HasPrefix(secret, authz.APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	hash, _ := helper.HashToken(secret)
+
+	cached, err := a.redisCache.GetCachedAPIKey(ctx, hash)
+	if err != nil {
+		log.Printf("api key cache unavailable: %v", err)
+	}
+	if cached == nil {
+		cached, err = a.loadAPIKey(hash)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.redisCache.CacheAPIKey(ctx, hash, cached, apiKeyCacheTTL); err != nil {
+			log.Printf("failed to cache api key %s: %v", cached.KeyID, err)
+		}
+	}
+
+	if cached.Revoked {
+		return nil, ErrAPIKeyRevoked
+	}
+	if time.Now().Unix() >= cached.ExpiresAt {
+		return nil, ErrInvalidAPIKey
+	}
+	a.touch(ctx, cached.KeyID)
+
+	return &authz.Principal{
+		UserID:   cached.UserID,
+		Email:    cached.Email,
+		Scopes:   cached.Scopes,
+		APIKeyID: cached.KeyID,
+	}, nil
+}
+
+// loadAPIKey reads the key and its owner from MongoDB
+func (a *APIKeyService) loadAPIKey(hash string) (*cache.CachedAPIKey, error) {
+	key, err := a.repo.FindByHash(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find api key: %w", err)
+	}
+	if key == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	cached := &cache.CachedAPIKey{
+		KeyID:     key.Key_id,
+		Revoked:   key.Revoked_at != nil,
+		CreatedAt: key.Created_at.Unix(),
+		ExpiresAt: key.Expires_at.Unix(),
+	}
+
+	if key.Owner_type == model.APIKeyOwnerService {
+		cached.UserID = "service:" + key.Service
+		cached.Scopes = key.Scopes
+		return cached, nil
+	}
+
+	owner, err := a.findOwner(key.User_id)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	granted := authz.PermissionsFor(rolesOf(owner))
+	cached.UserID = owner.User_id
+	if owner.Email != nil {
+		cached.Email = *owner.Email
+	}
+	cached.Scopes = []string{}
+	for _, scope := range key.Scopes {
+		if slices.Contains(granted, scope) {
+			cached.Scopes = append(cached.Scopes, scope)
+		}
+	}
+	return cached, nil
+}
+
+// findOwner returns the user, treating accounts scheduled for deletion as gone
+func (a *APIKeyService) findOwner(userID string) (*model.User, error) {
+	user, err := a.userRepo.Profile(&model.User{User_id: userID})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if user.Deleted_at != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// touch records the use of the key, at most once per apiKeyUsageInterval
+func (a *APIKeyService) touch(ctx context.Context, keyID string) {
+	first, err := a.redisCache.MarkAPIKeyUsed(ctx, keyID, apiKeyUsageInterval)
+	if err != nil || !first {
+		return
+	}
+	if err := a.repo.TouchLastUsed(keyID, time.Now()); err != nil {
+		log.Printf("failed to record use of api key %s: %v", keyID, err)
+	}
+}
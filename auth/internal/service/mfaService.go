@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
+	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// MFAChallengeTTL is how long the user has to enter the code after the
+	// password step of the login
+	MFAChallengeTTL = 5 * time.Minute
+
+	totpEnrollmentTTL = 10 * time.Minute
+	// totpReplayTTL covers every time step ValidateTOTP accepts
+	totpReplayTTL     = 2 * time.Minute
+	recoveryCodeCount = 10
+	totpIssuer        = "e-comm"
+)
+
+var (
+	ErrMFAAlreadyEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled        = errors.New("two-factor authentication is not enabled")
+	ErrMFAEnrollmentExpired = errors.New("no pending two-factor enrollment, start again")
+	ErrInvalidMFACode       = errors.New("invalid two-factor code")
+	ErrInvalidMFAChallenge  = errors.New("two-factor challenge is invalid or expired, log in again")
+)
+
+// EnrollTOTP generates a new TOTP secret for the user. It only becomes active
+// once ConfirmTOTP receives a valid code for it.
+func (u *UserService) EnrollTOTP(ctx context.Context, userID string) (*model.TOTPEnrollResponse, error) {
+	user, err := u.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Mfa_enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := helper.NewTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	if err := u.redisCache.SavePendingTOTPSecret(ctx, userID, secret, totpEnrollmentTTL); err != nil {
+		return nil, fmt.Errorf("failed to store secret: %w", err)
+	}
+	return &model.TOTPEnrollResponse{
+		Secret:     secret,
+		OtpauthURI: helper.TOTPURI(totpIssuer, *user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication when code matches the
+// pending secret and returns the recovery codes. They are shown only once,
+// only their hashes are stored.
+func (u *UserService) ConfirmTOTP(ctx context.Context, userID string, code string) ([]string, error) {
+	secret, err := u.redisCache.GetPendingTOTPSecret(ctx, userID)
+	if err != nil {
+		if errors.Is(err, cache.ErrMFAEnrollmentNotFound) {
+			return nil, ErrMFAEnrollmentExpired
+		}
+		return nil, err
+	}
+	if err := u.checkTOTP(ctx, userID, secret, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.repo.EnableMFA(userID, secret, hashes); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	if err := u.redisCache.DeletePendingTOTPSecret(ctx, userID); err != nil {
+		log.Printf("Failed to delete pending TOTP secret of user %s: %v", userID, err)
+	}
+	return codes, nil
+}
+
+// DisableMFA turns two-factor authentication off. The user has to prove
+// possession of the second factor one last time.
+func (u *UserService) DisableMFA(ctx context.Context, userID string, code string) error {
+	user, err := u.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := u.verifySecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+	return u.repo.DisableMFA(userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user
+func (u *UserService) RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error) {
+	user, err := u.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.verifySecondFactor(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.repo.SetRecoveryCodes(userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// newMFAChallenge starts the second login step for a user whose password
+// was checked
+func (u *UserService) newMFAChallenge(ctx context.Context, user *model.User) (string, error) {
+	token, id, err := helper.NewSignedToken(helper.PurposeMFAChallenge)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	if err := u.redisCache.SaveAccountToken(ctx, helper.PurposeMFAChallenge, helper.HashTokenID(id), user.User_id, MFAChallengeTTL); err != nil {
+		return "", fmt.Errorf("failed to store challenge: %w", err)
+	}
+	return token, nil
+}
+
+// CompleteMFALogin exchanges the challenge from LoginUser and a TOTP or
+// recovery code for a token pair. A wrong code leaves the challenge usable
+// until it expires; wrong codes count towards the account lockout.
+func (u *UserService) CompleteMFALogin(ctx context.Context, challenge string, code string, client model.ClientInfo) (*model.User, error) {
+	id, err := helper.VerifySignedToken(helper.PurposeMFAChallenge, challenge)
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	idHash := helper.HashTokenID(id)
+	userID, err := u.redisCache.LookupAccountToken(ctx, helper.PurposeMFAChallenge, idHash)
+	if err != nil {
+		if errors.Is(err, cache.ErrAccountTokenNotFound) {
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, err
+	}
+
+	user, err := u.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkLockout(ctx, *user.Email); err != nil {
+		return nil, err
+	}
+	if err := u.verifySecondFactor(ctx, user, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if lockErr := u.loginFailed(ctx, *user.Email); lockErr != nil {
+				return nil, lockErr
+			}
+		}
+		return nil, err
+	}
+
+	// Only one login may come out of a challenge
+	if _, err := u.redisCache.ConsumeAccountToken(ctx, helper.PurposeMFAChallenge, idHash); err != nil {
+		if errors.Is(err, cache.ErrAccountTokenNotFound) {
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, err
+	}
+
+	if err := u.issueTokens(ctx, user, client); err != nil {
+		return nil, err
+	}
+	u.loginSucceeded(ctx, user)
+	return u.repo.Login(user)
+}
+
+// verifySecondFactor accepts a current TOTP code or one of the unused
+// recovery codes of the user
+func (u *UserService) verifySecondFactor(ctx context.Context, user *model.User, code string) error {
+	if !user.Mfa_enabled || user.Totp_secret == nil {
+		return ErrMFANotEnabled
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) == 6 {
+		return u.checkTOTP(ctx, user.User_id, *user.Totp_secret, code)
+	}
+
+	code = strings.ToLower(code)
+	for _, hash := range user.Recovery_codes {
+		if !helper.CheckPasswordHash(code, hash) {
+			continue
+		}
+		if err := u.repo.ConsumeRecoveryCode(user.User_id, hash); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return ErrInvalidMFACode
+			}
+			return fmt.Errorf("failed to use recovery code: %w", err)
+		}
+		log.Printf("User %s logged in with a recovery code, %d left", user.User_id, len(user.Recovery_codes)-1)
+		return nil
+	}
+	return ErrInvalidMFACode
+}
+
+// checkTOTP validates code and rejects codes that were already used
+func (u *UserService) checkTOTP(ctx context.Context, userID string, secret string, code string) error {
+	step, ok := helper.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	fresh, err := u.redisCache.MarkTOTPStepUsed(ctx, userID, step, totpReplayTTL)
+	if err != nil {
+		return fmt.Errorf("failed to record code use: %w", err)
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (u *UserService) findUser(userID string) (*model.User, error) {
+	user, err := u.repo.Profile(&model.User{User_id: userID})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	return user, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and their bcrypt hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := helper.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		if hashes[i], err = helper.HashRecoveryCode(code); err != nil {
+			return nil, nil, fmt.Errorf("failed to hash recovery code: %w", err)
+		}
+	}
+	return codes, hashes, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
+	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/oauth"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"golang.org/x/oauth2"
+)
+
+// oauthStateTTL bounds how long the user may take at the provider
+const oauthStateTTL = 10 * time.Minute
+
+var (
+	ErrInvalidOAuthState       = errors.New("login state is invalid or expired, start the login again")
+	ErrProviderEmailUnverified = errors.New("the provider did not confirm the email address of this account")
+	ErrAccountEmailUnverified  = errors.New("an account with this email exists but its address is not verified, verify it or reset the password before signing in with a provider")
+)
+
+// OAuthService signs users in with external identity providers (Google,
+// GitHub, any OpenID Connect provider) and issues the same tokens as a
+// password login.
+type OAuthService struct {
+	repo        repository.UserRepository
+	redisCache  *cache.RedisCache
+	userService *UserService
+	providers   map[string]oauth.Provider
+}
+
+func NewOAuthService(repo repository.UserRepository, redisCache *cache.RedisCache, userService *UserService, providers map[string]oauth.Provider) *OAuthService {
+	return &OAuthService{
+		repo:        repo,
+		redisCache:  redisCache,
+		userService: userService,
+		providers:   providers,
+	}
+}
+
+// Providers lists the names of the configured providers
+func (o *OAuthService) Providers() []string {
+	names := make([]string, 0, len(o.providers))
+	for name := range o.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BeginLogin returns the state to bind to the browser and the provider URL
+// to send it to. State, nonce and PKCE verifier are kept in Redis for the
+// callback.
+func (o *OAuthService) BeginLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := o.providers[providerName]
+	if !ok {
+		return "", "", oauth.ErrUnknownProvider
+	}
+
+	state, err := helper.NewTokenID()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := helper.NewTokenID()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+	stored := &cache.OAuthState{Provider: providerName, Nonce: nonce, Verifier: verifier}
+	if err := o.redisCache.SaveOAuthState(ctx, state, stored, oauthStateTTL); err != nil {
+		return "", "", fmt.Errorf("failed to store login state: %w", err)
+	}
+	return state, authURL, nil
+}
+
+// CompleteLogin handles the provider callback: it redeems the code, finds or
+// creates the account and logs it in. Returns the user with tokens, or an
+// MFA challenge like UserService.LoginUser.
+func (o *OAuthService) CompleteLogin(ctx context.Context, providerName string, state string, code string, client model.ClientInfo) (*model.User, string, error) {
+	stored, err := o.redisCache.ConsumeOAuthState(ctx, state)
+	if err != nil {
+		if errors.Is(err, cache.ErrOAuthStateNotFound) {
+			return nil, "", ErrInvalidOAuthState
+		}
+		return nil, "", err
+	}
+	if stored.Provider != providerName {
+		return nil, "", ErrInvalidOAuthState
+	}
+	provider, ok := o.providers[providerName]
+	if !ok {
+		return nil, "", oauth.ErrUnknownProvider
+	}
+
+	identity, err := provider.Exchange(ctx, code, stored.Verifier, stored.Nonce)
+	if err != nil {
+		return nil, "", err
+	}
+	user, err := o.resolveUser(identity)
+	if err != nil {
+		return nil, "", err
+	}
+	return o.userService.CompleteLogin(ctx, user, client)
+}
+
+// resolveUser finds the account of the identity. An existing account is
+// linked only when the provider verified that the email belongs to the user,
+// otherwise anyone could take over an account by registering its address at
+// a provider. The account must have verified its address too: anyone can
+// register a password account for an address they do not own, and linking
+// would hand the owner an account the registrant can still log into.
+func (o *OAuthService) resolveUser(identity *oauth.Identity) (*model.User, error) {
+	user, err := o.repo.FindUserByIdentity(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrProviderEmailUnverified
+	}
+	link := model.ExternalIdentity{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Linked_at: time.Now(),
+	}
+
+	user, err = o.repo.FindUserByEmail(identity.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user by email: %w", err)
+	}
+	if user == nil {
+		log.Printf("Creating account for %s from %s login", identity.Email, identity.Provider)
+		return o.userService.RegisterExternalUser(identity.Email, link)
+	}
+
+	if !user.Email_verified {
+		log.Printf("Refused to link %s account to unverified user %s", identity.Provider, user.User_id)
+		return nil, ErrAccountEmailUnverified
+	}
+	if err := o.repo.LinkIdentity(user.User_id, link); err != nil {
+		return nil, fmt.Errorf("failed to link %s account: %w", identity.Provider, err)
+	}
+	log.Printf("Linked %s account to user %s", identity.Provider, user.User_id)
+	user.Identities = append(user.Identities, link)
+	return user, nil
+}
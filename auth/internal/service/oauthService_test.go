@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sachinggsingh/e-comm/internal/config"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/oauth"
+	"github.com/sachinggsingh/e-comm/internal/oauth/oauthtest"
+)
+
+type oauthFixture struct {
+	idp   *oauthtest.Provider
+	repo  *fakeUserRepo
+	oauth *OAuthService
+}
+
+// newOAuthFixture wires the service to the stub identity provider, with
+// the given users already registered
+func newOAuthFixture(t *testing.T, users ...*model.User) *oauthFixture {
+	t.Helper()
+	env := useTestEnv(t)
+	redisCache, _ := newTestCache(t)
+	idp, server, err := oauthtest.NewServer("e-comm", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	repo := newFakeUserRepo(users...)
+	provider := oauth.NewOIDCProvider(config.OAuthProvider{
+		Name:         "stub",
+		Kind:         "oidc",
+		IssuerURL:    idp.Issuer,
+		ClientID:     "e-comm",
+		ClientSecret: "secret",
+	}, "http://auth.test/oauth/stub/callback", http.DefaultClient)
+	service := NewOAuthService(repo, redisCache, NewUserService(repo, redisCache, env),
+		map[string]oauth.Provider{"stub": provider})
+	return &oauthFixture{idp: idp, repo: repo, oauth: service}
+}
+
+// login runs the whole social login as the stub provider's current user
+func (f *oauthFixture) login(t *testing.T) (*model.User, error) {
+	t.Helper()
+	ctx := context.Background()
+	state, authURL, err := f.oauth.BeginLogin(ctx, "stub")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	code, returnedState, err := f.idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if returnedState != state {
+		t.Fatalf("provider returned state %q, want %q", returnedState, state)
+	}
+	user, _, err := f.oauth.CompleteLogin(ctx, "stub", state, code, model.ClientInfo{})
+	return user, err
+}
+
+func TestSocialLoginCreatesVerifiedAccount(t *testing.T) {
+	f := newOAuthFixture(t)
+	f.idp.Email = "new@example.com"
+
+	user, err := f.login(t)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if user.Token == nil || user.Refresh_Token == nil {
+		t.Error("login issued no tokens")
+	}
+	stored, _ := f.repo.FindUserByEmail("new@example.com")
+	if stored == nil || !stored.Email_verified || len(stored.Identities) != 1 {
+		t.Fatalf("stored account = %+v, want a verified account with one identity", stored)
+	}
+
+	// The second login finds the account by its identity
+	again, err := f.login(t)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.User_id != stored.User_id {
+		t.Errorf("second login signed into %s, want %s", again.User_id, stored.User_id)
+	}
+}
+
+func TestSocialLoginLinksVerifiedAccount(t *testing.T) {
+	email := "owner@example.com"
+	f := newOAuthFixture(t, &model.User{User_id: "u1", Email: &email, Email_verified: true})
+	f.idp.Email = email
+
+	user, err := f.login(t)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if user.User_id != "u1" {
+		t.Errorf("signed into %s, want the existing account u1", user.User_id)
+	}
+	if identities := f.repo.get("u1").Identities; len(identities) != 1 || identities[0].Provider != "stub" {
+		t.Errorf("identities = %+v, want the stub identity", identities)
+	}
+}
+
+func TestSocialLoginDoesNotLinkUnverifiedAccount(t *testing.T) {
+	// Someone registered the victim's address with a password they know
+	email, password := "victim@example.com", "attacker-password"
+	f := newOAuthFixture(t, &model.User{User_id: "u1", Email: &email, Password: &password})
+	f.idp.Email = email
+
+	if _, err := f.login(t); !errors.Is(err, ErrAccountEmailUnverified) {
+		t.Fatalf("login: got %v, want %v", err, ErrAccountEmailUnverified)
+	}
+	stored := f.repo.get("u1")
+	if len(stored.Identities) != 0 || stored.Email_verified {
+		t.Errorf("unverified account was linked: %+v", stored)
+	}
+}
+
+func TestSocialLoginRequiresProviderVerifiedEmail(t *testing.T) {
+	email := "owner@example.com"
+	f := newOAuthFixture(t, &model.User{User_id: "u1", Email: &email, Email_verified: true})
+	f.idp.Email = email
+	f.idp.EmailVerified = false
+
+	if _, err := f.login(t); !errors.Is(err, ErrProviderEmailUnverified) {
+		t.Fatalf("login: got %v, want %v", err, ErrProviderEmailUnverified)
+	}
+	if identities := f.repo.get("u1").Identities; len(identities) != 0 {
+		t.Errorf("account was linked to an unverified provider email: %+v", identities)
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/model"
+)
+
+const (
+	maxNameLength  = 100
+	maxPhoneLength = 20
+)
+
+var (
+	ErrInvalidProfile    = errors.New("invalid profile")
+	ErrIncorrectPassword = errors.New("password is incorrect")
+)
+
+func toPublicProfile(user *model.User) *model.PublicProfile {
+	profile := &model.PublicProfile{
+		User_id:        user.User_id,
+		First_name:     user.First_name,
+		Last_name:      user.Last_name,
+		Phone:          user.Phone,
+		Roles:          rolesOf(user),
+		Email_verified: user.Email_verified,
+		Mfa_enabled:    user.Mfa_enabled,
+		Created_at:     user.Created_at,
+		Updated_at:     user.Updated_at,
+	}
+	if user.Email != nil {
+		profile.Email = *user.Email
+	}
+	return profile
+}
+
+// Profile returns the public profile of the user
+func (u *UserService) Profile(userID string) (*model.PublicProfile, error) {
+	user, err := u.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return toPublicProfile(user), nil
+}
+
+// UpdateProfile changes the personal details of the user. Empty strings
+// clear a field.
+func (u *UserService) UpdateProfile(userID string, update *model.UpdateProfileRequest) (*model.PublicProfile, error) {
+	for _, field := range []**string{&update.First_name, &update.Last_name, &update.Phone} {
+		if *field != nil {
+			trimmed := strings.TrimSpace(**field)
+			*field = &trimmed
+		}
+	}
+	if update.First_name != nil && len(*update.First_name) > maxNameLength ||
+		update.Last_name != nil && len(*update.Last_name) > maxNameLength {
+		return nil, fmt.Errorf("%w: names are limited to %d characters", ErrInvalidProfile, maxNameLength)
+	}
+	if update.Phone != nil && !validPhone(*update.Phone) {
+		return nil, fmt.Errorf("%w: phone must be up to %d digits with an optional leading +", ErrInvalidProfile, maxPhoneLength)
+	}
+
+	if update.First_name != nil || update.Last_name != nil || update.Phone != nil {
+		if err := u.repo.UpdateProfile(userID, update); err != nil {
+			return nil, fmt.Errorf("failed to update profile: %w", err)
+		}
+	}
+	return u.Profile(userID)
+}
+
+func validPhone(phone string) bool {
+	if phone == "" {
+		return true
+	}
+	digits := strings.TrimPrefix(phone, "+")
+	if len(digits) == 0 || len(digits) > maxPhoneLength {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ChangePassword sets a new password after checking the current one. Every
+// other session of the user is signed out, the one making the change stays
+// signed in.
+func (u *UserService) ChangePassword(ctx context.Context, token string, currentPassword string, newPassword string) error {
+	claims, err := helper.ValidateToken(token)
+	if err != nil {
+		return err
+	}
+	user, err := u.findUser(claims.Uid)
+	if err != nil {
+		return err
+	}
+	if !helper.CheckPasswordHash(currentPassword, *user.Password) {
+		return ErrIncorrectPassword
+	}
+	if len(newPassword) < minPasswordLength {
+		return ErrWeakPassword
+	}
+
+	hashedPassword, err := helper.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := u.repo.UpdatePassword(user.User_id, hashedPassword); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if err := u.redisCache.RevokeOtherUserTokens(ctx, user.User_id, time.Now(), claims.Family, helper.RefreshTokenTTL()); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// DeleteAccount schedules the account for deletion after the grace period
+// and signs the user out everywhere. Logging in before the returned time
+// restores the account.
+func (u *UserService) DeleteAccount(ctx context.Context, token string, password string) (time.Time, error) {
+	claims, err := helper.ValidateToken(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	user, err := u.findUser(claims.Uid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !helper.CheckPasswordHash(password, *user.Password) {
+		return time.Time{}, ErrIncorrectPassword
+	}
+
+	purgeAt := time.Now().AddDate(0, 0, u.env.ACCOUNT_DELETION_GRACE_DAYS)
+	if err := u.repo.ScheduleDeletion(user.User_id, purgeAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to delete account: %w", err)
+	}
+	if err := u.revokeAccessToken(ctx, token, claims); err != nil {
+		return time.Time{}, err
+	}
+	if err := u.redisCache.RevokeUserTokens(ctx, user.User_id, time.Now(), helper.RefreshTokenTTL()); err != nil {
+		return time.Time{}, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return purgeAt, nil
+}
+
+// PurgeDeletedAccounts removes the accounts whose grace period is over
+func (u *UserService) PurgeDeletedAccounts() error {
+	purged, err := u.repo.PurgeDeletedUsers(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to purge deleted accounts: %w", err)
+	}
+	if purged > 0 {
+		log.Printf("Purged %d deleted accounts", purged)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
+	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/model"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func toSession(session *cache.UserSession, currentSession string) *model.Session {
+	return &model.Session{
+		Session_id:   session.ID,
+		Device:       session.Device,
+		User_agent:   session.UserAgent,
+		Ip:           session.IP,
+		Created_at:   time.Unix(session.CreatedAt, 0).UTC(),
+		Last_seen_at: time.Unix(session.LastSeenAt, 0).UTC(),
+		Current:      session.ID == currentSession,
+	}
+}
+
+// saveSession records a new login. Failing to record it does not fail the
+// login, the session then just is not listed.
+func (u *UserService) saveSession(ctx context.Context, userID string, sessionID string, client model.ClientInfo) {
+	now := time.Now().Unix()
+	session := &cache.UserSession{
+		ID:         sessionID,
+		UserID:     userID,
+		Device:     helper.DeviceName(client.User_agent),
+		UserAgent:  client.User_agent,
+		IP:         client.Ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	if err := u.redisCache.SaveUserSession(ctx, session, helper.RefreshTokenTTL()); err != nil {
+		log.Printf("Failed to record session of user %s: %v", userID, err)
+	}
+}
+
+// refreshSession marks the session as seen on a token refresh and extends
+// it with the new refresh token. Sessions of logins from before sessions
+// were recorded are recorded now.
+func (u *UserService) refreshSession(ctx context.Context, userID string, sessionID string, client model.ClientInfo) {
+	session, err := u.redisCache.GetSavedUserSession(ctx, sessionID)
+	if err != nil {
+		log.Printf("Failed to load session %s: %v", sessionID, err)
+		return
+	}
+	if session == nil {
+		u.saveSession(ctx, userID, sessionID, client)
+		return
+	}
+	if err := u.redisCache.TouchUserSession(ctx, sessionID, client.Ip, 0, helper.RefreshTokenTTL()); err != nil {
+		log.Printf("Failed to update session %s: %v", sessionID, err)
+	}
+}
+
+// endSession revokes the session: its refresh tokens stop working at once
+// and its access tokens are rejected by every service that validates them
+// with auth
+func (u *UserService) endSession(ctx context.Context, userID string, sessionID string) error {
+	if err := u.redisCache.RevokeRefreshFamily(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	accessTTL := time.Duration(u.env.ACCESS_TOKEN_TTL_MINUTES) * time.Minute
+	if err := u.redisCache.RevokeSession(ctx, sessionID, accessTTL); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if err := u.redisCache.DeleteUserSession(ctx, userID, sessionID); err != nil {
+		return fmt.Errorf("failed to remove session: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns the active sessions of the user, most recently seen
+// first. currentSession marks the one making the request.
+func (u *UserService) ListSessions(ctx context.Context, userID string, currentSession string) ([]*model.Session, error) {
+	stored, err := u.redisCache.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].LastSeenAt > stored[j].LastSeenAt
+	})
+
+	sessions := make([]*model.Session, 0, len(stored))
+	for _, session := range stored {
+		sessions = append(sessions, toSession(session, currentSession))
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one session of the user out
+func (u *UserService) RevokeSession(ctx context.Context, userID string, sessionID string) error {
+	session, err := u.redisCache.GetSavedUserSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+	if session == nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return u.endSession(ctx, userID, sessionID)
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
+	"github.com/sachinggsingh/e-comm/internal/config"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// useTestEnv makes config.GetEnv, which the token helpers read, work inside
+// a test: it loads .env from the working directory, so an empty one is put
+// into a temporary directory and the required settings come from the
+// environment
+func useTestEnv(t *testing.T) *config.Env {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	for key, value := range map[string]string{
+		"PORT":           "8080",
+		"MONGO_URL":      "mongodb://localhost:27017",
+		"APP_SECRET":     "test-secret",
+		"REDIS_PORT":     "6379",
+		"REDIS_PASSWORD": "test",
+		"REDIS_DB":       "0",
+		"APP_BASE_URL":   "http://auth.test",
+		"MAILER":         "memory",
+	} {
+		t.Setenv(key, value)
+	}
+	return config.GetEnv()
+}
+
+// newTestCache returns a RedisCache backed by an in-process Redis
+func newTestCache(t *testing.T) (*cache.RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return cache.NewRedisCache(client), server
+}
+
+// fakeUserRepo keeps users in memory. Methods the tests do not need are
+// left to the embedded nil interface and panic when called.
+type fakeUserRepo struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users map[string]*model.User
+}
+
+func newFakeUserRepo(users ...*model.User) *fakeUserRepo {
+	repo := &fakeUserRepo{users: map[string]*model.User{}}
+	for _, user := range users {
+		repo.users[user.User_id] = user
+	}
+	return repo
+}
+
+// get returns a copy of the stored user, nil if there is none
+func (f *fakeUserRepo) get(userID string) *model.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[userID]
+	if !ok {
+		return nil
+	}
+	copied := *user
+	return &copied
+}
+
+func (f *fakeUserRepo) update(userID string, apply func(*model.User)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[userID]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+	apply(user)
+	user.Updated_at = time.Now()
+	return nil
+}
+
+func (f *fakeUserRepo) Register(user *model.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *user
+	f.users[user.User_id] = &copied
+	return nil
+}
+
+func (f *fakeUserRepo) Login(user *model.User) (*model.User, error) {
+	return user, nil
+}
+
+func (f *fakeUserRepo) Profile(user *model.User) (*model.User, error) {
+	if stored := f.get(user.User_id); stored != nil {
+		return stored, nil
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (f *fakeUserRepo) CheckIfEmailExist(email string) (bool, error) {
+	user, err := f.FindUserByEmail(email)
+	return user != nil, err
+}
+
+func (f *fakeUserRepo) FindUserByEmail(email string) (*model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.Email != nil && *user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeUserRepo) FindUserByIdentity(provider string, subject string) (*model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		for _, identity := range user.Identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				copied := *user
+				return &copied, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeUserRepo) LinkIdentity(userID string, identity model.ExternalIdentity) error {
+	return f.update(userID, func(user *model.User) {
+		user.Identities = append(user.Identities, identity)
+	})
+}
+
+func (f *fakeUserRepo) SetRoles(userID string, roles []string) error {
+	return f.update(userID, func(user *model.User) { user.Roles = roles })
+}
+
+func (f *fakeUserRepo) MarkEmailVerified(userID string) error {
+	return f.update(userID, func(user *model.User) { user.Email_verified = true })
+}
+
+func (f *fakeUserRepo) UpdatePassword(userID string, hashedPassword string) error {
+	return f.update(userID, func(user *model.User) { user.Password = &hashedPassword })
+}
@@ -1,107 +1,485 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	cache "github.com/sachinggsingh/e-comm/internal/caches"
+	"github.com/sachinggsingh/e-comm/internal/config"
 	"github.com/sachinggsingh/e-comm/internal/helper"
 	"github.com/sachinggsingh/e-comm/internal/model"
 	"github.com/sachinggsingh/e-comm/internal/repository"
+	"github.com/sachinggsingh/e-comm/pb/authz"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type UserService struct {
-	repo repository.UserRepository
+	repo       repository.UserRepository
+	redisCache *cache.RedisCache
+	env        *config.Env
 }
 
-func NewUserService(repo repository.UserRepository) *UserService {
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, all sessions of this login were revoked")
+	ErrUnknownRole         = errors.New("unknown role")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrEmailNotVerified    = errors.New("email address is not verified")
+	// ErrInvalidCredentials is returned for unknown emails and wrong
+	// passwords alike, so logins do not tell which accounts exist
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// AccountLockedError is returned while an account is locked after too many
+// failed logins
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account is locked after too many failed logins, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// NewUserService creates the service. Users of env.ADMIN_EMAILS are granted
+// the admin role once their address is verified.
+func NewUserService(repo repository.UserRepository, redisCache *cache.RedisCache, env *config.Env) *UserService {
 	return &UserService{
-		repo: repo,
+		repo:       repo,
+		redisCache: redisCache,
+		env:        env,
+	}
+}
+
+// rolesOf returns the roles of the user, treating users created before roles
+// existed as customers
+func rolesOf(user *model.User) []string {
+	if len(user.Roles) == 0 {
+		return []string{authz.RoleCustomer}
+	}
+	return user.Roles
+}
+
+// issueTokens mints a new token pair for the user and starts a new refresh
+// token family for it. The family is the session of this login, recorded
+// with the client it came from.
+func (u *UserService) issueTokens(ctx context.Context, user *model.User, client model.ClientInfo) error {
+	familyID, err := helper.NewTokenID()
+	if err != nil {
+		return fmt.Errorf("failed to generate token family: %w", err)
+	}
+	token, refreshToken, err := helper.GenerateToken(user.User_id, *user.Email, rolesOf(user), familyID)
+	if err != nil {
+		return fmt.Errorf("failed to generate token: %w", err)
+	}
+	refreshHash, _ := helper.HashToken(refreshToken)
+	if err := u.redisCache.SaveRefreshFamily(ctx, familyID, refreshHash, helper.RefreshTokenTTL()); err != nil {
+		return fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	u.saveSession(ctx, user.User_id, familyID, client)
+
+	user.Token = &token
+	user.Refresh_Token = &refreshToken
+	return nil
+}
+
+// newUserRecord builds a new user document. It is always a customer, the
+// admin role of ADMIN_EMAILS is only granted by grantConfiguredAdmin once the
+// address is verified.
+func (u *UserService) newUserRecord(email string, hashedPassword string) *model.User {
+	now := time.Now()
+	id := primitive.NewObjectID()
+
+	return &model.User{
+		ID:         id,
+		Password:   &hashedPassword,
+		Email:      &email,
+		Created_at: now,
+		Updated_at: now,
+		User_id:    id.Hex(),
+		Roles:      []string{authz.RoleCustomer},
+	}
+}
+
+// grantConfiguredAdmin gives the admin role to a user whose address is one
+// of env.ADMIN_EMAILS. Until the address is verified anyone could have
+// registered it, so unverified users are left alone.
+func grantConfiguredAdmin(repo repository.UserRepository, env *config.Env, user *model.User) error {
+	if user.Email == nil || !user.Email_verified || slices.Contains(user.Roles, authz.RoleAdmin) {
+		return nil
+	}
+	if !slices.Contains(env.ADMIN_EMAILS, strings.ToLower(*user.Email)) {
+		return nil
+	}
+	roles := append(rolesOf(user), authz.RoleAdmin)
+	if err := repo.SetRoles(user.User_id, roles); err != nil {
+		return fmt.Errorf("failed to grant admin to %s: %w", *user.Email, err)
+	}
+	user.Roles = roles
+	log.Printf("Granted admin role to %s", *user.Email)
+	return nil
+}
+
+// RegisterExternalUser creates an account for someone signing in with a
+// login provider for the first time. It gets a random password, which the
+// user can replace through the password reset flow.
+func (u *UserService) RegisterExternalUser(email string, identity model.ExternalIdentity) (*model.User, error) {
+	exist, err := u.repo.CheckIfEmailExist(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check user existence: %w", err)
+	}
+	if exist {
+		return nil, errors.New("user already exists")
+	}
+
+	password, err := helper.NewTokenID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+	hashedPassword, err := helper.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	newUser := u.newUserRecord(email, hashedPassword)
+	newUser.Email_verified = true
+	newUser.Identities = []model.ExternalIdentity{identity}
+	if err := u.repo.Register(newUser); err != nil {
+		return nil, err
+	}
+	if err := grantConfiguredAdmin(u.repo, u.env, newUser); err != nil {
+		log.Printf("Admin grant failed: %v", err)
 	}
+	return newUser, nil
 }
 
-func (u *UserService) RegisterUser(user *model.User) error {
+func (u *UserService) RegisterUser(user *model.User, client model.ClientInfo) (*model.User, error) {
 	if user.Email == nil || user.Password == nil {
-		return errors.New("email and password are required")
+		return nil, errors.New("email and password are required")
 	}
 
 	exist, err := u.repo.CheckIfEmailExist(*user.Email)
 	if err != nil {
-		return fmt.Errorf("failed to check user existence: %w", err)
+		return nil, fmt.Errorf("failed to check user existence: %w", err)
 	}
 	if exist {
-		return errors.New("user already exists")
+		return nil, errors.New("user already exists")
 	}
 
 	hashedPassword, err := helper.HashPassword(*user.Password)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	now := time.Now()
-	user.ID = primitive.NewObjectID()
-	user.User_id = user.ID.Hex()
+	newUser := u.newUserRecord(*user.Email, hashedPassword)
+	user.ID = newUser.ID
+	user.User_id = newUser.User_id
 
-	token, refreshToken, err := helper.GenerateToken(user.User_id, *user.Email)
-	if err != nil {
-		return fmt.Errorf("failed to generate token: %w", err)
+	// Users that have to verify their address first get their tokens at
+	// the first login after verifying it
+	if !u.env.REQUIRE_EMAIL_VERIFICATION {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := u.issueTokens(ctx, newUser, client); err != nil {
+			return nil, err
+		}
 	}
-
-	newUser := &model.User{
-		ID:            user.ID,
-		Password:      &hashedPassword,
-		Email:         user.Email,
-		Token:         &token,
-		Refresh_Token: &refreshToken,
-		Created_at:    now,
-		Updated_at:    now,
-		User_id:       user.User_id,
+	if err := u.repo.Register(newUser); err != nil {
+		return nil, err
 	}
-	return u.repo.Register(newUser)
+	return newUser, nil
 }
 
-func (u *UserService) LoginUser(user *model.User) (*model.User, error) {
+// dummyPasswordHash is compared against when the email of a login is
+// unknown. It is hashed with the cost of real passwords on first use.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := helper.HashPassword("dummy password")
+	return hash
+})
+
+// LoginUser checks the credentials and issues a token pair. For users with
+// two-factor authentication it issues no tokens and returns a challenge
+// instead, to be passed to CompleteMFALogin together with a code.
+func (u *UserService) LoginUser(user *model.User, client model.ClientInfo) (*model.User, string, error) {
 	//checking the user Exits
 	if user.Email == nil || user.Password == nil {
-		return nil, errors.New("email and password are required")
+		return nil, "", errors.New("email and password are required")
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := u.checkLockout(ctx, *user.Email); err != nil {
+		return nil, "", err
+	}
+
 	storedUser, err := u.repo.FindUserByEmail(*user.Email)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find user by email: %w", err)
+		return nil, "", fmt.Errorf("failed to find user by email: %w", err)
 	}
+	// Unknown emails count as failures too, so lockouts don't tell which
+	// accounts exist
 	if storedUser == nil {
-		return nil, errors.New("user not found")
+		// Spend the time of a password check so the response time does not
+		// tell either
+		helper.CheckPasswordHash(*user.Password, dummyPasswordHash())
+		if err := u.loginFailed(ctx, *user.Email); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrInvalidCredentials
 	}
 	isPasswordOk := helper.CheckPasswordHash(*user.Password, *storedUser.Password)
 	if !isPasswordOk {
-		return nil, errors.New("password is incorrect")
+		if err := u.loginFailed(ctx, *user.Email); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrInvalidCredentials
+	}
+	if u.env.REQUIRE_EMAIL_VERIFICATION && !storedUser.Email_verified {
+		return nil, "", ErrEmailNotVerified
+	}
+
+	return u.CompleteLogin(ctx, storedUser, client)
+}
+
+// CompleteLogin finishes the login of a user whose first factor was checked,
+// by password or by a login provider. Like LoginUser it returns either the
+// user with a fresh token pair or an MFA challenge.
+func (u *UserService) CompleteLogin(ctx context.Context, user *model.User, client model.ClientInfo) (*model.User, string, error) {
+	if user.Mfa_enabled {
+		challenge, err := u.newMFAChallenge(ctx, user)
+		if err != nil {
+			return nil, "", err
+		}
+		return nil, challenge, nil
+	}
+	if err := u.issueTokens(ctx, user, client); err != nil {
+		return nil, "", err
 	}
-	token, refreshToken, err := helper.GenerateToken(storedUser.User_id, *storedUser.Email)
+	u.loginSucceeded(ctx, user)
+	loggedInUser, err := u.repo.Login(user)
+	return loggedInUser, "", err
+}
+
+func (u *UserService) lockoutPolicy() cache.LockoutPolicy {
+	return cache.LockoutPolicy{
+		MaxFailures: u.env.RATE_LIMIT_MAX_ATTEMPTS,
+		Window:      time.Duration(u.env.RATE_LIMIT_WINDOW_MINUTES) * time.Minute,
+		Base:        time.Duration(u.env.LOCKOUT_BASE_MINUTES) * time.Minute,
+		Max:         time.Duration(u.env.LOCKOUT_MAX_MINUTES) * time.Minute,
+	}
+}
+
+// checkLockout returns an AccountLockedError while the account is locked.
+// Redis errors let the login through rather than locking everyone out.
+func (u *UserService) checkLockout(ctx context.Context, email string) error {
+	lockedFor, err := u.redisCache.AccountLockedFor(ctx, email)
+	if err != nil {
+		log.Printf("Failed to check lockout of %s: %v", email, err)
+		return nil
+	}
+	if lockedFor > 0 {
+		return &AccountLockedError{RetryAfter: lockedFor}
+	}
+	return nil
+}
+
+// loginFailed records a failed login and returns an AccountLockedError if
+// the account got locked by it
+func (u *UserService) loginFailed(ctx context.Context, email string) error {
+	lockedFor, err := u.redisCache.RecordLoginFailure(ctx, email, u.lockoutPolicy())
+	if err != nil {
+		log.Printf("Failed to record login failure of %s: %v", email, err)
+		return nil
+	}
+	if lockedFor > 0 {
+		log.Printf("Account %s locked for %s after repeated failed logins", email, lockedFor)
+		return &AccountLockedError{RetryAfter: lockedFor}
+	}
+	return nil
+}
+
+// loginSucceeded clears the failed logins of the user. Logging in during the
+// grace period of a deleted account restores it.
+func (u *UserService) loginSucceeded(ctx context.Context, user *model.User) {
+	if err := u.redisCache.ResetLoginFailures(ctx, *user.Email); err != nil {
+		log.Printf("Failed to reset login failures of %s: %v", *user.Email, err)
+	}
+	if user.Deleted_at != nil {
+		if err := u.repo.CancelDeletion(user.User_id); err != nil {
+			log.Printf("Failed to restore deleted account %s: %v", user.User_id, err)
+			return
+		}
+		user.Deleted_at, user.Purge_at = nil, nil
+		log.Printf("Restored account %s scheduled for deletion", user.User_id)
+	}
+}
+
+// RefreshTokens exchanges a refresh token for a new access/refresh pair. The
+// presented token is rotated out; replaying it later revokes the whole family.
+func (u *UserService) RefreshTokens(ctx context.Context, refreshToken string, client model.ClientInfo) (*model.User, error) {
+	claims, err := helper.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if claims.IssuedAt == nil || u.redisCache.IsUserTokenRevoked(ctx, claims.Uid, claims.IssuedAt.Time, claims.Family) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	storedUser, err := u.repo.Profile(&model.User{User_id: claims.Uid})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	token, newRefreshToken, err := helper.GenerateToken(storedUser.User_id, *storedUser.Email, rolesOf(storedUser), claims.Family)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
+	oldHash, _ := helper.HashToken(refreshToken)
+	newHash, _ := helper.HashToken(newRefreshToken)
+	err = u.redisCache.RotateRefreshToken(ctx, claims.Family, oldHash, newHash, helper.RefreshTokenTTL())
+	if err != nil {
+		if errors.Is(err, cache.ErrRefreshTokenReused) {
+			log.Printf("Refresh token reuse detected for user %s, family %s revoked", claims.Uid, claims.Family)
+			return nil, ErrRefreshTokenReused
+		}
+		if errors.Is(err, cache.ErrRefreshFamilyNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	u.refreshSession(ctx, storedUser.User_id, claims.Family, client)
+
 	storedUser.Token = &token
-	storedUser.Refresh_Token = &refreshToken
-	return u.repo.Login(storedUser)
+	storedUser.Refresh_Token = &newRefreshToken
+	if err := u.repo.UpdateTokens(storedUser); err != nil {
+		return nil, fmt.Errorf("failed to store tokens: %w", err)
+	}
+	return storedUser, nil
 }
 
-// func (u *UserService) LogoutUser(user *model.User) error {
-// 	return u.repo.Logout(user)
-// }
+// revokeAccessToken blacklists the access token for the rest of its lifetime
+// and drops it from the validation cache
+func (u *UserService) revokeAccessToken(ctx context.Context, token string, claims *helper.JWTAccessClaims) error {
+	tokenHash, _ := helper.HashToken(token)
+	ttl := helper.RefreshTokenTTL()
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	if ttl > 0 {
+		if err := u.redisCache.BlacklistToken(ctx, tokenHash, ttl); err != nil {
+			return fmt.Errorf("failed to blacklist token: %w", err)
+		}
+	}
+	u.redisCache.RemoveValidToken(ctx, tokenHash)
+	return nil
+}
 
-func (u *UserService) Profile(user *model.User) (*model.User, error) {
-	if user.Email == nil {
-		return nil, errors.New("email is not defined")
+// LogoutUser ends the session the access token belongs to: the token itself is
+// blacklisted and the session with its refresh token family is revoked
+func (u *UserService) LogoutUser(ctx context.Context, token string) error {
+	claims, err := helper.ValidateToken(token)
+	if err != nil {
+		return err
 	}
-	storedUser, err := u.repo.FindUserByEmail(*user.Email)
+	if err := u.revokeAccessToken(ctx, token, claims); err != nil {
+		return err
+	}
+	if claims.Family != "" {
+		if err := u.endSession(ctx, claims.Uid, claims.Family); err != nil {
+			return err
+		}
+	}
+	return u.repo.Logout(&model.User{User_id: claims.Uid})
+}
+
+// LogoutAllSessions revokes every access and refresh token issued to the user
+// up to now, across all devices
+func (u *UserService) LogoutAllSessions(ctx context.Context, token string) error {
+	claims, err := helper.ValidateToken(token)
+	if err != nil {
+		return err
+	}
+	if err := u.revokeAccessToken(ctx, token, claims); err != nil {
+		return err
+	}
+	if err := u.redisCache.RevokeUserTokens(ctx, claims.Uid, time.Now(), helper.RefreshTokenTTL()); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return u.repo.Logout(&model.User{User_id: claims.Uid})
+}
+
+// BootstrapAdmins grants the admin role to the existing users configured in
+// ADMIN_EMAILS so a fresh deployment always has an administrator. Users that
+// did not verify their address yet get it when they do.
+func (u *UserService) BootstrapAdmins() error {
+	for _, email := range u.env.ADMIN_EMAILS {
+		user, err := u.repo.FindUserByEmail(email)
+		if err != nil {
+			return fmt.Errorf("failed to find admin %s: %w", email, err)
+		}
+		if user == nil {
+			continue
+		}
+		if !user.Email_verified {
+			log.Printf("Not granting admin role to %s until the address is verified", email)
+			continue
+		}
+		if err := grantConfiguredAdmin(u.repo, u.env, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *UserService) ListUsers() ([]*model.UserSummary, error) {
+	users, err := u.repo.ListUsers()
 	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	summaries := make([]*model.UserSummary, 0, len(users))
+	for _, user := range users {
+		summary := &model.UserSummary{
+			User_id:    user.User_id,
+			Roles:      rolesOf(user),
+			Created_at: user.Created_at,
+		}
+		if user.Email != nil {
+			summary.Email = *user.Email
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// SetUserRoles replaces the roles of a user. All tokens of the user are
+// revoked so a removed role cannot be used until the tokens expire.
+func (u *UserService) SetUserRoles(ctx context.Context, userID string, roles []string) error {
+	if len(roles) == 0 {
+		return ErrUnknownRole
+	}
+	for _, role := range roles {
+		if !authz.IsKnownRole(role) {
+			return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+		}
+	}
+	if err := u.repo.SetRoles(userID, roles); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, errors.New("user not found")
+			return ErrUserNotFound
 		}
-		return nil, fmt.Errorf("failed to find user by email: %w", err)
+		return fmt.Errorf("failed to set roles: %w", err)
+	}
+	if err := u.redisCache.RevokeUserTokens(ctx, userID, time.Now(), helper.RefreshTokenTTL()); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
 	}
-	return u.repo.Profile(storedUser)
+	return nil
 }
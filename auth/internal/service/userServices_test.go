@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/sachinggsingh/e-comm/internal/helper"
+	"github.com/sachinggsingh/e-comm/internal/model"
+)
+
+func TestLoginDoesNotTellUnknownEmailsFromWrongPasswords(t *testing.T) {
+	env := useTestEnv(t)
+	redisCache, _ := newTestCache(t)
+	email := "user@example.com"
+	hash, err := helper.HashPassword("right password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := NewUserService(newFakeUserRepo(&model.User{User_id: "u1", Email: &email, Password: &hash}), redisCache, env)
+
+	for _, attempt := range []struct{ email, password string }{
+		{"nobody@example.com", "right password"},
+		{email, "wrong password"},
+	} {
+		_, _, err := users.LoginUser(&model.User{Email: &attempt.email, Password: &attempt.password}, model.ClientInfo{})
+		if err != ErrInvalidCredentials {
+			t.Errorf("login as %s: got %v, want %v", attempt.email, err, ErrInvalidCredentials)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/money"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
@@ -72,7 +73,7 @@ func main() {
 	fmt.Printf("  ID:          %s\n", res.Id)
 	fmt.Printf("  Name:        %s\n", res.Name)
 	fmt.Printf("  Description: %s\n", res.Description)
-	fmt.Printf("  Price:       $%.2f\n", res.Price)
+	fmt.Printf("  Price:       %s\n", money.New(res.PriceAmount, res.Currency))
 
 	fmt.Println("\n" + strings.Repeat("=", 62))
 	fmt.Println("gRPC client is working perfectly!")
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 
 	restapi "github.com/sachinggsingh/e-comm/internal/api"
@@ -10,6 +11,7 @@ import (
 	"github.com/sachinggsingh/e-comm/internal/pkg/payment"
 	"github.com/sachinggsingh/e-comm/internal/repository"
 	"github.com/sachinggsingh/e-comm/internal/service"
+	"github.com/sachinggsingh/e-comm/pb/money"
 )
 
 func main() {
@@ -28,16 +30,62 @@ func main() {
 	}
 	defer productClient.Close()
 
+	// Initialize auth gRPC client used to check token revocation
+	authClient, err := pkg.NewAuthClient(env.AUTH_SERVICE_URL)
+	if err != nil {
+		log.Fatalf("Failed to initialize auth gRPC client: %v", err)
+	}
+	defer authClient.Close()
+
+	// Initialize order gRPC client, checkouts place their orders with it
+	orderClient, err := pkg.NewOrderClient(env.ORDER_SERVICE_URL)
+	if err != nil {
+		log.Fatalf("Failed to initialize order gRPC client: %v", err)
+	}
+	defer orderClient.Close()
+
 	// Initialize Stripe payment client
 	paymentClient := payment.NewPaymentClient(
 		env.STRIPE_SECRET_KEY,
+		env.STRIPE_WEBHOOK_SECRET,
 		env.STRIPE_SUCCESS_URL,
 		env.STRIPE_FAILURE_URL,
 	)
 
+	pricing, err := newPricing(env)
+	if err != nil {
+		log.Fatalf("Failed to initialize pricing: %v", err)
+	}
+
 	server := restapi.NewServer(env, database)
 	repo := repository.NewCartRepository(database)
-	cartService := service.NewCartService(repo, productClient)
-	server.CartRoute(cartService, paymentClient)
+	cartService := service.NewCartService(repo, productClient, orderClient, pricing)
+	if err := cartService.MigratePrices(); err != nil {
+		log.Fatalf("Failed to migrate prices: %v", err)
+	}
+	server.CartRoute(cartService, paymentClient, authClient)
 	server.StartServer()
 }
+
+// newPricing sets up the currency conversion of carts: rates from the
+// remote endpoint, or the file, cached for the configured time
+func newPricing(env *config.Env) (service.Pricing, error) {
+	rounding, err := money.ParseRoundingRules(env.CURRENCY_ROUNDING)
+	if err != nil {
+		return service.Pricing{}, fmt.Errorf("CURRENCY_ROUNDING: %w", err)
+	}
+	pricing := service.Pricing{Currency: env.DEFAULT_CURRENCY, Rounding: rounding}
+	switch {
+	case env.EXCHANGE_RATES_URL != "":
+		pricing.Rates = money.NewCachedRates(money.NewRemoteRates(env.EXCHANGE_RATES_URL, nil), env.EXCHANGE_RATES_TTL)
+	case env.EXCHANGE_RATES_FILE != "":
+		rates, err := money.LoadStaticRates(env.EXCHANGE_RATES_FILE)
+		if err != nil {
+			return service.Pricing{}, fmt.Errorf("EXCHANGE_RATES_FILE: %w", err)
+		}
+		pricing.Rates = rates
+	default:
+		log.Println("No exchange rates configured, carts only take prices in their currency")
+	}
+	return pricing, nil
+}
@@ -5,13 +5,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"time"
 
 	carterrors "github.com/sachinggsingh/e-comm/internal/errors"
 	"github.com/sachinggsingh/e-comm/internal/middleware"
 	"github.com/sachinggsingh/e-comm/internal/model"
 	"github.com/sachinggsingh/e-comm/internal/pkg/payment"
 	"github.com/sachinggsingh/e-comm/internal/service"
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"github.com/stripe/stripe-go/v74"
 )
 
 type CartHandler struct {
@@ -19,18 +24,32 @@ type CartHandler struct {
 	paymentClient payment.PaymentClient
 }
 
+// CartItemRequest is an item to put in the cart. Price is a money object or
+// a decimal of money.DefaultCurrency; it is replaced by the price of the
+// product service.
 type CartItemRequest struct {
-	Product_id string  `json:"product_id"`
-	Price      float64 `json:"price"`
-	Quantity   int     `json:"quantity"`
+	Product_id string      `json:"product_id"`
+	Sku        string      `json:"sku"`
+	Price      money.Money `json:"price"`
+	Quantity   int         `json:"quantity"`
 }
 
+// CreateCartRequest creates a cart priced in Currency, the default currency
+// when empty. An existing cart is switched to Currency when it is set, and
+// the items are added to it.
 type CreateCartRequest struct {
-	Items []CartItemRequest `json:"items"`
+	Items    []CartItemRequest `json:"items"`
+	Currency string            `json:"currency"`
 }
 
+// UpdateCartRequest replaces the items, priced in Currency when set
 type UpdateCartRequest struct {
-	Items []CartItemRequest `json:"items"`
+	Items    []CartItemRequest `json:"items"`
+	Currency string            `json:"currency"`
+}
+
+type SetCurrencyRequest struct {
+	Currency string `json:"currency"`
 }
 
 func NewCartHandler(cartService *service.CartService, paymentClient payment.PaymentClient) *CartHandler {
@@ -60,21 +79,27 @@ func (c *CartHandler) CreateCart(w http.ResponseWriter, r *http.Request) {
 	for _, item := range req.Items {
 		items = append(items, model.CartItem{
 			Product_id: item.Product_id,
+			Sku:        item.Sku,
 			Price:      item.Price,
 			Quantity:   item.Quantity,
 		})
 	}
 
-	cart, err := c.cartService.CreateCart(userID, items)
+	cart, err := c.cartService.CreateCart(userID, items, req.Currency)
 	if err != nil {
 		if errors.Is(err, carterrors.ErrInvalidUserID) ||
 			errors.Is(err, carterrors.ErrEmptyCart) ||
 			errors.Is(err, carterrors.ErrInvalidItem) ||
 			errors.Is(err, carterrors.ErrInvalidQuantity) ||
-			errors.Is(err, carterrors.ErrInvalidPrice) {
+			errors.Is(err, carterrors.ErrInvalidPrice) ||
+			errors.Is(err, carterrors.ErrInvalidCurrency) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, carterrors.ErrOutOfStock) || errors.Is(err, carterrors.ErrCheckoutPending) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -128,12 +153,13 @@ func (c *CartHandler) UpdateCart(w http.ResponseWriter, r *http.Request) {
 	for _, item := range req.Items {
 		items = append(items, model.CartItem{
 			Product_id: item.Product_id,
+			Sku:        item.Sku,
 			Price:      item.Price,
 			Quantity:   item.Quantity,
 		})
 	}
 
-	cart, err := c.cartService.UpdateCart(userID, items)
+	cart, err := c.cartService.UpdateCart(userID, items, req.Currency)
 	if err != nil {
 		if errors.Is(err, carterrors.ErrCartNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -143,10 +169,15 @@ func (c *CartHandler) UpdateCart(w http.ResponseWriter, r *http.Request) {
 			errors.Is(err, carterrors.ErrEmptyCart) ||
 			errors.Is(err, carterrors.ErrInvalidItem) ||
 			errors.Is(err, carterrors.ErrInvalidQuantity) ||
-			errors.Is(err, carterrors.ErrInvalidPrice) {
+			errors.Is(err, carterrors.ErrInvalidPrice) ||
+			errors.Is(err, carterrors.ErrInvalidCurrency) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, carterrors.ErrOutOfStock) || errors.Is(err, carterrors.ErrCheckoutPending) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -156,6 +187,49 @@ func (c *CartHandler) UpdateCart(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(cart)
 }
 
+// SetCurrency switches the cart to the currency of the body and prices its
+// items in it again
+func (c *CartHandler) SetCurrency(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "user_id claim missing or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	var req SetCurrencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cart, err := c.cartService.SetCurrency(userID, req.Currency)
+	if err != nil {
+		if errors.Is(err, carterrors.ErrCartNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, carterrors.ErrInvalidUserID) ||
+			errors.Is(err, carterrors.ErrInvalidItem) ||
+			errors.Is(err, carterrors.ErrInvalidPrice) ||
+			errors.Is(err, carterrors.ErrInvalidCurrency) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, carterrors.ErrOutOfStock) || errors.Is(err, carterrors.ErrCheckoutPending) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(cart)
+}
+
+// DeleteCart deletes the cart, cancelling its pending checkout first. A
+// checkout already paid must be confirmed instead.
 func (c *CartHandler) DeleteCart(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
 	if !ok {
@@ -163,7 +237,24 @@ func (c *CartHandler) DeleteCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := c.cartService.DeleteCart(userID)
+	cart, err := c.cartService.FindCartByUserID(userID)
+	if err != nil {
+		if errors.Is(err, carterrors.ErrCartNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, carterrors.ErrInvalidUserID) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if cart.Checkout_session_id != "" && !c.abandonCheckout(w, cart, "cart deleted") {
+		return
+	}
+
+	err = c.cartService.DeleteCart(userID)
 	if err != nil {
 		if errors.Is(err, carterrors.ErrCartNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -173,6 +264,10 @@ func (c *CartHandler) DeleteCart(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, carterrors.ErrCheckoutPending) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -208,25 +303,254 @@ func (c *CartHandler) CheckoutCart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := context.Background()
+
+	// An earlier checkout must not be paid once its stock is given back
+	if cart.Checkout_session_id != "" {
+		status, err := c.paymentClient.CheckPaymentStatus(cart.Checkout_session_id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to check previous payment: %v", err), http.StatusInternalServerError)
+			return
+		}
+		switch *status {
+		case stripe.CheckoutSessionStatusComplete:
+			http.Error(w, "the previous checkout was paid, confirm it first", http.StatusConflict)
+			return
+		case stripe.CheckoutSessionStatusOpen:
+			if err := c.paymentClient.ExpirePayment(cart.Checkout_session_id); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		c.cartService.CancelOrder(ctx, cart.Order_id, "replaced by a new checkout")
+	}
+
+	// Hold the stock before taking payment so we never sell what we lack
+	reservationID, err := c.cartService.ReserveCart(ctx, cart)
+	if err != nil {
+		if errors.Is(err, carterrors.ErrOutOfStock) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("failed to reserve stock: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	paymentItems, err := c.cartService.PreparePaymentItems(ctx, cart)
 	if err != nil {
+		c.cartService.ReleaseReservation(ctx, reservationID)
 		http.Error(w, fmt.Sprintf("failed to prepare payment items: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	session, err := c.paymentClient.CreatePayment(paymentItems, userID, cart.Cart_id)
+	// Record what is bought, at the prices charged, before taking payment
+	email, _ := r.Context().Value(middleware.UserEmailKey).(string)
+	orderID, err := c.cartService.PlaceOrder(ctx, cart, email, reservationID, paymentItems)
+	if err != nil {
+		c.cartService.ReleaseReservation(ctx, reservationID)
+		http.Error(w, fmt.Sprintf("failed to place order: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	session, err := c.paymentClient.CreatePayment(paymentItems, userID, orderID, reservationID, time.Now().Add(service.CheckoutPaymentWindow))
 	if err != nil {
+		c.cartService.CancelOrder(ctx, orderID, "payment session could not be created")
+		c.cartService.ReleaseReservation(ctx, reservationID)
 		http.Error(w, fmt.Sprintf("failed to create payment session: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	if err := c.cartService.StartCheckout(userID, reservationID, session.ID, orderID); err != nil {
+		c.paymentClient.ExpirePayment(session.ID)
+		c.cartService.CancelOrder(ctx, orderID, "checkout could not be started")
+		c.cartService.ReleaseReservation(ctx, reservationID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	response := map[string]any{
 		"checkout_url": session.URL,
 		"session_id":   session.ID,
 		"cart_id":      cart.Cart_id,
+		"order_id":     orderID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// ConfirmCheckout finishes the pending checkout of the cart: a paid one
+// marks its order paid, keeps its stock sold and empties the cart, an
+// expired one cancels its order and gives the stock back. An open one is
+// left alone.
+func (c *CartHandler) ConfirmCheckout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "user_id claim missing or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	cart, err := c.cartService.FindCartByUserID(userID)
+	if err != nil {
+		if errors.Is(err, carterrors.ErrCartNotFound) {
+			http.Error(w, "cart not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if cart.Checkout_session_id == "" {
+		http.Error(w, carterrors.ErrNoCheckout.Error(), http.StatusConflict)
+		return
+	}
+
+	status, err := c.paymentClient.CheckPaymentStatus(cart.Checkout_session_id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to check payment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx := context.Background()
+	switch *status {
+	case stripe.CheckoutSessionStatusComplete:
+		if err := c.cartService.CompleteCheckout(ctx, cart); err != nil {
+			if errors.Is(err, carterrors.ErrReservationLost) {
+				log.Printf("Checkout %s of cart %s was paid after its stock was released", cart.Checkout_session_id, cart.Cart_id)
+				http.Error(w, "payment received after the stock hold expired, the order is flagged for a refund", http.StatusConflict)
+				return
+			}
+			http.Error(w, fmt.Sprintf("failed to complete checkout: %v", err), http.StatusInternalServerError)
+			return
+		}
+	case stripe.CheckoutSessionStatusExpired:
+		if err := c.cartService.CancelCheckout(ctx, cart, "payment expired"); err != nil {
+			http.Error(w, fmt.Sprintf("failed to cancel checkout: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":   *status,
+		"cart_id":  cart.Cart_id,
+		"order_id": cart.Order_id,
+	})
+}
+
+// CancelCheckout cancels the pending checkout of the cart: its payment
+// session is closed, its order cancelled and its stock given back, and the
+// cart can be changed again. A checkout already paid must be confirmed
+// instead.
+func (c *CartHandler) CancelCheckout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "user_id claim missing or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	cart, err := c.cartService.FindCartByUserID(userID)
+	if err != nil {
+		if errors.Is(err, carterrors.ErrCartNotFound) {
+			http.Error(w, "cart not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if cart.Checkout_session_id == "" {
+		http.Error(w, carterrors.ErrNoCheckout.Error(), http.StatusConflict)
+		return
+	}
+	if !c.abandonCheckout(w, cart, "checkout cancelled") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":   "cancelled",
+		"cart_id":  cart.Cart_id,
+		"order_id": cart.Order_id,
+	})
+}
+
+// abandonCheckout closes the payment session of the pending checkout of the
+// cart and cancels the checkout for reason. It answers the request and
+// returns false when it cannot: a paid checkout is answered with 409.
+func (c *CartHandler) abandonCheckout(w http.ResponseWriter, cart *model.Cart, reason string) bool {
+	status, err := c.paymentClient.CheckPaymentStatus(cart.Checkout_session_id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to check payment: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	switch *status {
+	case stripe.CheckoutSessionStatusComplete:
+		http.Error(w, "the checkout was paid, confirm it first", http.StatusConflict)
+		return false
+	case stripe.CheckoutSessionStatusOpen:
+		if err := c.paymentClient.ExpirePayment(cart.Checkout_session_id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return false
+		}
+	}
+	if err := c.cartService.CancelCheckout(context.Background(), cart, reason); err != nil {
+		http.Error(w, fmt.Sprintf("failed to cancel checkout: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// maxWebhookBody is the largest webhook request read, as Stripe advises
+const maxWebhookBody = 65536
+
+// StripeWebhook settles checkouts from the Stripe events of their payment
+// sessions, so a checkout is completed even when the customer never comes
+// back to confirm it. The checkout is read from the metadata of the
+// session, not from the cart, which may be gone. Events failing to settle are answered with 500 for
+// Stripe to send them again.
+func (c *CartHandler) StripeWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	event, err := c.paymentClient.VerifyEvent(payload, r.Header.Get("Stripe-Signature"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.expired":
+	default:
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var session stripe.CheckoutSession
+	if err := json.Unmarshal(event.Data.Raw, &session); err != nil {
+		http.Error(w, "invalid checkout session", http.StatusBadRequest)
+		return
+	}
+	userID := session.Metadata["user_id"]
+	orderID, reservationID := session.Metadata["order_id"], session.Metadata["reservation_id"]
+
+	ctx := context.Background()
+	if event.Type == "checkout.session.expired" {
+		err = c.cartService.ExpireCheckout(ctx, userID, session.ID, orderID, reservationID)
+	} else if session.PaymentStatus == stripe.CheckoutSessionPaymentStatusPaid {
+		err = c.cartService.SettleCheckout(ctx, userID, session.ID, orderID, reservationID)
+	}
+	if err != nil {
+		if errors.Is(err, carterrors.ErrReservationLost) {
+			// Stripe sending it again would not bring the stock back
+			log.Printf("Checkout %s of user %s was paid after its stock was released", session.ID, userID)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		log.Printf("Failed to settle checkout %s: %v", session.ID, err)
+		http.Error(w, "failed to settle checkout", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -10,8 +10,10 @@ import (
 	"github.com/sachinggsingh/e-comm/internal/config"
 	"github.com/sachinggsingh/e-comm/internal/intra/db"
 	"github.com/sachinggsingh/e-comm/internal/middleware"
+	"github.com/sachinggsingh/e-comm/internal/pkg"
 	"github.com/sachinggsingh/e-comm/internal/pkg/payment"
 	"github.com/sachinggsingh/e-comm/internal/service"
+	"github.com/sachinggsingh/e-comm/pb/jwks"
 )
 
 type Server struct {
@@ -37,21 +39,36 @@ func (s *Server) StartServer() {
 	}
 }
 
-func (s *Server) CartRoute(cartservice *service.CartService, paymentClient payment.PaymentClient) {
+func (s *Server) CartRoute(cartservice *service.CartService, paymentClient payment.PaymentClient, authClient *pkg.AuthClient) {
 	cartHandler := restapi.NewCartHandler(cartservice, paymentClient)
+	authMiddleware := middleware.NewAuthMiddleware(authClient, jwks.NewCache(s.env.AUTH_JWKS_URL))
 
 	// Create cart - requires authentication
-	s.r.Handle("/cart", middleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.CreateCart))).Methods("POST")
+	s.r.Handle("/cart", authMiddleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.CreateCart))).Methods("POST")
+
+	// Set the cart currency - requires authentication. Registered before the
+	// user_id routes, which would take "currency" for a user id.
+	s.r.Handle("/cart/currency", authMiddleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.SetCurrency))).Methods("PUT")
 
 	// Get cart by user ID - requires authentication
-	s.r.Handle("/cart/{user_id}", middleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.FindCartByUserID))).Methods("GET")
+	s.r.Handle("/cart/{user_id}", authMiddleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.FindCartByUserID))).Methods("GET")
 
 	// Update cart - requires authentication
-	s.r.Handle("/cart/{user_id}", middleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.UpdateCart))).Methods("PUT")
+	s.r.Handle("/cart/{user_id}", authMiddleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.UpdateCart))).Methods("PUT")
+
+	// Cancel the pending checkout - requires authentication. Registered
+	// before the user_id routes, which would take "checkout" for a user id.
+	s.r.Handle("/cart/checkout", authMiddleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.CancelCheckout))).Methods("DELETE")
 
 	// Delete cart - requires authentication
-	s.r.Handle("/cart/{user_id}", middleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.DeleteCart))).Methods("DELETE")
+	s.r.Handle("/cart/{user_id}", authMiddleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.DeleteCart))).Methods("DELETE")
 
 	// Checkout cart - creates Stripe payment session - requires authentication
-	s.r.Handle("/cart/checkout", middleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.CheckoutCart))).Methods("POST")
+	s.r.Handle("/cart/checkout", authMiddleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.CheckoutCart))).Methods("POST")
+
+	// Confirm checkout - commits the held stock once paid - requires authentication
+	s.r.Handle("/cart/checkout/confirm", authMiddleware.GetUserIdFromToken(http.HandlerFunc(cartHandler.ConfirmCheckout))).Methods("POST")
+
+	// Stripe webhook - settles checkouts from their payment events - signed by Stripe
+	s.r.HandleFunc("/cart/webhook/stripe", cartHandler.StripeWebhook).Methods("POST")
 }
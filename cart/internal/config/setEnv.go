@@ -3,18 +3,36 @@ package config
 import (
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sachinggsingh/e-comm/pb/money"
 )
 
 type Env struct {
 	PORT                string
 	MONGO_URL           string
-	APP_SECRET          string
 	PRODUCT_SERVICE_URL string
+	AUTH_SERVICE_URL    string
+	ORDER_SERVICE_URL   string
+	AUTH_JWKS_URL       string
 	STRIPE_SECRET_KEY   string
-	STRIPE_SUCCESS_URL  string
-	STRIPE_FAILURE_URL  string
+	// STRIPE_WEBHOOK_SECRET is the signing secret of the webhook endpoint
+	// checkouts are settled from
+	STRIPE_WEBHOOK_SECRET string
+	STRIPE_SUCCESS_URL    string
+	STRIPE_FAILURE_URL    string
+	// DEFAULT_CURRENCY is the currency of carts that did not choose one
+	DEFAULT_CURRENCY string
+	// EXCHANGE_RATES_URL or, failing that, EXCHANGE_RATES_FILE give the
+	// rates to convert prices at, kept for EXCHANGE_RATES_TTL
+	EXCHANGE_RATES_URL  string
+	EXCHANGE_RATES_FILE string
+	EXCHANGE_RATES_TTL  time.Duration
+	// CURRENCY_ROUNDING are the rounding rules of converted prices, see
+	// money.ParseRoundingRules
+	CURRENCY_ROUNDING string
 }
 
 func SetEnv() *Env {
@@ -31,18 +49,30 @@ func SetEnv() *Env {
 	if mongoURL == "" {
 		log.Fatalf("MONGODB_URI is not set")
 	}
-	appSecret := os.Getenv("APP_SECRET")
-	if appSecret == "" {
-		log.Fatalf("APP_SECRET is not set")
-	}
 	productServiceURL := os.Getenv("PRODUCT_SERVICE_URL")
 	if productServiceURL == "" {
 		productServiceURL = "localhost:9091" // Default to product service gRPC port
 	}
+	authServiceURL := os.Getenv("AUTH_SERVICE_URL")
+	if authServiceURL == "" {
+		authServiceURL = "localhost:9090" // Default to auth service gRPC port
+	}
+	orderServiceURL := os.Getenv("ORDER_SERVICE_URL")
+	if orderServiceURL == "" {
+		orderServiceURL = "localhost:9092" // Default to order service gRPC port
+	}
+	authJWKSURL := os.Getenv("AUTH_JWKS_URL")
+	if authJWKSURL == "" {
+		authJWKSURL = "http://localhost:8080/.well-known/jwks.json"
+	}
 	stripeSecretKey := os.Getenv("STRIPE_SECRET_KEY")
 	if stripeSecretKey == "" {
 		log.Fatalf("STRIPE_SECRET_KEY is not set")
 	}
+	stripeWebhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+	if stripeWebhookSecret == "" {
+		log.Fatalf("STRIPE_WEBHOOK_SECRET is not set")
+	}
 	stripeSuccessURL := os.Getenv("STRIPE_SUCCESS_URL")
 	if stripeSuccessURL == "" {
 		log.Fatalf("STRIPE_SUCCESS_URL is not set")
@@ -51,13 +81,36 @@ func SetEnv() *Env {
 	if stripeFailureURL == "" {
 		log.Fatalf("STRIPE_FAILURE_URL is not set")
 	}
+	defaultCurrency := strings.ToUpper(os.Getenv("DEFAULT_CURRENCY"))
+	if defaultCurrency == "" {
+		defaultCurrency = money.DefaultCurrency
+	}
+	if _, err := money.Exponent(defaultCurrency); err != nil {
+		log.Fatalf("DEFAULT_CURRENCY: %v", err)
+	}
+	ratesTTL := time.Hour
+	if ttl := os.Getenv("EXCHANGE_RATES_TTL"); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("EXCHANGE_RATES_TTL must be a positive duration such as 1h")
+		}
+		ratesTTL = parsed
+	}
 	return &Env{
-		PORT:                port,
-		MONGO_URL:           mongoURL,
-		APP_SECRET:          appSecret,
-		PRODUCT_SERVICE_URL: productServiceURL,
-		STRIPE_SECRET_KEY:   stripeSecretKey,
-		STRIPE_SUCCESS_URL:  stripeSuccessURL,
-		STRIPE_FAILURE_URL:  stripeFailureURL,
+		PORT:                  port,
+		MONGO_URL:             mongoURL,
+		PRODUCT_SERVICE_URL:   productServiceURL,
+		AUTH_SERVICE_URL:      authServiceURL,
+		ORDER_SERVICE_URL:     orderServiceURL,
+		AUTH_JWKS_URL:         authJWKSURL,
+		STRIPE_SECRET_KEY:     stripeSecretKey,
+		STRIPE_WEBHOOK_SECRET: stripeWebhookSecret,
+		STRIPE_SUCCESS_URL:    stripeSuccessURL,
+		STRIPE_FAILURE_URL:    stripeFailureURL,
+		DEFAULT_CURRENCY:      defaultCurrency,
+		EXCHANGE_RATES_URL:    os.Getenv("EXCHANGE_RATES_URL"),
+		EXCHANGE_RATES_FILE:   os.Getenv("EXCHANGE_RATES_FILE"),
+		EXCHANGE_RATES_TTL:    ratesTTL,
+		CURRENCY_ROUNDING:     os.Getenv("CURRENCY_ROUNDING"),
 	}
 }
@@ -7,10 +7,20 @@ var (
 	ErrInvalidItem     = errors.New("invalid cart item")
 	ErrInvalidQuantity = errors.New("quantity must be greater than 0")
 	ErrInvalidPrice    = errors.New("price must be greater than 0")
+	ErrInvalidCurrency = errors.New("currency is not supported")
 	ErrEmptyCart       = errors.New("cart items cannot be empty")
+	ErrOutOfStock      = errors.New("not enough stock")
+	// ErrReservationLost means the stock held for a checkout was released
+	// before the checkout completed
+	ErrReservationLost = errors.New("stock reservation expired")
+	ErrNoCheckout      = errors.New("no checkout in progress")
+	// ErrCheckoutPending means the cart is being paid and cannot change
+	// until its checkout is confirmed or cancelled
+	ErrCheckoutPending = errors.New("a checkout of the cart is in progress, confirm or cancel it first")
 	ErrInvalidUserID   = errors.New("user_id is required")
 	ErrNoTokenProvided = errors.New("no token provided")
 	ErrInvalidToken    = errors.New("invalid token")
+	ErrTokenRevoked    = errors.New("token is expired or blacklisted")
 	ErrInGenerating    = errors.New("error in generating token")
 	DifferentTokenUsed = errors.New("uid missing: likely refresh token used instead of access token")
 )
@@ -7,56 +7,81 @@ import (
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/sachinggsingh/e-comm/internal/config"
 	"github.com/sachinggsingh/e-comm/internal/errors"
+	"github.com/sachinggsingh/e-comm/internal/pkg"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+	"github.com/sachinggsingh/e-comm/pb/jwks"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-type JWTAccessClaims struct {
-	Email string `json:"email"`
-	Uid   string `json:"uid"`
-	jwt.RegisteredClaims
-}
-
-type JWTRefreshClaims struct {
-	Uid string `json:"uid"`
-	jwt.RegisteredClaims
-}
-
 type contextKey string
 
-const UserIDKey contextKey = "uid"
+const (
+	UserIDKey    contextKey = "uid"
+	UserEmailKey contextKey = "email"
+)
 
-// GetTheToken extracts and validates the JWT token.
+// GetTheToken extracts the JWT token, or the API key of an
+// "Authorization: ApiKey" header.
 func GetTheToken(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
 		return "", errors.ErrNoTokenProvided
 	}
 
-	if !strings.HasPrefix(authHeader, "Bearer ") {
+	token, err := authz.Credential(r)
+	if err != nil {
 		return "", errors.ErrInvalidToken
 	}
+	return token, nil
+}
 
-	return strings.TrimPrefix(authHeader, "Bearer "), nil
+type AuthMiddleware struct {
+	authClient *pkg.AuthClient
+	keys       *jwks.Cache
 }
-func ValidateToken(tokenString string) (*JWTAccessClaims, error) {
-	claims := &JWTAccessClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
-		return []byte(config.SetEnv().APP_SECRET), nil
-	})
-	if err != nil || !token.Valid {
-		return nil, errors.ErrInvalidToken
+func NewAuthMiddleware(authClient *pkg.AuthClient, keys *jwks.Cache) *AuthMiddleware {
+	return &AuthMiddleware{
+		authClient: authClient,
+		keys:       keys,
+	}
+}
+
+// verifyToken checks the signature and expiry of an access token against the
+// auth service's published keys
+func (a *AuthMiddleware) verifyToken(ctx context.Context, tokenString string) error {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return a.keys.Key(ctx, kid)
+	}, jwt.WithValidMethods([]string{"RS256", "EdDSA"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return errors.ErrInvalidToken
+	}
+	if typ, _ := claims["typ"].(string); typ != "access" {
+		return errors.DifferentTokenUsed
 	}
-	if claims.Uid == "" {
-		return nil, errors.DifferentTokenUsed
+	return nil
+}
 
+// introspectErrorStatus maps a failed Introspect call to a response status.
+// The auth service rejecting the token is the client's fault, anything else
+// means it could not be asked.
+func introspectErrorStatus(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.Unauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusServiceUnavailable
 	}
-	return claims, nil
 }
 
-// Middleware: Inject user_id into request context
-func GetUserIdFromToken(next http.Handler) http.Handler {
+// Middleware: Inject user_id into request context. The token signature is
+// verified locally against the auth JWKS, then introspected by the auth
+// service so revoked tokens are rejected too. API keys are introspected only.
+func (a *AuthMiddleware) GetUserIdFromToken(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		tokenString, err := GetTheToken(r)
@@ -65,17 +90,29 @@ func GetUserIdFromToken(next http.Handler) http.Handler {
 			return
 		}
 
-		// ✅ Validate ACCESS TOKEN properly
-		claims, err := ValidateToken(tokenString)
+		// API keys are opaque, only the auth service can check them
+		if !strings.HasPrefix(tokenString, authz.APIKeyPrefix) {
+			if err := a.verifyToken(r.Context(), tokenString); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		info, err := a.authClient.Introspect(r.Context(), tokenString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			http.Error(w, "token validation failed", introspectErrorStatus(err))
+			return
+		}
+		if !info.Active {
+			if info.Revoked {
+				http.Error(w, errors.ErrTokenRevoked.Error(), http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, errors.ErrInvalidToken.Error(), http.StatusUnauthorized)
 			return
 		}
 
-		fmt.Println("Authenticated User:", claims)
-
-		// Now we know it's a valid ACCESS token
-		userID := claims.Uid
+		userID := info.UserId
 		if userID == "" {
 			http.Error(w, "Invalid access token: uid missing", http.StatusUnauthorized)
 			return
@@ -84,6 +121,7 @@ func GetUserIdFromToken(next http.Handler) http.Handler {
 
 		// Inject UID in context
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		ctx = context.WithValue(ctx, UserEmailKey, info.Email)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -3,24 +3,36 @@ package model
 import (
 	"time"
 
+	"github.com/sachinggsingh/e-comm/pb/money"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type CartItem struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id"`
-	Product_id  string             `json:"product_id" bson:"product_id"`
-	Price       float64            `json:"price" bson:"price"`
-	Quantity    int                `json:"quantity" bson:"quantity"`
-	Total       float64            `json:"total" bson:"total"`
-	CartItem_id string             `json:"cartItem_id" bson:"cartItem_id"`
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	Product_id string             `json:"product_id" bson:"product_id"`
+	// Sku is the variant of the product, empty for products without variants
+	Sku         string      `json:"sku,omitempty" bson:"sku,omitempty"`
+	Price       money.Money `json:"price" bson:"price"`
+	Quantity    int         `json:"quantity" bson:"quantity"`
+	Total       money.Money `json:"total" bson:"total"`
+	CartItem_id string      `json:"cartItem_id" bson:"cartItem_id"`
 }
 
 type Cart struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id"`
-	User_id     string             `json:"user_id" bson:"user_id"`
-	Items       []CartItem         `json:"items" bson:"items"`
-	TotalAmount float64            `json:"total_amount" bson:"total_amount"`
-	Created_at  time.Time          `json:"created_at" bson:"created_at"`
-	Updated_at  time.Time          `json:"updated_at" bson:"updated_at"`
-	Cart_id     string             `json:"cart_id" bson:"cart_id"`
+	ID      primitive.ObjectID `json:"id" bson:"_id"`
+	User_id string             `json:"user_id" bson:"user_id"`
+	Items   []CartItem         `json:"items" bson:"items"`
+	// Currency is the currency the buyer chose, the items are priced and
+	// paid in it
+	Currency    string      `json:"currency" bson:"currency,omitempty"`
+	TotalAmount money.Money `json:"total_amount" bson:"total_amount"`
+	Created_at  time.Time   `json:"created_at" bson:"created_at"`
+	Updated_at  time.Time   `json:"updated_at" bson:"updated_at"`
+	Cart_id     string      `json:"cart_id" bson:"cart_id"`
+	// Reservation_id, Checkout_session_id and Order_id are set while a
+	// checkout is pending: the stock held for it, the payment session paying
+	// it and the order placed for it
+	Reservation_id      string `json:"reservation_id,omitempty" bson:"reservation_id,omitempty"`
+	Checkout_session_id string `json:"checkout_session_id,omitempty" bson:"checkout_session_id,omitempty"`
+	Order_id            string `json:"order_id,omitempty" bson:"order_id,omitempty"`
 }
@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+
+	proto "github.com/sachinggsingh/e-comm/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type AuthClient struct {
+	client proto.ValidateTokenClient
+	conn   *grpc.ClientConn
+}
+
+func NewAuthClient(authServiceURL string) (*AuthClient, error) {
+	conn, err := grpc.NewClient(authServiceURL, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to auth service: %w", err)
+	}
+
+	client := proto.NewValidateTokenClient(conn)
+
+	return &AuthClient{
+		client: client,
+		conn:   conn,
+	}, nil
+}
+
+// Introspect asks the auth service who the token belongs to. Unlike local JWT
+// verification this also catches tokens revoked by a logout. Errors keep the
+// gRPC status of the call.
+func (ac *AuthClient) Introspect(ctx context.Context, token string) (*proto.IntrospectResponse, error) {
+	resp, err := ac.client.Introspect(ctx, &proto.IntrospectRequest{Token: token})
+	if err != nil {
+		return nil, fmt.Errorf("failed to introspect token: %w", err)
+	}
+	return resp, nil
+}
+
+func (ac *AuthClient) Close() error {
+	if ac.conn != nil {
+		return ac.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+
+	proto "github.com/sachinggsingh/e-comm/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+type OrderClient struct {
+	client proto.OrdersClient
+	conn   *grpc.ClientConn
+}
+
+func NewOrderClient(orderServiceURL string) (*OrderClient, error) {
+	conn, err := grpc.NewClient(orderServiceURL, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to order service: %w", err)
+	}
+
+	return &OrderClient{
+		client: proto.NewOrdersClient(conn),
+		conn:   conn,
+	}, nil
+}
+
+// CreateOrder places a pending order for the lines of a checkout
+func (oc *OrderClient) CreateOrder(ctx context.Context, req *proto.CreateOrderRequest) (*proto.OrderResponse, error) {
+	resp, err := oc.client.CreateOrder(ctx, req)
+	if err != nil {
+		return nil, orderError("failed to create order", err)
+	}
+	return resp, nil
+}
+
+// MarkOrderPaid records that paymentID paid the order; an order already
+// paid is not an error. A non-empty refundReason flags the order as needing
+// a refund.
+func (oc *OrderClient) MarkOrderPaid(ctx context.Context, orderID string, paymentID string, refundReason string) (*proto.OrderResponse, error) {
+	resp, err := oc.client.MarkOrderPaid(ctx, &proto.MarkOrderPaidRequest{OrderId: orderID, PaymentId: paymentID, RefundReason: refundReason})
+	if err != nil {
+		return nil, orderError("failed to mark order paid", err)
+	}
+	return resp, nil
+}
+
+// CancelOrder cancels a pending order; an order already cancelled is not an
+// error
+func (oc *OrderClient) CancelOrder(ctx context.Context, orderID string, reason string) (*proto.OrderResponse, error) {
+	resp, err := oc.client.CancelOrder(ctx, &proto.CancelOrderRequest{OrderId: orderID, Reason: reason})
+	if err != nil {
+		return nil, orderError("failed to cancel order", err)
+	}
+	return resp, nil
+}
+
+func orderError(message string, err error) error {
+	if st, ok := status.FromError(err); ok {
+		return fmt.Errorf("%s: %s (code: %s)", message, st.Message(), st.Code())
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+func (oc *OrderClient) Close() error {
+	if oc.conn != nil {
+		return oc.conn.Close()
+	}
+	return nil
+}
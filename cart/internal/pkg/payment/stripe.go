@@ -3,57 +3,74 @@ package payment
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/sachinggsingh/e-comm/pb/money"
 	"github.com/stripe/stripe-go/v74"
 	"github.com/stripe/stripe-go/v74/checkout/session"
+	"github.com/stripe/stripe-go/v74/webhook"
 )
 
 // PaymentItem represents a product item in the payment
 type PaymentItem struct {
 	Name        string
 	Description string
-	Price       float64
+	Price       money.Money
 	Quantity    int64
 }
 
 type PaymentClient interface {
-	CreatePayment(items []PaymentItem, userID string, orderID string) (*stripe.CheckoutSession, error)
+	// CreatePayment opens a checkout session for the order and stock
+	// reservation of the user, whose ids it keeps in its metadata; a non-zero
+	// expiresAt closes it then, at least 30 minutes from now
+	CreatePayment(items []PaymentItem, userID string, orderID string, reservationID string, expiresAt time.Time) (*stripe.CheckoutSession, error)
 	CheckPaymentStatus(pID string) (*stripe.CheckoutSessionStatus, error)
+	// ExpirePayment closes an open checkout session so it can no longer be paid
+	ExpirePayment(pID string) error
+	// VerifyEvent checks the Stripe-Signature header of a webhook request
+	// and returns the event it carries
+	VerifyEvent(payload []byte, signature string) (*stripe.Event, error)
 }
 
 type Payment struct {
 	stripeSecretKey string
+	webhookSecret   string
 	successUrl      string
 	failureUrl      string
 }
 
-func NewPaymentClient(stripeSecretKey, successUrl, failureUrl string) PaymentClient {
+func NewPaymentClient(stripeSecretKey, webhookSecret, successUrl, failureUrl string) PaymentClient {
 	return &Payment{
 		stripeSecretKey: stripeSecretKey,
+		webhookSecret:   webhookSecret,
 		successUrl:      successUrl,
 		failureUrl:      failureUrl,
 	}
 }
 
-func (p *Payment) CreatePayment(items []PaymentItem, userID string, orderID string) (*stripe.CheckoutSession, error) {
+func (p *Payment) CreatePayment(items []PaymentItem, userID string, orderID string, reservationID string, expiresAt time.Time) (*stripe.CheckoutSession, error) {
 	if len(items) == 0 {
 		return nil, errors.New("payment items cannot be empty")
 	}
 
 	stripe.Key = p.stripeSecretKey
 
-	// Build line items from product details
+	// Build line items from product details. A session charges in one
+	// currency, unit amounts are in its minor units.
+	currency := items[0].Price.Currency
 	lineItems := make([]*stripe.CheckoutSessionLineItemParams, 0, len(items))
 	for _, item := range items {
-		if item.Price <= 0 {
-			return nil, fmt.Errorf("invalid price for item %s: %f", item.Name, item.Price)
+		if item.Price.Amount <= 0 {
+			return nil, fmt.Errorf("invalid price for item %s: %s", item.Name, item.Price)
+		}
+		if item.Price.Currency != currency {
+			return nil, fmt.Errorf("item %s is priced in %s, not %s", item.Name, item.Price.Currency, currency)
 		}
 		if item.Quantity <= 0 {
 			return nil, fmt.Errorf("invalid quantity for item %s: %d", item.Name, item.Quantity)
 		}
 
-		amountInCents := int64(item.Price * 100)
-
 		productData := &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
 			Name: stripe.String(item.Name),
 		}
@@ -65,8 +82,8 @@ func (p *Payment) CreatePayment(items []PaymentItem, userID string, orderID stri
 
 		lineItem := &stripe.CheckoutSessionLineItemParams{
 			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-				UnitAmount:  stripe.Int64(amountInCents),
-				Currency:    stripe.String("usd"),
+				UnitAmount:  stripe.Int64(item.Price.Amount),
+				Currency:    stripe.String(strings.ToLower(currency)),
 				ProductData: productData,
 			},
 			Quantity: stripe.Int64(item.Quantity),
@@ -81,8 +98,12 @@ func (p *Payment) CreatePayment(items []PaymentItem, userID string, orderID stri
 		SuccessURL:         stripe.String(p.successUrl),
 		CancelURL:          stripe.String(p.failureUrl),
 	}
+	if !expiresAt.IsZero() {
+		params.ExpiresAt = stripe.Int64(expiresAt.Unix())
+	}
 	params.AddMetadata("order_id", orderID)
 	params.AddMetadata("user_id", userID)
+	params.AddMetadata("reservation_id", reservationID)
 
 	session, err := session.New(params)
 	if err != nil {
@@ -102,3 +123,24 @@ func (p *Payment) CheckPaymentStatus(pID string) (*stripe.CheckoutSessionStatus,
 	fmt.Println(&status, "  Checking the & status")
 	return &status, nil
 }
+
+func (p *Payment) ExpirePayment(pID string) error {
+	stripe.Key = p.stripeSecretKey
+
+	if _, err := session.Expire(pID, nil); err != nil {
+		return fmt.Errorf("failed to expire payment session: %w", err)
+	}
+	return nil
+}
+
+func (p *Payment) VerifyEvent(payload []byte, signature string) (*stripe.Event, error) {
+	// Events are read for their session only, the endpoint may be pinned to
+	// another API version than the library
+	event, err := webhook.ConstructEventWithOptions(payload, signature, p.webhookSecret, webhook.ConstructEventOptions{
+		IgnoreAPIVersionMismatch: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook event: %w", err)
+	}
+	return &event, nil
+}
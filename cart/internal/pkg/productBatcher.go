@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	proto "github.com/sachinggsingh/e-comm/pb"
+)
+
+const (
+	// batchWindow is how long a lookup waits for others to share its call
+	batchWindow = 2 * time.Millisecond
+	// maxBatch matches the limit of the GetProductsBatch RPC
+	maxBatch = 100
+	// batchTimeout bounds one GetProductsBatch call. The call serves many
+	// callers, so it does not run under the context of any of them.
+	batchTimeout = 10 * time.Second
+)
+
+// ProductKey names a product, or one of its variants, to look up
+type ProductKey struct {
+	ProductID string
+	Sku       string
+}
+
+type lookupResult struct {
+	product *proto.ProductLookup
+	err     error
+}
+
+// batcher coalesces the product lookups made within batchWindow of each
+// other into one GetProductsBatch call, asking for each product once
+type batcher struct {
+	fetch func(ctx context.Context, keys []ProductKey) (map[ProductKey]*proto.ProductLookup, error)
+
+	mu      sync.Mutex
+	keys    []ProductKey
+	waiting map[ProductKey][]chan lookupResult
+	timer   *time.Timer
+}
+
+func newBatcher(fetch func(ctx context.Context, keys []ProductKey) (map[ProductKey]*proto.ProductLookup, error)) *batcher {
+	return &batcher{
+		fetch:   fetch,
+		waiting: map[ProductKey][]chan lookupResult{},
+	}
+}
+
+// lookup returns the lookup of every key. It fails when the batch call
+// fails or ctx ends first.
+func (b *batcher) lookup(ctx context.Context, keys []ProductKey) (map[ProductKey]*proto.ProductLookup, error) {
+	replies := make(map[ProductKey]chan lookupResult, len(keys))
+	b.mu.Lock()
+	for _, key := range keys {
+		if _, ok := replies[key]; ok {
+			continue
+		}
+		reply := make(chan lookupResult, 1)
+		replies[key] = reply
+		if _, ok := b.waiting[key]; !ok {
+			b.keys = append(b.keys, key)
+		}
+		b.waiting[key] = append(b.waiting[key], reply)
+		if len(b.keys) >= maxBatch {
+			b.flushLocked()
+		}
+	}
+	if len(b.keys) > 0 && b.timer == nil {
+		b.timer = time.AfterFunc(batchWindow, b.flush)
+	}
+	b.mu.Unlock()
+
+	lookups := make(map[ProductKey]*proto.ProductLookup, len(replies))
+	for key, reply := range replies {
+		select {
+		case result := <-reply:
+			if result.err != nil {
+				return nil, result.err
+			}
+			lookups[key] = result.product
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return lookups, nil
+}
+
+func (b *batcher) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushLocked()
+}
+
+// flushLocked sends the pending keys off in a call of their own
+func (b *batcher) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.keys) == 0 {
+		return
+	}
+	keys, waiting := b.keys, b.waiting
+	b.keys, b.waiting = nil, map[ProductKey][]chan lookupResult{}
+	go b.run(keys, waiting)
+}
+
+func (b *batcher) run(keys []ProductKey, waiting map[ProductKey][]chan lookupResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), batchTimeout)
+	defer cancel()
+
+	lookups, err := b.fetch(ctx, keys)
+	for key, replies := range waiting {
+		result := lookupResult{product: lookups[key], err: err}
+		if err == nil && result.product == nil {
+			result.product = &proto.ProductLookup{ProductId: key.ProductID, Sku: key.Sku, Error: "missing from the batch response"}
+		}
+		// replies are buffered, callers that gave up do not block the batch
+		for _, reply := range replies {
+			reply <- result
+		}
+	}
+}
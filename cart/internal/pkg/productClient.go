@@ -3,16 +3,22 @@ package pkg
 import (
 	"context"
 	"fmt"
+	"time"
 
+	carterrors "github.com/sachinggsingh/e-comm/internal/errors"
 	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/money"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 )
 
 type ProductClient struct {
-	client proto.GetProductsClient
-	conn   *grpc.ClientConn
+	client    proto.GetProductsClient
+	inventory proto.InventoryClient
+	conn      *grpc.ClientConn
+	batcher   *batcher
 }
 
 func NewProductClient(productServiceURL string) (*ProductClient, error) {
@@ -23,31 +29,98 @@ func NewProductClient(productServiceURL string) (*ProductClient, error) {
 
 	client := proto.NewGetProductsClient(conn)
 
-	return &ProductClient{
-		client: client,
-		conn:   conn,
-	}, nil
+	// Inventory is served by the same gRPC server
+	pc := &ProductClient{
+		client:    client,
+		inventory: proto.NewInventoryClient(conn),
+		conn:      conn,
+	}
+	pc.batcher = newBatcher(pc.fetch)
+	return pc, nil
 }
 
-func (pc *ProductClient) GetProduct(ctx context.Context, productID string) (*proto.GetPRoductResponse, error) {
-	if productID == "" {
-		return nil, fmt.Errorf("product_id is required")
+// GetProduct fetches the product; a non-empty sku also fetches that variant
+// into the Variant of the response
+func (pc *ProductClient) GetProduct(ctx context.Context, productID string, sku string) (*proto.GetPRoductResponse, error) {
+	key := ProductKey{ProductID: productID, Sku: sku}
+	products, err := pc.GetProducts(ctx, []ProductKey{key})
+	if err != nil {
+		return nil, err
 	}
+	return products[key], nil
+}
 
-	req := &proto.GetProductRequest{
-		ProductId: productID,
+// GetProducts fetches the products of keys. Lookups made at the same time,
+// by this or other requests, share one GetProductsBatch call. It returns
+// ErrInvalidItem when a product or variant does not exist.
+func (pc *ProductClient) GetProducts(ctx context.Context, keys []ProductKey) (map[ProductKey]*proto.GetPRoductResponse, error) {
+	for _, key := range keys {
+		if key.ProductID == "" {
+			return nil, fmt.Errorf("product_id is required")
+		}
 	}
 
-	resp, err := pc.client.GetProducts(ctx, req)
+	lookups, err := pc.batcher.lookup(ctx, keys)
 	if err != nil {
-		st, ok := status.FromError(err)
-		if ok {
-			return nil, fmt.Errorf("gRPC error: %s (code: %s)", st.Message(), st.Code())
+		return nil, err
+	}
+	products := make(map[ProductKey]*proto.GetPRoductResponse, len(lookups))
+	for key, lookup := range lookups {
+		if !lookup.Found {
+			return nil, fmt.Errorf("%w: %s", carterrors.ErrInvalidItem, lookup.Error)
 		}
-		return nil, fmt.Errorf("failed to get product: %w", err)
+		products[key] = lookup.Product
 	}
+	return products, nil
+}
 
-	return resp, nil
+// fetch looks keys up in one call. Product services without the batch RPC
+// get one call per key.
+func (pc *ProductClient) fetch(ctx context.Context, keys []ProductKey) (map[ProductKey]*proto.ProductLookup, error) {
+	req := &proto.GetProductsBatchRequest{Items: make([]*proto.GetProductRequest, len(keys))}
+	for i, key := range keys {
+		req.Items[i] = &proto.GetProductRequest{ProductId: key.ProductID, Sku: key.Sku}
+	}
+
+	resp, err := pc.client.GetProductsBatch(ctx, req)
+	if status.Code(err) == codes.Unimplemented {
+		return pc.fetchEach(ctx, keys)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	lookups := make(map[ProductKey]*proto.ProductLookup, len(resp.Results))
+	for _, lookup := range resp.Results {
+		lookups[ProductKey{ProductID: lookup.ProductId, Sku: lookup.Sku}] = lookup
+	}
+	return lookups, nil
+}
+
+func (pc *ProductClient) fetchEach(ctx context.Context, keys []ProductKey) (map[ProductKey]*proto.ProductLookup, error) {
+	lookups := make(map[ProductKey]*proto.ProductLookup, len(keys))
+	for _, key := range keys {
+		lookup := &proto.ProductLookup{ProductId: key.ProductID, Sku: key.Sku}
+		resp, err := pc.client.GetProducts(ctx, &proto.GetProductRequest{ProductId: key.ProductID, Sku: key.Sku})
+		switch {
+		case status.Code(err) == codes.NotFound:
+			lookup.Error = status.Convert(err).Message()
+		case err != nil:
+			return nil, grpcError(err)
+		default:
+			lookup.Found = true
+			lookup.Product = resp
+		}
+		lookups[key] = lookup
+	}
+	return lookups, nil
+}
+
+func grpcError(err error) error {
+	if st, ok := status.FromError(err); ok {
+		return fmt.Errorf("gRPC error: %s (code: %s)", st.Message(), st.Code())
+	}
+	return fmt.Errorf("failed to get product: %w", err)
 }
 
 func (pc *ProductClient) Close() error {
@@ -57,16 +130,77 @@ func (pc *ProductClient) Close() error {
 	return nil
 }
 
-// ValidateProduct validates if a product exists and returns its price
-func (pc *ProductClient) ValidateProduct(ctx context.Context, productID string) (float64, error) {
-	product, err := pc.GetProduct(ctx, productID)
+// ValidateProduct validates if a product, or its variant sku, exists and
+// returns its price
+func (pc *ProductClient) ValidateProduct(ctx context.Context, productID string, sku string) (money.Money, error) {
+	product, err := pc.GetProduct(ctx, productID, sku)
 	if err != nil {
-		return 0, err
+		return money.Money{}, err
 	}
 
-	if product.Price <= 0 {
-		return 0, fmt.Errorf("invalid product price: %f", product.Price)
+	price, err := ProductPrice(product)
+	if err != nil {
+		return money.Money{}, err
 	}
+	if price.Amount <= 0 {
+		return money.Money{}, fmt.Errorf("invalid product price: %s", price)
+	}
+
+	return price, nil
+}
+
+// ProductPrice returns the price charged for the product, or for its
+// variant when one was requested. A product service predating exact prices
+// only sends the doubles, which are rounded to the cent.
+func ProductPrice(product *proto.GetPRoductResponse) (money.Money, error) {
+	amount, price := product.PriceAmount, product.Price
+	if product.Variant != nil {
+		amount, price = product.Variant.PriceAmount, product.Variant.Price
+	}
+	if product.Currency == "" {
+		return money.FromFloat(price, money.DefaultCurrency)
+	}
+	return money.New(amount, product.Currency), nil
+}
+
+// ReserveStock holds the items for ttl under reservationID and returns when
+// the hold expires. It returns ErrOutOfStock when a product has too little
+// stock, in which case nothing is held.
+func (pc *ProductClient) ReserveStock(ctx context.Context, reservationID string, items []*proto.StockItem, ttl time.Duration) (time.Time, error) {
+	resp, err := pc.inventory.ReserveStock(ctx, &proto.ReserveStockRequest{
+		ReservationId: reservationID,
+		Items:         items,
+		TtlSeconds:    int64(ttl.Seconds()),
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.FailedPrecondition {
+			return time.Time{}, fmt.Errorf("%w: %s", carterrors.ErrOutOfStock, st.Message())
+		}
+		return time.Time{}, fmt.Errorf("failed to reserve stock: %w", err)
+	}
+	return time.Unix(resp.ExpiresAt, 0), nil
+}
+
+// CommitReservation keeps the reserved stock sold. A reservation committed
+// before is not an error; it returns ErrReservationLost when the reservation
+// expired or was released.
+func (pc *ProductClient) CommitReservation(ctx context.Context, reservationID string) error {
+	_, err := pc.inventory.CommitReservation(ctx, &proto.ReservationRequest{ReservationId: reservationID})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return carterrors.ErrReservationLost
+		}
+		return fmt.Errorf("failed to commit reservation: %w", err)
+	}
+	return nil
+}
 
-	return product.Price, nil
+// ReleaseReservation gives the reserved stock back. A reservation that is
+// already gone is not an error.
+func (pc *ProductClient) ReleaseReservation(ctx context.Context, reservationID string) error {
+	_, err := pc.inventory.ReleaseReservation(ctx, &proto.ReservationRequest{ReservationId: reservationID})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to release reservation: %w", err)
+	}
+	return nil
 }
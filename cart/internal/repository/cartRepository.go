@@ -16,8 +16,22 @@ import (
 type CartRepository interface {
 	CreateCart(cart *model.Cart) (*model.Cart, error)
 	FindCartByUserID(userID string) (*model.Cart, error)
+	// UpdateCart and DeleteCart leave a cart with a pending checkout alone
+	// and return ErrCheckoutPending for it
 	UpdateCart(cart *model.Cart) (*model.Cart, error)
 	DeleteCart(userID string) error
+	// SetCheckout records the pending checkout of the user's cart; empty ids
+	// clear it
+	SetCheckout(userID string, reservationID string, sessionID string, orderID string) error
+	// ClearCheckout clears the checkout of the user's cart and
+	// DeleteCheckedOutCart deletes the cart, provided the cart still waits
+	// for the payment session; they return ErrCartNotFound otherwise
+	ClearCheckout(userID string, sessionID string) error
+	DeleteCheckedOutCart(userID string, sessionID string) error
+	// MigratePrices converts the carts stored with double prices to money
+	// and returns how many it converted and the ids of those it skipped
+	// because they kept changing
+	MigratePrices() (int, []string, error)
 }
 
 type cartRepository struct {
@@ -68,12 +82,14 @@ func (c *cartRepository) UpdateCart(cart *model.Cart) (*model.Cart, error) {
 	defer cancel()
 
 	filter := bson.M{
-		"user_id": cart.User_id,
+		"user_id":             cart.User_id,
+		"checkout_session_id": bson.M{"$exists": false},
 	}
 
 	update := bson.M{
 		"$set": bson.M{
 			"items":        cart.Items,
+			"currency":     cart.Currency,
 			"total_amount": cart.TotalAmount,
 			"updated_at":   cart.Updated_at,
 		},
@@ -84,8 +100,7 @@ func (c *cartRepository) UpdateCart(cart *model.Cart) (*model.Cart, error) {
 	err := c.db.CartCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedCart)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			log.Printf("Cart not found for user_id: %s", cart.User_id)
-			return nil, errors.ErrCartNotFound
+			return nil, c.notWritten(ctx, cart.User_id)
 		}
 		log.Printf("Error updating cart: %v", err)
 		return nil, err
@@ -98,7 +113,8 @@ func (c *cartRepository) DeleteCart(userID string) error {
 	defer cancel()
 
 	filter := bson.M{
-		"user_id": userID,
+		"user_id":             userID,
+		"checkout_session_id": bson.M{"$exists": false},
 	}
 
 	result, err := c.db.CartCollection.DeleteOne(ctx, filter)
@@ -108,9 +124,100 @@ func (c *cartRepository) DeleteCart(userID string) error {
 	}
 
 	if result.DeletedCount == 0 {
+		return c.notWritten(ctx, userID)
+	}
+
+	return nil
+}
+
+// notWritten explains why a write that leaves carts in checkout alone
+// matched no cart of the user
+func (c *cartRepository) notWritten(ctx context.Context, userID string) error {
+	count, err := c.db.CartCollection.CountDocuments(ctx, bson.M{"user_id": userID}, options.Count().SetLimit(1))
+	if err != nil {
+		log.Printf("Error finding cart: %v", err)
+		return err
+	}
+	if count == 0 {
 		log.Printf("Cart not found for user_id: %s", userID)
 		return errors.ErrCartNotFound
 	}
+	return errors.ErrCheckoutPending
+}
+
+func (c *cartRepository) SetCheckout(userID string, reservationID string, sessionID string, orderID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id": userID,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"reservation_id":      reservationID,
+			"checkout_session_id": sessionID,
+			"order_id":            orderID,
+			"updated_at":          time.Now().UTC(),
+		},
+	}
+	if reservationID == "" && sessionID == "" && orderID == "" {
+		update = bson.M{
+			"$unset": bson.M{"reservation_id": "", "checkout_session_id": "", "order_id": ""},
+			"$set":   bson.M{"updated_at": time.Now().UTC()},
+		}
+	}
 
+	result, err := c.db.CartCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error updating cart checkout: %v", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.ErrCartNotFound
+	}
+	return nil
+}
+
+func (c *cartRepository) ClearCheckout(userID string, sessionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":             userID,
+		"checkout_session_id": sessionID,
+	}
+	update := bson.M{
+		"$unset": bson.M{"reservation_id": "", "checkout_session_id": "", "order_id": ""},
+		"$set":   bson.M{"updated_at": time.Now().UTC()},
+	}
+
+	result, err := c.db.CartCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error clearing cart checkout: %v", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.ErrCartNotFound
+	}
+	return nil
+}
+
+func (c *cartRepository) DeleteCheckedOutCart(userID string, sessionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":             userID,
+		"checkout_session_id": sessionID,
+	}
+
+	result, err := c.db.CartCollection.DeleteOne(ctx, filter)
+	if err != nil {
+		log.Printf("Error deleting cart: %v", err)
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.ErrCartNotFound
+	}
 	return nil
 }
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// legacyCart is a cart stored before prices were money, with prices and
+// totals as doubles
+type legacyCart struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Items      []legacyCartItem   `bson:"items"`
+	Updated_at time.Time          `bson:"updated_at"`
+}
+
+type legacyCartItem struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Product_id  string             `bson:"product_id"`
+	Sku         string             `bson:"sku,omitempty"`
+	Price       float64            `bson:"price"`
+	Quantity    int                `bson:"quantity"`
+	CartItem_id string             `bson:"cartItem_id"`
+}
+
+// migrationAttempts is how often a cart changed during the migration is
+// read and converted again
+const migrationAttempts = 3
+
+// legacyFilter matches the carts still stored with double prices
+func legacyFilter() bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"items.price": bson.M{"$type": "double"}},
+		bson.M{"total_amount": bson.M{"$type": "double"}},
+	}}
+}
+
+// MigratePrices converts the carts stored with double prices to amounts of
+// money.DefaultCurrency. Line totals and the cart total are computed again
+// from the converted prices, so they add up exactly. A cart changed
+// meanwhile is read again and converted again if it still has doubles; the
+// carts that kept changing are returned as skipped, the next run takes
+// them up.
+func (c *cartRepository) MigratePrices() (int, []string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cursor, err := c.db.CartCollection.Find(ctx, legacyFilter())
+	if err != nil {
+		return 0, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	skipped := []string{}
+	for cursor.Next(ctx) {
+		var cart legacyCart
+		if err := cursor.Decode(&cart); err != nil {
+			return migrated, skipped, fmt.Errorf("cart %v: %w", cursor.Current.Lookup("_id"), err)
+		}
+		done, err := c.migrateCart(ctx, cart)
+		if err != nil {
+			return migrated, skipped, err
+		}
+		switch done {
+		case cartMigrated:
+			migrated++
+		case cartSkipped:
+			skipped = append(skipped, cart.ID.Hex())
+		}
+	}
+	return migrated, skipped, cursor.Err()
+}
+
+type cartMigration int
+
+const (
+	cartMigrated cartMigration = iota
+	// cartGone is a cart deleted or written with money meanwhile
+	cartGone
+	cartSkipped
+)
+
+// migrateCart writes the prices of cart as money, provided it did not
+// change since it was read, and reads it again when it did
+func (c *cartRepository) migrateCart(ctx context.Context, cart legacyCart) (cartMigration, error) {
+	for range migrationAttempts {
+		items, totalAmount, err := convertLegacyCart(cart)
+		if err != nil {
+			return 0, fmt.Errorf("cart %s: %w", cart.ID.Hex(), err)
+		}
+		filter := bson.M{"_id": cart.ID, "updated_at": cart.Updated_at}
+		update := bson.M{"$set": bson.M{"items": items, "total_amount": totalAmount}}
+		res, err := c.db.CartCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return 0, err
+		}
+		if res.MatchedCount == 1 {
+			return cartMigrated, nil
+		}
+
+		id := cart.ID
+		filter = legacyFilter()
+		filter["_id"] = id
+		cart = legacyCart{}
+		if err := c.db.CartCollection.FindOne(ctx, filter).Decode(&cart); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return cartGone, nil
+			}
+			return 0, fmt.Errorf("cart %s: %w", id.Hex(), err)
+		}
+	}
+	return cartSkipped, nil
+}
+
+// convertLegacyCart returns the items of cart priced as money and their
+// total
+func convertLegacyCart(cart legacyCart) ([]model.CartItem, money.Money, error) {
+	items := make([]model.CartItem, 0, len(cart.Items))
+	totals := make([]money.Money, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		price, err := money.FromFloat(item.Price, money.DefaultCurrency)
+		if err != nil {
+			return nil, money.Money{}, err
+		}
+		total, err := price.Mul(int64(item.Quantity))
+		if err != nil {
+			return nil, money.Money{}, err
+		}
+		items = append(items, model.CartItem{
+			ID:          item.ID,
+			Product_id:  item.Product_id,
+			Sku:         item.Sku,
+			Price:       price,
+			Quantity:    item.Quantity,
+			Total:       total,
+			CartItem_id: item.CartItem_id,
+		})
+		totals = append(totals, total)
+	}
+	totalAmount, err := money.Sum(money.DefaultCurrency, totals...)
+	if err != nil {
+		return nil, money.Money{}, err
+	}
+	return items, totalAmount, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/sachinggsingh/e-comm/internal/errors"
@@ -11,33 +13,69 @@ import (
 	"github.com/sachinggsingh/e-comm/internal/pkg"
 	"github.com/sachinggsingh/e-comm/internal/pkg/payment"
 	"github.com/sachinggsingh/e-comm/internal/repository"
+	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/money"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type CartService struct {
 	cartRepo      repository.CartRepository
 	productClient *pkg.ProductClient
+	orderClient   *pkg.OrderClient
+	pricing       Pricing
 }
 
-func NewCartService(cartRepo repository.CartRepository, productClient *pkg.ProductClient) *CartService {
+func NewCartService(cartRepo repository.CartRepository, productClient *pkg.ProductClient, orderClient *pkg.OrderClient, pricing Pricing) *CartService {
+	if pricing.Currency == "" {
+		pricing.Currency = money.DefaultCurrency
+	}
 	return &CartService{
 		cartRepo:      cartRepo,
 		productClient: productClient,
+		orderClient:   orderClient,
+		pricing:       pricing,
 	}
 }
 
 // CalculateTotal calculates the total price for a cart item
-func CalculateTotal(price float64, quantity int) float64 {
-	return price * float64(quantity)
+func CalculateTotal(price money.Money, quantity int) (money.Money, error) {
+	total, err := price.Mul(int64(quantity))
+	if err != nil {
+		return money.Money{}, fmt.Errorf("%w: %v", errors.ErrInvalidQuantity, err)
+	}
+	return total, nil
 }
 
-// CalculateCartTotal calculates the total amount for the entire cart
-func CalculateCartTotal(items []model.CartItem) float64 {
-	var total float64
+// CalculateCartTotal calculates the total amount for the entire cart, the
+// sum of the item totals. The items must be priced in currency, the
+// currency of the cart.
+func CalculateCartTotal(items []model.CartItem, currency string) (money.Money, error) {
+	totals := make([]money.Money, 0, len(items))
 	for _, item := range items {
-		total += item.Total
+		totals = append(totals, item.Total)
+	}
+	total, err := money.Sum(currency, totals...)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("%w: %v", errors.ErrInvalidItem, err)
+	}
+	return total, nil
+}
+
+// MigratePrices converts the carts stored before prices were money. Carts
+// are read as money, so it must run before the service takes requests.
+func (c *CartService) MigratePrices() error {
+	migrated, skipped, err := c.cartRepo.MigratePrices()
+	if err != nil {
+		return fmt.Errorf("failed to migrate cart prices: %w", err)
+	}
+	if migrated > 0 {
+		log.Printf("Migrated the prices of %d carts to %s", migrated, money.DefaultCurrency)
+	}
+	if len(skipped) > 0 {
+		log.Printf("Skipped %d carts that kept changing during the price migration, the next start retries them: %s",
+			len(skipped), strings.Join(skipped, ", "))
 	}
-	return total
+	return nil
 }
 
 // ValidateCartItems validates cart items
@@ -53,7 +91,7 @@ func validateCartItems(items []model.CartItem) error {
 		if item.Quantity <= 0 {
 			return errors.ErrInvalidQuantity
 		}
-		if item.Price <= 0 {
+		if item.Price.Amount <= 0 || item.Price.Validate() != nil {
 			return errors.ErrInvalidPrice
 		}
 	}
@@ -61,33 +99,76 @@ func validateCartItems(items []model.CartItem) error {
 	return nil
 }
 
-// ValidateProductsWithGRPC validates products exist and optionally updates prices from product service
-func (c *CartService) ValidateProductsWithGRPC(ctx context.Context, items []model.CartItem) ([]model.CartItem, error) {
+// offer returns the price and stock of the product, or of the variant the
+// item selects
+func offer(product *proto.GetPRoductResponse, item model.CartItem) (money.Money, int64, error) {
+	stock := product.Stock
+	if item.Sku != "" {
+		if product.Variant == nil {
+			return money.Money{}, 0, fmt.Errorf("%w: product %s has no variant %s", errors.ErrInvalidItem, item.Product_id, item.Sku)
+		}
+		stock = product.Variant.Stock
+	} else if len(product.Variants) > 0 {
+		return money.Money{}, 0, fmt.Errorf("%w: product %s has variants, a sku is required", errors.ErrInvalidItem, item.Product_id)
+	}
+	price, err := pkg.ProductPrice(product)
+	if err != nil {
+		return money.Money{}, 0, fmt.Errorf("%w: product %s: %v", errors.ErrInvalidPrice, item.Product_id, err)
+	}
+	return price, stock, nil
+}
+
+// productKeys lists the products, or variants, of the items
+func productKeys(items []model.CartItem) []pkg.ProductKey {
+	keys := make([]pkg.ProductKey, len(items))
+	for i, item := range items {
+		keys[i] = pkg.ProductKey{ProductID: item.Product_id, Sku: item.Sku}
+	}
+	return keys
+}
+
+// ValidateProductsWithGRPC validates products, or their variants, exist and are in stock and updates prices from product service, in currency
+func (c *CartService) ValidateProductsWithGRPC(ctx context.Context, items []model.CartItem, currency string) ([]model.CartItem, error) {
 	if c.productClient == nil {
 		// If product client is not available, skip validation
 		log.Println("Product client not available, skipping product validation")
 		return items, nil
 	}
 
+	// Validate products exist via gRPC, all in one call
+	products, err := c.productClient.GetProducts(ctx, productKeys(items))
+	if err != nil {
+		return nil, fmt.Errorf("product validation failed: %w", err)
+	}
+
 	validatedItems := make([]model.CartItem, 0, len(items))
 	for _, item := range items {
-		// Validate product exists via gRPC
-		product, err := c.productClient.GetProduct(ctx, item.Product_id)
+		product := products[pkg.ProductKey{ProductID: item.Product_id, Sku: item.Sku}]
+		price, stock, err := c.priceIn(ctx, product, item, currency)
 		if err != nil {
-			return nil, fmt.Errorf("product %s validation failed: %w", item.Product_id, err)
+			return nil, err
+		}
+		if stock < int64(item.Quantity) {
+			return nil, fmt.Errorf("%w: product %s has %d available", errors.ErrOutOfStock, item.Product_id, stock)
 		}
 
 		// Use price from product service to ensure consistency
 		validatedItem := item
-		validatedItem.Price = product.Price
-		validatedItem.Total = CalculateTotal(product.Price, item.Quantity)
+		validatedItem.Price = price
+		validatedItem.Total, err = CalculateTotal(price, item.Quantity)
+		if err != nil {
+			return nil, err
+		}
 		validatedItems = append(validatedItems, validatedItem)
 	}
 
 	return validatedItems, nil
 }
 
-func (c *CartService) CreateCart(userID string, items []model.CartItem) (*model.Cart, error) {
+// CreateCart creates the cart of the user priced in currency, the default
+// currency when empty, or adds the items to the cart the user has. A cart
+// the user has is switched to currency first when one is given.
+func (c *CartService) CreateCart(userID string, items []model.CartItem, currency string) (*model.Cart, error) {
 	if userID == "" {
 		return nil, errors.ErrInvalidUserID
 	}
@@ -96,12 +177,10 @@ func (c *CartService) CreateCart(userID string, items []model.CartItem) (*model.
 	if err := validateCartItems(items); err != nil {
 		return nil, err
 	}
-
-	// Validate products via gRPC and get updated prices
-	ctx := context.Background()
-	validatedItems, err := c.ValidateProductsWithGRPC(ctx, items)
+	requested := strings.TrimSpace(currency) != ""
+	currency, err := c.chooseCurrency(currency)
 	if err != nil {
-		return nil, fmt.Errorf("product validation failed: %w", err)
+		return nil, err
 	}
 
 	// Check if cart already exists for user
@@ -111,13 +190,28 @@ func (c *CartService) CreateCart(userID string, items []model.CartItem) (*model.
 		return nil, err
 	}
 
-	now := time.Now().UTC()
-
 	// If cart exists, add items to existing cart
 	if existingCart != nil {
-		return c.AddItemsToCart(userID, validatedItems)
+		if err := editable(existingCart); err != nil {
+			return nil, err
+		}
+		if requested && cartCurrency(existingCart) != currency {
+			if _, err := c.SetCurrency(userID, currency); err != nil {
+				return nil, err
+			}
+		}
+		return c.AddItemsToCart(userID, items)
 	}
 
+	// Validate products via gRPC and get updated prices
+	ctx := context.Background()
+	validatedItems, err := c.ValidateProductsWithGRPC(ctx, items, currency)
+	if err != nil {
+		return nil, fmt.Errorf("product validation failed: %w", err)
+	}
+
+	now := time.Now().UTC()
+
 	// Create new cart
 	cartID := primitive.NewObjectID()
 	cartItems := make([]model.CartItem, 0, len(validatedItems))
@@ -127,6 +221,7 @@ func (c *CartService) CreateCart(userID string, items []model.CartItem) (*model.
 		cartItem := model.CartItem{
 			ID:          cartItemID,
 			Product_id:  item.Product_id,
+			Sku:         item.Sku,
 			Price:       item.Price,
 			Quantity:    item.Quantity,
 			Total:       item.Total,
@@ -135,12 +230,16 @@ func (c *CartService) CreateCart(userID string, items []model.CartItem) (*model.
 		cartItems = append(cartItems, cartItem)
 	}
 
-	totalAmount := CalculateCartTotal(cartItems)
+	totalAmount, err := CalculateCartTotal(cartItems, currency)
+	if err != nil {
+		return nil, err
+	}
 
 	cart := &model.Cart{
 		ID:          cartID,
 		User_id:     userID,
 		Items:       cartItems,
+		Currency:    currency,
 		TotalAmount: totalAmount,
 		Created_at:  now,
 		Updated_at:  now,
@@ -154,10 +253,17 @@ func (c *CartService) FindCartByUserID(userID string) (*model.Cart, error) {
 	if userID == "" {
 		return nil, errors.ErrInvalidUserID
 	}
-	return c.cartRepo.FindCartByUserID(userID)
+	cart, err := c.cartRepo.FindCartByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	cart.Currency = cartCurrency(cart)
+	return cart, nil
 }
 
-func (c *CartService) UpdateCart(userID string, items []model.CartItem) (*model.Cart, error) {
+// UpdateCart replaces the items of the cart, priced in currency when one is
+// given and in the currency of the cart otherwise
+func (c *CartService) UpdateCart(userID string, items []model.CartItem, currency string) (*model.Cart, error) {
 	if userID == "" {
 		return nil, errors.ErrInvalidUserID
 	}
@@ -167,18 +273,27 @@ func (c *CartService) UpdateCart(userID string, items []model.CartItem) (*model.
 		return nil, err
 	}
 
-	// Validate products via gRPC and get updated prices
-	ctx := context.Background()
-	validatedItems, err := c.ValidateProductsWithGRPC(ctx, items)
-	if err != nil {
-		return nil, fmt.Errorf("product validation failed: %w", err)
-	}
-
 	// Get existing cart
 	existingCart, err := c.cartRepo.FindCartByUserID(userID)
 	if err != nil {
 		return nil, err
 	}
+	if err := editable(existingCart); err != nil {
+		return nil, err
+	}
+	if currency == "" {
+		currency = cartCurrency(existingCart)
+	}
+	if currency, err = c.chooseCurrency(currency); err != nil {
+		return nil, err
+	}
+
+	// Validate products via gRPC and get updated prices
+	ctx := context.Background()
+	validatedItems, err := c.ValidateProductsWithGRPC(ctx, items, currency)
+	if err != nil {
+		return nil, fmt.Errorf("product validation failed: %w", err)
+	}
 
 	// Update items
 	cartItems := make([]model.CartItem, 0, len(validatedItems))
@@ -187,6 +302,7 @@ func (c *CartService) UpdateCart(userID string, items []model.CartItem) (*model.
 		cartItem := model.CartItem{
 			ID:          cartItemID,
 			Product_id:  item.Product_id,
+			Sku:         item.Sku,
 			Price:       item.Price,
 			Quantity:    item.Quantity,
 			Total:       item.Total,
@@ -195,12 +311,16 @@ func (c *CartService) UpdateCart(userID string, items []model.CartItem) (*model.
 		cartItems = append(cartItems, cartItem)
 	}
 
-	totalAmount := CalculateCartTotal(cartItems)
+	totalAmount, err := CalculateCartTotal(cartItems, currency)
+	if err != nil {
+		return nil, err
+	}
 
 	updatedCart := &model.Cart{
 		ID:          existingCart.ID,
 		User_id:     userID,
 		Items:       cartItems,
+		Currency:    currency,
 		TotalAmount: totalAmount,
 		Created_at:  existingCart.Created_at,
 		Updated_at:  time.Now().UTC(),
@@ -210,6 +330,17 @@ func (c *CartService) UpdateCart(userID string, items []model.CartItem) (*model.
 	return c.cartRepo.UpdateCart(updatedCart)
 }
 
+// editable refuses changes to a cart while a checkout of it is pending: the
+// checkout holds the stock of the items and charges for them as they were
+func editable(cart *model.Cart) error {
+	if cart.Checkout_session_id != "" {
+		return errors.ErrCheckoutPending
+	}
+	return nil
+}
+
+// DeleteCart deletes the cart of the user; a cart with a pending checkout
+// is kept and ErrCheckoutPending returned, cancel the checkout first
 func (c *CartService) DeleteCart(userID string) error {
 	if userID == "" {
 		return errors.ErrInvalidUserID
@@ -226,16 +357,20 @@ func (c *CartService) AddItemsToCart(userID string, items []model.CartItem) (*mo
 		return nil, err
 	}
 
-	// Validate products via gRPC and get updated prices
-	ctx := context.Background()
-	validatedItems, err := c.ValidateProductsWithGRPC(ctx, items)
+	existingCart, err := c.cartRepo.FindCartByUserID(userID)
 	if err != nil {
-		return nil, fmt.Errorf("product validation failed: %w", err)
+		return nil, err
 	}
+	if err := editable(existingCart); err != nil {
+		return nil, err
+	}
+	currency := cartCurrency(existingCart)
 
-	existingCart, err := c.cartRepo.FindCartByUserID(userID)
+	// Validate products via gRPC and get updated prices
+	ctx := context.Background()
+	validatedItems, err := c.ValidateProductsWithGRPC(ctx, items, currency)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("product validation failed: %w", err)
 	}
 
 	// Add new items to existing items
@@ -243,19 +378,26 @@ func (c *CartService) AddItemsToCart(userID string, items []model.CartItem) (*mo
 		// 	Check if product already exists in cart
 		found := false
 		for i, existingItems := range existingCart.Items {
-			if existingItems.Product_id == item.Product_id {
+			if existingItems.Product_id == item.Product_id && existingItems.Sku == item.Sku {
 				existingCart.Items[i].Quantity += item.Quantity
-				existingCart.Items[i].Total = CalculateTotal(existingCart.Items[i].Price, existingCart.Items[i].Quantity)
+				existingCart.Items[i].Total, err = CalculateTotal(existingCart.Items[i].Price, existingCart.Items[i].Quantity)
+				if err != nil {
+					return nil, err
+				}
 				found = true
 				break
 			}
 		}
 		if !found {
 			cartItemID := primitive.NewObjectID()
-			total := CalculateTotal(item.Price, item.Quantity)
+			total, err := CalculateTotal(item.Price, item.Quantity)
+			if err != nil {
+				return nil, err
+			}
 			cartItem := model.CartItem{
 				ID:          cartItemID,
 				Product_id:  item.Product_id,
+				Sku:         item.Sku,
 				Price:       item.Price,
 				Quantity:    item.Quantity,
 				Total:       total,
@@ -266,13 +408,16 @@ func (c *CartService) AddItemsToCart(userID string, items []model.CartItem) (*mo
 	}
 
 	// Recalculate total
-	existingCart.TotalAmount = CalculateCartTotal(existingCart.Items)
+	if existingCart.TotalAmount, err = CalculateCartTotal(existingCart.Items, currency); err != nil {
+		return nil, err
+	}
+	existingCart.Currency = currency
 	existingCart.Updated_at = time.Now().UTC()
 
 	return c.cartRepo.UpdateCart(existingCart)
 }
 
-func (c *CartService) UpdateCartItem(userID string, productID string, quantity int) (*model.Cart, error) {
+func (c *CartService) UpdateCartItem(userID string, productID string, sku string, quantity int) (*model.Cart, error) {
 	if userID == "" {
 		return nil, errors.ErrInvalidUserID
 	}
@@ -288,13 +433,18 @@ func (c *CartService) UpdateCartItem(userID string, productID string, quantity i
 	if err != nil {
 		return nil, err
 	}
+	if err := editable(existingCart); err != nil {
+		return nil, err
+	}
 
 	// Find and update item
 	found := false
 	for i, item := range existingCart.Items {
-		if item.Product_id == productID {
+		if item.Product_id == productID && item.Sku == sku {
 			existingCart.Items[i].Quantity = quantity
-			existingCart.Items[i].Total = CalculateTotal(item.Price, quantity)
+			if existingCart.Items[i].Total, err = CalculateTotal(item.Price, quantity); err != nil {
+				return nil, err
+			}
 			found = true
 			break
 		}
@@ -305,13 +455,16 @@ func (c *CartService) UpdateCartItem(userID string, productID string, quantity i
 	}
 
 	// Recalculate total
-	existingCart.TotalAmount = CalculateCartTotal(existingCart.Items)
+	existingCart.Currency = cartCurrency(existingCart)
+	if existingCart.TotalAmount, err = CalculateCartTotal(existingCart.Items, existingCart.Currency); err != nil {
+		return nil, err
+	}
 	existingCart.Updated_at = time.Now().UTC()
 
 	return c.cartRepo.UpdateCart(existingCart)
 }
 
-func (c *CartService) RemoveItemFromCart(userID string, productID string) (*model.Cart, error) {
+func (c *CartService) RemoveItemFromCart(userID string, productID string, sku string) (*model.Cart, error) {
 	if userID == "" {
 		return nil, errors.ErrInvalidUserID
 	}
@@ -324,12 +477,15 @@ func (c *CartService) RemoveItemFromCart(userID string, productID string) (*mode
 	if err != nil {
 		return nil, err
 	}
+	if err := editable(existingCart); err != nil {
+		return nil, err
+	}
 
 	// Remove item
 	newItems := make([]model.CartItem, 0)
 	found := false
 	for _, item := range existingCart.Items {
-		if item.Product_id != productID {
+		if item.Product_id != productID || item.Sku != sku {
 			newItems = append(newItems, item)
 		} else {
 			found = true
@@ -341,7 +497,10 @@ func (c *CartService) RemoveItemFromCart(userID string, productID string) (*mode
 	}
 
 	existingCart.Items = newItems
-	existingCart.TotalAmount = CalculateCartTotal(existingCart.Items)
+	existingCart.Currency = cartCurrency(existingCart)
+	if existingCart.TotalAmount, err = CalculateCartTotal(existingCart.Items, existingCart.Currency); err != nil {
+		return nil, err
+	}
 	existingCart.Updated_at = time.Now().UTC()
 
 	return c.cartRepo.UpdateCart(existingCart)
@@ -357,7 +516,7 @@ func (c *CartService) ClearCart(userID string) error {
 // Calling the details through the gRPC client
 
 // PreparePaymentItems fetches product details for cart items and converts them to payment items
-// This method is used to prepare cart items with product details for Stripe payment
+// This method is used to prepare cart items with product details for Stripe payment, priced in the cart currency
 func (c *CartService) PreparePaymentItems(ctx context.Context, cart *model.Cart) ([]payment.PaymentItem, error) {
 	if cart == nil {
 		return nil, fmt.Errorf("cart cannot be nil")
@@ -369,20 +528,26 @@ func (c *CartService) PreparePaymentItems(ctx context.Context, cart *model.Cart)
 		return nil, fmt.Errorf("product client not available")
 	}
 
+	// Fetch product details from product service
+	products, err := c.productClient.GetProducts(ctx, productKeys(cart.Items))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
 	paymentItems := make([]payment.PaymentItem, 0, len(cart.Items))
 
 	for _, item := range cart.Items {
-		// Fetch product details from product service
-		product, err := c.productClient.GetProduct(ctx, item.Product_id)
+		product := products[pkg.ProductKey{ProductID: item.Product_id, Sku: item.Sku}]
+		price, _, err := c.priceIn(ctx, product, item, cartCurrency(cart))
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch product %s: %w", item.Product_id, err)
+			return nil, err
 		}
 
 		// Create payment item with product details
 		paymentItem := payment.PaymentItem{
-			Name:        product.Name,
+			Name:        variantName(product),
 			Description: product.Description,
-			Price:       product.Price,
+			Price:       price,
 			Quantity:    int64(item.Quantity),
 		}
 		paymentItems = append(paymentItems, paymentItem)
@@ -390,3 +555,21 @@ func (c *CartService) PreparePaymentItems(ctx context.Context, cart *model.Cart)
 
 	return paymentItems, nil
 }
+
+// variantName names the product with the attributes of its variant, such as
+// "T-Shirt (M / Red)"
+func variantName(product *proto.GetPRoductResponse) string {
+	if product.Variant == nil || len(product.Variant.Attributes) == 0 {
+		return product.Name
+	}
+	keys := make([]string, 0, len(product.Variant.Attributes))
+	for key := range product.Variant.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, product.Variant.Attributes[key])
+	}
+	return product.Name + " (" + strings.Join(values, " / ") + ")"
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	stderrors "errors"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/sachinggsingh/e-comm/internal/errors"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/pb/money"
+)
+
+func TestCartTotalIsTheSumOfItsLines(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 1000 {
+		items := make([]model.CartItem, r.IntN(10))
+		want := int64(0)
+		for i := range items {
+			price := money.New(1+r.Int64N(1_000_000), "JPY")
+			quantity := 1 + r.IntN(50)
+			total, err := CalculateTotal(price, quantity)
+			if err != nil {
+				t.Fatalf("CalculateTotal: %v", err)
+			}
+			if total != money.New(price.Amount*int64(quantity), "JPY") {
+				t.Fatalf("line total %s, want %d x %s", total, quantity, price)
+			}
+			items[i] = model.CartItem{Price: price, Quantity: quantity, Total: total}
+			want += total.Amount
+		}
+
+		total, err := CalculateCartTotal(items, "JPY")
+		if err != nil || total != money.New(want, "JPY") {
+			t.Fatalf("cart total %v, %v; want %d JPY", total, err, want)
+		}
+	}
+}
+
+func TestCartTotalRefusesOverflowAndOtherCurrencies(t *testing.T) {
+	if _, err := CalculateTotal(money.New(1<<62, "USD"), 2); !stderrors.Is(err, errors.ErrInvalidQuantity) {
+		t.Errorf("overflowing line: err = %v, want ErrInvalidQuantity", err)
+	}
+	half := money.New(1<<62, "USD")
+	cases := map[string][]model.CartItem{
+		"overflow": {{Total: half}, {Total: half}},
+		"currency": {{Total: money.New(100, "EUR")}},
+	}
+	for name, items := range cases {
+		if _, err := CalculateCartTotal(items, "USD"); !stderrors.Is(err, errors.ErrInvalidItem) {
+			t.Errorf("%s: err = %v, want ErrInvalidItem", name, err)
+		}
+	}
+}
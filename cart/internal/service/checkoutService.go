@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	carterrors "github.com/sachinggsingh/e-comm/internal/errors"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/pkg/payment"
+	proto "github.com/sachinggsingh/e-comm/pb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CheckoutPaymentWindow is how long a checkout can be paid, the least
+	// Stripe allows for a session
+	CheckoutPaymentWindow = 30 * time.Minute
+	// checkoutHold is how long the stock of a checkout is held. It outlasts
+	// the payment window so a payment made at its very end still finds the
+	// stock held when the checkout is confirmed.
+	checkoutHold = 45 * time.Minute
+)
+
+// ReserveCart holds the stock of the cart items for a new checkout and
+// returns the reservation id. The stock held for an earlier checkout of the
+// cart is given back first.
+func (c *CartService) ReserveCart(ctx context.Context, cart *model.Cart) (string, error) {
+	if c.productClient == nil {
+		return "", fmt.Errorf("product client not available")
+	}
+	if cart.Reservation_id != "" {
+		if err := c.productClient.ReleaseReservation(ctx, cart.Reservation_id); err != nil {
+			return "", err
+		}
+	}
+
+	items := make([]*proto.StockItem, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		items = append(items, &proto.StockItem{ProductId: item.Product_id, Sku: item.Sku, Quantity: int64(item.Quantity)})
+	}
+	reservationID := cart.Cart_id + "-" + primitive.NewObjectID().Hex()
+	if _, err := c.productClient.ReserveStock(ctx, reservationID, items, checkoutHold); err != nil {
+		return "", err
+	}
+	return reservationID, nil
+}
+
+// PlaceOrder places a pending order for the checkout of the cart and returns
+// its id. The order keeps the lines as charged: items are the payment items
+// of the cart, in the order of its items, as PreparePaymentItems returns
+// them.
+func (c *CartService) PlaceOrder(ctx context.Context, cart *model.Cart, email string, reservationID string, items []payment.PaymentItem) (string, error) {
+	if c.orderClient == nil {
+		return "", fmt.Errorf("order client not available")
+	}
+	if len(items) != len(cart.Items) {
+		return "", fmt.Errorf("%d payment items for %d cart items", len(items), len(cart.Items))
+	}
+
+	lines := make([]*proto.OrderLine, 0, len(items))
+	for i, item := range items {
+		lines = append(lines, &proto.OrderLine{
+			ProductId: cart.Items[i].Product_id,
+			Sku:       cart.Items[i].Sku,
+			Name:      item.Name,
+			Price:     &proto.Money{Amount: item.Price.Amount, Currency: item.Price.Currency},
+			Quantity:  item.Quantity,
+		})
+	}
+	order, err := c.orderClient.CreateOrder(ctx, &proto.CreateOrderRequest{
+		UserId:        cart.User_id,
+		Email:         email,
+		CartId:        cart.Cart_id,
+		Currency:      cartCurrency(cart),
+		Lines:         lines,
+		ReservationId: reservationID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return order.OrderId, nil
+}
+
+// StartCheckout records the reservation, payment session and order of the
+// checkout
+func (c *CartService) StartCheckout(userID string, reservationID string, sessionID string, orderID string) error {
+	return c.cartRepo.SetCheckout(userID, reservationID, sessionID, orderID)
+}
+
+// CancelOrder cancels the order of a checkout that will not be paid
+func (c *CartService) CancelOrder(ctx context.Context, orderID string, reason string) {
+	if orderID == "" || c.orderClient == nil {
+		return
+	}
+	if _, err := c.orderClient.CancelOrder(ctx, orderID, reason); err != nil {
+		log.Printf("Failed to cancel order %s: %v", orderID, err)
+	}
+}
+
+// ReleaseReservation gives back stock held for a checkout that did not start
+func (c *CartService) ReleaseReservation(ctx context.Context, reservationID string) {
+	if err := c.productClient.ReleaseReservation(ctx, reservationID); err != nil {
+		log.Printf("Failed to release reservation %s: %v", reservationID, err)
+	}
+}
+
+// CompleteCheckout keeps the reserved stock sold, marks the order paid and
+// empties the cart, once the checkout is paid. It returns ErrReservationLost
+// when the stock was released before; the order is marked paid and flagged
+// for refund then, and the cart is kept for another checkout. Every step may
+// be done again, so a checkout that failed halfway is completed by calling
+// it again. The cart is left alone once it no longer waits for the payment
+// session.
+func (c *CartService) CompleteCheckout(ctx context.Context, cart *model.Cart) error {
+	committed := c.productClient.CommitReservation(ctx, cart.Reservation_id)
+	if committed != nil && !errors.Is(committed, carterrors.ErrReservationLost) {
+		return committed
+	}
+	// Checkouts started before orders existed have none to flag, the
+	// payment is logged for a refund instead
+	if cart.Order_id == "" && committed != nil {
+		log.Printf("Payment session %s of user %s was paid after the stock hold expired and has no order to flag, refund it in Stripe",
+			cart.Checkout_session_id, cart.User_id)
+	}
+	if cart.Order_id != "" {
+		refundReason := ""
+		if committed != nil {
+			refundReason = "paid after the stock hold expired"
+		}
+		if _, err := c.orderClient.MarkOrderPaid(ctx, cart.Order_id, cart.Checkout_session_id, refundReason); err != nil {
+			return err
+		}
+	}
+	if committed != nil {
+		// The cart keeps its items for another checkout
+		if err := c.cartRepo.ClearCheckout(cart.User_id, cart.Checkout_session_id); err != nil && !errors.Is(err, carterrors.ErrCartNotFound) {
+			return err
+		}
+		return committed
+	}
+	if err := c.cartRepo.DeleteCheckedOutCart(cart.User_id, cart.Checkout_session_id); err != nil && !errors.Is(err, carterrors.ErrCartNotFound) {
+		return err
+	}
+	return nil
+}
+
+// SettleCheckout completes the checkout paid by a payment session, as told
+// by the payment webhook. The checkout is that of the metadata of the
+// session, so it is settled even when the cart was deleted meanwhile.
+func (c *CartService) SettleCheckout(ctx context.Context, userID string, sessionID string, orderID string, reservationID string) error {
+	checkout, err := c.sessionCheckout(userID, sessionID, orderID, reservationID)
+	if err != nil || checkout == nil {
+		return err
+	}
+	return c.CompleteCheckout(ctx, checkout)
+}
+
+// ExpireCheckout cancels the checkout of a payment session once the session
+// expired unpaid, as told by the payment webhook
+func (c *CartService) ExpireCheckout(ctx context.Context, userID string, sessionID string, orderID string, reservationID string) error {
+	checkout, err := c.sessionCheckout(userID, sessionID, orderID, reservationID)
+	if err != nil || checkout == nil {
+		return err
+	}
+	return c.CancelCheckout(ctx, checkout, "payment expired")
+}
+
+// sessionCheckout returns the checkout a payment session is for, built from
+// the ids in its metadata. Sessions opened before they carried the
+// reservation are looked up in the cart of the user; nil is returned when
+// the cart no longer waits for them.
+func (c *CartService) sessionCheckout(userID string, sessionID string, orderID string, reservationID string) (*model.Cart, error) {
+	if userID == "" {
+		return nil, carterrors.ErrInvalidUserID
+	}
+	if reservationID != "" {
+		return &model.Cart{
+			User_id:             userID,
+			Reservation_id:      reservationID,
+			Checkout_session_id: sessionID,
+			Order_id:            orderID,
+		}, nil
+	}
+	cart, err := c.cartRepo.FindCartByUserID(userID)
+	if err != nil {
+		if errors.Is(err, carterrors.ErrCartNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if cart.Checkout_session_id != sessionID {
+		return nil, nil
+	}
+	return cart, nil
+}
+
+// CancelCheckout gives back the stock of an unpaid checkout, cancels its
+// order for reason and keeps the cart for another attempt. The payment
+// session must be closed before, so it can no longer be paid.
+func (c *CartService) CancelCheckout(ctx context.Context, cart *model.Cart, reason string) error {
+	if err := c.productClient.ReleaseReservation(ctx, cart.Reservation_id); err != nil {
+		return err
+	}
+	if cart.Order_id != "" {
+		if _, err := c.orderClient.CancelOrder(ctx, cart.Order_id, reason); err != nil {
+			return err
+		}
+	}
+	if err := c.cartRepo.ClearCheckout(cart.User_id, cart.Checkout_session_id); err != nil && !errors.Is(err, carterrors.ErrCartNotFound) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/errors"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/money"
+)
+
+// Pricing prices carts in their currency
+type Pricing struct {
+	// Currency is the currency of carts that did not choose one
+	Currency string
+	// Rates convert prices to the cart currency. Without them only products
+	// priced in it, or with a fixed price in it, can be added.
+	Rates money.RateProvider
+	// Rounding rounds converted prices
+	Rounding money.RoundingRules
+}
+
+// cartCurrency returns the currency of the cart, that of carts created
+// before carts had one being the default currency, which their prices
+// were migrated to
+func cartCurrency(cart *model.Cart) string {
+	if cart.Currency == "" {
+		return money.DefaultCurrency
+	}
+	return cart.Currency
+}
+
+// chooseCurrency checks a currency a buyer asked for, falling back to the
+// default one when empty
+func (c *CartService) chooseCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return c.pricing.Currency, nil
+	}
+	if _, err := money.Exponent(currency); err != nil {
+		return "", fmt.Errorf("%w: %v", errors.ErrInvalidCurrency, err)
+	}
+	return currency, nil
+}
+
+// priceIn returns the price and stock of the item in currency. A fixed price
+// of the product in currency is charged as is; other prices are converted
+// at the current rate and rounded by the rules of currency, so the unit
+// price is what is charged and totals are exact multiples of it.
+func (c *CartService) priceIn(ctx context.Context, product *proto.GetPRoductResponse, item model.CartItem, currency string) (money.Money, int64, error) {
+	price, stock, err := offer(product, item)
+	if err != nil || price.Currency == currency {
+		return price, stock, err
+	}
+
+	list := product.PriceList
+	if product.Variant != nil {
+		list = product.Variant.PriceList
+	}
+	for _, fixed := range list {
+		if fixed.Currency == currency {
+			return money.New(fixed.Amount, fixed.Currency), stock, nil
+		}
+	}
+
+	if c.pricing.Rates == nil {
+		return money.Money{}, 0, fmt.Errorf("%w: product %s has no price in %s", errors.ErrInvalidCurrency, item.Product_id, currency)
+	}
+	table, err := c.pricing.Rates.Rates(ctx)
+	if err != nil {
+		return money.Money{}, 0, fmt.Errorf("failed to get exchange rates: %w", err)
+	}
+	rate, err := table.Rate(price.Currency, currency)
+	if err != nil {
+		if stderrors.Is(err, money.ErrNoRate) {
+			return money.Money{}, 0, fmt.Errorf("%w: cannot convert %s to %s", errors.ErrInvalidCurrency, price.Currency, currency)
+		}
+		return money.Money{}, 0, err
+	}
+	converted, err := price.Convert(currency, rate, c.pricing.Rounding.For(currency))
+	if err != nil {
+		return money.Money{}, 0, fmt.Errorf("%w: product %s: %v", errors.ErrInvalidPrice, item.Product_id, err)
+	}
+	return converted, stock, nil
+}
+
+// SetCurrency switches the cart of the user to currency and prices its
+// items in it again. A cart with a pending checkout keeps the currency its
+// order and payment session are in, ErrCheckoutPending is returned.
+func (c *CartService) SetCurrency(userID string, currency string) (*model.Cart, error) {
+	if userID == "" {
+		return nil, errors.ErrInvalidUserID
+	}
+	if strings.TrimSpace(currency) == "" {
+		return nil, fmt.Errorf("%w: currency is required", errors.ErrInvalidCurrency)
+	}
+	currency, err := c.chooseCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	existingCart, err := c.cartRepo.FindCartByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := editable(existingCart); err != nil {
+		return nil, err
+	}
+	items, err := c.ValidateProductsWithGRPC(context.Background(), existingCart.Items, currency)
+	if err != nil {
+		return nil, fmt.Errorf("product validation failed: %w", err)
+	}
+	total, err := CalculateCartTotal(items, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	existingCart.Items = items
+	existingCart.Currency = currency
+	existingCart.TotalAmount = total
+	existingCart.Updated_at = time.Now().UTC()
+	return c.cartRepo.UpdateCart(existingCart)
+}
@@ -3,25 +3,38 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 
 	grpc_client "github.com/sachinggsingh/e-comm/internal/gRPC"
 	"github.com/sachinggsingh/e-comm/internal/handler"
+	"github.com/sachinggsingh/e-comm/pb/jwks"
 )
 
+// getEnv returns the environment variable key, or fallback when it is unset
+func getEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func main() {
+	productAddr := getEnv("PRODUCT_SERVICE_URL", "localhost:9091")
 	client, err := grpc_client.NewClient(
-		"localhost:9090", // auth
-		"localhost:9091", // product (also hosts ShowProduct service)
-		"localhost:9091", // showProduct (same server as product)
+		getEnv("AUTH_SERVICE_URL", "localhost:9090"),
+		productAddr, // product (also hosts ShowProduct service)
+		productAddr, // showProduct (same server as product)
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	productHandler := handler.NewProductHandler(client.AuthClient, client.ProductClient, client.ShowProduct)
+	keys := jwks.NewCache(getEnv("AUTH_JWKS_URL", "http://localhost:8080/.well-known/jwks.json"))
+	productHandler := handler.NewProductHandler(client.AuthClient, client.ProductClient, client.ShowProduct, client.Search, keys)
 	mux := http.NewServeMux()
 	mux.HandleFunc("/gateway/product/", productHandler.GetProductGateway)
 	mux.HandleFunc("/gateway/showproduct/", productHandler.ShowProductGateway)
+	mux.HandleFunc("/gateway/search", productHandler.SearchGateway)
 
 	log.Println("Gateway running at :8085")
 	http.ListenAndServe(":8085", mux)
@@ -3,6 +3,7 @@ module github.com/sachinggsingh/e-comm
 go 1.25.3
 
 require (
+	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/sachinggsingh/e-comm/pb v0.0.0
 	google.golang.org/grpc v1.76.0
 )
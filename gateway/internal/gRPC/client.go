@@ -10,6 +10,7 @@ type Clients struct {
 	AuthClient    proto.ValidateTokenClient
 	ProductClient proto.GetProductsClient
 	ShowProduct   proto.ShowProductClient
+	Search        proto.SearchProductsClient
 }
 
 func NewClientWithConn(authConn *grpc.ClientConn, productConn *grpc.ClientConn, showProductConn *grpc.ClientConn) *Clients {
@@ -17,6 +18,7 @@ func NewClientWithConn(authConn *grpc.ClientConn, productConn *grpc.ClientConn,
 		AuthClient:    proto.NewValidateTokenClient(authConn),
 		ProductClient: proto.NewGetProductsClient(productConn),
 		ShowProduct:   proto.NewShowProductClient(showProductConn),
+		Search:        proto.NewSearchProductsClient(productConn),
 	}
 }
 
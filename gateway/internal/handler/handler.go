@@ -1,41 +1,79 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/golang-jwt/jwt/v5"
 	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+	"github.com/sachinggsingh/e-comm/pb/jwks"
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ProductHandler struct {
 	auth        proto.ValidateTokenClient
 	product     proto.GetProductsClient
 	showProduct proto.ShowProductClient
+	search      proto.SearchProductsClient
+	keys        *jwks.Cache
 }
 
-func NewProductHandler(auth proto.ValidateTokenClient, product proto.GetProductsClient, showProduct proto.ShowProductClient) *ProductHandler {
-	return &ProductHandler{auth, product, showProduct}
+func NewProductHandler(auth proto.ValidateTokenClient, product proto.GetProductsClient, showProduct proto.ShowProductClient, search proto.SearchProductsClient, keys *jwks.Cache) *ProductHandler {
+	return &ProductHandler{auth, product, showProduct, search, keys}
+}
+
+// verifyToken checks the token signature against the auth service's published
+// keys before asking auth about it, so forged tokens never leave the gateway
+func (h *ProductHandler) verifyToken(ctx context.Context, token string) error {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return h.keys.Key(ctx, kid)
+	}, jwt.WithValidMethods([]string{"RS256", "EdDSA"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return err
+	}
+	if typ, _ := claims["typ"].(string); typ != "access" {
+		return errors.New("not an access token")
+	}
+	return nil
 }
 
 func (h *ProductHandler) GetProductGateway(w http.ResponseWriter, r *http.Request) {
-	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
-	parts := strings.SplitN(authHeader, " ", 2)
-	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
-		http.Error(w, "missing or malformed bearer token", http.StatusUnauthorized)
+	token, err := authz.Credential(r)
+	if err != nil {
+		http.Error(w, "missing or malformed bearer token or api key", http.StatusUnauthorized)
 		return
 	}
 
-	res, err := h.auth.ValidateToken(r.Context(), &proto.ValidateTokenRequest{Token: strings.TrimSpace(parts[1])})
+	// API keys are opaque, only the auth service can check them
+	if !strings.HasPrefix(token, authz.APIKeyPrefix) {
+		if err := h.verifyToken(r.Context(), token); err != nil {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	caller, err := h.auth.Introspect(r.Context(), &proto.IntrospectRequest{Token: token})
 	if err != nil {
 		http.Error(w, "token validation failed", http.StatusUnauthorized)
 		fmt.Println()
 		return
 	}
-	if !res.Valid {
+	if !caller.Active {
+		if caller.Revoked {
+			http.Error(w, "token has been revoked", http.StatusUnauthorized)
+			return
+		}
 		http.Error(w, "invalid token", http.StatusUnauthorized)
 		return
 	}
@@ -57,6 +95,7 @@ func (h *ProductHandler) GetProductGateway(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{
 		"authorized": true,
+		"user_id":    caller.UserId,
 		"product":    productData,
 	})
 }
@@ -92,10 +131,12 @@ func (h *ProductHandler) ShowProductGateway(w http.ResponseWriter, r *http.Reque
 			return
 		}
 		product := map[string]any{
-			"id":          res.Id,
-			"name":        res.Name,
-			"description": res.Description,
-			"price":       res.Price,
+			"id":           res.Id,
+			"name":         res.Name,
+			"description":  res.Description,
+			"price":        money.New(res.PriceAmount, res.Currency),
+			"category_ids": res.CategoryIds,
+			"images":       res.Images,
 		}
 		products = append(products, product)
 	}
@@ -105,3 +146,58 @@ func (h *ProductHandler) ShowProductGateway(w http.ResponseWriter, r *http.Reque
 	})
 
 }
+
+// SearchGateway forwards a search to the product service. It takes the same
+// query parameters as GET /product/search.
+func (h *ProductHandler) SearchGateway(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	req := &proto.SearchProductsRequest{
+		Query:    values.Get("q"),
+		Category: values.Get("category"),
+		Currency: values.Get("currency"),
+	}
+	currency := req.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	for name, n := range map[string]*int32{"limit": &req.Limit, "offset": &req.Offset} {
+		if value := values.Get(name); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				http.Error(w, name+" must be a number", http.StatusBadRequest)
+				return
+			}
+			*n = int32(parsed)
+		}
+	}
+	for name, bound := range map[string]**int64{"min_price": &req.MinPriceAmount, "max_price": &req.MaxPriceAmount} {
+		if value := values.Get(name); value != "" {
+			price, err := money.Parse(value, currency)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s: %v", name, err), http.StatusBadRequest)
+				return
+			}
+			*bound = &price.Amount
+		}
+	}
+	if inStock := values.Get("in_stock"); inStock != "" {
+		only, err := strconv.ParseBool(inStock)
+		if err != nil {
+			http.Error(w, "in_stock must be true or false", http.StatusBadRequest)
+			return
+		}
+		req.InStock = only
+	}
+
+	res, err := h.search.SearchProducts(r.Context(), req)
+	if err != nil {
+		if status.Code(err) == codes.InvalidArgument {
+			http.Error(w, status.Convert(err).Message(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf("unable to search products: %v", err), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sachinggsingh/e-comm/internal/api"
+	"github.com/sachinggsingh/e-comm/internal/config"
+	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"github.com/sachinggsingh/e-comm/internal/service"
+	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	env := config.SetEnv()
+	database := db.NewDatabase()
+	err := database.ConnectToDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer database.Disconnect()
+
+	server := api.NewServer(env, database)
+	orderService := service.NewOrderService(repository.NewOrderRepository(database))
+
+	// Callers are authenticated by introspecting their token with the auth service
+	authConn, err := grpc.NewClient(env.AUTH_SERVICE_URL, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to initialize auth gRPC client: %v", err)
+	}
+	defer authConn.Close()
+	authenticator := authz.NewIntrospectionAuthenticator(proto.NewValidateTokenClient(authConn))
+
+	server.OrderRoutes(orderService, authenticator)
+
+	go func() {
+		if err := server.StartServer(); err != nil {
+			fmt.Printf("Failed to start HTTP server: %v\n", err)
+		}
+	}()
+
+	// Start gRPC Server
+	go func() {
+		if err := server.GrpcServer(orderService); err != nil {
+			fmt.Printf("Failed to start gRPC server: %v\n", err)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	<-sigChan
+
+	fmt.Println("Shutting down")
+}
@@ -0,0 +1,104 @@
+package grpc_handler
+
+import (
+	"context"
+	"errors"
+
+	ordererrors "github.com/sachinggsingh/e-comm/internal/errors"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/service"
+	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type OrderServer struct {
+	proto.UnimplementedOrdersServer
+	orderService *service.OrderService
+}
+
+func NewOrderServer(orderService *service.OrderService) *OrderServer {
+	return &OrderServer{
+		orderService: orderService,
+	}
+}
+
+func (o *OrderServer) CreateOrder(ctx context.Context, req *proto.CreateOrderRequest) (*proto.OrderResponse, error) {
+	lines := make([]model.OrderLine, 0, len(req.Lines))
+	for _, line := range req.Lines {
+		if line.Price == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "line of product %s has no price", line.ProductId)
+		}
+		lines = append(lines, model.OrderLine{
+			Product_id: line.ProductId,
+			Sku:        line.Sku,
+			Name:       line.Name,
+			Price:      money.New(line.Price.Amount, line.Price.Currency),
+			Quantity:   line.Quantity,
+		})
+	}
+
+	order, err := o.orderService.CreateOrder(&model.Order{
+		User_id:        req.UserId,
+		Email:          req.Email,
+		Cart_id:        req.CartId,
+		Lines:          lines,
+		Currency:       req.Currency,
+		Reservation_id: req.ReservationId,
+	})
+	if err != nil {
+		return nil, orderStatus(err)
+	}
+	return orderResponse(order), nil
+}
+
+func (o *OrderServer) GetOrder(ctx context.Context, req *proto.GetOrderRequest) (*proto.OrderResponse, error) {
+	order, err := o.orderService.GetOrder(req.OrderId)
+	if err != nil {
+		return nil, orderStatus(err)
+	}
+	return orderResponse(order), nil
+}
+
+func (o *OrderServer) MarkOrderPaid(ctx context.Context, req *proto.MarkOrderPaidRequest) (*proto.OrderResponse, error) {
+	order, err := o.orderService.MarkPaid(req.OrderId, req.PaymentId, req.RefundReason)
+	if err != nil {
+		return nil, orderStatus(err)
+	}
+	return orderResponse(order), nil
+}
+
+func (o *OrderServer) CancelOrder(ctx context.Context, req *proto.CancelOrderRequest) (*proto.OrderResponse, error) {
+	order, err := o.orderService.CancelPending(req.OrderId, req.Reason)
+	if err != nil {
+		return nil, orderStatus(err)
+	}
+	return orderResponse(order), nil
+}
+
+func orderResponse(order *model.Order) *proto.OrderResponse {
+	return &proto.OrderResponse{
+		OrderId:     order.Order_id,
+		UserId:      order.User_id,
+		Status:      string(order.Status),
+		Total:       &proto.Money{Amount: order.Total.Amount, Currency: order.Total.Currency},
+		CreatedAt:   order.Created_at.Unix(),
+		NeedsRefund: order.Needs_refund,
+	}
+}
+
+// orderStatus maps service errors to gRPC status codes
+func orderStatus(err error) error {
+	switch {
+	case errors.Is(err, ordererrors.ErrInvalidOrder), errors.Is(err, ordererrors.ErrInvalidUserID):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ordererrors.ErrOrderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ordererrors.ErrInvalidTransition):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ordererrors.ErrOrderChanged):
+		return status.Error(codes.Aborted, err.Error())
+	}
+	return status.Errorf(codes.Internal, "%v", err)
+}
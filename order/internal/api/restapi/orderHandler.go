@@ -0,0 +1,138 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	ordererrors "github.com/sachinggsingh/e-comm/internal/errors"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/service"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+)
+
+type OrderHandler struct {
+	orderService *service.OrderService
+}
+
+func NewOrderHandler(orderService *service.OrderService) *OrderHandler {
+	return &OrderHandler{
+		orderService: orderService,
+	}
+}
+
+// ListMyOrders lists the orders of the caller, newest first. Query
+// parameters: status, limit and cursor.
+func (o *OrderHandler) ListMyOrders(w http.ResponseWriter, r *http.Request) {
+	principal := authz.PrincipalFromContext(r.Context())
+	query, err := parseOrderQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.User_id = principal.UserID
+
+	page, err := o.orderService.ListOrders(query)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// ListOrders lists the orders of all users, newest first. Query parameters:
+// user_id, status, limit and cursor.
+func (o *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	query, err := parseOrderQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.User_id = r.URL.Query().Get("user_id")
+
+	page, err := o.orderService.ListOrders(query)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GetOrder returns an order of the caller, or any order to callers with the
+// order:manage permission. Orders of other users are not found.
+func (o *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	principal := authz.PrincipalFromContext(r.Context())
+	order, err := o.orderService.GetOrder(mux.Vars(r)["order_id"])
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	if order.User_id != principal.UserID && !principal.HasPermission(authz.PermOrderManage) {
+		http.Error(w, ordererrors.ErrOrderNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+// SetStatus moves an order to the status of the body, recording the reason
+// and the admin who did it
+func (o *OrderHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	principal := authz.PrincipalFromContext(r.Context())
+	var req model.SetStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	order, err := o.orderService.SetStatus(mux.Vars(r)["order_id"], req.Status, principal.UserID, req.Reason)
+	if err != nil {
+		writeOrderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+func parseOrderQuery(r *http.Request) (*model.OrderQuery, error) {
+	values := r.URL.Query()
+	query := &model.OrderQuery{
+		Status: model.Status(values.Get("status")),
+		Cursor: values.Get("cursor"),
+	}
+	if needsRefund := values.Get("needs_refund"); needsRefund != "" {
+		flag, err := strconv.ParseBool(needsRefund)
+		if err != nil {
+			return nil, errors.New("needs_refund must be true or false")
+		}
+		query.Needs_refund = flag
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.New("limit must be a number")
+		}
+		query.Limit = n
+	}
+	return query, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeOrderError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ordererrors.ErrInvalidQuery), errors.Is(err, ordererrors.ErrInvalidStatus),
+		errors.Is(err, ordererrors.ErrInvalidOrder):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ordererrors.ErrOrderNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ordererrors.ErrInvalidTransition), errors.Is(err, ordererrors.ErrOrderChanged):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	grpc_handler "github.com/sachinggsingh/e-comm/internal/api/grpc"
+	"github.com/sachinggsingh/e-comm/internal/api/restapi"
+	"github.com/sachinggsingh/e-comm/internal/config"
+	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/service"
+	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+	"google.golang.org/grpc"
+)
+
+type Server struct {
+	env *config.Env
+	db  *db.Database
+	r   *mux.Router
+}
+
+func NewServer(env *config.Env, database *db.Database) *Server {
+	return &Server{
+		env: env,
+		db:  database,
+		r:   mux.NewRouter(),
+	}
+}
+
+func (s *Server) StartServer() error {
+	addr := fmt.Sprintf(":%s", s.env.PORT)
+	log.Printf("Starting server on port %s\n", s.env.PORT)
+
+	if err := http.ListenAndServe(addr, s.r); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+	return nil
+}
+
+// GrpcServer serves the Orders service the cart service checks out with
+func (s *Server) GrpcServer(orderService *service.OrderService) error {
+	lis, err := net.Listen("tcp", ":9092")
+	if err != nil {
+		log.Fatalf(" gRPC failed to listen on :9092: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	proto.RegisterOrdersServer(grpcServer, grpc_handler.NewOrderServer(orderService))
+
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalf(" gRPC server failed to serve: %v", err)
+	}
+	return nil
+}
+
+// OrderRoutes registers the order history routes. Users see their own
+// orders; listing all orders and changing their status require the
+// order:manage permission, resolved through authn.
+func (s *Server) OrderRoutes(orderService *service.OrderService, authn authz.Authenticator) {
+	orderHandler := restapi.NewOrderHandler(orderService)
+	requireAuth := authz.RequireAuthentication(authn)
+	requireManage := authz.RequirePermission(authn, authz.PermOrderManage)
+
+	s.r.Handle("/orders", requireAuth(http.HandlerFunc(orderHandler.ListMyOrders))).Methods("GET")
+	s.r.Handle("/orders/{order_id}", requireAuth(http.HandlerFunc(orderHandler.GetOrder))).Methods("GET")
+	s.r.Handle("/admin/orders", requireManage(http.HandlerFunc(orderHandler.ListOrders))).Methods("GET")
+	s.r.Handle("/admin/orders/{order_id}/status", requireManage(http.HandlerFunc(orderHandler.SetStatus))).Methods("POST")
+}
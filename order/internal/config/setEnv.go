@@ -0,0 +1,39 @@
+package config
+
+import (
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+type Env struct {
+	PORT             string
+	MONGO_URL        string
+	AUTH_SERVICE_URL string
+}
+
+func SetEnv() *Env {
+	load := godotenv.Load()
+	if load != nil {
+		log.Fatalf("Error loading .env file")
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		log.Fatalf("PORT is not set")
+	}
+	mongoURL := os.Getenv("MONGO_URL")
+	if mongoURL == "" {
+		log.Fatalf("MONGO_URL is not set")
+	}
+	authServiceURL := os.Getenv("AUTH_SERVICE_URL")
+	if authServiceURL == "" {
+		authServiceURL = "localhost:9090" // Default to auth service gRPC port
+	}
+	return &Env{
+		PORT:             port,
+		MONGO_URL:        mongoURL,
+		AUTH_SERVICE_URL: authServiceURL,
+	}
+}
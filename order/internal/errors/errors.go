@@ -0,0 +1,17 @@
+package errors
+
+import "errors"
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrInvalidOrder  = errors.New("invalid order")
+	ErrInvalidStatus = errors.New("invalid order status")
+	// ErrInvalidTransition means the order cannot move from its status to
+	// the one asked for
+	ErrInvalidTransition = errors.New("order status cannot change")
+	// ErrOrderChanged means the status of the order changed while it was
+	// being updated
+	ErrOrderChanged  = errors.New("order was changed by another request, try again")
+	ErrInvalidQuery  = errors.New("invalid order query")
+	ErrInvalidUserID = errors.New("user_id is required")
+)
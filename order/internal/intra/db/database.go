@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Database struct {
+	Client          *mongo.Client
+	Database        *mongo.Database
+	OrderCollection *mongo.Collection
+}
+
+func NewDatabase() *Database {
+	return &Database{}
+}
+
+func (d *Database) ConnectToDB() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	env := config.SetEnv()
+	uri := env.MONGO_URL
+	clientOptions := options.Client().ApplyURI(uri)
+
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return err
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		return err
+	}
+
+	d.Client = client
+	d.Database = client.Database("micro-ecomm")
+
+	d.OrderCollection = d.Database.Collection("orders")
+
+	if err := d.createIndexes(ctx); err != nil {
+		return err
+	}
+
+	log.Println("Connected to MongoDB!")
+	return nil
+}
+
+// createIndexes creates the indexes the order queries rely on. Creating an
+// existing index is a no-op. Listings are newest first by _id.
+func (d *Database) createIndexes(ctx context.Context) error {
+	_, err := d.OrderCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "order_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "needs_refund", Value: 1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"needs_refund": true})},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create order indexes: %w", err)
+	}
+	return nil
+}
+
+func (d *Database) Disconnect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return d.Client.Disconnect(ctx)
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Status is where an order is in its life: pending until the checkout is
+// paid, then fulfilled once shipped. Cancelled and refunded orders are done.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusPaid      Status = "paid"
+	StatusFulfilled Status = "fulfilled"
+	StatusCancelled Status = "cancelled"
+	StatusRefunded  Status = "refunded"
+)
+
+// transitions lists the statuses each status can move to
+var transitions = map[Status][]Status{
+	StatusPending:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusFulfilled, StatusCancelled, StatusRefunded},
+	StatusFulfilled: {StatusRefunded},
+}
+
+func (s Status) Valid() bool {
+	switch s {
+	case StatusPending, StatusPaid, StatusFulfilled, StatusCancelled, StatusRefunded:
+		return true
+	}
+	return false
+}
+
+// CanBecome reports whether an order in s can move to next
+func (s Status) CanBecome(next Status) bool {
+	return slices.Contains(transitions[s], next)
+}
+
+// OrderLine is a cart line as it was charged at checkout. Name and Price are
+// copies, later changes of the product leave the order alone.
+type OrderLine struct {
+	Product_id string `json:"product_id" bson:"product_id"`
+	// Sku is the variant bought, empty for products without variants
+	Sku      string      `json:"sku,omitempty" bson:"sku,omitempty"`
+	Name     string      `json:"name" bson:"name"`
+	Price    money.Money `json:"price" bson:"price"`
+	Quantity int64       `json:"quantity" bson:"quantity"`
+	Total    money.Money `json:"total" bson:"total"`
+}
+
+// StatusChange records an order moving to Status
+type StatusChange struct {
+	Status     Status    `json:"status" bson:"status"`
+	Changed_at time.Time `json:"changed_at" bson:"changed_at"`
+	// Changed_by is the user who changed it, empty for the checkout
+	Changed_by string `json:"changed_by,omitempty" bson:"changed_by,omitempty"`
+	Reason     string `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
+type Order struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Order_id string             `json:"order_id" bson:"order_id"`
+	User_id  string             `json:"user_id" bson:"user_id"`
+	Email    string             `json:"email,omitempty" bson:"email,omitempty"`
+	// Cart_id is the cart checked out
+	Cart_id  string      `json:"cart_id" bson:"cart_id"`
+	Lines    []OrderLine `json:"lines" bson:"lines"`
+	Currency string      `json:"currency" bson:"currency"`
+	Total    money.Money `json:"total" bson:"total"`
+	Status   Status      `json:"status" bson:"status"`
+	// History lists every status of the order, the first being pending
+	History []StatusChange `json:"history" bson:"history"`
+	// Reservation_id is the stock held for the order and Payment_id the
+	// payment session that paid it
+	Reservation_id string    `json:"reservation_id,omitempty" bson:"reservation_id,omitempty"`
+	Payment_id     string    `json:"payment_id,omitempty" bson:"payment_id,omitempty"`
+	Created_at     time.Time `json:"created_at" bson:"created_at"`
+	Updated_at     time.Time `json:"updated_at" bson:"updated_at"`
+	// Needs_refund flags a paid order that could not be fulfilled, for the
+	// reason in Refund_reason; refunding the order clears it
+	Needs_refund  bool   `json:"needs_refund,omitempty" bson:"needs_refund,omitempty"`
+	Refund_reason string `json:"refund_reason,omitempty" bson:"refund_reason,omitempty"`
+}
+
+// OrderQuery selects one page of orders, newest first
+type OrderQuery struct {
+	// User_id and Status filter the orders when set
+	User_id string
+	Status  Status
+	// Needs_refund only selects the orders flagged for refund
+	Needs_refund bool
+	// Limit is the page size
+	Limit int
+	// Cursor is the Next_cursor of the previous page, empty for the first
+	Cursor string
+}
+
+// OrderPage is one page of orders. Next_cursor is empty on the last page.
+type OrderPage struct {
+	Items       []*Order `json:"items"`
+	Next_cursor string   `json:"next_cursor,omitempty"`
+}
+
+// SetStatusRequest is an admin moving an order to Status
+type SetStatusRequest struct {
+	Status Status `json:"status"`
+	Reason string `json:"reason"`
+}
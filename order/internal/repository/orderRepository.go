@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/errors"
+	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OrderRepository interface {
+	CreateOrder(order *model.Order) (*model.Order, error)
+	FindOrder(orderID string) (*model.Order, error)
+	// ListOrders returns one page of the orders matching query, newest first
+	ListOrders(query *model.OrderQuery) (*model.OrderPage, error)
+	// SetStatus moves the order from status from to that of change and
+	// records change; a non-empty paymentID is recorded too. It returns
+	// ErrOrderChanged when the order is no longer in from.
+	SetStatus(orderID string, from model.Status, change model.StatusChange, paymentID string) (*model.Order, error)
+	// FlagRefund flags the order as needing a refund for reason
+	FlagRefund(orderID string, reason string) (*model.Order, error)
+}
+
+type orderRepository struct {
+	db *db.Database
+}
+
+func NewOrderRepository(db *db.Database) OrderRepository {
+	return &orderRepository{
+		db: db,
+	}
+}
+
+func (o *orderRepository) CreateOrder(order *model.Order) (*model.Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := o.db.OrderCollection.InsertOne(ctx, order)
+	if err != nil {
+		log.Printf("Error creating order: %v", err)
+		return nil, err
+	}
+	return order, nil
+}
+
+func (o *orderRepository) FindOrder(orderID string) (*model.Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var order model.Order
+	err := o.db.OrderCollection.FindOne(ctx, bson.M{"order_id": orderID}).Decode(&order)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrOrderNotFound
+		}
+		log.Printf("Error finding order: %v", err)
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ListOrders pages by _id, which grows with the creation time, so the
+// cursor is the _id of the last order of the previous page
+func (o *orderRepository) ListOrders(query *model.OrderQuery) (*model.OrderPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if query.User_id != "" {
+		filter["user_id"] = query.User_id
+	}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	if query.Needs_refund {
+		filter["needs_refund"] = true
+	}
+	if query.Cursor != "" {
+		after, err := primitive.ObjectIDFromHex(query.Cursor)
+		if err != nil {
+			return nil, errors.ErrInvalidQuery
+		}
+		filter["_id"] = bson.M{"$lt": after}
+	}
+
+	// One more than the page tells whether another page follows
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(query.Limit) + 1)
+	cursor, err := o.db.OrderCollection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error listing orders: %v", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	page := &model.OrderPage{Items: []*model.Order{}}
+	for cursor.Next(ctx) {
+		var order model.Order
+		if err := cursor.Decode(&order); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, &order)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Items) > query.Limit {
+		page.Items = page.Items[:query.Limit]
+		page.Next_cursor = page.Items[len(page.Items)-1].ID.Hex()
+	}
+	return page, nil
+}
+
+func (o *orderRepository) SetStatus(orderID string, from model.Status, change model.StatusChange, paymentID string) (*model.Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{
+		"status":     change.Status,
+		"updated_at": change.Changed_at,
+	}
+	if paymentID != "" {
+		set["payment_id"] = paymentID
+	}
+	update := bson.M{
+		"$set":  set,
+		"$push": bson.M{"history": change},
+	}
+	if change.Status == model.StatusRefunded {
+		update["$unset"] = bson.M{"needs_refund": ""}
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var order model.Order
+	err := o.db.OrderCollection.FindOneAndUpdate(ctx, bson.M{"order_id": orderID, "status": from}, update, opts).Decode(&order)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrOrderChanged
+		}
+		log.Printf("Error updating order status: %v", err)
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (o *orderRepository) FlagRefund(orderID string, reason string) (*model.Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"needs_refund":  true,
+		"refund_reason": reason,
+		"updated_at":    time.Now().UTC(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var order model.Order
+	err := o.db.OrderCollection.FindOneAndUpdate(ctx, bson.M{"order_id": orderID}, update, opts).Decode(&order)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrOrderNotFound
+		}
+		log.Printf("Error flagging order for refund: %v", err)
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/errors"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type OrderService struct {
+	orderRepo repository.OrderRepository
+}
+
+func NewOrderService(orderRepo repository.OrderRepository) *OrderService {
+	return &OrderService{
+		orderRepo: orderRepo,
+	}
+}
+
+// CreateOrder places a pending order for the lines of a checkout. Line
+// totals and the order total are computed from the unit prices, which must
+// all be in the currency of the order.
+func (o *OrderService) CreateOrder(order *model.Order) (*model.Order, error) {
+	if order.User_id == "" {
+		return nil, errors.ErrInvalidUserID
+	}
+	if len(order.Lines) == 0 {
+		return nil, fmt.Errorf("%w: an order needs at least one line", errors.ErrInvalidOrder)
+	}
+	order.Currency = strings.ToUpper(order.Currency)
+	if _, err := money.Exponent(order.Currency); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidOrder, err)
+	}
+
+	totals := make([]money.Money, 0, len(order.Lines))
+	for i, line := range order.Lines {
+		if line.Product_id == "" {
+			return nil, fmt.Errorf("%w: line %d has no product_id", errors.ErrInvalidOrder, i+1)
+		}
+		if line.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: line %d: quantity must be greater than 0", errors.ErrInvalidOrder, i+1)
+		}
+		if err := line.Price.Validate(); err != nil || line.Price.Amount <= 0 {
+			return nil, fmt.Errorf("%w: line %d: price must be greater than 0", errors.ErrInvalidOrder, i+1)
+		}
+		if line.Price.Currency != order.Currency {
+			return nil, fmt.Errorf("%w: line %d is priced in %s, not %s", errors.ErrInvalidOrder, i+1, line.Price.Currency, order.Currency)
+		}
+		total, err := line.Price.Mul(line.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", errors.ErrInvalidOrder, i+1, err)
+		}
+		order.Lines[i].Total = total
+		totals = append(totals, total)
+	}
+	total, err := money.Sum(order.Currency, totals...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidOrder, err)
+	}
+
+	now := time.Now().UTC()
+	order.ID = primitive.NewObjectID()
+	order.Order_id = order.ID.Hex()
+	order.Total = total
+	order.Status = model.StatusPending
+	order.History = []model.StatusChange{{Status: model.StatusPending, Changed_at: now}}
+	order.Payment_id = ""
+	order.Created_at = now
+	order.Updated_at = now
+	return o.orderRepo.CreateOrder(order)
+}
+
+func (o *OrderService) GetOrder(orderID string) (*model.Order, error) {
+	if orderID == "" {
+		return nil, errors.ErrOrderNotFound
+	}
+	return o.orderRepo.FindOrder(orderID)
+}
+
+// ListOrders returns one page of orders, newest first
+func (o *OrderService) ListOrders(query *model.OrderQuery) (*model.OrderPage, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	if query.Limit < 0 || query.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", errors.ErrInvalidQuery, MaxPageSize)
+	}
+	if query.Status != "" && !query.Status.Valid() {
+		return nil, fmt.Errorf("%w: unknown status %q", errors.ErrInvalidQuery, query.Status)
+	}
+	return o.orderRepo.ListOrders(query)
+}
+
+// MarkPaid records the payment of a pending order. Confirming a checkout
+// twice marks it paid once. A non-empty refundReason flags the order as
+// needing a refund, the payment came in for an order that cannot be
+// fulfilled.
+func (o *OrderService) MarkPaid(orderID string, paymentID string, refundReason string) (*model.Order, error) {
+	refundReason = strings.TrimSpace(refundReason)
+	order, err := o.transition(orderID, model.StatusPaid, "", refundReason, paymentID)
+	if err != nil || refundReason == "" || order.Needs_refund {
+		return order, err
+	}
+	return o.orderRepo.FlagRefund(orderID, refundReason)
+}
+
+// CancelPending cancels a pending order whose checkout expired or was
+// replaced
+func (o *OrderService) CancelPending(orderID string, reason string) (*model.Order, error) {
+	order, err := o.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != model.StatusPending && order.Status != model.StatusCancelled {
+		return nil, fmt.Errorf("%w: the order is %s, not pending", errors.ErrInvalidTransition, order.Status)
+	}
+	return o.transition(orderID, model.StatusCancelled, "", reason, "")
+}
+
+// SetStatus moves an order as an admin: a paid order to fulfilled,
+// cancelled or refunded, a fulfilled one to refunded. Pending orders are
+// settled by their checkout, which holds their stock and payment.
+//
+// The status is bookkeeping only: cancelling or refunding an order neither
+// refunds the payment, which is made in Stripe, nor gives its stock back,
+// which is done by adjusting the stock of the products.
+func (o *OrderService) SetStatus(orderID string, status model.Status, by string, reason string) (*model.Order, error) {
+	if !status.Valid() {
+		return nil, fmt.Errorf("%w: %q", errors.ErrInvalidStatus, status)
+	}
+	if status == model.StatusPaid {
+		return nil, fmt.Errorf("%w: orders are marked paid by their payment", errors.ErrInvalidTransition)
+	}
+	order, err := o.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == model.StatusPending {
+		return nil, fmt.Errorf("%w: pending orders are settled by their checkout", errors.ErrInvalidTransition)
+	}
+	return o.transition(orderID, status, by, reason, "")
+}
+
+// transition moves the order to status. An order already in status is
+// returned as is, so retries do not fail.
+func (o *OrderService) transition(orderID string, status model.Status, by string, reason string, paymentID string) (*model.Order, error) {
+	order, err := o.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == status {
+		return order, nil
+	}
+	if !order.Status.CanBecome(status) {
+		return nil, fmt.Errorf("%w: a %s order cannot become %s", errors.ErrInvalidTransition, order.Status, status)
+	}
+	change := model.StatusChange{
+		Status:     status,
+		Changed_at: time.Now().UTC(),
+		Changed_by: by,
+		Reason:     strings.TrimSpace(reason),
+	}
+	return o.orderRepo.SetStatus(orderID, order.Status, change, paymentID)
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	stderrors "errors"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/sachinggsingh/e-comm/internal/errors"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"github.com/sachinggsingh/e-comm/pb/money"
+)
+
+// fakeOrderRepo keeps orders in memory. Methods the tests do not need are
+// left to the embedded nil interface and panic when called.
+type fakeOrderRepo struct {
+	repository.OrderRepository
+
+	orders map[string]*model.Order
+}
+
+func newFakeOrderRepo() *fakeOrderRepo {
+	return &fakeOrderRepo{orders: map[string]*model.Order{}}
+}
+
+func (f *fakeOrderRepo) CreateOrder(order *model.Order) (*model.Order, error) {
+	f.orders[order.Order_id] = order
+	return order, nil
+}
+
+func (f *fakeOrderRepo) FindOrder(orderID string) (*model.Order, error) {
+	order, ok := f.orders[orderID]
+	if !ok {
+		return nil, errors.ErrOrderNotFound
+	}
+	copied := *order
+	copied.History = slices.Clone(order.History)
+	return &copied, nil
+}
+
+func (f *fakeOrderRepo) SetStatus(orderID string, from model.Status, change model.StatusChange, paymentID string) (*model.Order, error) {
+	order, ok := f.orders[orderID]
+	if !ok || order.Status != from {
+		return nil, errors.ErrOrderChanged
+	}
+	order.Status = change.Status
+	order.History = append(order.History, change)
+	if paymentID != "" {
+		order.Payment_id = paymentID
+	}
+	if change.Status == model.StatusRefunded {
+		order.Needs_refund = false
+	}
+	return f.FindOrder(orderID)
+}
+
+func (f *fakeOrderRepo) FlagRefund(orderID string, reason string) (*model.Order, error) {
+	order, ok := f.orders[orderID]
+	if !ok {
+		return nil, errors.ErrOrderNotFound
+	}
+	order.Needs_refund, order.Refund_reason = true, reason
+	return f.FindOrder(orderID)
+}
+
+// placeOrder returns a service holding one order in status
+func placeOrder(t *testing.T, status model.Status) (*OrderService, *fakeOrderRepo, string) {
+	t.Helper()
+	repo := newFakeOrderRepo()
+	service := NewOrderService(repo)
+	order, err := service.CreateOrder(&model.Order{
+		User_id:  "u1",
+		Currency: "USD",
+		Lines:    []model.OrderLine{{Product_id: "p", Price: money.New(999, "USD"), Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	stored := repo.orders[order.Order_id]
+	stored.Status = status
+	stored.History = append(stored.History, model.StatusChange{Status: status})
+	return service, repo, order.Order_id
+}
+
+func TestCreateOrderTotalIsTheSumOfItsLines(t *testing.T) {
+	service := NewOrderService(newFakeOrderRepo())
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 1000 {
+		lines := make([]model.OrderLine, 1+r.IntN(10))
+		want := int64(0)
+		for i := range lines {
+			price := money.New(1+r.Int64N(1_000_000), "EUR")
+			quantity := 1 + r.Int64N(50)
+			lines[i] = model.OrderLine{Product_id: "p", Price: price, Quantity: quantity}
+			want += price.Amount * quantity
+		}
+
+		order, err := service.CreateOrder(&model.Order{User_id: "u1", Currency: "eur", Lines: lines})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		sum := int64(0)
+		for _, line := range order.Lines {
+			if line.Total != money.New(line.Price.Amount*line.Quantity, "EUR") {
+				t.Fatalf("line total %s, want %d x %s", line.Total, line.Quantity, line.Price)
+			}
+			sum += line.Total.Amount
+		}
+		if order.Total != money.New(want, "EUR") || sum != want {
+			t.Fatalf("order total %s, lines add up to %d, want %d EUR", order.Total, sum, want)
+		}
+	}
+}
+
+func TestCreateOrderRejectsOverflowingTotals(t *testing.T) {
+	service := NewOrderService(newFakeOrderRepo())
+	huge := money.New(1<<62, "USD")
+	cases := map[string][]model.OrderLine{
+		"line":     {{Product_id: "p", Price: huge, Quantity: 2}},
+		"order":    {{Product_id: "p", Price: huge, Quantity: 1}, {Product_id: "q", Price: huge, Quantity: 1}},
+		"currency": {{Product_id: "p", Price: money.New(100, "EUR"), Quantity: 1}},
+	}
+	for name, lines := range cases {
+		_, err := service.CreateOrder(&model.Order{User_id: "u1", Currency: "USD", Lines: lines})
+		if !stderrors.Is(err, errors.ErrInvalidOrder) {
+			t.Errorf("%s: err = %v, want ErrInvalidOrder", name, err)
+		}
+	}
+}
+
+func TestMarkPaid(t *testing.T) {
+	cases := []struct {
+		name    string
+		from    model.Status
+		reasons []string
+		wantErr error
+		refund  string
+	}{
+		{name: "pending", from: model.StatusPending, reasons: []string{""}},
+		{name: "paid again by the same session", from: model.StatusPending, reasons: []string{"", ""}},
+		{name: "paid late", from: model.StatusPending, reasons: []string{"stock hold expired"}, refund: "stock hold expired"},
+		{name: "paid late again", from: model.StatusPending, reasons: []string{"stock hold expired", "stock hold expired"}, refund: "stock hold expired"},
+		{name: "cancelled", from: model.StatusCancelled, reasons: []string{""}, wantErr: errors.ErrInvalidTransition},
+		{name: "refunded", from: model.StatusRefunded, reasons: []string{""}, wantErr: errors.ErrInvalidTransition},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			service, repo, orderID := placeOrder(t, c.from)
+			history := len(repo.orders[orderID].History)
+
+			var err error
+			for _, reason := range c.reasons {
+				if _, err = service.MarkPaid(orderID, "cs_test_1", reason); err != nil {
+					break
+				}
+			}
+			if c.wantErr != nil {
+				if !stderrors.Is(err, c.wantErr) {
+					t.Fatalf("MarkPaid err = %v, want %v", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MarkPaid: %v", err)
+			}
+
+			order := repo.orders[orderID]
+			if order.Status != model.StatusPaid || order.Payment_id != "cs_test_1" {
+				t.Errorf("order is %s paid by %q, want paid by cs_test_1", order.Status, order.Payment_id)
+			}
+			if len(order.History) != history+1 {
+				t.Errorf("history has %d changes, want the payment once", len(order.History)-history)
+			}
+			if order.Needs_refund != (c.refund != "") || order.Refund_reason != c.refund {
+				t.Errorf("needs_refund %v for %q, want %v for %q", order.Needs_refund, order.Refund_reason, c.refund != "", c.refund)
+			}
+		})
+	}
+}
+
+func TestCancelPending(t *testing.T) {
+	cases := []struct {
+		from    model.Status
+		wantErr error
+	}{
+		{from: model.StatusPending},
+		{from: model.StatusCancelled},
+		{from: model.StatusPaid, wantErr: errors.ErrInvalidTransition},
+		{from: model.StatusFulfilled, wantErr: errors.ErrInvalidTransition},
+		{from: model.StatusRefunded, wantErr: errors.ErrInvalidTransition},
+	}
+	for _, c := range cases {
+		t.Run(string(c.from), func(t *testing.T) {
+			service, repo, orderID := placeOrder(t, c.from)
+			_, err := service.CancelPending(orderID, "payment expired")
+			if c.wantErr != nil {
+				if !stderrors.Is(err, c.wantErr) || repo.orders[orderID].Status != c.from {
+					t.Fatalf("CancelPending err = %v, status %s; want %v and %s", err, repo.orders[orderID].Status, c.wantErr, c.from)
+				}
+				return
+			}
+			if err != nil || repo.orders[orderID].Status != model.StatusCancelled {
+				t.Fatalf("CancelPending err = %v, status %s; want cancelled", err, repo.orders[orderID].Status)
+			}
+		})
+	}
+}
+
+// TestStatusMoves drives every move between two statuses through the call
+// that makes it: pending orders are paid by MarkPaid and cancelled by
+// CancelPending, admins move the others with SetStatus
+func TestStatusMoves(t *testing.T) {
+	statuses := []model.Status{model.StatusPending, model.StatusPaid, model.StatusFulfilled, model.StatusCancelled, model.StatusRefunded}
+	allowed := map[[2]model.Status]bool{
+		{model.StatusPending, model.StatusPaid}:       true,
+		{model.StatusPending, model.StatusCancelled}:  true,
+		{model.StatusPaid, model.StatusFulfilled}:     true,
+		{model.StatusPaid, model.StatusCancelled}:     true,
+		{model.StatusPaid, model.StatusRefunded}:      true,
+		{model.StatusFulfilled, model.StatusRefunded}: true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if from == to {
+				continue
+			}
+			t.Run(string(from)+" to "+string(to), func(t *testing.T) {
+				moves := map[string]func(*OrderService, string) error{
+					"SetStatus": func(s *OrderService, id string) error {
+						_, err := s.SetStatus(id, to, "admin", "")
+						return err
+					},
+				}
+				switch to {
+				case model.StatusPaid:
+					moves["MarkPaid"] = func(s *OrderService, id string) error {
+						_, err := s.MarkPaid(id, "cs_test_1", "")
+						return err
+					}
+				case model.StatusCancelled:
+					moves["CancelPending"] = func(s *OrderService, id string) error {
+						_, err := s.CancelPending(id, "payment expired")
+						return err
+					}
+				}
+
+				// The call that owns the move
+				owner := "SetStatus"
+				if from == model.StatusPending {
+					owner = map[model.Status]string{model.StatusPaid: "MarkPaid", model.StatusCancelled: "CancelPending"}[to]
+				}
+
+				for name, move := range moves {
+					service, repo, orderID := placeOrder(t, from)
+					err := move(service, orderID)
+					status := repo.orders[orderID].Status
+					if allowed[[2]model.Status{from, to}] && name == owner {
+						if err != nil || status != to {
+							t.Errorf("%s: err = %v, status %s; want %s", name, err, status, to)
+						}
+						continue
+					}
+					if !stderrors.Is(err, errors.ErrInvalidTransition) || status != from {
+						t.Errorf("%s: err = %v, status %s; want the move refused", name, err, status)
+					}
+				}
+			})
+		}
+	}
+}
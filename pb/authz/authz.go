@@ -0,0 +1,225 @@
+// Package authz holds the roles and permissions shared by all services and a
+// net/http middleware enforcing them. It works with both http.ServeMux and
+// gorilla/mux since both accept func(http.Handler) http.Handler middleware.
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	proto "github.com/sachinggsingh/e-comm/pb"
+)
+
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
+const (
+	PermProductWrite = "product:write"
+	PermUserAdmin    = "user:admin"
+	PermOrderManage  = "order:manage"
+)
+
+// RolePermissions maps every known role to the permissions it grants
+var RolePermissions = map[string][]string{
+	RoleCustomer: {},
+	RoleAdmin:    {PermProductWrite, PermUserAdmin, PermOrderManage},
+}
+
+var ErrUnauthenticated = errors.New("authentication required")
+
+// IsKnownRole reports whether role is defined in RolePermissions
+func IsKnownRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// PermissionsFor returns the de-duplicated permissions granted by roles
+func PermissionsFor(roles []string) []string {
+	perms := []string{}
+	for _, role := range roles {
+		for _, perm := range RolePermissions[role] {
+			if !slices.Contains(perms, perm) {
+				perms = append(perms, perm)
+			}
+		}
+	}
+	return perms
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID string
+	Email  string
+	Roles  []string
+	Scopes []string
+	// SessionID is the login session of a bearer token
+	SessionID string
+	// APIKeyID is set when the caller authenticated with an API key
+	APIKeyID string
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+func (p *Principal) HasPermission(perm string) bool {
+	return slices.Contains(p.Scopes, perm)
+}
+
+// Authenticator resolves the caller of a request. It returns
+// ErrUnauthenticated (or another error) when the request carries no valid
+// credentials.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type contextKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFromContext returns the caller stored by WithPrincipal, or nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(contextKey{}).(*Principal)
+	return p
+}
+
+// Forbidden is the body of a 403 response
+type Forbidden struct {
+	Error              string `json:"error"`
+	Reason             string `json:"reason"`
+	RequiredPermission string `json:"required_permission,omitempty"`
+}
+
+func WriteForbidden(w http.ResponseWriter, reason string, perm string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(Forbidden{
+		Error:              "forbidden",
+		Reason:             reason,
+		RequiredPermission: perm,
+	})
+}
+
+// RequirePermission only lets requests through whose caller holds perm. A
+// principal already placed in the context by an earlier middleware is reused,
+// otherwise authn is asked.
+func RequirePermission(authn Authenticator, perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := PrincipalFromContext(r.Context())
+			if p == nil {
+				var err error
+				p, err = authn.Authenticate(r)
+				if err != nil {
+					http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+					return
+				}
+			}
+			if !p.HasPermission(perm) {
+				WriteForbidden(w, "missing permission "+perm, perm)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// RequireAuthentication only lets requests through whose caller authn
+// resolves, and places the caller in the context
+func RequireAuthentication(authn Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := PrincipalFromContext(r.Context())
+			if p == nil {
+				var err error
+				p, err = authn.Authenticate(r)
+				if err != nil {
+					http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// BearerToken extracts the token of an "Authorization: Bearer" header
+func BearerToken(r *http.Request) (string, error) {
+	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+		return "", ErrUnauthenticated
+	}
+	return strings.TrimSpace(parts[1]), nil
+}
+
+// APIKeyPrefix starts every API key, telling keys apart from JWTs
+const APIKeyPrefix = "ek_"
+
+// Token types reported by the Introspect RPC
+const (
+	TokenTypeAccess = "access"
+	TokenTypeAPIKey = "api_key"
+)
+
+// Credential returns the bearer token or the API key of the request. API
+// keys are sent as "Authorization: ApiKey <key>".
+func Credential(r *http.Request) (string, error) {
+	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "ApiKey") {
+		key := strings.TrimSpace(parts[1])
+		if !strings.HasPrefix(key, APIKeyPrefix) {
+			return "", ErrUnauthenticated
+		}
+		return key, nil
+	}
+	return BearerToken(r)
+}
+
+// IntrospectionAuthenticator authenticates bearer tokens and API keys by
+// asking the auth service, for services that do not verify tokens themselves
+type IntrospectionAuthenticator struct {
+	client proto.ValidateTokenClient
+}
+
+func NewIntrospectionAuthenticator(client proto.ValidateTokenClient) *IntrospectionAuthenticator {
+	return &IntrospectionAuthenticator{client: client}
+}
+
+func (a *IntrospectionAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, err := Credential(r)
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.client.Introspect(r.Context(), &proto.IntrospectRequest{Token: token})
+	if err != nil {
+		return nil, errors.New("token validation failed")
+	}
+	if !res.Active {
+		if res.Revoked {
+			return nil, errors.New("token has been revoked")
+		}
+		return nil, errors.New("invalid token")
+	}
+	principal := &Principal{
+		UserID: res.UserId,
+		Email:  res.Email,
+		Roles:  res.Roles,
+		Scopes: res.Scopes,
+	}
+	if res.TokenType == TokenTypeAPIKey {
+		principal.APIKeyID = res.SessionId
+	} else {
+		principal.SessionID = res.SessionId
+	}
+	return principal, nil
+}
@@ -0,0 +1,230 @@
+// Package jwks holds the JSON Web Key Set format shared by the auth service,
+// which publishes its token verification keys, and the services that verify
+// tokens against them.
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownKey         = errors.New("jwks: unknown key id")
+	ErrUnsupportedKeyType = errors.New("jwks: unsupported key type")
+)
+
+// JSONWebKey is a public key as described by RFC 7517. Only RSA and Ed25519
+// keys are supported.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type KeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKey describes a public signing key under the given key id
+func NewJSONWebKey(kid string, pub crypto.PublicKey) (JSONWebKey, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return JSONWebKey{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JSONWebKey{
+			Kty: "OKP",
+			Kid: kid,
+			Use: "sig",
+			Alg: "EdDSA",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	}
+	return JSONWebKey{}, ErrUnsupportedKeyType
+}
+
+// PublicKey decodes the key material
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: invalid modulus for key %s: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: invalid exponent for key %s: %w", k.Kid, err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, ErrUnsupportedKeyType
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwks: invalid Ed25519 key %s", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrUnsupportedKeyType
+}
+
+// Cache fetches a key set from a JWKS endpoint and keeps it in memory. The
+// set is refetched once it is older than the refresh interval, or when a key
+// id is requested that the cached set does not know about (the issuer has
+// rotated keys). Refetches on unknown keys are rate limited so tokens with
+// bogus key ids cannot be used to hammer the issuer, and concurrent
+// refetches share a single request.
+type Cache struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	minInterval     time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	inflight    *refreshCall
+}
+
+// refreshCall is a fetch of the key set that other callers wait for
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+func NewCache(url string) *Cache {
+	return &Cache{
+		url:             url,
+		client:          &http.Client{Timeout: 5 * time.Second},
+		refreshInterval: time.Hour,
+		minInterval:     30 * time.Second,
+		keys:            map[string]crypto.PublicKey{},
+	}
+}
+
+// Key returns the public key with the given key id
+func (c *Cache) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	fresh := time.Since(c.fetchedAt) < c.refreshInterval
+	canRefetch := time.Since(c.attemptedAt) >= c.minInterval
+	c.mu.RUnlock()
+
+	if ok && (fresh || !canRefetch) {
+		return key, nil
+	}
+	if !canRefetch {
+		return nil, ErrUnknownKey
+	}
+
+	if err := c.refresh(ctx); err != nil {
+		// Keep serving a known key if the issuer is temporarily unreachable
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh refetches the key set. Callers arriving while a fetch runs wait
+// for it, and callers that raced past the rate limit in Key are answered by
+// the fetch that just finished.
+func (c *Cache) refresh(ctx context.Context) error {
+	c.mu.Lock()
+	if call := c.inflight; call != nil {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if !c.attemptedAt.IsZero() && time.Since(c.attemptedAt) < c.minInterval {
+		c.mu.Unlock()
+		return nil
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	c.inflight = call
+	c.attemptedAt = time.Now()
+	c.mu.Unlock()
+
+	// The fetch is shared, so it must not fail because the caller that
+	// started it went away
+	call.err = c.fetch(context.WithoutCancel(ctx))
+
+	c.mu.Lock()
+	c.inflight = nil
+	c.mu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+func (c *Cache) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("jwks: failed to fetch %s: %w", c.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks: unexpected status %d from %s", resp.StatusCode, c.url)
+	}
+
+	var set KeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("jwks: invalid key set: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,286 @@
+// Package money holds sums of money as integer minor units of an ISO 4217
+// currency, cents for USD, so prices and totals add up exactly.
+//
+// Money is serialized to JSON as {"amount": 1999, "currency": "USD"} with
+// the amount in minor units. For compatibility a bare decimal, such as 19.99
+// or "19.99", is read as an amount of DefaultCurrency; it is parsed as text,
+// never through a float, and may not have more decimals than the currency.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts given without one
+const DefaultCurrency = "USD"
+
+var (
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrOverflow         = errors.New("amount out of range")
+)
+
+// exponents are the decimals of the minor unit of each supported currency
+var exponents = map[string]int{
+	"USD": 2, "EUR": 2, "GBP": 2, "INR": 2, "CAD": 2, "AUD": 2, "CHF": 2,
+	"CNY": 2, "SEK": 2, "NOK": 2, "DKK": 2, "PLN": 2, "SGD": 2, "HKD": 2,
+	"NZD": 2, "MXN": 2, "BRL": 2, "ZAR": 2, "AED": 2,
+	"JPY": 0, "KRW": 0, "CLP": 0, "VND": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+// Exponent returns how many decimals the minor unit of the currency has
+func Exponent(currency string) (int, error) {
+	exp, ok := exponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+	return exp, nil
+}
+
+// Money is an amount in minor units of Currency, an upper case ISO 4217 code
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"`
+	Currency string `json:"currency" bson:"currency"`
+}
+
+// New returns amount minor units of currency
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Zero returns no money in currency
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// Parse reads a decimal such as "19.99" or "-5" as an amount of currency.
+// More decimals than the currency has are an error, not rounded away.
+func Parse(text string, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	s := strings.TrimSpace(text)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !digits(whole) || !digits(frac) {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidAmount, text)
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("%w %q: %s has %d decimals", ErrInvalidAmount, text, currency, exp)
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w %q", ErrOverflow, text)
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromFloat converts a float amount of currency, rounding half away from
+// zero to the minor unit. It is meant for legacy data only.
+func FromFloat(f float64, currency string) (Money, error) {
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return Money{}, fmt.Errorf("%w %v", ErrInvalidAmount, f)
+	}
+	// Rounding the shortest decimal text rather than the binary value keeps
+	// 19.99, stored as 19.98999..., at 1999
+	text := strconv.FormatFloat(f, 'f', -1, 64)
+	return Parse(roundDecimal(text, exp), currency)
+}
+
+// roundDecimal rounds a decimal text to exp decimals, half away from zero
+func roundDecimal(text string, exp int) string {
+	negative := strings.HasPrefix(text, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(text, "-"), ".")
+	frac += strings.Repeat("0", exp+1)
+	up := frac[exp] >= '5'
+	digits := []byte(whole + frac[:exp])
+	for i := len(digits) - 1; up && i >= 0; i-- {
+		if digits[i] == '9' {
+			digits[i] = '0'
+			continue
+		}
+		digits[i]++
+		up = false
+	}
+	s := string(digits)
+	if up {
+		s = "1" + s
+	}
+	s = s[:len(s)-exp] + "." + s[len(s)-exp:]
+	if negative {
+		s = "-" + s
+	}
+	return strings.TrimSuffix(s, ".")
+}
+
+// Add returns m plus o, which must be in the same currency
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	sum := m.Amount + o.Amount
+	if (sum > m.Amount) != (o.Amount > 0) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m minus o, which must be in the same currency
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Mul returns m times n
+func (m Money) Mul(n int64) (Money, error) {
+	if n != 0 && (m.Amount*n/n != m.Amount || m.Amount == math.MinInt64 && n == -1) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount * n, Currency: m.Currency}, nil
+}
+
+// Sum adds up amounts in currency; none is zero
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := Zero(currency)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Cmp compares m with o, which must be in the same currency: -1 when m is
+// less, 0 when equal and 1 when more
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Validate checks the currency is known
+func (m Money) Validate() error {
+	_, err := Exponent(m.Currency)
+	return err
+}
+
+// Decimal formats the amount in major units, such as "19.99"
+func (m Money) Decimal() string {
+	exp, err := Exponent(m.Currency)
+	if err != nil {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+	s := strconv.FormatUint(abs(m.Amount), 10)
+	if exp > 0 {
+		s = strings.Repeat("0", max(exp+1-len(s), 0)) + s
+		s = s[:len(s)-exp] + "." + s[len(s)-exp:]
+	}
+	if m.Amount < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func abs(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// Float64 returns the amount in major units for display and the legacy
+// double fields of the protos; never compute with it
+func (m Money) Float64() float64 {
+	f, _ := strconv.ParseFloat(m.Decimal(), 64)
+	return f
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// UnmarshalJSON reads {"amount": ..., "currency": ...} or a bare decimal of
+// DefaultCurrency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var v struct {
+			Amount   *int64 `json:"amount"`
+			Currency string `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if v.Amount == nil {
+			return fmt.Errorf("%w: amount is required", ErrInvalidAmount)
+		}
+		if v.Currency == "" {
+			v.Currency = DefaultCurrency
+		}
+		*m = Money{Amount: *v.Amount, Currency: strings.ToUpper(v.Currency)}
+		return m.Validate()
+	}
+
+	text := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	if strings.ContainsAny(text, "eE") {
+		return fmt.Errorf("%w %q: use a plain decimal", ErrInvalidAmount, text)
+	}
+	parsed, err := Parse(text, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,280 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"testing"
+)
+
+// iterations is how many random cases each property is checked on
+const iterations = 10000
+
+func newRand(t *testing.T) *rand.Rand {
+	t.Helper()
+	return rand.New(rand.NewPCG(uint64(len(t.Name())), 2024))
+}
+
+// amount returns a random amount, mostly small and sometimes near the
+// limits of int64
+func amount(r *rand.Rand) int64 {
+	switch r.IntN(4) {
+	case 0:
+		return r.Int64N(2_000_001) - 1_000_000
+	case 1:
+		return r.Int64()
+	case 2:
+		return -r.Int64()
+	}
+	return r.Int64N(2_000_000_001) - 1_000_000_000
+}
+
+func fitsInt64(n *big.Int) bool {
+	return n.IsInt64()
+}
+
+func TestMulIsExactOrOverflows(t *testing.T) {
+	r := newRand(t)
+	for range iterations {
+		m := New(amount(r), "USD")
+		n := r.Int64N(2001) - 1000
+		if r.IntN(10) == 0 {
+			n = amount(r)
+		}
+
+		got, err := m.Mul(n)
+		want := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))
+		if !fitsInt64(want) {
+			if !errors.Is(err, ErrOverflow) {
+				t.Fatalf("%d * %d = %v, %v; want ErrOverflow", m.Amount, n, got, err)
+			}
+			continue
+		}
+		if err != nil || got.Amount != want.Int64() || got.Currency != "USD" {
+			t.Fatalf("%d * %d = %v, %v; want %s USD", m.Amount, n, got, err, want)
+		}
+	}
+}
+
+func TestMulOverflowEdges(t *testing.T) {
+	if _, err := New(math.MinInt64, "USD").Mul(-1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MinInt64 * -1: err = %v, want ErrOverflow", err)
+	}
+	if got, err := New(math.MinInt64, "USD").Mul(1); err != nil || got.Amount != math.MinInt64 {
+		t.Errorf("MinInt64 * 1 = %v, %v", got, err)
+	}
+	if got, err := New(math.MaxInt64, "USD").Mul(0); err != nil || got.Amount != 0 {
+		t.Errorf("MaxInt64 * 0 = %v, %v", got, err)
+	}
+}
+
+func TestSumIsExactOrOverflows(t *testing.T) {
+	r := newRand(t)
+	for range iterations {
+		amounts := make([]Money, r.IntN(8))
+		for i := range amounts {
+			amounts[i] = New(amount(r), "EUR")
+		}
+
+		got, err := Sum("EUR", amounts...)
+		// Sum adds left to right, so it overflows as soon as a partial sum
+		// leaves int64, even if later amounts would bring it back
+		want, overflow := new(big.Int), false
+		for _, a := range amounts {
+			want.Add(want, big.NewInt(a.Amount))
+			overflow = overflow || !fitsInt64(want)
+		}
+		if overflow {
+			if !errors.Is(err, ErrOverflow) {
+				t.Fatalf("Sum(%v) = %v, %v; want ErrOverflow", amounts, got, err)
+			}
+			continue
+		}
+		if err != nil || got.Amount != want.Int64() || got.Currency != "EUR" {
+			t.Fatalf("Sum(%v) = %v, %v; want %s EUR", amounts, got, err, want)
+		}
+	}
+}
+
+func TestSumRefusesOtherCurrencies(t *testing.T) {
+	if _, err := Sum("USD", New(100, "USD"), New(100, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("err = %v, want ErrCurrencyMismatch", err)
+	}
+	if got, err := Sum("JPY"); err != nil || got != Zero("JPY") {
+		t.Fatalf("Sum of nothing = %v, %v; want 0 JPY", got, err)
+	}
+}
+
+// TestTotalsAreSumsOfLines checks what carts and orders rely on: line
+// totals from Mul add up with Sum to the exact total of the lines
+func TestTotalsAreSumsOfLines(t *testing.T) {
+	r := newRand(t)
+	for range iterations {
+		lines := make([]Money, 1+r.IntN(20))
+		want := int64(0)
+		for i := range lines {
+			price := New(1+r.Int64N(10_000_000), "USD")
+			quantity := 1 + r.Int64N(100)
+			total, err := price.Mul(quantity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines[i] = total
+			want += price.Amount * quantity
+		}
+		total, err := Sum("USD", lines...)
+		if err != nil || total.Amount != want {
+			t.Fatalf("total of %v = %v, %v; want %d", lines, total, err, want)
+		}
+	}
+}
+
+func TestRoundHalfUpGoesAwayFromZero(t *testing.T) {
+	cases := []struct {
+		value     *big.Rat
+		increment int64
+		want      int64
+	}{
+		{big.NewRat(5, 2), 1, 3},
+		{big.NewRat(-5, 2), 1, -3},
+		{big.NewRat(-7, 2), 1, -4},
+		{big.NewRat(-12, 5), 1, -2},
+		{big.NewRat(-13, 5), 1, -3},
+		{big.NewRat(-1, 2), 1, -1},
+		{big.NewRat(1, 2), 1, 1},
+		{big.NewRat(-1, 3), 1, 0},
+		{big.NewRat(25, 2), 5, 15},
+		{big.NewRat(-25, 2), 5, -15},
+		{big.NewRat(-12, 1), 5, -10},
+		{big.NewRat(-13, 1), 5, -15},
+	}
+	for _, c := range cases {
+		got, err := Rounding{Mode: HalfUp, Increment: c.increment}.Round(c.value)
+		if err != nil || got != c.want {
+			t.Errorf("HalfUp(%s, step %d) = %d, %v; want %d", c.value.RatString(), c.increment, got, err, c.want)
+		}
+	}
+}
+
+// exact returns a random value of minor units with up to 3 decimals, often
+// exactly half way between two steps
+func exact(r *rand.Rand) *big.Rat {
+	units := r.Int64N(2_000_001) - 1_000_000
+	switch r.IntN(3) {
+	case 0:
+		return new(big.Rat).SetInt64(units)
+	case 1:
+		return big.NewRat(2*units+1, 2)
+	}
+	return big.NewRat(units*1000+r.Int64N(1000), 1000)
+}
+
+func TestRoundingProperties(t *testing.T) {
+	r := newRand(t)
+	for range iterations {
+		value := exact(r)
+		increment := []int64{1, 1, 5, 10, 100}[r.IntN(5)]
+		step := new(big.Rat).SetInt64(increment)
+
+		rounded := map[RoundingMode]int64{}
+		for _, mode := range []RoundingMode{HalfUp, HalfEven, Ceiling, Floor} {
+			got, err := Rounding{Mode: mode, Increment: increment}.Round(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got%increment != 0 {
+				t.Fatalf("mode %d: %s rounded to %d, not a multiple of %d", mode, value.RatString(), got, increment)
+			}
+			rounded[mode] = got
+		}
+
+		floor := new(big.Rat).SetInt64(rounded[Floor])
+		ceiling := new(big.Rat).SetInt64(rounded[Ceiling])
+		if floor.Cmp(value) > 0 || ceiling.Cmp(value) < 0 {
+			t.Fatalf("%s: floor %d or ceiling %d on the wrong side", value.RatString(), rounded[Floor], rounded[Ceiling])
+		}
+		if gap := rounded[Ceiling] - rounded[Floor]; gap != 0 && gap != increment {
+			t.Fatalf("%s: floor %d and ceiling %d are %d apart", value.RatString(), rounded[Floor], rounded[Ceiling], gap)
+		}
+
+		// The half modes land on the nearest step
+		for _, mode := range []RoundingMode{HalfUp, HalfEven} {
+			distance := new(big.Rat).Sub(new(big.Rat).SetInt64(rounded[mode]), value)
+			distance.Abs(distance)
+			if distance.Cmp(new(big.Rat).Quo(step, big.NewRat(2, 1))) > 0 {
+				t.Fatalf("mode %d: %s rounded to %d, more than half a step away", mode, value.RatString(), rounded[mode])
+			}
+		}
+
+		// Half up is symmetric around zero, halves go away from it
+		negated, err := Rounding{Mode: HalfUp, Increment: increment}.Round(new(big.Rat).Neg(value))
+		if err != nil || negated != -rounded[HalfUp] {
+			t.Fatalf("HalfUp(-%s) = %d, want %d", value.RatString(), negated, -rounded[HalfUp])
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		from     Money
+		to       string
+		rate     *big.Rat
+		rounding Rounding
+		want     int64
+	}{
+		// 10.00 USD at 0.92 is 9.20 EUR
+		{New(1000, "USD"), "EUR", big.NewRat(92, 100), Rounding{}, 920},
+		// 19.99 USD at 151.3 is 3024.487 JPY
+		{New(1999, "USD"), "JPY", big.NewRat(1513, 10), Rounding{}, 3024},
+		// 1500 JPY at 0.0066 is 9.90 USD
+		{New(1500, "JPY"), "USD", big.NewRat(66, 10000), Rounding{}, 990},
+		// 1.00 USD at 0.377 is 0.377 BHD, three decimals
+		{New(100, "USD"), "BHD", big.NewRat(377, 1000), Rounding{}, 377},
+		// -0.05 USD at 0.5 is -0.025 EUR, half up away from zero
+		{New(-5, "USD"), "EUR", big.NewRat(1, 2), Rounding{Mode: HalfUp}, -3},
+		{New(-5, "USD"), "EUR", big.NewRat(1, 2), Rounding{Mode: HalfEven}, -2},
+		// 10.00 USD at 0.9137 is 9.137 CHF, to the next 0.05
+		{New(1000, "USD"), "CHF", big.NewRat(9137, 10000), Rounding{Mode: HalfUp, Increment: 5}, 915},
+	}
+	for _, c := range cases {
+		got, err := c.from.Convert(c.to, c.rate, c.rounding)
+		if err != nil || got.Amount != c.want || got.Currency != c.to {
+			t.Errorf("%s to %s at %s = %v, %v; want %d", c.from, c.to, c.rate.RatString(), got, err, c.want)
+		}
+	}
+	if _, err := New(100, "USD").Convert("XXX", big.NewRat(1, 1), Rounding{}); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("unknown currency: err = %v", err)
+	}
+}
+
+func TestConvertProperties(t *testing.T) {
+	r := newRand(t)
+	for range iterations {
+		m := New(r.Int64N(2_000_000_001)-1_000_000_000, "USD")
+
+		// At par between currencies with the same minor unit nothing moves
+		same, err := m.Convert("EUR", big.NewRat(1, 1), Rounding{})
+		if err != nil || same.Amount != m.Amount {
+			t.Fatalf("%s at 1 = %v, %v", m, same, err)
+		}
+
+		// The result is the exact conversion rounded, and half up rounding
+		// treats a negative amount as the mirror of the positive one
+		rate := big.NewRat(1+r.Int64N(2_000_000), 1_000_000)
+		got, err := m.Convert("JPY", rate, Rounding{Mode: HalfUp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+		value.Quo(value, big.NewRat(100, 1))
+		distance := new(big.Rat).Sub(new(big.Rat).SetInt64(got.Amount), value)
+		if distance.Abs(distance).Cmp(big.NewRat(1, 2)) > 0 {
+			t.Fatalf("%s at %s = %d JPY, exact %s", m, rate.RatString(), got.Amount, value.FloatString(3))
+		}
+		mirrored, err := New(-m.Amount, "USD").Convert("JPY", rate, Rounding{Mode: HalfUp})
+		if err != nil || mirrored.Amount != -got.Amount {
+			t.Fatalf("-%s at %s = %v, %v; want %d", m, rate.RatString(), mirrored, err, -got.Amount)
+		}
+	}
+}
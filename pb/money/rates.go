@@ -0,0 +1,226 @@
+package money
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoRate = errors.New("no exchange rate")
+
+// RateProvider gives the current exchange rates. StaticRates reads them
+// from a file, RemoteRates from an HTTP endpoint; other sources plug in by
+// implementing it. CachedRates keeps the rates of any provider for a while.
+type RateProvider interface {
+	Rates(ctx context.Context) (*RateTable, error)
+}
+
+// RateTable holds the rates of currencies against Base: one unit of Base
+// buys Rates[c] units of c. Rates are exact decimals.
+type RateTable struct {
+	Base  string
+	Rates map[string]*big.Rat
+	// Fetched_at is when the rates were read
+	Fetched_at time.Time
+}
+
+// Rate returns how many units of to one unit of from buys, crossing
+// through Base
+func (t *RateTable) Rate(from string, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	fromRate, err := t.perBase(from)
+	if err != nil {
+		return nil, err
+	}
+	toRate, err := t.perBase(to)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+func (t *RateTable) perBase(currency string) (*big.Rat, error) {
+	if currency == t.Base {
+		return big.NewRat(1, 1), nil
+	}
+	rate, ok := t.Rates[currency]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoRate, currency)
+	}
+	return rate, nil
+}
+
+// ParseRateTable reads the JSON of a rate table, such as
+// {"base": "USD", "rates": {"EUR": "0.92", "JPY": 151.3}}. Rates may be
+// numbers or strings and are read as exact decimals.
+func ParseRateTable(data []byte) (*RateTable, error) {
+	var file struct {
+		Base  string                 `json:"base"`
+		Rates map[string]json.Number `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid rate table: %w", err)
+	}
+	table := &RateTable{
+		Base:       strings.ToUpper(file.Base),
+		Rates:      map[string]*big.Rat{},
+		Fetched_at: time.Now(),
+	}
+	if _, err := Exponent(table.Base); err != nil {
+		return nil, fmt.Errorf("invalid rate table base: %w", err)
+	}
+	for currency, number := range file.Rates {
+		currency = strings.ToUpper(currency)
+		rate, ok := new(big.Rat).SetString(number.String())
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate table: rate of %s must be a positive number", currency)
+		}
+		// Rates of currencies we cannot price in are of no use
+		if _, err := Exponent(currency); err != nil {
+			continue
+		}
+		table.Rates[currency] = rate
+	}
+	return table, nil
+}
+
+// StaticRates are rates read once from a file in the format of
+// ParseRateTable
+type StaticRates struct {
+	table *RateTable
+}
+
+func LoadStaticRates(path string) (*StaticRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates: %w", err)
+	}
+	table, err := ParseRateTable(data)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticRates{table: table}, nil
+}
+
+func (s *StaticRates) Rates(ctx context.Context) (*RateTable, error) {
+	return s.table, nil
+}
+
+// RemoteRates fetches the rates from an HTTP endpoint answering in the
+// format of ParseRateTable, on every call; wrap it in CachedRates
+type RemoteRates struct {
+	url    string
+	client *http.Client
+}
+
+// NewRemoteRates fetches from url with client, a client with a 10 second
+// timeout when nil
+func NewRemoteRates(url string, client *http.Client) *RemoteRates {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &RemoteRates{url: url, client: client}
+}
+
+func (r *RemoteRates) Rates(ctx context.Context) (*RateTable, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch rates: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rates: %w", err)
+	}
+	return ParseRateTable(data)
+}
+
+// CachedRates keeps the rates of a provider for ttl. When a refresh fails
+// the rates it has are used on, and the refresh is retried after a minute.
+// One caller refreshes at a time; meanwhile the others are served the rates
+// it has, or wait for the refresh when it has none yet.
+type CachedRates struct {
+	provider RateProvider
+	ttl      time.Duration
+
+	mu       sync.Mutex
+	table    *RateTable
+	expires  time.Time
+	inflight *ratesRefresh
+}
+
+// ratesRefresh is a fetch of the rates that callers without rates wait for
+type ratesRefresh struct {
+	done  chan struct{}
+	table *RateTable
+	err   error
+}
+
+// retryAfter is how long stale rates are used after a failed refresh
+const retryAfter = time.Minute
+
+func NewCachedRates(provider RateProvider, ttl time.Duration) *CachedRates {
+	return &CachedRates{provider: provider, ttl: ttl}
+}
+
+func (c *CachedRates) Rates(ctx context.Context) (*RateTable, error) {
+	c.mu.Lock()
+	table := c.table
+	if table != nil && time.Now().Before(c.expires) {
+		c.mu.Unlock()
+		return table, nil
+	}
+	if call := c.inflight; call != nil {
+		c.mu.Unlock()
+		if table != nil {
+			return table, nil
+		}
+		select {
+		case <-call.done:
+			return call.table, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &ratesRefresh{done: make(chan struct{})}
+	c.inflight = call
+	c.mu.Unlock()
+
+	// The fetch is shared, so it must not fail because the caller that
+	// started it went away
+	table, err := c.provider.Rates(context.WithoutCancel(ctx))
+
+	c.mu.Lock()
+	now := time.Now()
+	if err == nil {
+		c.table, c.expires = table, now.Add(c.ttl)
+	} else if c.table != nil {
+		log.Printf("Failed to refresh exchange rates, using those of %s: %v", c.table.Fetched_at.Format(time.RFC3339), err)
+		c.expires = now.Add(retryAfter)
+		table, err = c.table, nil
+	}
+	c.inflight = nil
+	call.table, call.err = table, err
+	c.mu.Unlock()
+	close(call.done)
+	return table, err
+}
@@ -0,0 +1,91 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingRates answers once release is closed and counts its calls
+type blockingRates struct {
+	release chan struct{}
+	calls   atomic.Int32
+	err     error
+}
+
+func (b *blockingRates) Rates(ctx context.Context) (*RateTable, error) {
+	b.calls.Add(1)
+	<-b.release
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &RateTable{Base: "USD", Rates: map[string]*big.Rat{"EUR": big.NewRat(92, 100)}, Fetched_at: time.Now()}, nil
+}
+
+func TestCachedRatesServesStaleRatesDuringARefresh(t *testing.T) {
+	provider := &blockingRates{release: make(chan struct{})}
+	cache := NewCachedRates(provider, time.Hour)
+	stale := &RateTable{Base: "USD", Rates: map[string]*big.Rat{}}
+	cache.table, cache.expires = stale, time.Now().Add(-time.Second)
+
+	refreshed := make(chan *RateTable)
+	go func() {
+		table, _ := cache.Rates(context.Background())
+		refreshed <- table
+	}()
+	for provider.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The refresh is blocked, yet other callers get the rates at hand
+	for range 10 {
+		table, err := cache.Rates(context.Background())
+		if err != nil || table != stale {
+			t.Fatalf("Rates during a refresh = %v, %v; want the stale table", table, err)
+		}
+	}
+
+	close(provider.release)
+	table := <-refreshed
+	if table == stale || table.Rates["EUR"] == nil {
+		t.Fatalf("refresh returned %v, want the new table", table)
+	}
+	if got, _ := cache.Rates(context.Background()); got != table {
+		t.Fatalf("Rates after the refresh = %v, want the new table", got)
+	}
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Fatalf("provider called %d times, want 1", calls)
+	}
+}
+
+func TestCachedRatesWithoutRatesReportTheFetchError(t *testing.T) {
+	provider := &blockingRates{release: make(chan struct{}), err: errors.New("down")}
+	cache := NewCachedRates(provider, time.Hour)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Rates(context.Background())
+			errs <- err
+		}()
+	}
+	for provider.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(provider.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err == nil || err.Error() != "down" {
+			t.Fatalf("Rates without rates = %v, want the error of the fetch", err)
+		}
+	}
+}
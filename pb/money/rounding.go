@@ -0,0 +1,146 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode decides which way an amount between two steps goes
+type RoundingMode int
+
+const (
+	// HalfUp rounds to the nearest step, halves away from zero
+	HalfUp RoundingMode = iota
+	// HalfEven rounds to the nearest step, halves to the even one
+	HalfEven
+	// Ceiling rounds up
+	Ceiling
+	// Floor rounds down
+	Floor
+)
+
+var roundingModes = map[string]RoundingMode{
+	"half_up":   HalfUp,
+	"half_even": HalfEven,
+	"ceiling":   Ceiling,
+	"floor":     Floor,
+}
+
+// Rounding rounds exact amounts, such as converted prices, to a whole
+// number of Increment minor units: 5 rounds CHF to 0.05. An Increment of 0
+// is the minor unit.
+type Rounding struct {
+	Mode      RoundingMode
+	Increment int64
+}
+
+// Round rounds value, in minor units, to a multiple of the increment
+func (r Rounding) Round(value *big.Rat) (int64, error) {
+	increment := max(r.Increment, 1)
+	steps := new(big.Rat).Quo(value, new(big.Rat).SetInt64(increment))
+	// big.Int.Div is Euclidean, so with the positive denominator of a Rat
+	// it rounds down and rest is in [0, 1)
+	n := new(big.Int).Div(steps.Num(), steps.Denom())
+	rest := new(big.Rat).Sub(steps, new(big.Rat).SetInt(n))
+	half := new(big.Rat).Mul(rest, big.NewRat(2, 1)).Cmp(big.NewRat(1, 1))
+
+	up := false
+	switch r.Mode {
+	case HalfUp:
+		up = half > 0 || half == 0 && value.Sign() >= 0
+	case HalfEven:
+		up = half > 0 || half == 0 && n.Bit(0) == 1
+	case Ceiling:
+		up = rest.Sign() != 0
+	}
+	if up {
+		n.Add(n, big.NewInt(1))
+	}
+	n.Mul(n, big.NewInt(increment))
+	if !n.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return n.Int64(), nil
+}
+
+// RoundingRules are the roundings of currencies, Default for the others
+type RoundingRules struct {
+	Default    Rounding
+	Currencies map[string]Rounding
+}
+
+// For returns the rounding of currency
+func (r RoundingRules) For(currency string) Rounding {
+	if rounding, ok := r.Currencies[currency]; ok {
+		return rounding
+	}
+	return r.Default
+}
+
+// ParseRoundingRules reads rules such as "*:1:half_even,CHF:5,SEK:100:ceiling":
+// comma separated currency, increment in minor units and optional mode
+// (half_up, the default, half_even, ceiling or floor). "*" sets the
+// default, which otherwise is the minor unit, half up.
+func ParseRoundingRules(spec string) (RoundingRules, error) {
+	rules := RoundingRules{Default: Rounding{Mode: HalfUp, Increment: 1}, Currencies: map[string]Rounding{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return RoundingRules{}, fmt.Errorf("rounding rule %q is not currency:increment[:mode]", entry)
+		}
+		increment, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || increment < 1 {
+			return RoundingRules{}, fmt.Errorf("rounding rule %q needs a positive whole increment", entry)
+		}
+		rounding := Rounding{Mode: HalfUp, Increment: increment}
+		if len(parts) == 3 {
+			mode, ok := roundingModes[strings.ToLower(parts[2])]
+			if !ok {
+				return RoundingRules{}, fmt.Errorf("rounding rule %q: mode must be half_up, half_even, ceiling or floor", entry)
+			}
+			rounding.Mode = mode
+		}
+		currency := strings.ToUpper(parts[0])
+		if currency == "*" {
+			rules.Default = rounding
+			continue
+		}
+		if _, err := Exponent(currency); err != nil {
+			return RoundingRules{}, err
+		}
+		rules.Currencies[currency] = rounding
+	}
+	return rules, nil
+}
+
+// Convert returns m in currency to at rate, units of to per unit of m's
+// currency, rounded with rounding
+func (m Money) Convert(to string, rate *big.Rat, rounding Rounding) (Money, error) {
+	from, err := Exponent(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	exp, err := Exponent(to)
+	if err != nil {
+		return Money{}, err
+	}
+	// Minor units of m to minor units of to
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(int64(exp-from)))), nil))
+	if exp >= from {
+		value.Mul(value, scale)
+	} else {
+		value.Quo(value, scale)
+	}
+	amount, err := rounding.Round(value)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: to}, nil
+}
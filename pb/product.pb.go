@@ -22,8 +22,10 @@ const (
 )
 
 type GetProductRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ProductId     string                 `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ProductId string                 `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	// sku selects a variant of the product, returned as variant
+	Sku           string `protobuf:"bytes,2,opt,name=sku,proto3" json:"sku,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -65,20 +67,284 @@ func (x *GetProductRequest) GetProductId() string {
 	return ""
 }
 
-type GetPRoductResponse struct {
+func (x *GetProductRequest) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+// Money is an amount in minor units of an ISO 4217 currency
+type Money struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
-	Price         float64                `protobuf:"fixed64,4,opt,name=price,proto3" json:"price,omitempty"`
-	Stock         float32                `protobuf:"fixed32,5,opt,name=stock,proto3" json:"stock,omitempty"`
+	Amount        int64                  `protobuf:"varint,1,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Money) Reset() {
+	*x = Money{}
+	mi := &file_product_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Money) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Money) ProtoMessage() {}
+
+func (x *Money) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Money.ProtoReflect.Descriptor instead.
+func (*Money) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Money) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Money) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+type ProductVariant struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Sku        string                 `protobuf:"bytes,1,opt,name=sku,proto3" json:"sku,omitempty"`
+	Attributes map[string]string      `protobuf:"bytes,2,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// price is the price of the variant charged at request time, the
+	// product price unless overridden
+	Price float64 `protobuf:"fixed64,3,opt,name=price,proto3" json:"price,omitempty"`
+	Stock int64   `protobuf:"varint,4,opt,name=stock,proto3" json:"stock,omitempty"`
+	// list_price is the regular price, price is lower during a sale
+	ListPrice float64 `protobuf:"fixed64,5,opt,name=list_price,json=listPrice,proto3" json:"list_price,omitempty"`
+	// price_amount and list_price_amount are the exact prices in minor
+	// units of the currency of the product; the doubles are for display
+	PriceAmount     int64 `protobuf:"varint,6,opt,name=price_amount,json=priceAmount,proto3" json:"price_amount,omitempty"`
+	ListPriceAmount int64 `protobuf:"varint,7,opt,name=list_price_amount,json=listPriceAmount,proto3" json:"list_price_amount,omitempty"`
+	// price_list are fixed prices in other currencies, charged instead of
+	// converting the price; empty for a variant with a price of its own
+	PriceList     []*Money `protobuf:"bytes,8,rep,name=price_list,json=priceList,proto3" json:"price_list,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductVariant) Reset() {
+	*x = ProductVariant{}
+	mi := &file_product_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductVariant) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductVariant) ProtoMessage() {}
+
+func (x *ProductVariant) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductVariant.ProtoReflect.Descriptor instead.
+func (*ProductVariant) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ProductVariant) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+func (x *ProductVariant) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *ProductVariant) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *ProductVariant) GetStock() int64 {
+	if x != nil {
+		return x.Stock
+	}
+	return 0
+}
+
+func (x *ProductVariant) GetListPrice() float64 {
+	if x != nil {
+		return x.ListPrice
+	}
+	return 0
+}
+
+func (x *ProductVariant) GetPriceAmount() int64 {
+	if x != nil {
+		return x.PriceAmount
+	}
+	return 0
+}
+
+func (x *ProductVariant) GetListPriceAmount() int64 {
+	if x != nil {
+		return x.ListPriceAmount
+	}
+	return 0
+}
+
+func (x *ProductVariant) GetPriceList() []*Money {
+	if x != nil {
+		return x.PriceList
+	}
+	return nil
+}
+
+type ProductImage struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	ImageId string                 `protobuf:"bytes,1,opt,name=image_id,json=imageId,proto3" json:"image_id,omitempty"`
+	Url     string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Width   int32                  `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height  int32                  `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	// thumbnails maps a size (small, medium, large) to its url
+	Thumbnails    map[string]string `protobuf:"bytes,5,rep,name=thumbnails,proto3" json:"thumbnails,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductImage) Reset() {
+	*x = ProductImage{}
+	mi := &file_product_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductImage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductImage) ProtoMessage() {}
+
+func (x *ProductImage) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductImage.ProtoReflect.Descriptor instead.
+func (*ProductImage) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ProductImage) GetImageId() string {
+	if x != nil {
+		return x.ImageId
+	}
+	return ""
+}
+
+func (x *ProductImage) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *ProductImage) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *ProductImage) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *ProductImage) GetThumbnails() map[string]string {
+	if x != nil {
+		return x.Thumbnails
+	}
+	return nil
+}
+
+type GetPRoductResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id          string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name        string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	// price is the price charged at request time, the sale price during a sale
+	Price float64 `protobuf:"fixed64,4,opt,name=price,proto3" json:"price,omitempty"`
+	// stock is the quantity available, not counting reserved stock
+	Stock int64 `protobuf:"varint,6,opt,name=stock,proto3" json:"stock,omitempty"`
+	// category_ids are the categories the product is assigned to
+	CategoryIds []string `protobuf:"bytes,7,rep,name=category_ids,json=categoryIds,proto3" json:"category_ids,omitempty"`
+	// variants of a product with options; such a product only sells them
+	Variants []*ProductVariant `protobuf:"bytes,8,rep,name=variants,proto3" json:"variants,omitempty"`
+	// variant is the variant of the requested sku
+	Variant *ProductVariant `protobuf:"bytes,9,opt,name=variant,proto3" json:"variant,omitempty"`
+	// images in display order, the first is the main image
+	Images []*ProductImage `protobuf:"bytes,10,rep,name=images,proto3" json:"images,omitempty"`
+	// list_price is the regular price
+	ListPrice float64 `protobuf:"fixed64,11,opt,name=list_price,json=listPrice,proto3" json:"list_price,omitempty"`
+	// price_amount and list_price_amount are the exact prices in minor units
+	// of currency, an ISO 4217 code; the doubles are for display
+	PriceAmount     int64  `protobuf:"varint,12,opt,name=price_amount,json=priceAmount,proto3" json:"price_amount,omitempty"`
+	ListPriceAmount int64  `protobuf:"varint,13,opt,name=list_price_amount,json=listPriceAmount,proto3" json:"list_price_amount,omitempty"`
+	Currency        string `protobuf:"bytes,14,opt,name=currency,proto3" json:"currency,omitempty"`
+	// price_list are fixed prices in other currencies, charged instead of
+	// converting the price
+	PriceList     []*Money `protobuf:"bytes,15,rep,name=price_list,json=priceList,proto3" json:"price_list,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetPRoductResponse) Reset() {
 	*x = GetPRoductResponse{}
-	mi := &file_product_proto_msgTypes[1]
+	mi := &file_product_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -90,7 +356,7 @@ func (x *GetPRoductResponse) String() string {
 func (*GetPRoductResponse) ProtoMessage() {}
 
 func (x *GetPRoductResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_product_proto_msgTypes[1]
+	mi := &file_product_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -103,7 +369,7 @@ func (x *GetPRoductResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetPRoductResponse.ProtoReflect.Descriptor instead.
 func (*GetPRoductResponse) Descriptor() ([]byte, []int) {
-	return file_product_proto_rawDescGZIP(), []int{1}
+	return file_product_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *GetPRoductResponse) GetId() string {
@@ -134,29 +400,315 @@ func (x *GetPRoductResponse) GetPrice() float64 {
 	return 0
 }
 
-func (x *GetPRoductResponse) GetStock() float32 {
+func (x *GetPRoductResponse) GetStock() int64 {
 	if x != nil {
 		return x.Stock
 	}
 	return 0
 }
 
+func (x *GetPRoductResponse) GetCategoryIds() []string {
+	if x != nil {
+		return x.CategoryIds
+	}
+	return nil
+}
+
+func (x *GetPRoductResponse) GetVariants() []*ProductVariant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *GetPRoductResponse) GetVariant() *ProductVariant {
+	if x != nil {
+		return x.Variant
+	}
+	return nil
+}
+
+func (x *GetPRoductResponse) GetImages() []*ProductImage {
+	if x != nil {
+		return x.Images
+	}
+	return nil
+}
+
+func (x *GetPRoductResponse) GetListPrice() float64 {
+	if x != nil {
+		return x.ListPrice
+	}
+	return 0
+}
+
+func (x *GetPRoductResponse) GetPriceAmount() int64 {
+	if x != nil {
+		return x.PriceAmount
+	}
+	return 0
+}
+
+func (x *GetPRoductResponse) GetListPriceAmount() int64 {
+	if x != nil {
+		return x.ListPriceAmount
+	}
+	return 0
+}
+
+func (x *GetPRoductResponse) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *GetPRoductResponse) GetPriceList() []*Money {
+	if x != nil {
+		return x.PriceList
+	}
+	return nil
+}
+
+type GetProductsBatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// items are looked up by product_id and, when set, sku; at most 100
+	Items         []*GetProductRequest `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetProductsBatchRequest) Reset() {
+	*x = GetProductsBatchRequest{}
+	mi := &file_product_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetProductsBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProductsBatchRequest) ProtoMessage() {}
+
+func (x *GetProductsBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProductsBatchRequest.ProtoReflect.Descriptor instead.
+func (*GetProductsBatchRequest) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetProductsBatchRequest) GetItems() []*GetProductRequest {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type ProductLookup struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ProductId string                 `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Sku       string                 `protobuf:"bytes,2,opt,name=sku,proto3" json:"sku,omitempty"`
+	// found is false when the product or its variant does not exist, error
+	// then says which
+	Found         bool                `protobuf:"varint,3,opt,name=found,proto3" json:"found,omitempty"`
+	Error         string              `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Product       *GetPRoductResponse `protobuf:"bytes,5,opt,name=product,proto3" json:"product,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductLookup) Reset() {
+	*x = ProductLookup{}
+	mi := &file_product_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductLookup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductLookup) ProtoMessage() {}
+
+func (x *ProductLookup) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductLookup.ProtoReflect.Descriptor instead.
+func (*ProductLookup) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ProductLookup) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *ProductLookup) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+func (x *ProductLookup) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *ProductLookup) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ProductLookup) GetProduct() *GetPRoductResponse {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+type GetProductsBatchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// results in the order of the request items
+	Results       []*ProductLookup `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetProductsBatchResponse) Reset() {
+	*x = GetProductsBatchResponse{}
+	mi := &file_product_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetProductsBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProductsBatchResponse) ProtoMessage() {}
+
+func (x *GetProductsBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProductsBatchResponse.ProtoReflect.Descriptor instead.
+func (*GetProductsBatchResponse) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetProductsBatchResponse) GetResults() []*ProductLookup {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
 var File_product_proto protoreflect.FileDescriptor
 
 const file_product_proto_rawDesc = "" +
 	"\n" +
-	"\rproduct.proto\x12\aproduct\"2\n" +
+	"\rproduct.proto\x12\aproduct\"D\n" +
 	"\x11GetProductRequest\x12\x1d\n" +
 	"\n" +
-	"product_id\x18\x01 \x01(\tR\tproductId\"\x86\x01\n" +
+	"product_id\x18\x01 \x01(\tR\tproductId\x12\x10\n" +
+	"\x03sku\x18\x02 \x01(\tR\x03sku\";\n" +
+	"\x05Money\x12\x16\n" +
+	"\x06amount\x18\x01 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x02 \x01(\tR\bcurrency\"\xf3\x02\n" +
+	"\x0eProductVariant\x12\x10\n" +
+	"\x03sku\x18\x01 \x01(\tR\x03sku\x12G\n" +
+	"\n" +
+	"attributes\x18\x02 \x03(\v2'.product.ProductVariant.AttributesEntryR\n" +
+	"attributes\x12\x14\n" +
+	"\x05price\x18\x03 \x01(\x01R\x05price\x12\x14\n" +
+	"\x05stock\x18\x04 \x01(\x03R\x05stock\x12\x1d\n" +
+	"\n" +
+	"list_price\x18\x05 \x01(\x01R\tlistPrice\x12!\n" +
+	"\fprice_amount\x18\x06 \x01(\x03R\vpriceAmount\x12*\n" +
+	"\x11list_price_amount\x18\a \x01(\x03R\x0flistPriceAmount\x12-\n" +
+	"\n" +
+	"price_list\x18\b \x03(\v2\x0e.product.MoneyR\tpriceList\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xef\x01\n" +
+	"\fProductImage\x12\x19\n" +
+	"\bimage_id\x18\x01 \x01(\tR\aimageId\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x14\n" +
+	"\x05width\x18\x03 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x04 \x01(\x05R\x06height\x12E\n" +
+	"\n" +
+	"thumbnails\x18\x05 \x03(\v2%.product.ProductImage.ThumbnailsEntryR\n" +
+	"thumbnails\x1a=\n" +
+	"\x0fThumbnailsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xff\x03\n" +
 	"\x12GetPRoductResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
 	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x14\n" +
 	"\x05price\x18\x04 \x01(\x01R\x05price\x12\x14\n" +
-	"\x05stock\x18\x05 \x01(\x02R\x05stock2U\n" +
+	"\x05stock\x18\x06 \x01(\x03R\x05stock\x12!\n" +
+	"\fcategory_ids\x18\a \x03(\tR\vcategoryIds\x123\n" +
+	"\bvariants\x18\b \x03(\v2\x17.product.ProductVariantR\bvariants\x121\n" +
+	"\avariant\x18\t \x01(\v2\x17.product.ProductVariantR\avariant\x12-\n" +
+	"\x06images\x18\n" +
+	" \x03(\v2\x15.product.ProductImageR\x06images\x12\x1d\n" +
+	"\n" +
+	"list_price\x18\v \x01(\x01R\tlistPrice\x12!\n" +
+	"\fprice_amount\x18\f \x01(\x03R\vpriceAmount\x12*\n" +
+	"\x11list_price_amount\x18\r \x01(\x03R\x0flistPriceAmount\x12\x1a\n" +
+	"\bcurrency\x18\x0e \x01(\tR\bcurrency\x12-\n" +
+	"\n" +
+	"price_list\x18\x0f \x03(\v2\x0e.product.MoneyR\tpriceListJ\x04\b\x05\x10\x06\"K\n" +
+	"\x17GetProductsBatchRequest\x120\n" +
+	"\x05items\x18\x01 \x03(\v2\x1a.product.GetProductRequestR\x05items\"\xa3\x01\n" +
+	"\rProductLookup\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x01 \x01(\tR\tproductId\x12\x10\n" +
+	"\x03sku\x18\x02 \x01(\tR\x03sku\x12\x14\n" +
+	"\x05found\x18\x03 \x01(\bR\x05found\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x125\n" +
+	"\aproduct\x18\x05 \x01(\v2\x1b.product.GetPRoductResponseR\aproduct\"L\n" +
+	"\x18GetProductsBatchResponse\x120\n" +
+	"\aresults\x18\x01 \x03(\v2\x16.product.ProductLookupR\aresults2\xae\x01\n" +
 	"\vGetProducts\x12F\n" +
-	"\vGetProducts\x12\x1a.product.GetProductRequest\x1a\x1b.product.GetPRoductResponseB\tZ\a./protob\x06proto3"
+	"\vGetProducts\x12\x1a.product.GetProductRequest\x1a\x1b.product.GetPRoductResponse\x12W\n" +
+	"\x10GetProductsBatch\x12 .product.GetProductsBatchRequest\x1a!.product.GetProductsBatchResponseB\tZ\a./protob\x06proto3"
 
 var (
 	file_product_proto_rawDescOnce sync.Once
@@ -170,19 +722,39 @@ func file_product_proto_rawDescGZIP() []byte {
 	return file_product_proto_rawDescData
 }
 
-var file_product_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_product_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_product_proto_goTypes = []any{
-	(*GetProductRequest)(nil),  // 0: product.GetProductRequest
-	(*GetPRoductResponse)(nil), // 1: product.GetPRoductResponse
+	(*GetProductRequest)(nil),        // 0: product.GetProductRequest
+	(*Money)(nil),                    // 1: product.Money
+	(*ProductVariant)(nil),           // 2: product.ProductVariant
+	(*ProductImage)(nil),             // 3: product.ProductImage
+	(*GetPRoductResponse)(nil),       // 4: product.GetPRoductResponse
+	(*GetProductsBatchRequest)(nil),  // 5: product.GetProductsBatchRequest
+	(*ProductLookup)(nil),            // 6: product.ProductLookup
+	(*GetProductsBatchResponse)(nil), // 7: product.GetProductsBatchResponse
+	nil,                              // 8: product.ProductVariant.AttributesEntry
+	nil,                              // 9: product.ProductImage.ThumbnailsEntry
 }
 var file_product_proto_depIdxs = []int32{
-	0, // 0: product.GetProducts.GetProducts:input_type -> product.GetProductRequest
-	1, // 1: product.GetProducts.GetProducts:output_type -> product.GetPRoductResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	8,  // 0: product.ProductVariant.attributes:type_name -> product.ProductVariant.AttributesEntry
+	1,  // 1: product.ProductVariant.price_list:type_name -> product.Money
+	9,  // 2: product.ProductImage.thumbnails:type_name -> product.ProductImage.ThumbnailsEntry
+	2,  // 3: product.GetPRoductResponse.variants:type_name -> product.ProductVariant
+	2,  // 4: product.GetPRoductResponse.variant:type_name -> product.ProductVariant
+	3,  // 5: product.GetPRoductResponse.images:type_name -> product.ProductImage
+	1,  // 6: product.GetPRoductResponse.price_list:type_name -> product.Money
+	0,  // 7: product.GetProductsBatchRequest.items:type_name -> product.GetProductRequest
+	4,  // 8: product.ProductLookup.product:type_name -> product.GetPRoductResponse
+	6,  // 9: product.GetProductsBatchResponse.results:type_name -> product.ProductLookup
+	0,  // 10: product.GetProducts.GetProducts:input_type -> product.GetProductRequest
+	5,  // 11: product.GetProducts.GetProductsBatch:input_type -> product.GetProductsBatchRequest
+	4,  // 12: product.GetProducts.GetProducts:output_type -> product.GetPRoductResponse
+	7,  // 13: product.GetProducts.GetProductsBatch:output_type -> product.GetProductsBatchResponse
+	12, // [12:14] is the sub-list for method output_type
+	10, // [10:12] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_product_proto_init() }
@@ -196,7 +768,7 @@ func file_product_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_product_proto_rawDesc), len(file_product_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,7 +19,8 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	GetProducts_GetProducts_FullMethodName = "/product.GetProducts/GetProducts"
+	GetProducts_GetProducts_FullMethodName      = "/product.GetProducts/GetProducts"
+	GetProducts_GetProductsBatch_FullMethodName = "/product.GetProducts/GetProductsBatch"
 )
 
 // GetProductsClient is the client API for GetProducts service.
@@ -27,6 +28,8 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type GetProductsClient interface {
 	GetProducts(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*GetPRoductResponse, error)
+	// GetProductsBatch looks up many products, or variants, with one query
+	GetProductsBatch(ctx context.Context, in *GetProductsBatchRequest, opts ...grpc.CallOption) (*GetProductsBatchResponse, error)
 }
 
 type getProductsClient struct {
@@ -47,11 +50,23 @@ func (c *getProductsClient) GetProducts(ctx context.Context, in *GetProductReque
 	return out, nil
 }
 
+func (c *getProductsClient) GetProductsBatch(ctx context.Context, in *GetProductsBatchRequest, opts ...grpc.CallOption) (*GetProductsBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetProductsBatchResponse)
+	err := c.cc.Invoke(ctx, GetProducts_GetProductsBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GetProductsServer is the server API for GetProducts service.
 // All implementations must embed UnimplementedGetProductsServer
 // for forward compatibility.
 type GetProductsServer interface {
 	GetProducts(context.Context, *GetProductRequest) (*GetPRoductResponse, error)
+	// GetProductsBatch looks up many products, or variants, with one query
+	GetProductsBatch(context.Context, *GetProductsBatchRequest) (*GetProductsBatchResponse, error)
 	mustEmbedUnimplementedGetProductsServer()
 }
 
@@ -65,6 +80,9 @@ type UnimplementedGetProductsServer struct{}
 func (UnimplementedGetProductsServer) GetProducts(context.Context, *GetProductRequest) (*GetPRoductResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetProducts not implemented")
 }
+func (UnimplementedGetProductsServer) GetProductsBatch(context.Context, *GetProductsBatchRequest) (*GetProductsBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProductsBatch not implemented")
+}
 func (UnimplementedGetProductsServer) mustEmbedUnimplementedGetProductsServer() {}
 func (UnimplementedGetProductsServer) testEmbeddedByValue()                     {}
 
@@ -104,6 +122,24 @@ func _GetProducts_GetProducts_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GetProducts_GetProductsBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProductsBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GetProductsServer).GetProductsBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GetProducts_GetProductsBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GetProductsServer).GetProductsBatch(ctx, req.(*GetProductsBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // GetProducts_ServiceDesc is the grpc.ServiceDesc for GetProducts service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,6 +151,10 @@ var GetProducts_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetProducts",
 			Handler:    _GetProducts_GetProducts_Handler,
 		},
+		{
+			MethodName: "GetProductsBatch",
+			Handler:    _GetProducts_GetProductsBatch_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "product.proto",
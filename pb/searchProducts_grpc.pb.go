@@ -0,0 +1,127 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v6.33.0
+// source: proto/searchProducts.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SearchProducts_SearchProducts_FullMethodName = "/searchProducts.SearchProducts/SearchProducts"
+)
+
+// SearchProductsClient is the client API for SearchProducts service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SearchProducts is the product search of the product service, for other
+// services to reuse
+type SearchProductsClient interface {
+	SearchProducts(ctx context.Context, in *SearchProductsRequest, opts ...grpc.CallOption) (*SearchProductsResponse, error)
+}
+
+type searchProductsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSearchProductsClient(cc grpc.ClientConnInterface) SearchProductsClient {
+	return &searchProductsClient{cc}
+}
+
+func (c *searchProductsClient) SearchProducts(ctx context.Context, in *SearchProductsRequest, opts ...grpc.CallOption) (*SearchProductsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchProductsResponse)
+	err := c.cc.Invoke(ctx, SearchProducts_SearchProducts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SearchProductsServer is the server API for SearchProducts service.
+// All implementations must embed UnimplementedSearchProductsServer
+// for forward compatibility.
+//
+// SearchProducts is the product search of the product service, for other
+// services to reuse
+type SearchProductsServer interface {
+	SearchProducts(context.Context, *SearchProductsRequest) (*SearchProductsResponse, error)
+	mustEmbedUnimplementedSearchProductsServer()
+}
+
+// UnimplementedSearchProductsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSearchProductsServer struct{}
+
+func (UnimplementedSearchProductsServer) SearchProducts(context.Context, *SearchProductsRequest) (*SearchProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchProducts not implemented")
+}
+func (UnimplementedSearchProductsServer) mustEmbedUnimplementedSearchProductsServer() {}
+func (UnimplementedSearchProductsServer) testEmbeddedByValue()                        {}
+
+// UnsafeSearchProductsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SearchProductsServer will
+// result in compilation errors.
+type UnsafeSearchProductsServer interface {
+	mustEmbedUnimplementedSearchProductsServer()
+}
+
+func RegisterSearchProductsServer(s grpc.ServiceRegistrar, srv SearchProductsServer) {
+	// If the following call pancis, it indicates UnimplementedSearchProductsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SearchProducts_ServiceDesc, srv)
+}
+
+func _SearchProducts_SearchProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchProductsServer).SearchProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SearchProducts_SearchProducts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchProductsServer).SearchProducts(ctx, req.(*SearchProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SearchProducts_ServiceDesc is the grpc.ServiceDesc for SearchProducts service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SearchProducts_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "searchProducts.SearchProducts",
+	HandlerType: (*SearchProductsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SearchProducts",
+			Handler:    _SearchProducts_SearchProducts_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/searchProducts.proto",
+}
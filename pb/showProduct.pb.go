@@ -65,19 +65,107 @@ func (x *ShowProductRequest) GetProductId() string {
 	return ""
 }
 
-type ShowProductResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
-	Price         float64                `protobuf:"fixed64,4,opt,name=price,proto3" json:"price,omitempty"`
+type ShowProductImage struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	ImageId string                 `protobuf:"bytes,1,opt,name=image_id,json=imageId,proto3" json:"image_id,omitempty"`
+	Url     string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Width   int32                  `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height  int32                  `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	// thumbnails maps a size (small, medium, large) to its url
+	Thumbnails    map[string]string `protobuf:"bytes,5,rep,name=thumbnails,proto3" json:"thumbnails,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *ShowProductImage) Reset() {
+	*x = ShowProductImage{}
+	mi := &file_proto_showProduct_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShowProductImage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShowProductImage) ProtoMessage() {}
+
+func (x *ShowProductImage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_showProduct_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShowProductImage.ProtoReflect.Descriptor instead.
+func (*ShowProductImage) Descriptor() ([]byte, []int) {
+	return file_proto_showProduct_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ShowProductImage) GetImageId() string {
+	if x != nil {
+		return x.ImageId
+	}
+	return ""
+}
+
+func (x *ShowProductImage) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *ShowProductImage) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *ShowProductImage) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *ShowProductImage) GetThumbnails() map[string]string {
+	if x != nil {
+		return x.Thumbnails
+	}
+	return nil
+}
+
+type ShowProductResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id          string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name        string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	// price is the price charged at request time, the sale price during a sale
+	Price float64 `protobuf:"fixed64,4,opt,name=price,proto3" json:"price,omitempty"`
+	// category_ids are the categories the product is assigned to
+	CategoryIds []string `protobuf:"bytes,5,rep,name=category_ids,json=categoryIds,proto3" json:"category_ids,omitempty"`
+	// images in display order, the first is the main image
+	Images []*ShowProductImage `protobuf:"bytes,6,rep,name=images,proto3" json:"images,omitempty"`
+	// price_amount is the exact price in minor units of currency
+	PriceAmount int64  `protobuf:"varint,7,opt,name=price_amount,json=priceAmount,proto3" json:"price_amount,omitempty"`
+	Currency    string `protobuf:"bytes,8,opt,name=currency,proto3" json:"currency,omitempty"`
+	// list_price is the regular price, list_price_amount its exact amount
+	ListPrice       float64 `protobuf:"fixed64,9,opt,name=list_price,json=listPrice,proto3" json:"list_price,omitempty"`
+	ListPriceAmount int64   `protobuf:"varint,10,opt,name=list_price_amount,json=listPriceAmount,proto3" json:"list_price_amount,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
 func (x *ShowProductResponse) Reset() {
 	*x = ShowProductResponse{}
-	mi := &file_proto_showProduct_proto_msgTypes[1]
+	mi := &file_proto_showProduct_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -89,7 +177,7 @@ func (x *ShowProductResponse) String() string {
 func (*ShowProductResponse) ProtoMessage() {}
 
 func (x *ShowProductResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_showProduct_proto_msgTypes[1]
+	mi := &file_proto_showProduct_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -102,7 +190,7 @@ func (x *ShowProductResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShowProductResponse.ProtoReflect.Descriptor instead.
 func (*ShowProductResponse) Descriptor() ([]byte, []int) {
-	return file_proto_showProduct_proto_rawDescGZIP(), []int{1}
+	return file_proto_showProduct_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *ShowProductResponse) GetId() string {
@@ -133,6 +221,48 @@ func (x *ShowProductResponse) GetPrice() float64 {
 	return 0
 }
 
+func (x *ShowProductResponse) GetCategoryIds() []string {
+	if x != nil {
+		return x.CategoryIds
+	}
+	return nil
+}
+
+func (x *ShowProductResponse) GetImages() []*ShowProductImage {
+	if x != nil {
+		return x.Images
+	}
+	return nil
+}
+
+func (x *ShowProductResponse) GetPriceAmount() int64 {
+	if x != nil {
+		return x.PriceAmount
+	}
+	return 0
+}
+
+func (x *ShowProductResponse) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *ShowProductResponse) GetListPrice() float64 {
+	if x != nil {
+		return x.ListPrice
+	}
+	return 0
+}
+
+func (x *ShowProductResponse) GetListPriceAmount() int64 {
+	if x != nil {
+		return x.ListPriceAmount
+	}
+	return 0
+}
+
 var File_proto_showProduct_proto protoreflect.FileDescriptor
 
 const file_proto_showProduct_proto_rawDesc = "" +
@@ -140,12 +270,31 @@ const file_proto_showProduct_proto_rawDesc = "" +
 	"\x17proto/showProduct.proto\x12\vshowProduct\"3\n" +
 	"\x12ShowProductRequest\x12\x1d\n" +
 	"\n" +
-	"product_id\x18\x01 \x01(\tR\tproductId\"q\n" +
+	"product_id\x18\x01 \x01(\tR\tproductId\"\xfb\x01\n" +
+	"\x10ShowProductImage\x12\x19\n" +
+	"\bimage_id\x18\x01 \x01(\tR\aimageId\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x14\n" +
+	"\x05width\x18\x03 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x04 \x01(\x05R\x06height\x12M\n" +
+	"\n" +
+	"thumbnails\x18\x05 \x03(\v2-.showProduct.ShowProductImage.ThumbnailsEntryR\n" +
+	"thumbnails\x1a=\n" +
+	"\x0fThumbnailsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xd5\x02\n" +
 	"\x13ShowProductResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
 	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x14\n" +
-	"\x05price\x18\x04 \x01(\x01R\x05price2a\n" +
+	"\x05price\x18\x04 \x01(\x01R\x05price\x12!\n" +
+	"\fcategory_ids\x18\x05 \x03(\tR\vcategoryIds\x125\n" +
+	"\x06images\x18\x06 \x03(\v2\x1d.showProduct.ShowProductImageR\x06images\x12!\n" +
+	"\fprice_amount\x18\a \x01(\x03R\vpriceAmount\x12\x1a\n" +
+	"\bcurrency\x18\b \x01(\tR\bcurrency\x12\x1d\n" +
+	"\n" +
+	"list_price\x18\t \x01(\x01R\tlistPrice\x12*\n" +
+	"\x11list_price_amount\x18\n" +
+	" \x01(\x03R\x0flistPriceAmount2a\n" +
 	"\vShowProduct\x12R\n" +
 	"\vShowProduct\x12\x1f.showProduct.ShowProductRequest\x1a .showProduct.ShowProductResponse0\x01B\tZ\a./protob\x06proto3"
 
@@ -161,19 +310,23 @@ func file_proto_showProduct_proto_rawDescGZIP() []byte {
 	return file_proto_showProduct_proto_rawDescData
 }
 
-var file_proto_showProduct_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_showProduct_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_proto_showProduct_proto_goTypes = []any{
 	(*ShowProductRequest)(nil),  // 0: showProduct.ShowProductRequest
-	(*ShowProductResponse)(nil), // 1: showProduct.ShowProductResponse
+	(*ShowProductImage)(nil),    // 1: showProduct.ShowProductImage
+	(*ShowProductResponse)(nil), // 2: showProduct.ShowProductResponse
+	nil,                         // 3: showProduct.ShowProductImage.ThumbnailsEntry
 }
 var file_proto_showProduct_proto_depIdxs = []int32{
-	0, // 0: showProduct.ShowProduct.ShowProduct:input_type -> showProduct.ShowProductRequest
-	1, // 1: showProduct.ShowProduct.ShowProduct:output_type -> showProduct.ShowProductResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	3, // 0: showProduct.ShowProductImage.thumbnails:type_name -> showProduct.ShowProductImage.ThumbnailsEntry
+	1, // 1: showProduct.ShowProductResponse.images:type_name -> showProduct.ShowProductImage
+	0, // 2: showProduct.ShowProduct.ShowProduct:input_type -> showProduct.ShowProductRequest
+	2, // 3: showProduct.ShowProduct.ShowProduct:output_type -> showProduct.ShowProductResponse
+	3, // [3:4] is the sub-list for method output_type
+	2, // [2:3] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_proto_showProduct_proto_init() }
@@ -187,7 +340,7 @@ func file_proto_showProduct_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_showProduct_proto_rawDesc), len(file_proto_showProduct_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
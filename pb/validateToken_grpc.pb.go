@@ -20,6 +20,7 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	ValidateToken_ValidateToken_FullMethodName = "/validateToken.ValidateToken/ValidateToken"
+	ValidateToken_Introspect_FullMethodName    = "/validateToken.ValidateToken/Introspect"
 )
 
 // ValidateTokenClient is the client API for ValidateToken service.
@@ -27,6 +28,7 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ValidateTokenClient interface {
 	ValidateToken(ctx context.Context, in *ValidateTokenRequest, opts ...grpc.CallOption) (*ValidateTokenResponse, error)
+	Introspect(ctx context.Context, in *IntrospectRequest, opts ...grpc.CallOption) (*IntrospectResponse, error)
 }
 
 type validateTokenClient struct {
@@ -47,11 +49,22 @@ func (c *validateTokenClient) ValidateToken(ctx context.Context, in *ValidateTok
 	return out, nil
 }
 
+func (c *validateTokenClient) Introspect(ctx context.Context, in *IntrospectRequest, opts ...grpc.CallOption) (*IntrospectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IntrospectResponse)
+	err := c.cc.Invoke(ctx, ValidateToken_Introspect_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ValidateTokenServer is the server API for ValidateToken service.
 // All implementations must embed UnimplementedValidateTokenServer
 // for forward compatibility.
 type ValidateTokenServer interface {
 	ValidateToken(context.Context, *ValidateTokenRequest) (*ValidateTokenResponse, error)
+	Introspect(context.Context, *IntrospectRequest) (*IntrospectResponse, error)
 	mustEmbedUnimplementedValidateTokenServer()
 }
 
@@ -65,6 +78,9 @@ type UnimplementedValidateTokenServer struct{}
 func (UnimplementedValidateTokenServer) ValidateToken(context.Context, *ValidateTokenRequest) (*ValidateTokenResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ValidateToken not implemented")
 }
+func (UnimplementedValidateTokenServer) Introspect(context.Context, *IntrospectRequest) (*IntrospectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Introspect not implemented")
+}
 func (UnimplementedValidateTokenServer) mustEmbedUnimplementedValidateTokenServer() {}
 func (UnimplementedValidateTokenServer) testEmbeddedByValue()                       {}
 
@@ -104,6 +120,24 @@ func _ValidateToken_ValidateToken_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ValidateToken_Introspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IntrospectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidateTokenServer).Introspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ValidateToken_Introspect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidateTokenServer).Introspect(ctx, req.(*IntrospectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ValidateToken_ServiceDesc is the grpc.ServiceDesc for ValidateToken service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,6 +149,10 @@ var ValidateToken_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ValidateToken",
 			Handler:    _ValidateToken_ValidateToken_Handler,
 		},
+		{
+			MethodName: "Introspect",
+			Handler:    _ValidateToken_Introspect_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/proto/validateToken.proto",
@@ -0,0 +1,209 @@
+// Command catalog imports and exports the product catalog through the
+// product service's REST API.
+//
+//	catalog import [-url URL] [-token TOKEN] [-format csv|jsonl] FILE
+//	catalog export [-url URL] [-token TOKEN] [-format csv|jsonl] [-o FILE]
+//
+// The token needs the product:write permission; it defaults to the
+// CATALOG_TOKEN environment variable. import uploads the file, follows the
+// job until it is done and lists the rejected rows. It exits with status 1
+// when rows were rejected or the import failed.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/catalog"
+	"github.com/sachinggsingh/e-comm/internal/model"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "catalog:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog import [-url URL] [-token TOKEN] [-format csv|jsonl] FILE")
+	fmt.Fprintln(os.Stderr, "       catalog export [-url URL] [-token TOKEN] [-format csv|jsonl] [-o FILE]")
+	os.Exit(2)
+}
+
+type client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+func commonFlags(fs *flag.FlagSet) (*string, *string, *string) {
+	url := fs.String("url", "http://localhost:8081", "base URL of the product service")
+	token := fs.String("token", os.Getenv("CATALOG_TOKEN"), "access token or API key with product:write")
+	format := fs.String("format", "", "csv or jsonl, by default taken from the file extension")
+	return url, token, format
+}
+
+func (c *client) do(method string, path string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.url, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, res.Status, strings.TrimSpace(string(msg)))
+	}
+	return res, nil
+}
+
+// formatOf picks the format from the flag or else the file extension
+func formatOf(flagValue string, file string) (string, error) {
+	if flagValue != "" {
+		return catalog.ParseFormat(flagValue)
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return catalog.CSV, nil
+	case ".jsonl", ".ndjson":
+		return catalog.JSONL, nil
+	}
+	return "", errors.New("cannot tell the format from the file name, use -format")
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	url, token, formatFlag := commonFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+	path := fs.Arg(0)
+	format, err := formatOf(*formatFlag, path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	c := &client{url: *url, token: *token, http: &http.Client{}}
+	res, err := c.do(http.MethodPost, "/catalog/import?format="+format, catalog.ContentType(format), file)
+	if err != nil {
+		return err
+	}
+	var job model.ImportJob
+	err = json.NewDecoder(res.Body).Decode(&job)
+	res.Body.Close()
+	if err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "import %s started\n", job.Job_id)
+
+	c.http.Timeout = 30 * time.Second
+	for job.Status == model.ImportQueued || job.Status == model.ImportRunning {
+		time.Sleep(time.Second)
+		res, err := c.do(http.MethodGet, "/catalog/import/"+job.Job_id, "", nil)
+		if err != nil {
+			return err
+		}
+		err = json.NewDecoder(res.Body).Decode(&job)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		percent := 0.0
+		if job.Bytes_total > 0 {
+			percent = 100 * float64(job.Bytes_read) / float64(job.Bytes_total)
+		}
+		fmt.Fprintf(os.Stderr, "\r%s: %.0f%%, %d rows", job.Status, percent, job.Rows)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	fmt.Printf("%d rows: %d created, %d updated, %d unchanged, %d failed\n",
+		job.Rows, job.Created, job.Updated, job.Unchanged, job.Failed)
+	for _, rowErr := range job.Errors {
+		if rowErr.Sku != "" {
+			fmt.Printf("row %d (%s): %s\n", rowErr.Row, rowErr.Sku, rowErr.Message)
+		} else {
+			fmt.Printf("row %d: %s\n", rowErr.Row, rowErr.Message)
+		}
+	}
+	if missing := job.Failed - int64(len(job.Errors)); missing > 0 {
+		fmt.Printf("and %d more failed rows\n", missing)
+	}
+	if job.Status == model.ImportFailed {
+		return errors.New("import failed: " + job.Error)
+	}
+	if job.Failed > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	url, token, formatFlag := commonFlags(fs)
+	output := fs.String("o", "", "file to write, standard output by default")
+	fs.Parse(args)
+
+	format := catalog.CSV
+	if *formatFlag != "" || *output != "" {
+		parsed, err := formatOf(*formatFlag, *output)
+		if err != nil {
+			return err
+		}
+		format = parsed
+	}
+
+	c := &client{url: *url, token: *token, http: &http.Client{}}
+	res, err := c.do(http.MethodGet, "/catalog/export?format="+format, "", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	_, err = io.Copy(w, res.Body)
+	return err
+}
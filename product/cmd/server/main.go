@@ -6,12 +6,18 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/sachinggsingh/e-comm/internal/api"
 	"github.com/sachinggsingh/e-comm/internal/config"
 	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/media"
 	"github.com/sachinggsingh/e-comm/internal/repository"
 	"github.com/sachinggsingh/e-comm/internal/service"
+	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
@@ -26,9 +32,61 @@ func main() {
 	server := api.NewServer(env, database)
 
 	repo := repository.NewProductRepository(database)
-	productService := service.NewProductService(repo)
+	categoryRepo := repository.NewCategoryRepository(database)
+	productService := service.NewProductService(repo, categoryRepo, repository.NewPriceRepository(database))
+	categoryService := service.NewCategoryService(categoryRepo, repo)
+	if err := productService.MigratePrices(); err != nil {
+		log.Fatalf("Failed to migrate prices: %v", err)
+	}
+	if err := productService.BackfillSearchKeys(); err != nil {
+		log.Printf("Failed to index products for search: %v", err)
+	}
+
+	// Callers are authenticated by introspecting their token with the auth service
+	authConn, err := grpc.NewClient(env.AUTH_SERVICE_URL, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to initialize auth gRPC client: %v", err)
+	}
+	defer authConn.Close()
+	authenticator := authz.NewIntrospectionAuthenticator(proto.NewValidateTokenClient(authConn))
 
-	server.ProductRoutes(productService)
+	server.ProductRoutes(productService, authenticator)
+	server.CategoryRoutes(categoryService, productService, authenticator)
+
+	store, err := newBlobStore(env)
+	if err != nil {
+		log.Fatalf("Failed to initialize media store: %v", err)
+	}
+	mediaService := service.NewMediaService(repo, store, int64(env.MEDIA_MAX_MB)<<20)
+	server.MediaRoutes(mediaService, store, authenticator)
+
+	catalogService := service.NewCatalogService(productService, repo, repository.NewImportJobRepository(database))
+	if err := catalogService.FailInterruptedImports(); err != nil {
+		log.Printf("Failed to mark interrupted imports: %v", err)
+	}
+	server.CatalogRoutes(catalogService, authenticator)
+
+	// Give back the stock of reservations that were not committed in time
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			if err := productService.ReleaseExpiredReservations(); err != nil {
+				log.Printf("Reservation sweep failed: %v", err)
+			}
+		}
+	}()
+
+	// Apply scheduled price changes and record sales starting and ending
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			if err := productService.ApplyPriceSchedules(); err != nil {
+				log.Printf("Price scheduler failed: %v", err)
+			}
+		}
+	}()
 
 	go func() {
 		if err := server.StartServer(); err != nil {
@@ -38,7 +96,7 @@ func main() {
 
 	// Start gRPC Server
 	go func() {
-		if err := server.GrpcServer(); err != nil {
+		if err := server.GrpcServer(productService); err != nil {
 			fmt.Printf("Failed to start gRPC server: %v\n", err)
 		}
 		log.Printf("GRPC server running")
@@ -52,3 +110,21 @@ func main() {
 	fmt.Println("Shutting down")
 
 }
+
+// newBlobStore returns the store for product images chosen by MEDIA_STORE
+func newBlobStore(env *config.Env) (media.BlobStore, error) {
+	switch env.MEDIA_STORE {
+	case "local":
+		return media.NewLocalStore(env.MEDIA_DIR, env.MEDIA_BASE_URL)
+	case "s3":
+		return media.NewS3Store(media.S3Config{
+			Endpoint:  env.S3_ENDPOINT,
+			Region:    env.S3_REGION,
+			Bucket:    env.S3_BUCKET,
+			AccessKey: env.S3_ACCESS_KEY,
+			SecretKey: env.S3_SECRET_KEY,
+			PublicURL: env.S3_PUBLIC_URL,
+		})
+	}
+	return nil, fmt.Errorf("unknown MEDIA_STORE %q, use local or s3", env.MEDIA_STORE)
+}
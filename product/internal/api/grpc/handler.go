@@ -2,11 +2,17 @@ package grpc_handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/sachinggsingh/e-comm/internal/intra/db"
 	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"github.com/sachinggsingh/e-comm/internal/service"
 	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/money"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"google.golang.org/grpc/codes"
@@ -16,12 +22,16 @@ import (
 type ProductServer struct {
 	proto.UnimplementedGetProductsServer
 	proto.UnimplementedShowProductServer
-	database *db.Database
+	proto.UnimplementedSearchProductsServer
+	proto.UnimplementedInventoryServer
+	database       *db.Database
+	productService *service.Productservice
 }
 
-func NewProductServer(database *db.Database) *ProductServer {
+func NewProductServer(database *db.Database, productService *service.Productservice) *ProductServer {
 	return &ProductServer{
-		database: database,
+		database:       database,
+		productService: productService,
 	}
 }
 
@@ -32,9 +42,8 @@ func (p *ProductServer) GetProducts(ctx context.Context, req *proto.GetProductRe
 	}
 
 	var product model.Product
-	filter := bson.M{
-		"product_id": req.ProductId,
-	}
+	filter := repository.NotDeleted()
+	filter["product_id"] = req.ProductId
 	err := p.database.ProductCollection.FindOne(ctx, filter).Decode(&product)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -44,25 +53,132 @@ func (p *ProductServer) GetProducts(ctx context.Context, req *proto.GetProductRe
 		return nil, status.Errorf(codes.Internal, "failed to fetch product: %v", err)
 	}
 
+	res := productResponse(&product, req.Sku)
+	if req.Sku != "" && res.Variant == nil {
+		return nil, status.Errorf(codes.NotFound, "product %s has no variant %s", req.ProductId, req.Sku)
+	}
+	return res, nil
+}
+
+// maxBatchItems bounds the lookups of one GetProductsBatch call
+const maxBatchItems = 100
+
+// GetProductsBatch answers many lookups with a single query. Missing
+// products and variants are reported per item instead of failing the call.
+func (p *ProductServer) GetProductsBatch(ctx context.Context, req *proto.GetProductsBatchRequest) (*proto.GetProductsBatchResponse, error) {
+	if len(req.Items) > maxBatchItems {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d items can be looked up at once", maxBatchItems)
+	}
+	ids := make([]string, 0, len(req.Items))
+	seen := map[string]bool{}
+	for _, item := range req.Items {
+		if item.ProductId == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "every item needs a product_id")
+		}
+		if !seen[item.ProductId] {
+			seen[item.ProductId] = true
+			ids = append(ids, item.ProductId)
+		}
+	}
+
+	products := make(map[string]*model.Product, len(ids))
+	if len(ids) > 0 {
+		filter := repository.NotDeleted()
+		filter["product_id"] = bson.M{"$in": ids}
+		cursor, err := p.database.ProductCollection.Find(ctx, filter)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to fetch products: %v", err)
+		}
+		defer cursor.Close(ctx)
+		for cursor.Next(ctx) {
+			var product model.Product
+			if err := cursor.Decode(&product); err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to decode product: %v", err)
+			}
+			products[product.Product_id] = &product
+		}
+		if err := cursor.Err(); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to fetch products: %v", err)
+		}
+	}
+
+	res := &proto.GetProductsBatchResponse{
+		Results: make([]*proto.ProductLookup, 0, len(req.Items)),
+	}
+	for _, item := range req.Items {
+		lookup := &proto.ProductLookup{ProductId: item.ProductId, Sku: item.Sku}
+		product, ok := products[item.ProductId]
+		if !ok {
+			lookup.Error = fmt.Sprintf("product with id %s not found", item.ProductId)
+		} else if found := productResponse(product, item.Sku); item.Sku != "" && found.Variant == nil {
+			lookup.Error = fmt.Sprintf("product %s has no variant %s", item.ProductId, item.Sku)
+		} else {
+			lookup.Found = true
+			lookup.Product = found
+		}
+		res.Results = append(res.Results, lookup)
+	}
+	return res, nil
+}
+
+// productResponse converts a product for GetProducts; sku selects the
+// variant returned as Variant, which stays nil when there is none. Prices
+// are those charged now. Every RPC returning a product takes its prices from
+// here, so they agree.
+func productResponse(product *model.Product, sku string) *proto.GetPRoductResponse {
 	// Handle nil pointers
 	var name, description string
-	var price float64
 	if product.Name != nil {
 		name = *product.Name
 	}
 	if product.Description != nil {
 		description = *product.Description
 	}
-	if product.Price != nil {
-		price = *product.Price
-	}
+	now := time.Now()
+	listPrice, price := product.Prices("", now)
 
-	return &proto.GetPRoductResponse{
-		Id:          product.ID.String(),
-		Name:        name,
-		Description: description,
-		Price:       price,
-	}, nil
+	res := &proto.GetPRoductResponse{
+		Id:              product.ID.String(),
+		Name:            name,
+		Description:     description,
+		Price:           price.Float64(),
+		ListPrice:       listPrice.Float64(),
+		PriceAmount:     price.Amount,
+		ListPriceAmount: listPrice.Amount,
+		Currency:        product.Currency(),
+		PriceList:       priceList(product.Price_list),
+		Stock:           product.Stock,
+		CategoryIds:     product.Category_ids,
+		Variants:        make([]*proto.ProductVariant, 0, len(product.Variants)),
+		Images:          make([]*proto.ProductImage, 0, len(product.Images)),
+	}
+	for _, image := range product.Images {
+		res.Images = append(res.Images, &proto.ProductImage{
+			ImageId:    image.Image_id,
+			Url:        image.Url,
+			Width:      int32(image.Width),
+			Height:     int32(image.Height),
+			Thumbnails: thumbnailURLs(image),
+		})
+	}
+	for _, variant := range product.Variants {
+		v := &proto.ProductVariant{
+			Sku:        variant.Sku,
+			Attributes: variant.Attributes,
+			Stock:      variant.Stock,
+		}
+		listPrice, price := product.Prices(variant.Sku, now)
+		v.ListPrice, v.Price = listPrice.Float64(), price.Float64()
+		v.ListPriceAmount, v.PriceAmount = listPrice.Amount, price.Amount
+		if variant.Price == nil {
+			v.PriceList = res.PriceList
+		}
+		res.Variants = append(res.Variants, v)
+		if sku != "" && variant.Sku == sku {
+			res.Variant = v
+		}
+	}
+	return res
 }
 
 func (p *ProductServer) ShowProduct(req *proto.ShowProductRequest, stream proto.ShowProduct_ShowProductServer) error {
@@ -72,9 +188,9 @@ func (p *ProductServer) ShowProduct(req *proto.ShowProductRequest, stream proto.
 	}
 
 	ctx := stream.Context()
-	cursor, err := p.database.ProductCollection.Find(ctx, bson.M{
-		"product_id": req.ProductId,
-	})
+	filter := repository.NotDeleted()
+	filter["product_id"] = req.ProductId
+	cursor, err := p.database.ProductCollection.Find(ctx, filter)
 	if err != nil {
 		return status.Errorf(codes.Internal, "failed to query products: %v", err)
 	}
@@ -87,22 +203,28 @@ func (p *ProductServer) ShowProduct(req *proto.ShowProductRequest, stream proto.
 		if err := cursor.Decode(&product); err != nil {
 			return err
 		}
-		var name, description string
-		var price float64
-		if product.Name != nil {
-			name = *product.Name
-		}
-		if product.Description != nil {
-			description = *product.Description
-		}
-		if product.Price != nil {
-			price = *product.Price
-		}
+		// Prices go through productResponse, as for GetProducts
+		found := productResponse(&product, "")
 		res := &proto.ShowProductResponse{
-			Id:          product.ID.String(),
-			Name:        name,
-			Description: description,
-			Price:       price,
+			Id:              found.Id,
+			Name:            found.Name,
+			Description:     found.Description,
+			Price:           found.Price,
+			ListPrice:       found.ListPrice,
+			PriceAmount:     found.PriceAmount,
+			ListPriceAmount: found.ListPriceAmount,
+			Currency:        found.Currency,
+			CategoryIds:     found.CategoryIds,
+			Images:          make([]*proto.ShowProductImage, 0, len(product.Images)),
+		}
+		for _, image := range product.Images {
+			res.Images = append(res.Images, &proto.ShowProductImage{
+				ImageId:    image.Image_id,
+				Url:        image.Url,
+				Width:      int32(image.Width),
+				Height:     int32(image.Height),
+				Thumbnails: thumbnailURLs(image),
+			})
 		}
 		if err := stream.Send(res); err != nil {
 			return err
@@ -116,3 +238,148 @@ func (p *ProductServer) ShowProduct(req *proto.ShowProductRequest, stream proto.
 	}
 	return nil
 }
+
+// SearchProducts runs the same search as GET /product/search
+func (p *ProductServer) SearchProducts(ctx context.Context, req *proto.SearchProductsRequest) (*proto.SearchProductsResponse, error) {
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	minPrice, err := priceBound(req.MinPriceAmount, req.MinPrice, currency)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "min_price: %v", err)
+	}
+	maxPrice, err := priceBound(req.MaxPriceAmount, req.MaxPrice, currency)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "max_price: %v", err)
+	}
+	query := &model.SearchQuery{
+		Query:     req.Query,
+		Limit:     int(req.Limit),
+		Offset:    int(req.Offset),
+		Category:  req.Category,
+		Min_price: minPrice,
+		Max_price: maxPrice,
+		In_stock:  req.InStock,
+	}
+	result, err := p.productService.Search(query)
+	if err != nil {
+		if errors.Is(err, service.InvalidQuery) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to search products: %v", err)
+	}
+
+	res := &proto.SearchProductsResponse{
+		Hits:         make([]*proto.SearchHit, 0, len(result.Hits)),
+		Total:        result.Total,
+		TotalExact:   result.Total_exact,
+		Categories:   facetCounts(result.Facets.Categories),
+		PriceBuckets: facetCounts(result.Facets.Price_buckets),
+		InStock:      result.Facets.In_stock,
+		OutOfStock:   result.Facets.Out_of_stock,
+	}
+	for _, hit := range result.Hits {
+		found := productResponse(hit.Product, "")
+		res.Hits = append(res.Hits, &proto.SearchHit{
+			ProductId:            hit.Product_id,
+			Name:                 found.Name,
+			Description:          found.Description,
+			Price:                found.Price,
+			ListPrice:            found.ListPrice,
+			PriceAmount:          found.PriceAmount,
+			ListPriceAmount:      found.ListPriceAmount,
+			Currency:             found.Currency,
+			Stock:                hit.Stock,
+			CategoryIds:          found.CategoryIds,
+			Score:                hit.Score,
+			NameHighlight:        hit.Name_highlight,
+			DescriptionHighlight: hit.Description_highlight,
+			ThumbnailUrl:         hit.Thumbnail("small"),
+		})
+	}
+	return res, nil
+}
+
+func priceList(list []money.Money) []*proto.Money {
+	prices := make([]*proto.Money, 0, len(list))
+	for _, price := range list {
+		prices = append(prices, &proto.Money{Amount: price.Amount, Currency: price.Currency})
+	}
+	return prices
+}
+
+// priceBound reads a search price bound from its exact amount or, failing
+// that, its double
+func priceBound(amount *int64, value *float64, currency string) (*money.Money, error) {
+	if amount != nil {
+		bound := money.New(*amount, currency)
+		return &bound, bound.Validate()
+	}
+	if value != nil {
+		bound, err := money.FromFloat(*value, currency)
+		return &bound, err
+	}
+	return nil, nil
+}
+
+// thumbnailURLs maps the sizes of the thumbnails of an image to their urls
+func thumbnailURLs(image model.ProductImage) map[string]string {
+	urls := make(map[string]string, len(image.Thumbnails))
+	for _, thumbnail := range image.Thumbnails {
+		urls[thumbnail.Size] = thumbnail.Url
+	}
+	return urls
+}
+
+func facetCounts(counts []model.FacetCount) []*proto.FacetCount {
+	out := make([]*proto.FacetCount, 0, len(counts))
+	for _, c := range counts {
+		out = append(out, &proto.FacetCount{Value: c.Value, Count: c.Count})
+	}
+	return out
+}
+
+// inventoryError maps the errors of the inventory methods to gRPC statuses
+func inventoryError(err error) error {
+	switch {
+	case errors.Is(err, service.InvalidReservation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ProductNotFound), errors.Is(err, service.ReservationNotFound),
+		errors.Is(err, service.VariantNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.InsufficientStock):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Errorf(codes.Internal, "%v", err)
+}
+
+func (p *ProductServer) ReserveStock(ctx context.Context, req *proto.ReserveStockRequest) (*proto.ReserveStockResponse, error) {
+	items := make([]model.StockItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, model.StockItem{Product_id: item.ProductId, Sku: item.Sku, Quantity: item.Quantity})
+	}
+	ttl := time.Duration(req.TtlSeconds) * time.Second
+	expiresAt, err := p.productService.ReserveStock(req.ReservationId, items, ttl)
+	if err != nil {
+		return nil, inventoryError(err)
+	}
+	return &proto.ReserveStockResponse{
+		ReservationId: req.ReservationId,
+		ExpiresAt:     expiresAt.Unix(),
+	}, nil
+}
+
+func (p *ProductServer) CommitReservation(ctx context.Context, req *proto.ReservationRequest) (*proto.ReservationResponse, error) {
+	if err := p.productService.CommitReservation(req.ReservationId); err != nil {
+		return nil, inventoryError(err)
+	}
+	return &proto.ReservationResponse{ReservationId: req.ReservationId}, nil
+}
+
+func (p *ProductServer) ReleaseReservation(ctx context.Context, req *proto.ReservationRequest) (*proto.ReservationResponse, error) {
+	if err := p.productService.ReleaseReservation(req.ReservationId); err != nil {
+		return nil, inventoryError(err)
+	}
+	return &proto.ReservationResponse{ReservationId: req.ReservationId}, nil
+}
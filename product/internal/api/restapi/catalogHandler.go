@@ -0,0 +1,88 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sachinggsingh/e-comm/internal/catalog"
+	"github.com/sachinggsingh/e-comm/internal/service"
+)
+
+// CatalogHandler imports and exports the catalog in bulk
+type CatalogHandler struct {
+	catalogService *service.CatalogService
+}
+
+func NewCatalogHandler(catalogService *service.CatalogService) *CatalogHandler {
+	return &CatalogHandler{
+		catalogService: catalogService,
+	}
+}
+
+// ImportCatalog takes a CSV or JSON Lines file as the request body, the
+// format given by the format query parameter or the Content-Type, and
+// imports it in the background. It answers 202 with the job to poll.
+func (ch *CatalogHandler) ImportCatalog(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = r.Header.Get("Content-Type")
+	}
+	job, err := ch.catalogService.StartImport(r.Body, format, actor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.InvalidImport):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ImportTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/catalog/import/"+job.Job_id)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetImport reports the progress of an import and the rows it rejected
+func (ch *CatalogHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	job, err := ch.catalogService.GetImport(mux.Vars(r)["job_id"])
+	if err != nil {
+		if errors.Is(err, service.ImportNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// ExportCatalog streams every product as CSV (the default) or JSON Lines,
+// in the format the import takes
+func (ch *CatalogHandler) ExportCatalog(w http.ResponseWriter, r *http.Request) {
+	format := catalog.CSV
+	if value := r.URL.Query().Get("format"); value != "" {
+		parsed, err := catalog.ParseFormat(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format = parsed
+	}
+
+	filename := "catalog-" + time.Now().UTC().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Type", catalog.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := ch.catalogService.Export(r.Context(), w, format); err != nil {
+		// The status is sent with the first row, the client sees a cut off file
+		log.Printf("Catalog export failed: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/service"
+)
+
+// CategoryHandler manages the category tree. Categories are addressed by id
+// or slug.
+type CategoryHandler struct {
+	categoryService *service.CategoryService
+	productService  *service.Productservice
+}
+
+func NewCategoryHandler(categoryService *service.CategoryService, productService *service.Productservice) *CategoryHandler {
+	return &CategoryHandler{
+		categoryService: categoryService,
+		productService:  productService,
+	}
+}
+
+func (ch *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var req model.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	category, err := ch.categoryService.CreateCategory(&req)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// GetTree returns all categories nested under their parents
+func (ch *CategoryHandler) GetTree(w http.ResponseWriter, r *http.Request) {
+	tree, err := ch.categoryService.Tree()
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
+// GetCategory returns the category with its subtree
+func (ch *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	node, err := ch.categoryService.GetCategory(mux.Vars(r)["category"])
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(node)
+}
+
+func (ch *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	var req model.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	category, err := ch.categoryService.UpdateCategory(mux.Vars(r)["category"], &req)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+func (ch *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	if err := ch.categoryService.DeleteCategory(mux.Vars(r)["category"]); err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListProducts lists the products of the category and its descendants. It
+// takes the query parameters of GET /product.
+func (ch *CategoryHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
+	if _, err := ch.categoryService.GetCategory(mux.Vars(r)["category"]); err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+	query, err := parseProductQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Category = mux.Vars(r)["category"]
+
+	page, err := ch.productService.ListProducts(query)
+	if err != nil {
+		if errors.Is(err, service.InvalidQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func writeCategoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.InvalidCategory):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.CategoryNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.CategoryExist), errors.Is(err, service.CategoryNotEmpty):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,122 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sachinggsingh/e-comm/internal/media"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/service"
+)
+
+// multipartMemory is how much of an upload is kept in memory, the rest is
+// buffered in temporary files
+const multipartMemory = 8 << 20
+
+// MediaHandler manages the images of products
+type MediaHandler struct {
+	mediaService *service.MediaService
+}
+
+func NewMediaHandler(mediaService *service.MediaService) *MediaHandler {
+	return &MediaHandler{
+		mediaService: mediaService,
+	}
+}
+
+// UploadImage adds the image in the multipart field "image" to the product.
+// The optional field "position" inserts it there, 0 making it the main
+// image; without it the image goes last.
+func (mh *MediaHandler) UploadImage(w http.ResponseWriter, r *http.Request) {
+	maxBytes := mh.mediaService.MaxBytes()
+	// leave room for the multipart framing and the other fields
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("image file is too large, at most %d bytes", maxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "request must be multipart/form-data with an image field", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		http.Error(w, "image field is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read image", http.StatusBadRequest)
+		return
+	}
+
+	position := -1
+	if value := r.FormValue("position"); value != "" {
+		position, err = strconv.Atoi(value)
+		if err != nil || position < 0 {
+			http.Error(w, "position must be a number of at least 0", http.StatusBadRequest)
+			return
+		}
+	}
+
+	pro, err := mh.mediaService.AddImage(r.Context(), mux.Vars(r)["product_id"], data, position)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(pro.Version))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pro)
+}
+
+func (mh *MediaHandler) DeleteImage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pro, err := mh.mediaService.RemoveImage(r.Context(), vars["product_id"], vars["image_id"])
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	writeProduct(w, pro)
+}
+
+// ReorderImages puts the images of the product in the order of the ids in
+// the body
+func (mh *MediaHandler) ReorderImages(w http.ResponseWriter, r *http.Request) {
+	var req model.ImageOrder
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	pro, err := mh.mediaService.ReorderImages(mux.Vars(r)["product_id"], req.Image_ids)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	writeProduct(w, pro)
+}
+
+func writeMediaError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, service.ImageTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, media.ErrInvalidImage), errors.Is(err, service.InvalidImage):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ImageNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		writeProductError(w, err)
+	}
+}
@@ -0,0 +1,91 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/service"
+)
+
+// GetPriceHistory lists the price changes of a product, newest first.
+// Query parameters: sku for a variant, from and to (RFC 3339) and limit.
+func (ph *ProductHandler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	query := &model.PriceHistoryQuery{
+		Product_id: mux.Vars(r)["product_id"],
+		Sku:        values.Get("sku"),
+	}
+	for name, bound := range map[string]**time.Time{"from": &query.From, "to": &query.To} {
+		if value := values.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, name+" must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			*bound = &t
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, "limit must be a number", http.StatusBadRequest)
+			return
+		}
+		query.Limit = n
+	}
+
+	changes, err := ph.productService.PriceHistory(query)
+	if err != nil {
+		if errors.Is(err, service.InvalidQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeProductError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
+
+func (ph *ProductHandler) GetPriceSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := ph.productService.PriceSchedules(mux.Vars(r)["product_id"])
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+// SchedulePrice schedules a list price change, or a sale when the body has
+// an ends_at
+func (ph *ProductHandler) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	var req model.PriceScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := ph.productService.SchedulePrice(mux.Vars(r)["product_id"], &req, actor(r))
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schedule)
+}
+
+func (ph *ProductHandler) CancelPriceSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := ph.productService.CancelPriceSchedule(vars["product_id"], vars["schedule_id"], actor(r)); err != nil {
+		writeProductError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
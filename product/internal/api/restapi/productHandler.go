@@ -3,12 +3,20 @@ package restapi
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
 	"github.com/sachinggsingh/e-comm/internal/service"
+	"github.com/sachinggsingh/e-comm/pb/authz"
+	"github.com/sachinggsingh/e-comm/pb/money"
 )
 
 type ProductHandler struct {
@@ -27,12 +35,20 @@ func (ph *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	pro, err := ph.productService.CreateProduct(&product)
+	pro, err := ph.productService.CreateProduct(&product, actor(r))
 	if err != nil {
 		if errors.Is(err, service.CantCreateProduct) {
 			http.Error(w, "Missing required product fields", http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, service.InvalidProduct) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.VariantExist) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if errors.Is(err, service.ProductExist) {
 			http.Error(w, err.Error(), http.StatusConflict)
 			return
@@ -41,14 +57,36 @@ func (ph *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	pro.SetEffectivePrices(time.Now())
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(pro.Version))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(pro)
 }
 
+// GetAllProducts lists the catalog. Query parameters: limit, sort (price,
+// name, created_at; prefix "-" for descending), min_price, max_price,
+// currency of the prices (USD when missing), category, cursor and
+// total=true. With limit or cursor it answers one page as a ProductPage,
+// without either every matching product as a plain array like it always did.
 func (ph *ProductHandler) GetAllProducts(w http.ResponseWriter, r *http.Request) {
-	pro, err := ph.productService.GetAllProducts()
+	query, err := parseProductQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var pro any
+	if r.URL.Query().Has("limit") || query.Cursor != "" {
+		pro, err = ph.productService.ListProducts(query)
+	} else {
+		pro, err = ph.productService.ListAllProducts(query)
+	}
 	if err != nil {
+		if errors.Is(err, service.InvalidQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -58,6 +96,32 @@ func (ph *ProductHandler) GetAllProducts(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(pro)
 }
 
+// SearchProducts finds products matching q in their name or description,
+// tolerating prefixes and single typos. Query parameters: q, limit, offset,
+// category, min_price, max_price, currency and in_stock=true. Facets are counted over
+// all matches, not just the returned page.
+func (ph *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := ph.productService.Search(query)
+	if err != nil {
+		if errors.Is(err, service.InvalidQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
 func (ph *ProductHandler) GetProductById(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
@@ -70,6 +134,10 @@ func (ph *ProductHandler) GetProductById(w http.ResponseWriter, r *http.Request)
 	pro, err := ph.productService.GetProductById(&model.Product{Product_id: productId})
 	log.Println(pro)
 	if err != nil {
+		if errors.Is(err, service.ProductNotFound) {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -79,6 +147,244 @@ func (ph *ProductHandler) GetProductById(w http.ResponseWriter, r *http.Request)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(pro.Version))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(pro)
 }
+
+// UpdateProduct replaces (PUT) or patches (PATCH) a product. The If-Match
+// header must carry the ETag the caller read the product with.
+func (ph *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	var update model.UpdateProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	pro, err := ph.productService.UpdateProduct(mux.Vars(r)["product_id"], version, &update, r.Method == http.MethodPut, actor(r))
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+	writeProduct(w, pro)
+}
+
+// DeleteProduct soft deletes a product, guarded by If-Match like updates
+func (ph *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	pro, err := ph.productService.DeleteProduct(mux.Vars(r)["product_id"], version)
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(pro.Version))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreProduct undoes a delete. If-Match is optional here.
+func (ph *ProductHandler) RestoreProduct(w http.ResponseWriter, r *http.Request) {
+	version := repository.AnyVersion
+	if r.Header.Get("If-Match") != "" {
+		var ok bool
+		if version, ok = requireIfMatch(w, r); !ok {
+			return
+		}
+	}
+
+	pro, err := ph.productService.RestoreProduct(mux.Vars(r)["product_id"], version)
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+	writeProduct(w, pro)
+}
+
+// AdjustStock adds to or takes from the stock of a product, with a body of
+// {"delta": n}, or of one of its variants with {"sku": "...", "delta": n}.
+// It is atomic, so it needs no If-Match, and refuses to take more stock than
+// is available.
+func (ph *ProductHandler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	var req model.StockAdjustment
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	pro, err := ph.productService.AdjustStock(mux.Vars(r)["product_id"], req.Sku, req.Delta)
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+	writeProduct(w, pro)
+}
+
+// UpsertVariant creates the variant of the SKU in the path or changes its
+// attributes and price. Its stock is only taken from the body when the
+// variant is created.
+func (ph *ProductHandler) UpsertVariant(w http.ResponseWriter, r *http.Request) {
+	var req model.VariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	pro, err := ph.productService.UpsertVariant(vars["product_id"], vars["sku"], &req, actor(r))
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+	writeProduct(w, pro)
+}
+
+func (ph *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pro, err := ph.productService.DeleteVariant(vars["product_id"], vars["sku"])
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+	writeProduct(w, pro)
+}
+
+func parseProductQuery(r *http.Request) (*model.ProductQuery, error) {
+	values := r.URL.Query()
+	query := &model.ProductQuery{
+		Category: values.Get("category"),
+		Cursor:   values.Get("cursor"),
+	}
+	if sort := values.Get("sort"); sort != "" {
+		query.Sort = strings.TrimPrefix(sort, "-")
+		query.Desc = strings.HasPrefix(sort, "-")
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.New("limit must be a number")
+		}
+		query.Limit = n
+	}
+	if err := parsePriceRange(values, &query.Min_price, &query.Max_price); err != nil {
+		return nil, err
+	}
+	if total := values.Get("total"); total != "" {
+		include, err := strconv.ParseBool(total)
+		if err != nil {
+			return nil, errors.New("total must be true or false")
+		}
+		query.Include_total = include
+	}
+	return query, nil
+}
+
+func parseSearchQuery(r *http.Request) (*model.SearchQuery, error) {
+	values := r.URL.Query()
+	query := &model.SearchQuery{
+		Query:    values.Get("q"),
+		Category: values.Get("category"),
+	}
+	for name, n := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if value := values.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.New(name + " must be a number")
+			}
+			*n = parsed
+		}
+	}
+	if err := parsePriceRange(values, &query.Min_price, &query.Max_price); err != nil {
+		return nil, err
+	}
+	if inStock := values.Get("in_stock"); inStock != "" {
+		only, err := strconv.ParseBool(inStock)
+		if err != nil {
+			return nil, errors.New("in_stock must be true or false")
+		}
+		query.In_stock = only
+	}
+	return query, nil
+}
+
+// parsePriceRange reads min_price and max_price as decimals of the currency
+// parameter, DefaultCurrency when missing
+func parsePriceRange(values url.Values, minPrice **money.Money, maxPrice **money.Money) error {
+	currency := values.Get("currency")
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	for name, bound := range map[string]**money.Money{"min_price": minPrice, "max_price": maxPrice} {
+		if value := values.Get(name); value != "" {
+			price, err := money.Parse(value, currency)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			*bound = &price
+		}
+	}
+	return nil
+}
+
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// requireIfMatch returns the version of the If-Match header, answering 428
+// when it is missing and 400 when it is not one of our ETags
+func requireIfMatch(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		http.Error(w, "If-Match header with the product ETag is required", http.StatusPreconditionRequired)
+		return 0, false
+	}
+	if ifMatch == "*" {
+		return repository.AnyVersion, true
+	}
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`), 10, 64)
+	if err != nil || version < 0 {
+		http.Error(w, "If-Match must be an ETag returned for the product", http.StatusBadRequest)
+		return 0, false
+	}
+	return version, true
+}
+
+// actor is the id of the user making the request, empty when unknown
+func actor(r *http.Request) string {
+	if p := authz.PrincipalFromContext(r.Context()); p != nil {
+		return p.UserID
+	}
+	return ""
+}
+
+func writeProduct(w http.ResponseWriter, pro *model.Product) {
+	pro.SetEffectivePrices(time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(pro.Version))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(pro)
+}
+
+func writeProductError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.InvalidProduct):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ProductNotFound), errors.Is(err, service.VariantNotFound),
+		errors.Is(err, service.ScheduleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.VersionMismatch):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, service.ProductNotDeleted), errors.Is(err, service.InsufficientStock),
+		errors.Is(err, service.VariantExist), errors.Is(err, service.ProductExist),
+		errors.Is(err, service.VariantReserved), errors.Is(err, service.ProductHasStock):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -11,8 +11,10 @@ import (
 	"github.com/sachinggsingh/e-comm/internal/api/restapi"
 	"github.com/sachinggsingh/e-comm/internal/config"
 	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/media"
 	"github.com/sachinggsingh/e-comm/internal/service"
 	proto "github.com/sachinggsingh/e-comm/pb"
+	"github.com/sachinggsingh/e-comm/pb/authz"
 	"google.golang.org/grpc"
 )
 
@@ -44,15 +46,17 @@ func (s *Server) StartServer() error {
 	return nil
 }
 
-func (s *Server) GrpcServer() error {
+func (s *Server) GrpcServer(productService *service.Productservice) error {
 	lis, err := net.Listen("tcp", ":9091")
 	if err != nil {
 		log.Fatalf(" gRPC failed to listen on :9091: %v", err)
 	}
 	grpcServer := grpc.NewServer()
-	productServer := grpc_handler.NewProductServer(s.db)
+	productServer := grpc_handler.NewProductServer(s.db, productService)
 	proto.RegisterGetProductsServer(grpcServer, productServer)
 	proto.RegisterShowProductServer(grpcServer, productServer)
+	proto.RegisterSearchProductsServer(grpcServer, productServer)
+	proto.RegisterInventoryServer(grpcServer, productServer)
 
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf(" gRPC server failed to serve: %v", err)
@@ -63,18 +67,65 @@ func (s *Server) GrpcServer() error {
 
 }
 
-func (s *Server) ProductRoutes(productService *service.Productservice) {
+// ProductRoutes registers the catalog routes. Writes, price history and
+// price schedules require the product:write permission, resolved through
+// authn.
+func (s *Server) ProductRoutes(productService *service.Productservice, authn authz.Authenticator) {
 	productHandler := restapi.NewProductHandler(productService)
+	requireWrite := authz.RequirePermission(authn, authz.PermProductWrite)
 
-	s.r.HandleFunc("/product", productHandler.CreateProduct).Methods("POST")
+	s.r.Handle("/product", requireWrite(http.HandlerFunc(productHandler.CreateProduct))).Methods("POST")
 	s.r.HandleFunc("/product", productHandler.GetAllProducts).Methods("GET")
+	s.r.HandleFunc("/product/search", productHandler.SearchProducts).Methods("GET")
 	s.r.HandleFunc("/product/{product_id}", productHandler.GetProductById).Methods("GET")
-	// http.HandleFunc("/updateproduct", func(w http.ResponseWriter, r *http.Request) {
-	// 	productHanlder := restapi.NewProductHandler(productService)
-	// 	productHanlder.UpdateProduct(w, r)
-	// })
-	// http.HandleFunc("/deleteproduct", func(w http.ResponseWriter, r *http.Request) {
-	// 	productHanlder := restapi.NewProductHandler(productService)
-	// 	productHanlder.DeleteProduct(w, r)
-	// })
+	s.r.Handle("/product/{product_id}", requireWrite(http.HandlerFunc(productHandler.UpdateProduct))).Methods("PUT", "PATCH")
+	s.r.Handle("/product/{product_id}", requireWrite(http.HandlerFunc(productHandler.DeleteProduct))).Methods("DELETE")
+	s.r.Handle("/product/{product_id}/restore", requireWrite(http.HandlerFunc(productHandler.RestoreProduct))).Methods("POST")
+	s.r.Handle("/product/{product_id}/stock", requireWrite(http.HandlerFunc(productHandler.AdjustStock))).Methods("POST")
+	s.r.Handle("/product/{product_id}/variants/{sku}", requireWrite(http.HandlerFunc(productHandler.UpsertVariant))).Methods("PUT")
+	s.r.Handle("/product/{product_id}/variants/{sku}", requireWrite(http.HandlerFunc(productHandler.DeleteVariant))).Methods("DELETE")
+	s.r.Handle("/product/{product_id}/prices/history", requireWrite(http.HandlerFunc(productHandler.GetPriceHistory))).Methods("GET")
+	s.r.Handle("/product/{product_id}/prices/schedules", requireWrite(http.HandlerFunc(productHandler.GetPriceSchedules))).Methods("GET")
+	s.r.Handle("/product/{product_id}/prices/schedules", requireWrite(http.HandlerFunc(productHandler.SchedulePrice))).Methods("POST")
+	s.r.Handle("/product/{product_id}/prices/schedules/{schedule_id}", requireWrite(http.HandlerFunc(productHandler.CancelPriceSchedule))).Methods("DELETE")
+}
+
+// CategoryRoutes registers the category tree routes. Changes require the
+// product:write permission.
+func (s *Server) CategoryRoutes(categoryService *service.CategoryService, productService *service.Productservice, authn authz.Authenticator) {
+	categoryHandler := restapi.NewCategoryHandler(categoryService, productService)
+	requireWrite := authz.RequirePermission(authn, authz.PermProductWrite)
+
+	s.r.HandleFunc("/categories", categoryHandler.GetTree).Methods("GET")
+	s.r.Handle("/categories", requireWrite(http.HandlerFunc(categoryHandler.CreateCategory))).Methods("POST")
+	s.r.HandleFunc("/categories/{category}", categoryHandler.GetCategory).Methods("GET")
+	s.r.Handle("/categories/{category}", requireWrite(http.HandlerFunc(categoryHandler.UpdateCategory))).Methods("PATCH")
+	s.r.Handle("/categories/{category}", requireWrite(http.HandlerFunc(categoryHandler.DeleteCategory))).Methods("DELETE")
+	s.r.HandleFunc("/categories/{category}/products", categoryHandler.ListProducts).Methods("GET")
+}
+
+// MediaRoutes registers the product image routes, which require the
+// product:write permission. A store that serves its own files, such as
+// media.LocalStore, is mounted at /media/.
+func (s *Server) MediaRoutes(mediaService *service.MediaService, store media.BlobStore, authn authz.Authenticator) {
+	mediaHandler := restapi.NewMediaHandler(mediaService)
+	requireWrite := authz.RequirePermission(authn, authz.PermProductWrite)
+
+	s.r.Handle("/product/{product_id}/images", requireWrite(http.HandlerFunc(mediaHandler.UploadImage))).Methods("POST")
+	s.r.Handle("/product/{product_id}/images/order", requireWrite(http.HandlerFunc(mediaHandler.ReorderImages))).Methods("PUT")
+	s.r.Handle("/product/{product_id}/images/{image_id}", requireWrite(http.HandlerFunc(mediaHandler.DeleteImage))).Methods("DELETE")
+	if files, ok := store.(http.Handler); ok {
+		s.r.PathPrefix("/media/").Handler(http.StripPrefix("/media", files)).Methods("GET", "HEAD")
+	}
+}
+
+// CatalogRoutes registers the bulk import and export routes, which require
+// the product:write permission
+func (s *Server) CatalogRoutes(catalogService *service.CatalogService, authn authz.Authenticator) {
+	catalogHandler := restapi.NewCatalogHandler(catalogService)
+	requireWrite := authz.RequirePermission(authn, authz.PermProductWrite)
+
+	s.r.Handle("/catalog/import", requireWrite(http.HandlerFunc(catalogHandler.ImportCatalog))).Methods("POST")
+	s.r.Handle("/catalog/import/{job_id}", requireWrite(http.HandlerFunc(catalogHandler.GetImport))).Methods("GET")
+	s.r.Handle("/catalog/export", requireWrite(http.HandlerFunc(catalogHandler.ExportCatalog))).Methods("GET")
 }
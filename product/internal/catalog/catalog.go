@@ -0,0 +1,379 @@
+// Package catalog reads and writes catalog files for bulk import and
+// export, one product per row, in CSV or JSON Lines.
+//
+// CSV files start with a header naming the columns, in any order: sku,
+// product_id, name, description, price, stock, category_ids, the latter
+// separated by "|", currency, the currency of the decimal price,
+// DefaultCurrency when missing, and for variant rows variant_of, the
+// product_id of their product, and attributes, option=value pairs separated
+// by "|". Only sku is required. A missing column leaves the field alone; an
+// empty price or stock cell does too. JSON Lines files hold one object with
+// the same fields per line, the price as a money object and the attributes
+// as an object.
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/pb/money"
+)
+
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+)
+
+// categorySeparator joins the category ids of a CSV cell and
+// attributeSeparator the option=value pairs
+const (
+	categorySeparator  = "|"
+	attributeSeparator = "|"
+)
+
+// maxLine bounds a JSON Lines row
+const maxLine = 1 << 20
+
+// Columns are the CSV columns in the order they are exported
+var Columns = []string{"sku", "product_id", "name", "description", "price", "stock", "category_ids", "currency", "variant_of", "attributes"}
+
+var ErrUnknownFormat = errors.New("format must be csv or jsonl")
+
+// RowError is a row that could not be read. The reader can go on with the
+// next row.
+type RowError struct {
+	Row int64
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ParseFormat accepts a format name or the content type of one
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(strings.Split(format, ";")[0])) {
+	case CSV, "text/csv":
+		return CSV, nil
+	case JSONL, "ndjson", "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return JSONL, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ContentType is the content type of a format
+func ContentType(format string) string {
+	if format == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Reader streams the rows of a catalog file
+type Reader struct {
+	next func() (int64, *model.CatalogRow, error)
+}
+
+// NewReader reads a file in format from r. For CSV it reads the header
+// right away and fails on unknown or duplicate columns or a missing sku.
+func NewReader(r io.Reader, format string) (*Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r)
+	case JSONL:
+		return newJSONLReader(r), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Next returns the next row and the line it starts on. It returns io.EOF
+// after the last row, a *RowError for a row it could not read and any
+// other error when reading cannot go on.
+func (r *Reader) Next() (int64, *model.CatalogRow, error) {
+	return r.next()
+}
+
+func newCSVReader(r io.Reader) (*Reader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		// spreadsheet programs start UTF-8 files with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, dup := index[name]; dup {
+			return nil, fmt.Errorf("column %s appears twice", name)
+		}
+		known := false
+		for _, column := range Columns {
+			known = known || column == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column %q, use %s", name, strings.Join(Columns, ", "))
+		}
+		index[name] = i
+	}
+	if _, ok := index["sku"]; !ok {
+		return nil, errors.New("the sku column is required")
+	}
+
+	next := func() (int64, *model.CatalogRow, error) {
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				return 0, nil, io.EOF
+			}
+			if err != nil {
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					return int64(parseErr.StartLine), nil, &RowError{Row: int64(parseErr.StartLine), Err: parseErr.Err}
+				}
+				return 0, nil, err
+			}
+			line, _ := cr.FieldPos(0)
+			if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+				continue
+			}
+			row, err := csvRow(record, index)
+			if err != nil {
+				return int64(line), row, &RowError{Row: int64(line), Err: err}
+			}
+			return int64(line), row, nil
+		}
+	}
+	return &Reader{next: next}, nil
+}
+
+func csvRow(record []string, index map[string]int) (*model.CatalogRow, error) {
+	cell := func(name string) (string, bool) {
+		i, ok := index[name]
+		if !ok || i >= len(record) {
+			return "", false
+		}
+		return strings.TrimSpace(record[i]), true
+	}
+
+	row := &model.CatalogRow{}
+	row.Sku, _ = cell("sku")
+	row.Product_id, _ = cell("product_id")
+	row.Variant_of, _ = cell("variant_of")
+	// Variant rows leave the product columns empty
+	variant := row.Variant_of != ""
+	if name, ok := cell("name"); ok && !(variant && name == "") {
+		row.Name = &name
+	}
+	if description, ok := cell("description"); ok && !(variant && description == "") {
+		row.Description = &description
+	}
+	if value, ok := cell("price"); ok && value != "" {
+		currency, ok := cell("currency")
+		if !ok || currency == "" {
+			currency = money.DefaultCurrency
+		}
+		price, err := money.Parse(value, currency)
+		if err != nil {
+			return row, fmt.Errorf("price %q: %w", value, err)
+		}
+		row.Price = &price
+	}
+	if value, ok := cell("stock"); ok && value != "" {
+		stock, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return row, fmt.Errorf("stock %q is not a whole number", value)
+		}
+		row.Stock = &stock
+	}
+	if value, ok := cell("attributes"); ok && value != "" {
+		attributes := map[string]string{}
+		for _, pair := range strings.Split(value, attributeSeparator) {
+			name, value, ok := strings.Cut(pair, "=")
+			name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+			if !ok || name == "" {
+				return row, fmt.Errorf("attributes %q must be option=value pairs", pair)
+			}
+			attributes[name] = value
+		}
+		row.Attributes = &attributes
+	}
+	if value, ok := cell("category_ids"); ok && !(variant && value == "") {
+		ids := []string{}
+		for _, id := range strings.Split(value, categorySeparator) {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		row.Category_ids = &ids
+	}
+	return row, nil
+}
+
+func newJSONLReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+	var line int64
+	next := func() (int64, *model.CatalogRow, error) {
+		for scanner.Scan() {
+			line++
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+			var row model.CatalogRow
+			if err := json.Unmarshal(text, &row); err != nil {
+				return line, nil, &RowError{Row: line, Err: fmt.Errorf("invalid JSON: %v", err)}
+			}
+			row.Sku = strings.TrimSpace(row.Sku)
+			row.Variant_of = strings.TrimSpace(row.Variant_of)
+			return line, &row, nil
+		}
+		if err := scanner.Err(); err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				return 0, nil, fmt.Errorf("line %d is longer than %d bytes", line+1, maxLine)
+			}
+			return 0, nil, err
+		}
+		return 0, nil, io.EOF
+	}
+	return &Reader{next: next}
+}
+
+// Writer streams products into a catalog file
+type Writer struct {
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+	wrote  bool
+}
+
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	switch format {
+	case CSV:
+		return &Writer{format: format, csv: csv.NewWriter(w)}, nil
+	case JSONL:
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		return &Writer{format: format, json: encoder}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Row converts a product to the row it is exported as
+func Row(product *model.Product) *model.CatalogRow {
+	stock := product.Stock
+	ids := product.Category_ids
+	if ids == nil {
+		ids = []string{}
+	}
+	return &model.CatalogRow{
+		Sku:          product.Sku,
+		Product_id:   product.Product_id,
+		Name:         product.Name,
+		Description:  product.Description,
+		Price:        product.Price,
+		Stock:        &stock,
+		Category_ids: &ids,
+	}
+}
+
+// VariantRow converts a variant of a product to the row it is exported as
+func VariantRow(product *model.Product, variant *model.Variant) *model.CatalogRow {
+	stock := variant.Stock
+	attributes := variant.Attributes
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+	return &model.CatalogRow{
+		Sku:        variant.Sku,
+		Price:      variant.Price,
+		Stock:      &stock,
+		Variant_of: product.Product_id,
+		Attributes: &attributes,
+	}
+}
+
+// Write adds a product, followed by its variants. CSV files get their
+// header before the first one.
+func (w *Writer) Write(product *model.Product) error {
+	if err := w.writeRow(Row(product)); err != nil {
+		return err
+	}
+	for i := range product.Variants {
+		if err := w.writeRow(VariantRow(product, &product.Variants[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) writeRow(row *model.CatalogRow) error {
+	if w.format == JSONL {
+		return w.json.Encode(row)
+	}
+
+	if !w.wrote {
+		w.wrote = true
+		if err := w.csv.Write(Columns); err != nil {
+			return err
+		}
+	}
+	record := []string{row.Sku, row.Product_id, "", "", "", strconv.FormatInt(*row.Stock, 10), "", "", row.Variant_of, ""}
+	if row.Name != nil {
+		record[2] = *row.Name
+	}
+	if row.Description != nil {
+		record[3] = *row.Description
+	}
+	if row.Price != nil {
+		record[4] = row.Price.Decimal()
+		record[7] = row.Price.Currency
+	}
+	if row.Category_ids != nil {
+		record[6] = strings.Join(*row.Category_ids, categorySeparator)
+	}
+	if row.Attributes != nil {
+		pairs := make([]string, 0, len(*row.Attributes))
+		for name, value := range *row.Attributes {
+			pairs = append(pairs, name+"="+value)
+		}
+		sort.Strings(pairs)
+		record[9] = strings.Join(pairs, attributeSeparator)
+	}
+	return w.csv.Write(record)
+}
+
+// Flush writes buffered rows out. An empty CSV export still gets its header.
+func (w *Writer) Flush() error {
+	if w.format != CSV {
+		return nil
+	}
+	if !w.wrote {
+		w.wrote = true
+		if err := w.csv.Write(Columns); err != nil {
+			return err
+		}
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
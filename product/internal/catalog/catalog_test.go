@@ -0,0 +1,105 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"testing"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/pb/money"
+)
+
+func TestExportReadsBackWithVariants(t *testing.T) {
+	name, description := "Tee", "Plain tee"
+	price := money.New(999, "USD")
+	override := money.New(1299, "USD")
+	product := &model.Product{
+		Product_id:   "p1",
+		Sku:          "TEE-001",
+		Name:         &name,
+		Description:  &description,
+		Price:        &price,
+		Category_ids: []string{"c1", "c2"},
+		Variants: []model.Variant{
+			{Sku: "TEE-001-M", Attributes: map[string]string{"size": "M", "color": "red"}, Stock: 25},
+			{Sku: "TEE-001-L", Attributes: map[string]string{"size": "L", "color": "red"}, Price: &override, Stock: 3},
+		},
+	}
+
+	for _, format := range []string{CSV, JSONL} {
+		t.Run(format, func(t *testing.T) {
+			var file bytes.Buffer
+			writer, err := NewWriter(&file, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Write(product); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if err := writer.Flush(); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			reader, err := NewReader(&file, format)
+			if err != nil {
+				t.Fatalf("NewReader: %v", err)
+			}
+			rows := []*model.CatalogRow{}
+			for {
+				_, row, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next: %v", err)
+				}
+				rows = append(rows, row)
+			}
+			if len(rows) != 3 {
+				t.Fatalf("read %d rows, want the product and its 2 variants", len(rows))
+			}
+
+			head := rows[0]
+			if head.Sku != "TEE-001" || head.Product_id != "p1" || head.Variant_of != "" || head.Attributes != nil {
+				t.Errorf("product row = %+v", head)
+			}
+			if head.Name == nil || *head.Name != name || head.Price == nil || *head.Price != price {
+				t.Errorf("product row name %v price %v, want %s %s", head.Name, head.Price, name, price)
+			}
+
+			for i, variant := range product.Variants {
+				row := rows[i+1]
+				if row.Sku != variant.Sku || row.Variant_of != "p1" {
+					t.Errorf("variant row %d = sku %s of %q, want %s of p1", i, row.Sku, row.Variant_of, variant.Sku)
+				}
+				if row.Name != nil || row.Description != nil || row.Category_ids != nil {
+					t.Errorf("variant row %s has product fields: %+v", row.Sku, row)
+				}
+				if row.Stock == nil || *row.Stock != variant.Stock {
+					t.Errorf("variant row %s stock = %v, want %d", row.Sku, row.Stock, variant.Stock)
+				}
+				if row.Attributes == nil || !maps.Equal(*row.Attributes, variant.Attributes) {
+					t.Errorf("variant row %s attributes = %v, want %v", row.Sku, row.Attributes, variant.Attributes)
+				}
+				if (row.Price == nil) != (variant.Price == nil) || (row.Price != nil && *row.Price != *variant.Price) {
+					t.Errorf("variant row %s price = %v, want %v", row.Sku, row.Price, variant.Price)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVRejectsMalformedAttributes(t *testing.T) {
+	file := "sku,variant_of,attributes\nTEE-001-M,p1,size\n"
+	reader, err := NewReader(bytes.NewBufferString(file), CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = reader.Next()
+	var rowErr *RowError
+	if err == nil || !errors.As(err, &rowErr) || rowErr.Row != 2 {
+		t.Fatalf("Next = %v, want a row error on row 2", err)
+	}
+}
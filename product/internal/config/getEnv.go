@@ -3,14 +3,27 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
 
 type Env struct {
-	MONGO_URL  string
-	PORT       string
-	APP_SECRET string
+	MONGO_URL        string
+	PORT             string
+	APP_SECRET       string
+	AUTH_SERVICE_URL string
+	// MEDIA_STORE is where product images are kept: local or s3
+	MEDIA_STORE    string
+	MEDIA_DIR      string
+	MEDIA_BASE_URL string
+	MEDIA_MAX_MB   int
+	S3_ENDPOINT    string
+	S3_REGION      string
+	S3_BUCKET      string
+	S3_ACCESS_KEY  string
+	S3_SECRET_KEY  string
+	S3_PUBLIC_URL  string
 }
 
 func GetEnv() *Env {
@@ -32,9 +45,44 @@ func GetEnv() *Env {
 	if app_sercert == "" {
 		log.Fatalf("APP_SERCET is not set")
 	}
+	authServiceURL := os.Getenv("AUTH_SERVICE_URL")
+	if authServiceURL == "" {
+		authServiceURL = "localhost:9090" // Default to auth service gRPC port
+	}
+	mediaStore := os.Getenv("MEDIA_STORE")
+	if mediaStore == "" {
+		mediaStore = "local"
+	}
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "./media"
+	}
+	mediaBaseURL := os.Getenv("MEDIA_BASE_URL")
+	if mediaBaseURL == "" {
+		mediaBaseURL = "http://localhost:" + port + "/media"
+	}
+	mediaMaxMB := 10
+	if value := os.Getenv("MEDIA_MAX_MB"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			log.Fatalf("MEDIA_MAX_MB must be a positive number")
+		}
+		mediaMaxMB = n
+	}
 	return &Env{
-		PORT:       port,
-		MONGO_URL:  mongoURL,
-		APP_SECRET: app_sercert,
+		PORT:             port,
+		MONGO_URL:        mongoURL,
+		APP_SECRET:       app_sercert,
+		AUTH_SERVICE_URL: authServiceURL,
+		MEDIA_STORE:      mediaStore,
+		MEDIA_DIR:        mediaDir,
+		MEDIA_BASE_URL:   mediaBaseURL,
+		MEDIA_MAX_MB:     mediaMaxMB,
+		S3_ENDPOINT:      os.Getenv("S3_ENDPOINT"),
+		S3_REGION:        os.Getenv("S3_REGION"),
+		S3_BUCKET:        os.Getenv("S3_BUCKET"),
+		S3_ACCESS_KEY:    os.Getenv("S3_ACCESS_KEY"),
+		S3_SECRET_KEY:    os.Getenv("S3_SECRET_KEY"),
+		S3_PUBLIC_URL:    os.Getenv("S3_PUBLIC_URL"),
 	}
 }
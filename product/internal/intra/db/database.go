@@ -2,18 +2,29 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/sachinggsingh/e-comm/internal/config"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ProductSkuIndex is the name of the unique index on the merchant SKU of
+// products, telling its duplicate key errors from those of variant SKUs
+const ProductSkuIndex = "product_sku"
+
 type Database struct {
-	Client            *mongo.Client
-	Database          *mongo.Database
-	ProductCollection *mongo.Collection
+	Client             *mongo.Client
+	Database           *mongo.Database
+	ProductCollection  *mongo.Collection
+	CategoryCollection *mongo.Collection
+	// ImportJobCollection tracks catalog imports
+	ImportJobCollection *mongo.Collection
+	// PriceHistoryCollection records every price change of products
+	PriceHistoryCollection *mongo.Collection
 }
 
 func NewDB() *Database {
@@ -41,11 +52,89 @@ func (d *Database) Connect() error {
 	d.Database = client.Database("micro-ecomm")
 
 	d.ProductCollection = d.Database.Collection("product")
+	d.CategoryCollection = d.Database.Collection("category")
+	d.ImportJobCollection = d.Database.Collection("import_jobs")
+	d.PriceHistoryCollection = d.Database.Collection("price_history")
+
+	if err := d.createIndexes(ctx); err != nil {
+		return err
+	}
 
 	log.Println("Connected to MongoDB!")
 	return nil
 }
 
+// createIndexes creates the indexes the product queries rely on. Creating an
+// existing index is a no-op. The listing sorts by one field and breaks ties
+// by _id, hence the compound indexes.
+func (d *Database) createIndexes(ctx context.Context) error {
+	_, err := d.ProductCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "product_id", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "price.amount", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "price.currency", Value: 1}, {Key: "price.amount", Value: 1}}},
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "category_ids", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		// Search: whole words through the text index, prefixes and typos
+		// through the search keys
+		{
+			Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().SetName("product_text").
+				SetWeights(bson.D{{Key: "name", Value: 3}, {Key: "description", Value: 1}}),
+		},
+		{Keys: bson.D{{Key: "search_keys", Value: 1}}},
+		// SKUs are unique across products
+		{
+			Keys: bson.D{{Key: "variants.sku", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"variants.sku": bson.M{"$exists": true}}),
+		},
+		// Merchant SKUs of products are unique too, products without one
+		// have none or an empty one
+		{
+			Keys: bson.D{{Key: "sku", Value: 1}},
+			Options: options.Index().SetName(ProductSkuIndex).SetUnique(true).
+				SetPartialFilterExpression(bson.M{"sku": bson.M{"$gt": ""}}),
+		},
+		// Stock reservations, looked up by id and swept once expired
+		{Keys: bson.D{{Key: "reservations.reservation_id", Value: 1}}},
+		{Keys: bson.D{{Key: "reservations.expires_at", Value: 1}}},
+		{Keys: bson.D{{Key: "committed_reservations", Value: 1}}},
+		// Price schedules, swept once they start or end
+		{Keys: bson.D{{Key: "price_schedules.starts_at", Value: 1}}},
+		{Keys: bson.D{{Key: "price_schedules.ends_at", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create product indexes: %w", err)
+	}
+
+	_, err = d.CategoryCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "category_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "slug", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "ancestors", Value: 1}}},
+		{Keys: bson.D{{Key: "parent_id", Value: 1}, {Key: "position", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create category indexes: %w", err)
+	}
+
+	_, err = d.ImportJobCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "job_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create import job indexes: %w", err)
+	}
+
+	_, err = d.PriceHistoryCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "sku", Value: 1}, {Key: "changed_at", Value: -1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create price history indexes: %w", err)
+	}
+	return nil
+}
+
 func (d *Database) Disconnect() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -0,0 +1,204 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// maxPixels bounds the decoded size of an upload, a small file can
+	// claim huge dimensions
+	maxPixels = 24_000_000
+	// jpegQuality is used for thumbnails of JPEG images
+	jpegQuality = 85
+)
+
+var (
+	ErrUnsupportedType = errors.New("image must be a JPEG, PNG or GIF")
+	ErrInvalidImage    = errors.New("invalid image")
+)
+
+// Size is a thumbnail size: the image scaled down to fit in Max x Max
+type Size struct {
+	Name string
+	Max  int
+}
+
+// ThumbnailSizes are rendered for every image, largest first
+var ThumbnailSizes = []Size{
+	{Name: "large", Max: 1024},
+	{Name: "medium", Max: 480},
+	{Name: "small", Max: 160},
+}
+
+// Rendition is an encoded thumbnail
+type Rendition struct {
+	Name        string
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+// Image is a validated upload with its thumbnails
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	Thumbnails  []Rendition
+}
+
+// Extension returns the file extension for a content type
+func Extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	}
+	return ""
+}
+
+// Process checks that data is an image of a supported type, judged by its
+// content rather than the name or type the client sent, and renders its
+// thumbnails. PNG and GIF thumbnails are PNGs to keep transparency, JPEG
+// ones JPEGs. Only the first frame of an animated GIF is used.
+func Process(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	if Extension(contentType) == "" {
+		return nil, ErrUnsupportedType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d is larger than %d megapixels", ErrInvalidImage, config.Width, config.Height, maxPixels/1_000_000)
+	}
+
+	var src image.Image
+	switch contentType {
+	case "image/jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		src, err = gif.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	img := &Image{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+	}
+	// Every size is scaled from the one before, which is cheaper than
+	// scaling the full image each time and just as sharp with a box filter
+	current := toRGBA(src)
+	for _, size := range ThumbnailSizes {
+		current = fit(current, size.Max)
+		rendition, err := encode(current, contentType == "image/jpeg")
+		if err != nil {
+			return nil, err
+		}
+		rendition.Name = size.Name
+		img.Thumbnails = append(img.Thumbnails, *rendition)
+	}
+	return img, nil
+}
+
+func encode(img *image.RGBA, asJPEG bool) (*Rendition, error) {
+	var buf bytes.Buffer
+	rendition := &Rendition{Width: img.Rect.Dx(), Height: img.Rect.Dy()}
+	if asJPEG {
+		rendition.ContentType = "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+	} else {
+		rendition.ContentType = "image/png"
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	}
+	rendition.Data = buf.Bytes()
+	return rendition, nil
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Rect, src, b.Min, draw.Src)
+	return dst
+}
+
+// fit scales src down to fit in limit x limit keeping its aspect ratio.
+// Images that already fit are returned as they are, never scaled up.
+func fit(src *image.RGBA, limit int) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if w <= limit && h <= limit {
+		return src
+	}
+	dw, dh := limit, limit
+	if w > h {
+		dh = h * limit / w
+	} else {
+		dw = w * limit / h
+	}
+	return scale(src, max1(dw), max1(dh))
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// scale shrinks src to dw x dh, every pixel the average of the source
+// pixels it covers. RGBA is alpha premultiplied, so averaging it directly
+// does not bleed the colour of transparent pixels.
+func scale(src *image.RGBA, dw, dh int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					sum[0] += int(p[0])
+					sum[1] += int(p[1])
+					sum[2] += int(p[2])
+					sum[3] += int(p[3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4]
+			for i := range d {
+				d[i] = uint8((sum[i] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,104 @@
+// Package media stores product images and renders their thumbnails.
+//
+// Images are kept in a BlobStore under keys the service chooses and served
+// from the URL the store gives for a key: the product service itself for
+// LocalStore, the bucket or a CDN in front of it for S3Store.
+package media
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore keeps blobs under keys such as "products/<id>/<image>/small.jpg"
+type BlobStore interface {
+	Put(ctx context.Context, key string, contentType string, data []byte) error
+	// Delete removes the blob; deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the blob from
+	URL(key string) string
+}
+
+// LocalStore keeps blobs as files below a directory. It serves them itself,
+// mount it at the path of its base URL.
+type LocalStore struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocalStore(dir string, baseURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// path maps a key to its file, keys cannot point outside the directory
+func (l *LocalStore) path(key string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (l *LocalStore) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	file := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	// Write next to the file and rename so readers never see half a file
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (l *LocalStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Drop the directories the image leaves empty, Remove fails on the others
+	for dir := filepath.Dir(l.path(key)); dir != filepath.Clean(l.dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (l *LocalStore) URL(key string) string {
+	return l.baseURL + "/" + key
+}
+
+// ServeHTTP serves the blob named by the request path, which must have the
+// prefix of the base URL stripped. Directories are not listed.
+func (l *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file := l.path(r.URL.Path)
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	// Keys are never reused, a new upload gets a new key
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, file)
+}
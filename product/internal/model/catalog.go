@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+
+	"github.com/sachinggsingh/e-comm/pb/money"
+)
+
+// Import job states
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// CatalogRow is one product of a catalog import or export, matched to the
+// catalog by Sku. Unset fields are left as they are on existing products.
+// A row with Variant_of is a variant of that product, with its Attributes,
+// Stock and optionally its own Price.
+type CatalogRow struct {
+	Sku          string             `json:"sku"`
+	Product_id   string             `json:"product_id,omitempty"`
+	Name         *string            `json:"name,omitempty"`
+	Description  *string            `json:"description,omitempty"`
+	Price        *money.Money       `json:"price,omitempty"`
+	Stock        *int64             `json:"stock"`
+	Category_ids *[]string          `json:"category_ids,omitempty"`
+	Variant_of   string             `json:"variant_of,omitempty"`
+	Attributes   *map[string]string `json:"attributes,omitempty"`
+}
+
+// ImportRowError explains why a row of an import was skipped. Row is the
+// line of the file it starts on.
+type ImportRowError struct {
+	Row     int64  `json:"row"`
+	Sku     string `json:"sku,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportJob tracks a catalog import running in the background
+type ImportJob struct {
+	Job_id     string `json:"job_id"`
+	Format     string `json:"format"`
+	Status     string `json:"status"`
+	Created_by string `json:"created_by,omitempty"`
+	// Bytes_read of Bytes_total show how far the file is processed
+	Bytes_total int64 `json:"bytes_total"`
+	Bytes_read  int64 `json:"bytes_read"`
+	// Rows counts the rows processed so far, split into the outcomes
+	Rows      int64 `json:"rows"`
+	Created   int64 `json:"created"`
+	Updated   int64 `json:"updated"`
+	Unchanged int64 `json:"unchanged"`
+	Failed    int64 `json:"failed"`
+	// Errors lists the first failed rows, Failed counts all of them
+	Errors []ImportRowError `json:"errors"`
+	// Error is why the whole import stopped, for a failed job
+	Error       string     `json:"error,omitempty"`
+	Created_at  time.Time  `json:"created_at"`
+	Started_at  *time.Time `json:"started_at,omitempty"`
+	Finished_at *time.Time `json:"finished_at,omitempty"`
+}
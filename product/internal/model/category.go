@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Category is a node of the category tree. Products are assigned to any
+// number of categories through their Category_ids.
+type Category struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Category_id string             `json:"category_id"`
+	Name        string             `json:"name"`
+	// Slug names the category in URLs, unique across the tree
+	Slug string `json:"slug"`
+	// Parent_id is empty for a top level category
+	Parent_id string `json:"parent_id,omitempty"`
+	// Ancestors are the ids of the categories above, from the top down, so
+	// a subtree is found by a single query
+	Ancestors []string `json:"ancestors"`
+	// Position orders the category among its siblings
+	Position   int       `json:"position"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+}
+
+// CategoryRequest creates a category or changes the fields that are set. An
+// empty Parent_id moves the category to the top level.
+type CategoryRequest struct {
+	Name      *string `json:"name"`
+	Slug      *string `json:"slug"`
+	Parent_id *string `json:"parent_id"`
+	Position  *int    `json:"position"`
+}
+
+// CategoryNode is a category with its subtree
+type CategoryNode struct {
+	*Category
+	Children []*CategoryNode `json:"children"`
+}
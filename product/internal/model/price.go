@@ -0,0 +1,176 @@
+package model
+
+import (
+	"time"
+
+	"github.com/sachinggsingh/e-comm/pb/money"
+)
+
+// Reasons of a price change
+const (
+	// PriceSet is a price set by hand, on create, update or import
+	PriceSet = "set"
+	// PriceScheduled is a scheduled list price change taking effect
+	PriceScheduled = "scheduled"
+	// SaleStarted and SaleEnded bound the window of a sale price
+	SaleStarted = "sale_started"
+	SaleEnded   = "sale_ended"
+)
+
+// PriceSchedule changes the price of the product, or of its variant Sku, at
+// Starts_at. Without Ends_at the list price changes for good; with it the
+// price is a sale price until Ends_at and the list price stays.
+type PriceSchedule struct {
+	Schedule_id string      `json:"schedule_id"`
+	Sku         string      `json:"sku,omitempty" bson:"sku,omitempty"`
+	Price       money.Money `json:"price"`
+	Starts_at   time.Time   `json:"starts_at"`
+	Ends_at     *time.Time  `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	// Started is set once the start of a sale is recorded in the history
+	Started    bool      `json:"started"`
+	Created_by string    `json:"created_by,omitempty"`
+	Created_at time.Time `json:"created_at"`
+}
+
+// PriceScheduleRequest schedules a price change, see PriceSchedule
+type PriceScheduleRequest struct {
+	Sku       string       `json:"sku"`
+	Price     *money.Money `json:"price"`
+	Starts_at time.Time    `json:"starts_at"`
+	Ends_at   *time.Time   `json:"ends_at"`
+}
+
+// IsSale reports whether the schedule is a sale window
+func (s *PriceSchedule) IsSale() bool {
+	return s.Ends_at != nil
+}
+
+// Active reports whether the sale runs at t; the end is exclusive
+func (s *PriceSchedule) Active(t time.Time) bool {
+	return s.IsSale() && !s.Starts_at.After(t) && s.Ends_at.After(t)
+}
+
+// PriceChange is an entry of the price history of a product, or of its
+// variant Sku: the prices from Changed_at on
+type PriceChange struct {
+	Change_id  string `json:"change_id"`
+	Product_id string `json:"product_id"`
+	Sku        string `json:"sku,omitempty" bson:"sku"`
+	// List_price is the regular price, Effective_price the one charged,
+	// which is lower during a sale
+	List_price      money.Money `json:"list_price"`
+	Effective_price money.Money `json:"effective_price"`
+	Reason          string      `json:"reason"`
+	Schedule_id     string      `json:"schedule_id,omitempty" bson:"schedule_id,omitempty"`
+	// Actor is the user who made the change, "scheduler" for scheduled ones
+	Actor      string    `json:"actor"`
+	Changed_at time.Time `json:"changed_at"`
+}
+
+// PriceHistoryQuery selects price changes of a product, newest first
+type PriceHistoryQuery struct {
+	Product_id string
+	// Sku selects the changes of a variant price, empty the product price
+	Sku string
+	// From and To bound Changed_at, inclusive
+	From  *time.Time
+	To    *time.Time
+	Limit int
+}
+
+// Prices returns the list price and the price charged at t of the product,
+// or of its variant sku. Scheduled changes due at t count before the
+// scheduler applied them. A variant without a price of its own has the
+// prices of the product, including its sales.
+func (p *Product) Prices(sku string, t time.Time) (money.Money, money.Money) {
+	list := money.Zero(p.Currency())
+	if p.Price != nil {
+		list = *p.Price
+	}
+	list, _ = p.scheduledPrice("", list, t)
+	effective := list
+	if sale := p.activeSale("", t); sale != nil {
+		effective = sale.Price
+	}
+	if sku == "" {
+		return list, effective
+	}
+
+	variant := p.FindVariant(sku)
+	if variant == nil {
+		return list, effective
+	}
+	own := variant.Price != nil
+	if own {
+		list = *variant.Price
+	}
+	list, scheduled := p.scheduledPrice(sku, list, t)
+	if own || scheduled {
+		effective = list
+	}
+	if sale := p.activeSale(sku, t); sale != nil {
+		effective = sale.Price
+	}
+	return list, effective
+}
+
+// scheduledPrice returns the price of the last list price change of sku due
+// at t, or price when none is
+func (p *Product) scheduledPrice(sku string, price money.Money, t time.Time) (money.Money, bool) {
+	var last *PriceSchedule
+	for i := range p.Price_schedules {
+		s := &p.Price_schedules[i]
+		if s.Sku != sku || s.IsSale() || s.Starts_at.After(t) {
+			continue
+		}
+		if last == nil || s.Starts_at.After(last.Starts_at) {
+			last = s
+		}
+	}
+	if last == nil {
+		return price, false
+	}
+	return last.Price, true
+}
+
+// activeSale returns the sale of sku running at t, nil if there is none
+func (p *Product) activeSale(sku string, t time.Time) *PriceSchedule {
+	for i := range p.Price_schedules {
+		if s := &p.Price_schedules[i]; s.Sku == sku && s.Active(t) {
+			return s
+		}
+	}
+	return nil
+}
+
+// Currency is the currency the product is priced in
+func (p *Product) Currency() string {
+	if p.Price == nil || p.Price.Currency == "" {
+		return money.DefaultCurrency
+	}
+	return p.Price.Currency
+}
+
+// PricedInCurrency tells whether variants or schedules carry prices in the
+// currency of the product, which then cannot change
+func (p *Product) PricedInCurrency() bool {
+	if len(p.Price_schedules) > 0 {
+		return true
+	}
+	for _, variant := range p.Variants {
+		if variant.Price != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SetEffectivePrices fills in the prices charged at t for display
+func (p *Product) SetEffectivePrices(t time.Time) {
+	_, effective := p.Prices("", t)
+	p.Effective_price = &effective
+	for i := range p.Variants {
+		_, effective := p.Prices(p.Variants[i].Sku, t)
+		p.Variants[i].Effective_price = &effective
+	}
+}
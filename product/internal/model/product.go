@@ -3,6 +3,7 @@ package model
 import (
 	"time"
 
+	"github.com/sachinggsingh/e-comm/pb/money"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -10,8 +11,267 @@ type Product struct {
 	ID          primitive.ObjectID `bson:"_id"`
 	Name        *string            `json:"name" validate:"required"`
 	Description *string            `json:"description" validate:"required"`
-	Price       *float64           `json:"price" validate:"required"`
-	Created_at  time.Time          `json:"created_at"`
-	Updated_at  time.Time          `json:"updated_at"`
-	Product_id  string             `json:"product_id"`
+	// Price is the list price; sales lower the price charged, see Prices.
+	// Variants and schedules of the product are priced in its currency.
+	Price *money.Money `json:"price" validate:"required"`
+	// Effective_price is the price charged right now, filled in on reads
+	Effective_price *money.Money `json:"effective_price,omitempty" bson:"-"`
+	// Price_list are fixed prices in other currencies, one per currency.
+	// Buyers paying in one of them are charged it, sales included, instead
+	// of the converted price. Variants without a price of their own share it.
+	Price_list []money.Money `json:"price_list,omitempty" bson:"price_list,omitempty"`
+	// Stock is the quantity available to order; reserved stock is already
+	// taken off it
+	Stock      int64     `json:"stock"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+	Product_id string    `json:"product_id"`
+	// Sku is the merchant's own identifier, unique across products. Imports
+	// match products by it.
+	Sku string `json:"sku,omitempty" bson:"sku,omitempty"`
+	// Category_ids are the categories the product is listed in
+	Category_ids []string `json:"category_ids,omitempty"`
+	// Options define the attributes variants differ in, e.g. size and colour
+	Options []ProductOption `json:"options,omitempty"`
+	// Variants are the sellable versions of a product with options. Each
+	// has its own stock; the product stock is not used.
+	Variants []Variant `json:"variants,omitempty" bson:"variants,omitempty"`
+	// Images are shown in this order, the first is the main image
+	Images []ProductImage `json:"images,omitempty" bson:"images,omitempty"`
+	// Search_keys are derived from the name for prefix and typo tolerant
+	// search, see package search
+	Search_keys []string `json:"-"`
+	// Version is incremented by every change and sent as the ETag, so
+	// concurrent updates cannot overwrite each other unnoticed
+	Version int64 `json:"version"`
+	// Deleted_at is set on soft deleted products, which are hidden until
+	// restored
+	Deleted_at *time.Time `json:"deleted_at,omitempty"`
+	// Reservations hold stock for orders that are not paid yet. Omitted
+	// when empty, $push cannot add to a null field.
+	Reservations []StockReservation `json:"-" bson:"reservations,omitempty"`
+	// Committed_reservations are the ids of the latest reservations
+	// committed on the product, so committing one again is not mistaken for
+	// an expired one
+	Committed_reservations []string `json:"-" bson:"committed_reservations,omitempty"`
+	// Price_schedules are the price changes and sales to come or running.
+	// They are not shown with the product, sales may not be public yet.
+	Price_schedules []PriceSchedule `json:"-" bson:"price_schedules,omitempty"`
+}
+
+// ProductOption is an attribute variants differ in and the values it takes
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Variant is a version of a product identified by its SKU
+type Variant struct {
+	Sku string `json:"sku"`
+	// Attributes hold a value for every option of the product
+	Attributes map[string]string `json:"attributes"`
+	// Price overrides the product price when set
+	Price *money.Money `json:"price,omitempty"`
+	// Effective_price is the price charged right now, filled in on reads
+	Effective_price *money.Money `json:"effective_price,omitempty" bson:"-"`
+	Stock           int64        `json:"stock"`
+}
+
+// ProductImage is an uploaded image of a product and its thumbnails. The
+// keys locate the files in the blob store.
+type ProductImage struct {
+	Image_id     string      `json:"image_id"`
+	Url          string      `json:"url"`
+	Key          string      `json:"-"`
+	Content_type string      `json:"content_type"`
+	Width        int         `json:"width"`
+	Height       int         `json:"height"`
+	Size         int64       `json:"size"`
+	Thumbnails   []Thumbnail `json:"thumbnails"`
+	Created_at   time.Time   `json:"created_at"`
+}
+
+// Thumbnail is an image scaled down to fit a named size
+type Thumbnail struct {
+	Size   string `json:"size"`
+	Url    string `json:"url"`
+	Key    string `json:"-"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ImageOrder lists the ids of all images of a product in their new order
+type ImageOrder struct {
+	Image_ids []string `json:"image_ids"`
+}
+
+// FindImage returns the image with the id, nil if there is none
+func (p *Product) FindImage(imageID string) *ProductImage {
+	for i := range p.Images {
+		if p.Images[i].Image_id == imageID {
+			return &p.Images[i]
+		}
+	}
+	return nil
+}
+
+// Thumbnail returns the url of the named thumbnail of the main image, empty
+// when the product has no image
+func (p *Product) Thumbnail(size string) string {
+	if len(p.Images) == 0 {
+		return ""
+	}
+	for _, thumbnail := range p.Images[0].Thumbnails {
+		if thumbnail.Size == size {
+			return thumbnail.Url
+		}
+	}
+	return p.Images[0].Url
+}
+
+// VariantRequest creates or changes the variant of a SKU. Stock only sets
+// the stock of a new variant; change it later with a stock adjustment.
+type VariantRequest struct {
+	Attributes map[string]string `json:"attributes"`
+	Price      *money.Money      `json:"price"`
+	Stock      int64             `json:"stock"`
+}
+
+// FindVariant returns the variant with the SKU, nil if there is none
+func (p *Product) FindVariant(sku string) *Variant {
+	for i := range p.Variants {
+		if p.Variants[i].Sku == sku {
+			return &p.Variants[i]
+		}
+	}
+	return nil
+}
+
+// InStock reports whether the product, or any of its variants, can be ordered
+func (p *Product) InStock() bool {
+	if p.Stock > 0 {
+		return true
+	}
+	for _, variant := range p.Variants {
+		if variant.Stock > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// StockReservation is stock of a product, or of its variant Sku, held for a
+// pending order
+type StockReservation struct {
+	Reservation_id string    `json:"reservation_id"`
+	Sku            string    `json:"sku,omitempty"`
+	Quantity       int64     `json:"quantity"`
+	Expires_at     time.Time `json:"expires_at"`
+}
+
+// StockItem is a quantity of a product, or of its variant Sku, to reserve
+type StockItem struct {
+	Product_id string `json:"product_id"`
+	Sku        string `json:"sku,omitempty"`
+	Quantity   int64  `json:"quantity"`
+}
+
+// StockAdjustment changes the stock of a product, or of its variant Sku, by
+// Delta, negative to take stock away
+type StockAdjustment struct {
+	Sku   string `json:"sku"`
+	Delta int64  `json:"delta"`
+}
+
+// UpdateProductRequest changes the fields that are set. PUT requires all of
+// them, PATCH any.
+type UpdateProductRequest struct {
+	Name        *string      `json:"name"`
+	Description *string      `json:"description"`
+	Price       *money.Money `json:"price"`
+	// Price_list replaces the fixed prices in other currencies when set
+	Price_list *[]money.Money `json:"price_list"`
+	// Category_ids replaces the categories of the product when set
+	Category_ids *[]string `json:"category_ids"`
+	// Options replaces the options when set; the variants must fit them
+	Options *[]ProductOption `json:"options"`
+	// Sku sets the merchant SKU, an empty one removes it
+	Sku *string `json:"sku"`
+	// Search_keys is set by the service when the name changes
+	Search_keys []string `json:"-"`
+}
+
+// ProductQuery selects one page of the product listing
+type ProductQuery struct {
+	// Limit is the page size
+	Limit int
+	// Sort is price, name or created_at; Desc reverses it
+	Sort string
+	Desc bool
+	// Min_price and Max_price bound the price, inclusive, and select the
+	// products priced in their currency
+	Min_price *money.Money
+	Max_price *money.Money
+	// Category is the id or slug of a category; the service resolves it to
+	// Category_ids, the category and its descendants
+	Category     string
+	Category_ids []string
+	// Cursor is the Next_cursor of the previous page, empty for the first
+	Cursor string
+	// Include_total counts all products matching the filters
+	Include_total bool
+}
+
+// ProductPage is one page of the product listing. Next_cursor is empty on
+// the last page.
+type ProductPage struct {
+	Items       []*Product `json:"items"`
+	Next_cursor string     `json:"next_cursor,omitempty"`
+	Total       *int64     `json:"total,omitempty"`
+}
+
+// SearchQuery is a full-text search of the catalog with optional filters
+type SearchQuery struct {
+	Query  string
+	Limit  int
+	Offset int
+	// Category and Category_ids are as in ProductQuery
+	Category     string
+	Category_ids []string
+	Min_price    *money.Money
+	Max_price    *money.Money
+	In_stock     bool
+}
+
+// SearchHit is a product found by a search. The highlights are HTML with
+// the matched words wrapped in <em>.
+type SearchHit struct {
+	*Product
+	Score                 float64 `json:"score"`
+	Name_highlight        string  `json:"name_highlight"`
+	Description_highlight string  `json:"description_highlight"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// SearchFacets count the matches of a search by category, price range and
+// availability
+type SearchFacets struct {
+	Categories    []FacetCount `json:"categories"`
+	Price_buckets []FacetCount `json:"price_buckets"`
+	In_stock      int64        `json:"in_stock"`
+	Out_of_stock  int64        `json:"out_of_stock"`
+}
+
+// SearchResult is one page of search hits. Total and Facets count every
+// match when Total_exact is set; otherwise the search hit its candidate
+// bound, Total is a lower bound and the facets cover the candidates only.
+type SearchResult struct {
+	Hits        []*SearchHit `json:"hits"`
+	Total       int64        `json:"total"`
+	Total_exact bool         `json:"total_exact"`
+	Facets      SearchFacets `json:"facets"`
 }
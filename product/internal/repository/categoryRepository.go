@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CategoryRepository interface {
+	// CreateCategory returns a mongo duplicate key error when the slug is taken
+	CreateCategory(c *model.Category) (*model.Category, error)
+	// FindCategory finds a category by id or slug
+	FindCategory(idOrSlug string) (*model.Category, error)
+	// FindCategories returns the categories of ids that exist
+	FindCategories(ids []string) ([]*model.Category, error)
+	// AllCategories returns every category in sibling order
+	AllCategories() ([]*model.Category, error)
+	// Descendants returns the categories below id at any depth
+	Descendants(id string) ([]*model.Category, error)
+	CountChildren(id string) (int64, error)
+	// UpdateCategory sets fields and returns the updated category, a mongo
+	// duplicate key error when the slug is taken
+	UpdateCategory(id string, fields bson.M) (*model.Category, error)
+	DeleteCategory(id string) error
+}
+
+type categoryRepo struct {
+	categoryColl *mongo.Collection
+}
+
+func NewCategoryRepository(database *db.Database) CategoryRepository {
+	return &categoryRepo{
+		categoryColl: database.CategoryCollection,
+	}
+}
+
+// siblingOrder sorts categories by position, then name
+var siblingOrder = bson.D{{Key: "position", Value: 1}, {Key: "name", Value: 1}}
+
+func (c *categoryRepo) CreateCategory(category *model.Category) (*model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if _, err := c.categoryColl.InsertOne(ctx, category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (c *categoryRepo) FindCategory(idOrSlug string) (*model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"category_id": idOrSlug},
+		bson.M{"slug": idOrSlug},
+	}}
+	var category model.Category
+	if err := c.categoryColl.FindOne(ctx, filter).Decode(&category); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (c *categoryRepo) FindCategories(ids []string) ([]*model.Category, error) {
+	return c.find(bson.M{"category_id": bson.M{"$in": ids}})
+}
+
+func (c *categoryRepo) AllCategories() ([]*model.Category, error) {
+	return c.find(bson.M{})
+}
+
+func (c *categoryRepo) Descendants(id string) ([]*model.Category, error) {
+	return c.find(bson.M{"ancestors": id})
+}
+
+func (c *categoryRepo) find(filter bson.M) ([]*model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := c.categoryColl.Find(ctx, filter, options.Find().SetSort(siblingOrder))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	categories := []*model.Category{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (c *categoryRepo) CountChildren(id string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return c.categoryColl.CountDocuments(ctx, bson.M{"parent_id": id})
+}
+
+func (c *categoryRepo) UpdateCategory(id string, fields bson.M) (*model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	fields["updated_at"] = time.Now()
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var category model.Category
+	err := c.categoryColl.FindOneAndUpdate(ctx, bson.M{"category_id": id}, bson.M{"$set": fields}, opts).Decode(&category)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (c *categoryRepo) DeleteCategory(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	res, err := c.categoryColl.DeleteOne(ctx, bson.M{"category_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (p *productRepo) AddImage(id string, image model.ProductImage, position int, maxImages int) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := NotDeleted()
+	filter["product_id"] = id
+	// Only while the product has room for one more
+	filter["images."+strconv.Itoa(maxImages-1)] = bson.M{"$exists": false}
+	push := bson.M{"$each": bson.A{image}}
+	if position >= 0 {
+		push["$position"] = position
+	}
+	update := bson.M{
+		"$push": bson.M{"images": push},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var product model.Product
+	if err := p.productColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (p *productRepo) RemoveImage(id string, imageID string) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := NotDeleted()
+	filter["product_id"] = id
+	filter["images.image_id"] = imageID
+	update := bson.M{
+		"$pull": bson.M{"images": bson.M{"image_id": imageID}},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var product model.Product
+	if err := p.productColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (p *productRepo) SetImages(id string, version int64, images []model.ProductImage) (*model.Product, error) {
+	filter := NotDeleted()
+	filter["product_id"] = id
+	return p.apply(filter, version, bson.M{"images": images})
+}
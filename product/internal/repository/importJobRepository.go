@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ImportJobRepository interface {
+	CreateJob(job *model.ImportJob) error
+	// SaveJob stores the progress of the job
+	SaveJob(job *model.ImportJob) error
+	FindJob(id string) (*model.ImportJob, error)
+	// FailUnfinishedJobs marks the jobs that were queued or running as
+	// failed with reason and returns how many there were
+	FailUnfinishedJobs(reason string) (int64, error)
+}
+
+type importJobRepo struct {
+	jobColl *mongo.Collection
+}
+
+func NewImportJobRepository(database *db.Database) ImportJobRepository {
+	return &importJobRepo{
+		jobColl: database.ImportJobCollection,
+	}
+}
+
+func (i *importJobRepo) CreateJob(job *model.ImportJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	_, err := i.jobColl.InsertOne(ctx, job)
+	return err
+}
+
+func (i *importJobRepo) SaveJob(job *model.ImportJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	_, err := i.jobColl.ReplaceOne(ctx, bson.M{"job_id": job.Job_id}, job)
+	return err
+}
+
+func (i *importJobRepo) FindJob(id string) (*model.ImportJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var job model.ImportJob
+	if err := i.jobColl.FindOne(ctx, bson.M{"job_id": id}).Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (i *importJobRepo) FailUnfinishedJobs(reason string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{"status": bson.M{"$in": bson.A{model.ImportQueued, model.ImportRunning}}}
+	update := bson.M{"$set": bson.M{
+		"status":      model.ImportFailed,
+		"error":       reason,
+		"finished_at": time.Now(),
+	}}
+	res, err := i.jobColl.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Reservations live on the product they hold stock of, so taking or giving
+// back stock and recording it is one atomic update of one document
+
+// stockField is the field holding the stock of the product, or of its
+// variant sku, with the array filter selecting the variant
+func stockField(sku string) (string, *options.ArrayFilters) {
+	if sku == "" {
+		return "stock", nil
+	}
+	return "variants.$[v].stock", &options.ArrayFilters{Filters: []any{bson.M{"v.sku": sku}}}
+}
+
+// skuMatch matches the sku of a reservation entry. Entries made before
+// variants existed have no sku.
+func skuMatch(sku string) any {
+	if sku == "" {
+		return bson.M{"$in": bson.A{"", nil}}
+	}
+	return sku
+}
+
+// withStock requires the product, or its variant sku, to have at least
+// quantity in stock. Products with variants only sell their variants.
+func withStock(filter bson.M, sku string, quantity int64) {
+	if sku == "" {
+		filter["stock"] = bson.M{"$gte": quantity}
+		filter["variants.0"] = bson.M{"$exists": false}
+		return
+	}
+	filter["variants"] = bson.M{"$elemMatch": bson.M{"sku": sku, "stock": bson.M{"$gte": quantity}}}
+}
+
+func (p *productRepo) ReserveStock(reservationID string, item model.StockItem, expiresAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := NotDeleted()
+	filter["product_id"] = item.Product_id
+	withStock(filter, item.Sku, item.Quantity)
+	filter["reservations"] = bson.M{"$not": bson.M{"$elemMatch": bson.M{
+		"reservation_id": reservationID,
+		"sku":            skuMatch(item.Sku),
+	}}}
+	field, arrayFilters := stockField(item.Sku)
+	update := bson.M{
+		"$inc": bson.M{field: -item.Quantity},
+		"$push": bson.M{"reservations": model.StockReservation{
+			Reservation_id: reservationID,
+			Sku:            item.Sku,
+			Quantity:       item.Quantity,
+			Expires_at:     expiresAt,
+		}},
+	}
+	opts := options.Update()
+	if arrayFilters != nil {
+		opts.SetArrayFilters(*arrayFilters)
+	}
+	res, err := p.productColl.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (p *productRepo) ReservedProducts(reservationID string) ([]*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := p.productColl.Find(ctx, bson.M{"reservations.reservation_id": reservationID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []*model.Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// committedKept is how many committed reservation ids a product remembers
+const committedKept = 100
+
+func (p *productRepo) CommitReservation(reservationID string, now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{"reservations": bson.M{"$elemMatch": bson.M{
+		"reservation_id": reservationID,
+		"expires_at":     bson.M{"$gt": now},
+	}}}
+	update := bson.M{
+		"$pull": bson.M{"reservations": bson.M{"reservation_id": reservationID}},
+		"$push": bson.M{"committed_reservations": bson.M{
+			"$each":  bson.A{reservationID},
+			"$slice": -committedKept,
+		}},
+	}
+	res, err := p.productColl.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+func (p *productRepo) ReservationCommitted(reservationID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	count, err := p.productColl.CountDocuments(ctx, bson.M{"committed_reservations": reservationID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (p *productRepo) ReleaseReservation(productID string, reservation model.StockReservation) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Matching the quantity too makes sure the stock given back is the stock
+	// that was taken, even if the reservation was made again meanwhile
+	filter := bson.M{
+		"product_id": productID,
+		"reservations": bson.M{"$elemMatch": bson.M{
+			"reservation_id": reservation.Reservation_id,
+			"sku":            skuMatch(reservation.Sku),
+			"quantity":       reservation.Quantity,
+		}},
+	}
+	field, arrayFilters := stockField(reservation.Sku)
+	update := bson.M{
+		"$inc": bson.M{field: reservation.Quantity},
+		"$pull": bson.M{"reservations": bson.M{
+			"reservation_id": reservation.Reservation_id,
+			"sku":            skuMatch(reservation.Sku),
+		}},
+	}
+	opts := options.Update()
+	if arrayFilters != nil {
+		opts.SetArrayFilters(*arrayFilters)
+	}
+	res, err := p.productColl.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (p *productRepo) ProductsWithExpiredReservations(now time.Time, limit int) ([]*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetLimit(int64(limit))
+	cursor, err := p.productColl.Find(ctx, bson.M{"reservations.expires_at": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []*model.Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (p *productRepo) AdjustStock(id string, sku string, delta int64) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := NotDeleted()
+	filter["product_id"] = id
+	withStock(filter, sku, max(-delta, 0))
+	field, arrayFilters := stockField(sku)
+	update := bson.M{
+		"$inc": bson.M{field: delta},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if arrayFilters != nil {
+		opts.SetArrayFilters(*arrayFilters)
+	}
+
+	var product model.Product
+	if err := p.productColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (p *productRepo) SetStock(id string, sku string, from int64, stock int64) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := NotDeleted()
+	filter["product_id"] = id
+	if sku == "" {
+		filter["stock"] = from
+		filter["variants.0"] = bson.M{"$exists": false}
+	} else {
+		filter["variants"] = bson.M{"$elemMatch": bson.M{"sku": sku, "stock": from}}
+	}
+	field, arrayFilters := stockField(sku)
+	update := bson.M{"$set": bson.M{field: stock, "updated_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if arrayFilters != nil {
+		opts.SetArrayFilters(*arrayFilters)
+	}
+
+	var product model.Product
+	if err := p.productColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Prices were stored as doubles before they became money. The migration
+// rewrites each as an amount of money.DefaultCurrency, one field at a time
+// and only while the field still holds the double it read, so a price
+// written meanwhile is kept. Running it again does nothing.
+
+// migrationTimeout bounds a migration of the whole collection
+const migrationTimeout = 10 * time.Minute
+
+// legacyPrices matches the products with a price still stored as a double
+var legacyPrices = bson.M{"$or": bson.A{
+	bson.M{"price": bson.M{"$type": "double"}},
+	bson.M{"variants.price": bson.M{"$type": "double"}},
+	bson.M{"price_schedules.price": bson.M{"$type": "double"}},
+}}
+
+func (p *productRepo) MigratePrices() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	cursor, err := p.productColl.Find(ctx, legacyPrices)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		id := cursor.Current.Lookup("_id")
+		old, price, ok, err := legacyPrice(cursor.Current.Lookup("price"))
+		if err != nil {
+			return migrated, fmt.Errorf("product %v: %w", id, err)
+		}
+		if ok {
+			filter := bson.M{"_id": id, "price": old}
+			if _, err := p.productColl.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"price": price}}); err != nil {
+				return migrated, err
+			}
+		}
+		// Variants are matched by SKU and schedules by id
+		for array, key := range map[string]string{"variants": "sku", "price_schedules": "schedule_id"} {
+			if err := p.migrateElements(ctx, cursor.Current, array, key); err != nil {
+				return migrated, fmt.Errorf("product %v: %w", id, err)
+			}
+		}
+		migrated++
+	}
+	return migrated, cursor.Err()
+}
+
+// migrateElements converts the double prices of the elements of array,
+// each identified by its key field
+func (p *productRepo) migrateElements(ctx context.Context, doc bson.Raw, array string, key string) error {
+	values, ok := doc.Lookup(array).ArrayOK()
+	if !ok {
+		return nil
+	}
+	elements, err := values.Values()
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		fields, ok := element.DocumentOK()
+		if !ok {
+			continue
+		}
+		old, price, ok, err := legacyPrice(fields.Lookup("price"))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []any{
+			bson.M{"e." + key: fields.Lookup(key), "e.price": old},
+		}})
+		update := bson.M{"$set": bson.M{array + ".$[e].price": price}}
+		if _, err := p.productColl.UpdateOne(ctx, bson.M{"_id": doc.Lookup("_id")}, update, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *priceRepo) MigratePrices() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	cursor, err := p.historyColl.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"list_price": bson.M{"$type": "double"}},
+		bson.M{"effective_price": bson.M{"$type": "double"}},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		id := cursor.Current.Lookup("_id")
+		for _, field := range []string{"list_price", "effective_price"} {
+			old, price, ok, err := legacyPrice(cursor.Current.Lookup(field))
+			if err != nil {
+				return migrated, fmt.Errorf("price change %v: %w", id, err)
+			}
+			if !ok {
+				continue
+			}
+			if _, err := p.historyColl.UpdateOne(ctx, bson.M{"_id": id, field: old}, bson.M{"$set": bson.M{field: price}}); err != nil {
+				return migrated, err
+			}
+		}
+		migrated++
+	}
+	return migrated, cursor.Err()
+}
+
+// legacyPrice converts a price stored as a double; ok is false for any
+// other value, a missing one included
+func legacyPrice(value bson.RawValue) (float64, money.Money, bool, error) {
+	old, ok := value.DoubleOK()
+	if !ok {
+		return 0, money.Money{}, false, nil
+	}
+	price, err := money.FromFloat(old, money.DefaultCurrency)
+	return old, price, true, err
+}
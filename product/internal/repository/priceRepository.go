@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/intra/db"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PriceRepository interface {
+	RecordPrice(change *model.PriceChange) error
+	// PriceHistory returns the changes matching query, newest first
+	PriceHistory(query *model.PriceHistoryQuery) ([]*model.PriceChange, error)
+	// MigratePrices converts the prices stored as doubles to money and
+	// returns how many changes had any
+	MigratePrices() (int, error)
+}
+
+type priceRepo struct {
+	historyColl *mongo.Collection
+}
+
+func NewPriceRepository(database *db.Database) PriceRepository {
+	return &priceRepo{
+		historyColl: database.PriceHistoryCollection,
+	}
+}
+
+func (p *priceRepo) RecordPrice(change *model.PriceChange) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	_, err := p.historyColl.InsertOne(ctx, change)
+	return err
+}
+
+func (p *priceRepo) PriceHistory(query *model.PriceHistoryQuery) ([]*model.PriceChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{"product_id": query.Product_id, "sku": query.Sku}
+	changedAt := bson.M{}
+	if query.From != nil {
+		changedAt["$gte"] = *query.From
+	}
+	if query.To != nil {
+		changedAt["$lte"] = *query.To
+	}
+	if len(changedAt) > 0 {
+		filter["changed_at"] = changedAt
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "changed_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(query.Limit))
+	cursor, err := p.historyColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	changes := []*model.PriceChange{}
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Schedules live on their product, so the scheduler applying one and taking
+// it off is one atomic update; a schedule another instance already applied
+// no longer matches
+
+func (p *productRepo) AddPriceSchedule(id string, version int64, schedule model.PriceSchedule) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := NotDeleted()
+	filter["product_id"] = id
+	filter["version"] = version
+	update := bson.M{
+		"$push": bson.M{"price_schedules": schedule},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	return p.findOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate())
+}
+
+func (p *productRepo) RemovePriceSchedule(id string, scheduleID string) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{"product_id": id, "price_schedules.schedule_id": scheduleID}
+	update := bson.M{
+		"$pull": bson.M{"price_schedules": bson.M{"schedule_id": scheduleID}},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	return p.findOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate())
+}
+
+func (p *productRepo) ProductsWithDueSchedules(now time.Time, limit int) ([]*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := NotDeleted()
+	filter["$or"] = bson.A{
+		bson.M{"price_schedules": bson.M{"$elemMatch": bson.M{
+			"starts_at": bson.M{"$lte": now},
+			"started":   false,
+		}}},
+		bson.M{"price_schedules.ends_at": bson.M{"$lte": now}},
+	}
+	opts := options.Find().SetLimit(int64(limit))
+	cursor, err := p.productColl.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []*model.Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (p *productRepo) ApplyListPrice(id string, schedule model.PriceSchedule) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{"product_id": id, "price_schedules.schedule_id": schedule.Schedule_id}
+	field := "price"
+	opts := options.FindOneAndUpdate()
+	if schedule.Sku != "" {
+		field = "variants.$[v].price"
+		opts.SetArrayFilters(options.ArrayFilters{Filters: []any{bson.M{"v.sku": schedule.Sku}}})
+	}
+	update := bson.M{
+		"$pull": bson.M{"price_schedules": bson.M{"schedule_id": schedule.Schedule_id}},
+		"$set":  bson.M{field: schedule.Price, "updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	return p.findOneAndUpdate(ctx, filter, update, opts)
+}
+
+func (p *productRepo) StartSale(id string, scheduleID string) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"product_id": id,
+		"price_schedules": bson.M{"$elemMatch": bson.M{
+			"schedule_id": scheduleID,
+			"started":     false,
+		}},
+	}
+	update := bson.M{"$set": bson.M{"price_schedules.$.started": true}}
+	return p.findOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate())
+}
+
+// findOneAndUpdate runs the update and returns the changed product
+func (p *productRepo) findOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, opts *options.FindOneAndUpdateOptions) (*model.Product, error) {
+	opts.SetReturnDocument(options.After)
+	var product model.Product
+	if err := p.productColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidCursor = errors.New("invalid or outdated cursor")
+
+// SortFields maps the sort names of the listing to product fields. Prices
+// sort by amount, products priced in different currencies mixed.
+var SortFields = map[string]string{
+	"price":      "price.amount",
+	"name":       "name",
+	"created_at": "created_at",
+}
+
+// pageCursor is the position after the last product of a page: its sort
+// value and id, which breaks ties. It is only valid for the same sort.
+type pageCursor struct {
+	Sort  string          `json:"s"`
+	Desc  bool            `json:"d"`
+	Value json.RawMessage `json:"v"`
+	ID    string          `json:"id"`
+}
+
+func encodeCursor(query *model.ProductQuery, last *model.Product) (string, error) {
+	var value any
+	switch query.Sort {
+	case "price":
+		if last.Price != nil {
+			value = last.Price.Amount
+		}
+	case "name":
+		value = last.Name
+	case "created_at":
+		value = last.Created_at.UTC().Format(time.RFC3339Nano)
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(pageCursor{Sort: query.Sort, Desc: query.Desc, Value: raw, ID: last.ID.Hex()})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// cursorFilter returns the filter selecting the products after the cursor
+func cursorFilter(query *model.ProductQuery) (bson.M, error) {
+	b, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.Sort != query.Sort || cursor.Desc != query.Desc {
+		return nil, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(cursor.ID)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var value any
+	switch cursor.Sort {
+	case "price":
+		var price int64
+		err = json.Unmarshal(cursor.Value, &price)
+		value = price
+	case "name":
+		var name string
+		err = json.Unmarshal(cursor.Value, &name)
+		value = name
+	case "created_at":
+		var created string
+		if err = json.Unmarshal(cursor.Value, &created); err == nil {
+			value, err = time.Parse(time.RFC3339Nano, created)
+		}
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	after := "$gt"
+	if cursor.Desc {
+		after = "$lt"
+	}
+	field := SortFields[cursor.Sort]
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{after: value}},
+		bson.M{field: value, "_id": bson.M{after: id}},
+	}}, nil
+}
+
+// productFilter matches the live products passing the filters of the query
+func productFilter(query *model.ProductQuery) bson.M {
+	filter := NotDeleted()
+	priceFilter(filter, query.Min_price, query.Max_price)
+	switch {
+	case len(query.Category_ids) > 0:
+		filter["category_ids"] = bson.M{"$in": query.Category_ids}
+	case query.Category != "":
+		filter["category_ids"] = query.Category
+	}
+	return filter
+}
+
+// priceFilter adds the bounds of a price range to filter. Only products
+// priced in the currency of the bounds match; the service made sure both
+// are in the same one.
+func priceFilter(filter bson.M, min *money.Money, max *money.Money) {
+	amount := bson.M{}
+	if min != nil {
+		amount["$gte"] = min.Amount
+		filter["price.currency"] = min.Currency
+	}
+	if max != nil {
+		amount["$lte"] = max.Amount
+		filter["price.currency"] = max.Currency
+	}
+	if len(amount) > 0 {
+		filter["price.amount"] = amount
+	}
+}
+
+func (p *productRepo) ListProducts(query *model.ProductQuery) (*model.ProductPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := productFilter(query)
+	page := &model.ProductPage{Items: []*model.Product{}}
+	if query.Include_total {
+		total, err := p.productColl.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	find := filter
+	if query.Cursor != "" {
+		after, err := cursorFilter(query)
+		if err != nil {
+			return nil, err
+		}
+		find = bson.M{"$and": bson.A{filter, after}}
+	}
+
+	order := 1
+	if query.Desc {
+		order = -1
+	}
+	// One extra product tells whether there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: SortFields[query.Sort], Value: order}, {Key: "_id", Value: order}}).
+		SetLimit(int64(query.Limit) + 1)
+	cursor, err := p.productColl.Find(ctx, find, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var product model.Product
+		if err := cursor.Decode(&product); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, &product)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Items) > query.Limit {
+		page.Items = page.Items[:query.Limit]
+		next, err := encodeCursor(query, page.Items[len(page.Items)-1])
+		if err != nil {
+			return nil, err
+		}
+		page.Next_cursor = next
+	}
+	return page, nil
+}
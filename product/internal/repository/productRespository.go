@@ -8,15 +8,100 @@ import (
 	"github.com/sachinggsingh/e-comm/internal/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// AnyVersion skips the version check of a write
+const AnyVersion int64 = -1
+
 type ProductRepository interface {
 	CreateProduct(p *model.Product) (*model.Product, error)
-	GetAllProducts() ([]*model.Product, error)
+	// ListProducts returns one page of the live products matching query.
+	// It returns ErrInvalidCursor for a cursor of another listing.
+	ListProducts(query *model.ProductQuery) (*model.ProductPage, error)
 	GetProductById(pro *model.Product) (*model.Product, error)
-	// UpdateProduct(p *model.Product) (*model.Product, error)
-	// DeleteProduct(id string) error
+	// FindProduct returns the product whether or not it is deleted
+	FindProduct(id string) (*model.Product, error)
+	// UpdateProduct, DeleteProduct and RestoreProduct only apply while the
+	// product is at version (or any version for AnyVersion) and return
+	// mongo.ErrNoDocuments otherwise
+	UpdateProduct(id string, version int64, update *model.UpdateProductRequest) (*model.Product, error)
+	DeleteProduct(id string, version int64) (*model.Product, error)
+	RestoreProduct(id string, version int64) (*model.Product, error)
+	// CheckIfPoductExist reports whether a product, deleted or not, has the
+	// SKU of pro
 	CheckIfPoductExist(pro *model.Product) (bool, error)
+	// FindBySku returns the product with the merchant SKU whether or not it
+	// is deleted
+	FindBySku(sku string) (*model.Product, error)
+	// EachProduct calls fn with every live product in creation order until
+	// fn returns an error
+	EachProduct(ctx context.Context, fn func(*model.Product) error) error
+	// SearchProducts returns up to limit products found by the text index
+	// and up to limit found by keys, each once. truncated reports that a
+	// strategy reached limit, so more products may match.
+	SearchProducts(query *model.SearchQuery, keys []string, limit int) (candidates []*SearchCandidate, truncated bool, err error)
+	ProductsWithoutSearchKeys() ([]*model.Product, error)
+	SetSearchKeys(id string, keys []string) error
+	// ReserveStock takes the quantity off the stock of a live product, or of
+	// its variant, and records the reservation. It returns false when the
+	// product or variant is missing, has too little stock or already holds
+	// the reservation.
+	ReserveStock(reservationID string, item model.StockItem, expiresAt time.Time) (bool, error)
+	ReservedProducts(reservationID string) ([]*model.Product, error)
+	// CommitReservation drops the reservation from the products where it has
+	// not expired, keeping the stock taken, and returns how many it was on
+	CommitReservation(reservationID string, now time.Time) (int64, error)
+	// ReservationCommitted reports whether a product remembers committing
+	// the reservation
+	ReservationCommitted(reservationID string) (bool, error)
+	// ReleaseReservation gives the reserved stock back to the product. It
+	// returns false when the product no longer holds the reservation.
+	ReleaseReservation(productID string, reservation model.StockReservation) (bool, error)
+	ProductsWithExpiredReservations(now time.Time, limit int) ([]*model.Product, error)
+	// AdjustStock changes the stock of the product, or of its variant sku,
+	// by delta. It returns mongo.ErrNoDocuments when the product or variant
+	// is missing or has less stock than delta takes away.
+	AdjustStock(id string, sku string, delta int64) (*model.Product, error)
+	// SetStock sets the stock of the product, or of its variant sku, to
+	// stock while it still is from. It returns mongo.ErrNoDocuments when the
+	// product or variant is missing or its stock changed.
+	SetStock(id string, sku string, from int64, stock int64) (*model.Product, error)
+	// UpsertVariant changes the attributes and price of the variant with the
+	// SKU or adds it, on the product at version. The first variant is only
+	// added to a product without stock of its own. It returns a mongo
+	// duplicate key error when another product has the SKU and
+	// mongo.ErrNoDocuments when the product is missing or changed.
+	UpsertVariant(id string, version int64, variant model.Variant) (*model.Product, error)
+	// DeleteVariant removes the variant with the SKU unless stock of it is
+	// reserved. It returns mongo.ErrNoDocuments otherwise.
+	DeleteVariant(id string, sku string) (*model.Product, error)
+	// AddImage inserts the image at position, at the end when negative. It
+	// returns mongo.ErrNoDocuments when the product is missing or already
+	// has maxImages images.
+	AddImage(id string, image model.ProductImage, position int, maxImages int) (*model.Product, error)
+	RemoveImage(id string, imageID string) (*model.Product, error)
+	// SetImages replaces the images while the product is at version
+	SetImages(id string, version int64, images []model.ProductImage) (*model.Product, error)
+	// RemoveCategory takes the category off every product
+	RemoveCategory(categoryID string) error
+	// AddPriceSchedule adds the schedule while the product is at version
+	AddPriceSchedule(id string, version int64, schedule model.PriceSchedule) (*model.Product, error)
+	// RemovePriceSchedule returns mongo.ErrNoDocuments when the product has
+	// no such schedule
+	RemovePriceSchedule(id string, scheduleID string) (*model.Product, error)
+	// ProductsWithDueSchedules returns live products with a schedule that
+	// is due to start or end at now
+	ProductsWithDueSchedules(now time.Time, limit int) ([]*model.Product, error)
+	// ApplyListPrice sets the price of a list price schedule and removes it.
+	// It returns mongo.ErrNoDocuments once the schedule is gone.
+	ApplyListPrice(id string, schedule model.PriceSchedule) (*model.Product, error)
+	// StartSale marks the sale schedule started. It returns
+	// mongo.ErrNoDocuments when it is gone or already started.
+	StartSale(id string, scheduleID string) (*model.Product, error)
+	// MigratePrices converts the prices stored as doubles to money and
+	// returns how many products had any
+	MigratePrices() (int, error)
 }
 
 type productRepo struct {
@@ -29,6 +114,11 @@ func NewProductRepository(database *db.Database) ProductRepository {
 	}
 }
 
+// NotDeleted matches the products that are not soft deleted
+func NotDeleted() bson.M {
+	return bson.M{"deleted_at": nil}
+}
+
 func (p *productRepo) CreateProduct(pro *model.Product) (*model.Product, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -39,61 +129,119 @@ func (p *productRepo) CreateProduct(pro *model.Product) (*model.Product, error)
 	return pro, nil
 }
 
-func (p *productRepo) GetAllProducts() ([]*model.Product, error) {
+func (p *productRepo) GetProductById(pro *model.Product) (*model.Product, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	cursor, err := p.productColl.Find(ctx, bson.M{})
+	var product model.Product
+	filter := NotDeleted()
+	filter["product_id"] = pro.Product_id
+	err := p.productColl.FindOne(ctx, filter).Decode(&product)
 	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var products []*model.Product
-	for cursor.Next(ctx) {
-		var product model.Product
-		if err := cursor.Decode(&product); err != nil {
+		if err == mongo.ErrNoDocuments {
 			return nil, err
 		}
-		products = append(products, &product)
-	}
-	if err := cursor.Err(); err != nil {
 		return nil, err
 	}
-	return products, nil
+	return &product, nil
 }
 
-func (p *productRepo) GetProductById(pro *model.Product) (*model.Product, error) {
+func (p *productRepo) FindProduct(id string) (*model.Product, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
 	var product model.Product
-	filter := bson.M{
-		"product_id": pro.Product_id,
-	}
-	err := p.productColl.FindOne(ctx, filter).Decode(&product)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, err
-		}
+	if err := p.productColl.FindOne(ctx, bson.M{"product_id": id}).Decode(&product); err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
-// func (p *productRepo) UpdateProduct(pro *model.Product) (*model.Product, error) {
-// 	return nil, nil
-// }
+func (p *productRepo) UpdateProduct(id string, version int64, update *model.UpdateProductRequest) (*model.Product, error) {
+	fields := bson.M{}
+	if update.Name != nil {
+		fields["name"] = *update.Name
+	}
+	if update.Description != nil {
+		fields["description"] = *update.Description
+	}
+	if update.Price != nil {
+		fields["price"] = *update.Price
+	}
+	if update.Price_list != nil {
+		fields["price_list"] = *update.Price_list
+	}
+	if update.Category_ids != nil {
+		fields["category_ids"] = *update.Category_ids
+	}
+	if update.Options != nil {
+		fields["options"] = *update.Options
+	}
+	if update.Sku != nil {
+		fields["sku"] = *update.Sku
+	}
+	if update.Search_keys != nil {
+		fields["search_keys"] = update.Search_keys
+	}
+	filter := NotDeleted()
+	filter["product_id"] = id
+	return p.apply(filter, version, fields)
+}
+
+func (p *productRepo) DeleteProduct(id string, version int64) (*model.Product, error) {
+	filter := NotDeleted()
+	filter["product_id"] = id
+	return p.apply(filter, version, bson.M{"deleted_at": time.Now()})
+}
 
-// func (p *productRepo) DeleteProduct(id string) error {
-// 	return nil
-// }
+func (p *productRepo) RestoreProduct(id string, version int64) (*model.Product, error) {
+	filter := bson.M{"product_id": id, "deleted_at": bson.M{"$ne": nil}}
+	return p.apply(filter, version, bson.M{"deleted_at": nil})
+}
+
+// atVersion requires the product to be at version, any version when it is
+// negative
+func atVersion(filter bson.M, version int64) {
+	switch {
+	case version == 0:
+		// Products created before versioning have no version field
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	case version > 0:
+		filter["version"] = version
+	}
+}
+
+// apply sets fields on the product matching filter at version, bumps its
+// version and returns the changed product
+func (p *productRepo) apply(filter bson.M, version int64, fields bson.M) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	atVersion(filter, version)
+	fields["updated_at"] = time.Now()
+	update := bson.M{
+		"$set": fields,
+		"$inc": bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var product model.Product
+	if err := p.productColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
 
 func (p *productRepo) CheckIfPoductExist(pro *model.Product) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
+	// Products are told apart by the merchant SKU, the product_id is
+	// generated on create
+	if pro.Sku == "" {
+		return false, nil
+	}
 	filter := bson.M{
-		"product_id": pro.Product_id,
+		"sku": pro.Sku,
 	}
 	var porduct model.Product
 
@@ -106,3 +254,48 @@ func (p *productRepo) CheckIfPoductExist(pro *model.Product) (bool, error) {
 	}
 	return true, nil
 }
+
+func (p *productRepo) RemoveCategory(categoryID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	_, err := p.productColl.UpdateMany(ctx,
+		bson.M{"category_ids": categoryID},
+		bson.M{
+			"$pull": bson.M{"category_ids": categoryID},
+			"$set":  bson.M{"updated_at": time.Now()},
+			"$inc":  bson.M{"version": 1},
+		})
+	return err
+}
+
+func (p *productRepo) FindBySku(sku string) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var product model.Product
+	if err := p.productColl.FindOne(ctx, bson.M{"sku": sku}).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (p *productRepo) EachProduct(ctx context.Context, fn func(*model.Product) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := p.productColl.Find(ctx, NotDeleted(), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var product model.Product
+		if err := cursor.Decode(&product); err != nil {
+			return err
+		}
+		if err := fn(&product); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
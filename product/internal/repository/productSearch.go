@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SearchCandidate is a product found by a search, with its MongoDB text
+// score (0 when it was only found through its search keys)
+type SearchCandidate struct {
+	model.Product `bson:",inline"`
+	TextScore     float64 `bson:"score"`
+}
+
+// searchFilter matches the live products passing the filters of the search
+func searchFilter(query *model.SearchQuery) bson.M {
+	filter := productFilter(&model.ProductQuery{
+		Min_price:    query.Min_price,
+		Max_price:    query.Max_price,
+		Category:     query.Category,
+		Category_ids: query.Category_ids,
+	})
+	if query.In_stock {
+		filter["$or"] = bson.A{
+			bson.M{"stock": bson.M{"$gt": 0}},
+			bson.M{"variants.stock": bson.M{"$gt": 0}},
+		}
+	}
+	return filter
+}
+
+func (p *productRepo) SearchProducts(query *model.SearchQuery, keys []string, limit int) ([]*SearchCandidate, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	found := map[string]*SearchCandidate{}
+	candidates := []*SearchCandidate{}
+	truncated := false
+	collect := func(filter bson.M, opts *options.FindOptions) error {
+		cursor, err := p.productColl.Find(ctx, filter, opts)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+		n := 0
+		for cursor.Next(ctx) {
+			if n++; n == limit {
+				truncated = true
+			}
+			var candidate SearchCandidate
+			if err := cursor.Decode(&candidate); err != nil {
+				return err
+			}
+			if existing, ok := found[candidate.Product_id]; ok {
+				existing.TextScore = max(existing.TextScore, candidate.TextScore)
+				continue
+			}
+			found[candidate.Product_id] = &candidate
+			candidates = append(candidates, &candidate)
+		}
+		return cursor.Err()
+	}
+
+	// Whole words, stemmed, ranked by the text index
+	textFilter := searchFilter(query)
+	textFilter["$text"] = bson.M{"$search": query.Query}
+	score := bson.M{"score": bson.M{"$meta": "textScore"}}
+	err := collect(textFilter, options.Find().SetProjection(score).SetSort(score).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Prefixes and typos through the search keys
+	if len(keys) > 0 {
+		keyFilter := searchFilter(query)
+		keyFilter["search_keys"] = bson.M{"$in": keys}
+		if err := collect(keyFilter, options.Find().SetLimit(int64(limit))); err != nil {
+			return nil, false, err
+		}
+	}
+	return candidates, truncated, nil
+}
+
+func (p *productRepo) ProductsWithoutSearchKeys() ([]*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := p.productColl.Find(ctx, bson.M{"search_keys": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []*model.Product{}
+	for cursor.Next(ctx) {
+		var product model.Product
+		if err := cursor.Decode(&product); err != nil {
+			return nil, err
+		}
+		products = append(products, &product)
+	}
+	return products, cursor.Err()
+}
+
+func (p *productRepo) SetSearchKeys(id string, keys []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	_, err := p.productColl.UpdateOne(ctx, bson.M{"product_id": id}, bson.M{"$set": bson.M{"search_keys": keys}})
+	return err
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Variant changes leave the stock alone, it only changes through
+// reservations and stock adjustments
+
+func (p *productRepo) UpsertVariant(id string, version int64, variant model.Variant) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := NotDeleted()
+	filter["product_id"] = id
+	atVersion(filter, version)
+	filter["variants.sku"] = variant.Sku
+	update := bson.M{
+		"$set": bson.M{
+			"variants.$[v].attributes": variant.Attributes,
+			"variants.$[v].price":      variant.Price,
+			"updated_at":               time.Now(),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetArrayFilters(options.ArrayFilters{Filters: []any{bson.M{"v.sku": variant.Sku}}})
+
+	var product model.Product
+	err := p.productColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product)
+	if err == nil {
+		return &product, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// A new variant. Products with variants only sell those, so the first
+	// one would strand stock the product holds or has reserved.
+	filter["variants.sku"] = bson.M{"$ne": variant.Sku}
+	filter["$or"] = bson.A{
+		bson.M{"variants.0": bson.M{"$exists": true}},
+		bson.M{
+			"stock":        bson.M{"$in": bson.A{0, nil}},
+			"reservations": bson.M{"$not": bson.M{"$elemMatch": bson.M{"sku": skuMatch("")}}},
+		},
+	}
+	update = bson.M{
+		"$push": bson.M{"variants": variant},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	opts = options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := p.productColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (p *productRepo) DeleteVariant(id string, sku string) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	filter := NotDeleted()
+	filter["product_id"] = id
+	filter["variants.sku"] = sku
+	filter["reservations"] = bson.M{"$not": bson.M{"$elemMatch": bson.M{"sku": sku}}}
+	update := bson.M{
+		"$pull": bson.M{"variants": bson.M{"sku": sku}},
+		"$set":  bson.M{"updated_at": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var product model.Product
+	if err := p.productColl.FindOneAndUpdate(ctx, filter, update, opts).Decode(&product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
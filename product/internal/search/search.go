@@ -0,0 +1,257 @@
+// Package search holds the text processing behind product search: the keys
+// stored on products for prefix and typo tolerant matching, the ranking of
+// candidates and the highlighting of matches.
+//
+// MongoDB text search only matches whole (stemmed) words, so every product
+// also stores keys derived from the words of its name: the word itself, its
+// prefixes and the word with any one letter removed. A query word then finds
+// products by exact word, by prefix and, through the deletions, within one
+// typo.
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	// minPrefix is the shortest prefix that matches
+	minPrefix = 2
+	// minTypoLength is the shortest word a typo is tolerated in
+	minTypoLength = 4
+)
+
+// Match qualities, also the weights used for ranking
+const (
+	noMatch     = 0.0
+	typoMatch   = 0.5
+	prefixMatch = 0.7
+	exactMatch  = 1.0
+)
+
+// Tokenize splits text into lower case words
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// deletions returns word with each one of its letters removed
+func deletions(word []rune) []string {
+	out := make([]string, 0, len(word))
+	for i := range word {
+		out = append(out, string(word[:i])+string(word[i+1:]))
+	}
+	return out
+}
+
+// Keys returns the search keys stored for a product name
+func Keys(name string) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for _, word := range Tokenize(name) {
+		runes := []rune(word)
+		add("w:" + word)
+		for n := minPrefix; n < len(runes); n++ {
+			add("p:" + string(runes[:n]))
+		}
+		if len(runes) >= minTypoLength {
+			for _, d := range deletions(runes) {
+				add("d:" + d)
+			}
+		}
+	}
+	return keys
+}
+
+// QueryKeys returns the keys to look products up with for a query. Any one
+// of them matching makes a product a candidate.
+func QueryKeys(query string) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for _, term := range Tokenize(query) {
+		runes := []rune(term)
+		add("w:" + term)
+		if len(runes) >= minPrefix {
+			add("p:" + term)
+		}
+		if len(runes) >= minTypoLength-1 {
+			// the product word has a letter the term lacks
+			add("d:" + term)
+		}
+		if len(runes) >= minTypoLength {
+			for _, d := range deletions(runes) {
+				// the term has an extra letter, or both differ in one
+				add("w:" + d)
+				add("d:" + d)
+			}
+		}
+	}
+	return keys
+}
+
+// withinOneEdit reports whether a and b differ by at most one insertion,
+// deletion, substitution or swap of adjacent letters
+func withinOneEdit(a, b []rune) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	switch len(b) - len(a) {
+	case 0:
+		diff := []int{}
+		for i := range a {
+			if a[i] != b[i] {
+				diff = append(diff, i)
+				if len(diff) > 2 {
+					return false
+				}
+			}
+		}
+		switch len(diff) {
+		case 0, 1:
+			return true
+		case 2:
+			i, j := diff[0], diff[1]
+			return j == i+1 && a[i] == b[j] && a[j] == b[i]
+		}
+		return false
+	case 1:
+		i := 0
+		for i < len(a) && a[i] == b[i] {
+			i++
+		}
+		return string(a[i:]) == string(b[i+1:])
+	}
+	return false
+}
+
+// matchWord rates how well a query term matches a word of a product
+func matchWord(term, word string) float64 {
+	switch {
+	case term == word:
+		return exactMatch
+	case len([]rune(term)) >= minPrefix && strings.HasPrefix(word, term):
+		return prefixMatch
+	case len([]rune(term)) >= minTypoLength-1 && len([]rune(word)) >= minTypoLength-1 &&
+		withinOneEdit([]rune(term), []rune(word)):
+		return typoMatch
+	}
+	return noMatch
+}
+
+func bestMatch(term string, words []string) float64 {
+	best := noMatch
+	for _, word := range words {
+		if m := matchWord(term, word); m > best {
+			best = m
+			if best == exactMatch {
+				break
+			}
+		}
+	}
+	return best
+}
+
+// Score rates a product for the query terms between 0 and 1. Words of the
+// name count fully, words of the description at a third.
+func Score(terms []string, name string, description string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	nameWords, descriptionWords := Tokenize(name), Tokenize(description)
+	total := 0.0
+	for _, term := range terms {
+		score := bestMatch(term, nameWords)
+		if d := bestMatch(term, descriptionWords) / 3; d > score {
+			score = d
+		}
+		total += score
+	}
+	return total / float64(len(terms))
+}
+
+// Highlight HTML escapes text and wraps the words matching the terms in
+// <em>. maxLength > 0 cuts the text to about that many characters around
+// the first match.
+func Highlight(text string, terms []string, maxLength int) string {
+	type span struct{ start, end int }
+	var matches []span
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := strings.ToLower(text[start:end])
+		for _, term := range terms {
+			if matchWord(term, word) > noMatch {
+				matches = append(matches, span{start, end})
+				break
+			}
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+
+	from, to := 0, len(text)
+	if maxLength > 0 && len(text) > maxLength {
+		if len(matches) > 0 {
+			from = max(0, matches[0].start-maxLength/4)
+		}
+		to = min(len(text), from+maxLength)
+		// do not cut words or multi-byte characters
+		for from > 0 && !isBoundary(text, from) {
+			from--
+		}
+		for to < len(text) && !isBoundary(text, to) {
+			to++
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, m := range matches {
+		if m.start < from || m.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m.start]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString("</em>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func isBoundary(text string, i int) bool {
+	r := []rune(text[i:])[0]
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
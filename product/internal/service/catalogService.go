@@ -0,0 +1,480 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/catalog"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// MaxImportBytes bounds the size of an import file
+	MaxImportBytes = 100 << 20
+	// maxImportErrors is how many failed rows a job lists
+	maxImportErrors = 1000
+	// progressInterval is how often a running job stores its progress
+	progressInterval = time.Second
+)
+
+var (
+	InvalidImport  = errors.New("invalid import")
+	ImportTooLarge = errors.New("import file is too large")
+	ImportNotFound = errors.New("import job not found")
+)
+
+// rowError rejects one row of an import, the import goes on
+type rowError struct {
+	msg string
+}
+
+func (e *rowError) Error() string {
+	return e.msg
+}
+
+func rejectRow(format string, args ...any) error {
+	return &rowError{msg: fmt.Sprintf(format, args...)}
+}
+
+// CatalogService imports and exports the catalog in bulk
+type CatalogService struct {
+	productService *Productservice
+	proRepo        repository.ProductRepository
+	jobRepo        repository.ImportJobRepository
+}
+
+func NewCatalogService(productService *Productservice, proRepo repository.ProductRepository, jobRepo repository.ImportJobRepository) *CatalogService {
+	return &CatalogService{
+		productService: productService,
+		proRepo:        proRepo,
+		jobRepo:        jobRepo,
+	}
+}
+
+// StartImport buffers the file to disk and imports it in the background.
+// The returned job reports the progress.
+func (c *CatalogService) StartImport(body io.Reader, format string, userID string) (*model.ImportJob, error) {
+	format, err := catalog.ParseFormat(format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidImport, err)
+	}
+
+	file, err := os.CreateTemp("", "catalog-import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer import: %w", err)
+	}
+	size, err := io.Copy(file, io.LimitReader(body, MaxImportBytes+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to buffer import: %w", err)
+	}
+	if size > MaxImportBytes {
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("%w: at most %d MB", ImportTooLarge, MaxImportBytes>>20)
+	}
+	if size == 0 {
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("%w: the file is empty", InvalidImport)
+	}
+
+	job := &model.ImportJob{
+		Job_id:      primitive.NewObjectID().Hex(),
+		Format:      format,
+		Status:      model.ImportQueued,
+		Created_by:  userID,
+		Bytes_total: size,
+		Errors:      []model.ImportRowError{},
+		Created_at:  time.Now(),
+	}
+	if err := c.jobRepo.CreateJob(job); err != nil {
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+	started := *job
+	go c.runImport(job, file.Name())
+	return &started, nil
+}
+
+func (c *CatalogService) GetImport(id string) (*model.ImportJob, error) {
+	job, err := c.jobRepo.FindJob(id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ImportNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// FailInterruptedImports marks the imports a previous run of the service
+// left unfinished as failed; nothing resumes them
+func (c *CatalogService) FailInterruptedImports() error {
+	n, err := c.jobRepo.FailUnfinishedJobs("interrupted by a restart of the product service, import the file again")
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Marked %d interrupted catalog imports as failed", n)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *CatalogService) runImport(job *model.ImportJob, path string) {
+	defer os.Remove(path)
+
+	now := time.Now()
+	job.Status = model.ImportRunning
+	job.Started_at = &now
+	c.saveJob(job)
+
+	file, err := os.Open(path)
+	if err != nil {
+		c.failJob(job, fmt.Errorf("failed to open import: %w", err))
+		return
+	}
+	defer file.Close()
+	counter := &countingReader{r: file}
+	reader, err := catalog.NewReader(counter, job.Format)
+	if err != nil {
+		c.failJob(job, err)
+		return
+	}
+
+	// rows of each SKU, a SKU is imported once per file
+	seen := map[string]int64{}
+	lastSave := time.Now()
+	for {
+		line, row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var readErr *catalog.RowError
+		if err != nil && !errors.As(err, &readErr) {
+			c.failJob(job, fmt.Errorf("failed to read the file after %d rows: %w", job.Rows, err))
+			return
+		}
+
+		job.Rows++
+		sku := ""
+		if row != nil {
+			sku = row.Sku
+		}
+		if err == nil {
+			if first, ok := seen[sku]; ok && sku != "" {
+				err = rejectRow("sku %s is also on row %d", sku, first)
+			} else {
+				seen[sku] = line
+				err = c.importRow(row, job)
+			}
+		}
+		if err != nil {
+			var rejected *rowError
+			if readErr == nil && !errors.As(err, &rejected) {
+				c.failJob(job, fmt.Errorf("failed to import row %d: %w", line, err))
+				return
+			}
+			msg := err.Error()
+			if readErr != nil {
+				msg = readErr.Err.Error()
+			}
+			job.Failed++
+			if len(job.Errors) < maxImportErrors {
+				job.Errors = append(job.Errors, model.ImportRowError{Row: line, Sku: sku, Message: msg})
+			}
+		}
+
+		if time.Since(lastSave) >= progressInterval {
+			job.Bytes_read = counter.n
+			c.saveJob(job)
+			lastSave = time.Now()
+		}
+	}
+
+	finished := time.Now()
+	job.Status = model.ImportCompleted
+	job.Bytes_read = job.Bytes_total
+	job.Finished_at = &finished
+	c.saveJob(job)
+	log.Printf("Catalog import %s: %d rows, %d created, %d updated, %d unchanged, %d failed",
+		job.Job_id, job.Rows, job.Created, job.Updated, job.Unchanged, job.Failed)
+}
+
+// importRow creates or updates the product, or variant, of the row's SKU
+// and counts the outcome on job. Rejected rows return a *rowError.
+func (c *CatalogService) importRow(row *model.CatalogRow, job *model.ImportJob) error {
+	if err := checkRow(row); err != nil {
+		return err
+	}
+	if row.Variant_of != "" {
+		return c.importVariant(row, job)
+	}
+
+	existing, err := c.proRepo.FindBySku(row.Sku)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		existing, err = c.claimProduct(row)
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		if row.Name == nil || row.Price == nil {
+			return rejectRow("name and price are required for a new product")
+		}
+		description := ""
+		if row.Description != nil {
+			description = *row.Description
+		}
+		product := &model.Product{
+			Sku:         row.Sku,
+			Name:        row.Name,
+			Description: &description,
+			Price:       row.Price,
+		}
+		if row.Stock != nil {
+			product.Stock = *row.Stock
+		}
+		if row.Category_ids != nil {
+			product.Category_ids = *row.Category_ids
+		}
+		if _, err := c.productService.CreateProduct(product, job.Created_by); err != nil {
+			return rowOutcome(err)
+		}
+		job.Created++
+		return nil
+	}
+
+	if existing.Deleted_at != nil {
+		return rejectRow("product %s with this sku is deleted, restore it first", existing.Product_id)
+	}
+	restock := row.Stock != nil && *row.Stock != existing.Stock
+	if restock && len(existing.Variants) > 0 {
+		return rejectRow("product %s has variants, give the stock on their rows", existing.Product_id)
+	}
+	update := changes(existing, row)
+	if update == nil && !restock {
+		job.Unchanged++
+		return nil
+	}
+	if update != nil {
+		if _, err := c.productService.UpdateProduct(existing.Product_id, existing.Version, update, false, job.Created_by); err != nil {
+			return rowOutcome(err)
+		}
+	}
+	if restock {
+		if err := c.setStock(existing.Product_id, "", existing.Stock, *row.Stock); err != nil {
+			return err
+		}
+	}
+	job.Updated++
+	return nil
+}
+
+// importVariant creates or updates the variant of the row's SKU on the
+// product the row is a variant of
+func (c *CatalogService) importVariant(row *model.CatalogRow, job *model.ImportJob) error {
+	product, err := c.proRepo.FindProduct(row.Variant_of)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return rejectRow("product %s does not exist", row.Variant_of)
+		}
+		return err
+	}
+	if product.Deleted_at != nil {
+		return rejectRow("product %s is deleted, restore it first", product.Product_id)
+	}
+
+	existing := product.FindVariant(row.Sku)
+	if existing == nil {
+		req := &model.VariantRequest{Price: row.Price}
+		if row.Attributes != nil {
+			req.Attributes = *row.Attributes
+		}
+		if row.Stock != nil {
+			req.Stock = *row.Stock
+		}
+		if _, err := c.productService.UpsertVariant(product.Product_id, row.Sku, req, job.Created_by); err != nil {
+			return rowOutcome(err)
+		}
+		job.Created++
+		return nil
+	}
+
+	req := &model.VariantRequest{Attributes: existing.Attributes, Price: existing.Price}
+	changed := false
+	if row.Attributes != nil && !maps.Equal(*row.Attributes, existing.Attributes) {
+		req.Attributes, changed = *row.Attributes, true
+	}
+	if row.Price != nil && !samePrice(row.Price, existing.Price) {
+		req.Price, changed = row.Price, true
+	}
+	restock := row.Stock != nil && *row.Stock != existing.Stock
+	if !changed && !restock {
+		job.Unchanged++
+		return nil
+	}
+	if changed {
+		if _, err := c.productService.UpsertVariant(product.Product_id, row.Sku, req, job.Created_by); err != nil {
+			return rowOutcome(err)
+		}
+	}
+	if restock {
+		if err := c.setStock(product.Product_id, row.Sku, existing.Stock, *row.Stock); err != nil {
+			return err
+		}
+	}
+	job.Updated++
+	return nil
+}
+
+// setStock sets the stock of the product, or of its variant sku, to stock
+// if it still is from; stock taken by a checkout meanwhile rejects the row
+func (c *CatalogService) setStock(id string, sku string, from int64, stock int64) error {
+	if _, err := c.proRepo.SetStock(id, sku, from, stock); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return rejectRow("stock changed during the import, import the row again")
+		}
+		return fmt.Errorf("failed to set stock: %w", err)
+	}
+	return nil
+}
+
+// claimProduct finds the product a row gives the product_id of, when the
+// row's SKU is not in the catalog yet. The product gets the SKU if it has
+// none, which is how products created without one are matched. It returns
+// nil when the row has no product_id.
+func (c *CatalogService) claimProduct(row *model.CatalogRow) (*model.Product, error) {
+	if row.Product_id == "" {
+		return nil, nil
+	}
+	product, err := c.proRepo.FindProduct(row.Product_id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, rejectRow("product %s does not exist", row.Product_id)
+		}
+		return nil, err
+	}
+	if product.Sku != "" {
+		return nil, rejectRow("product %s has sku %s", row.Product_id, product.Sku)
+	}
+	return product, nil
+}
+
+func checkRow(row *model.CatalogRow) error {
+	if row.Sku == "" {
+		return rejectRow("sku is required")
+	}
+	if !skuPattern.MatchString(row.Sku) {
+		return rejectRow("sku must be letters, digits, dots, dashes or underscores")
+	}
+	if row.Name != nil && strings.TrimSpace(*row.Name) == "" {
+		return rejectRow("name must not be empty")
+	}
+	if row.Price != nil {
+		if err := checkPrice(row.Price, ""); err != nil {
+			return rejectRow("%s", err.Error())
+		}
+	}
+	if row.Stock != nil && *row.Stock < 0 {
+		return rejectRow("stock must not be negative")
+	}
+	if row.Variant_of != "" && (row.Name != nil || row.Description != nil || row.Category_ids != nil) {
+		return rejectRow("variant rows take no name, description or category_ids")
+	}
+	if row.Variant_of == "" && row.Attributes != nil {
+		return rejectRow("attributes are only for variant rows, give variant_of")
+	}
+	return nil
+}
+
+// changes returns the update that brings the product in line with the row,
+// nil when it already is. Stock is not part of it, importRow sets it apart
+// so a checkout taking stock meanwhile is not overwritten.
+func changes(product *model.Product, row *model.CatalogRow) *model.UpdateProductRequest {
+	update := &model.UpdateProductRequest{}
+	changed := false
+	if row.Name != nil && (product.Name == nil || strings.TrimSpace(*row.Name) != *product.Name) {
+		update.Name, changed = row.Name, true
+	}
+	if row.Description != nil && (product.Description == nil || *row.Description != *product.Description) {
+		update.Description, changed = row.Description, true
+	}
+	if row.Price != nil && (product.Price == nil || *row.Price != *product.Price) {
+		update.Price, changed = row.Price, true
+	}
+	if row.Category_ids != nil && !slices.Equal(*row.Category_ids, product.Category_ids) {
+		update.Category_ids, changed = row.Category_ids, true
+	}
+	if product.Sku == "" {
+		update.Sku, changed = &row.Sku, true
+	}
+	if !changed {
+		return nil
+	}
+	return update
+}
+
+// rowOutcome rejects the row for errors of the product it describes and
+// passes on the others, which stop the import
+func rowOutcome(err error) error {
+	for _, rejected := range []error{CantCreateProduct, ProductExist, InvalidProduct, VariantExist, VersionMismatch, ProductNotFound, ProductHasStock} {
+		if errors.Is(err, rejected) {
+			return rejectRow("%s", err.Error())
+		}
+	}
+	return err
+}
+
+func (c *CatalogService) saveJob(job *model.ImportJob) {
+	if err := c.jobRepo.SaveJob(job); err != nil {
+		log.Printf("Failed to save progress of catalog import %s: %v", job.Job_id, err)
+	}
+}
+
+func (c *CatalogService) failJob(job *model.ImportJob, err error) {
+	finished := time.Now()
+	job.Status = model.ImportFailed
+	job.Error = err.Error()
+	job.Finished_at = &finished
+	c.saveJob(job)
+	log.Printf("Catalog import %s failed: %v", job.Job_id, err)
+}
+
+// Export writes every live product, and its variants, to w in format,
+// streaming them from the database
+func (c *CatalogService) Export(ctx context.Context, w io.Writer, format string) error {
+	writer, err := catalog.NewWriter(w, format)
+	if err != nil {
+		return fmt.Errorf("%w: %v", InvalidQuery, err)
+	}
+	if err := c.proRepo.EachProduct(ctx, writer.Write); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// maxCategoryDepth bounds how many levels the tree has
+	maxCategoryDepth = 8
+	maxCategoryName  = 100
+)
+
+var (
+	CategoryNotFound = errors.New("category not found")
+	CategoryExist    = errors.New("category slug already in use")
+	InvalidCategory  = errors.New("invalid category")
+	CategoryNotEmpty = errors.New("category has subcategories, move or delete them first")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type CategoryService struct {
+	catRepo repository.CategoryRepository
+	proRepo repository.ProductRepository
+}
+
+func NewCategoryService(catRepo repository.CategoryRepository, proRepo repository.ProductRepository) *CategoryService {
+	return &CategoryService{
+		catRepo: catRepo,
+		proRepo: proRepo,
+	}
+}
+
+// Slugify derives a slug from a name: lower case letters and digits joined
+// by single dashes
+func Slugify(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	return strings.Join(words, "-")
+}
+
+func (c *CategoryService) CreateCategory(req *model.CategoryRequest) (*model.Category, error) {
+	if req.Name == nil {
+		return nil, fmt.Errorf("%w: name is required", InvalidCategory)
+	}
+	name, err := categoryName(*req.Name)
+	if err != nil {
+		return nil, err
+	}
+	slug := Slugify(name)
+	if req.Slug != nil {
+		slug = *req.Slug
+	}
+	if !slugPattern.MatchString(slug) {
+		return nil, fmt.Errorf("%w: slug must be lower case letters and digits separated by dashes", InvalidCategory)
+	}
+
+	now := time.Now()
+	category := &model.Category{
+		ID:         primitive.NewObjectID(),
+		Name:       name,
+		Slug:       slug,
+		Ancestors:  []string{},
+		Created_at: now,
+		Updated_at: now,
+	}
+	category.Category_id = category.ID.Hex()
+	if req.Position != nil {
+		category.Position = *req.Position
+	}
+	if req.Parent_id != nil && *req.Parent_id != "" {
+		parent, err := c.findCategory(*req.Parent_id)
+		if err != nil {
+			return nil, err
+		}
+		category.Parent_id = parent.Category_id
+		category.Ancestors = append(slices.Clone(parent.Ancestors), parent.Category_id)
+		if len(category.Ancestors) >= maxCategoryDepth {
+			return nil, fmt.Errorf("%w: categories nest at most %d levels deep", InvalidCategory, maxCategoryDepth)
+		}
+	}
+
+	created, err := c.catRepo.CreateCategory(category)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, CategoryExist
+		}
+		return nil, fmt.Errorf("failed to create category: %w", err)
+	}
+	return created, nil
+}
+
+// Tree returns the whole category tree
+func (c *CategoryService) Tree() ([]*model.CategoryNode, error) {
+	categories, err := c.catRepo.AllCategories()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+	return buildTree(categories, ""), nil
+}
+
+// GetCategory returns the category, found by id or slug, with its subtree
+func (c *CategoryService) GetCategory(idOrSlug string) (*model.CategoryNode, error) {
+	category, err := c.findCategory(idOrSlug)
+	if err != nil {
+		return nil, err
+	}
+	descendants, err := c.catRepo.Descendants(category.Category_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subcategories: %w", err)
+	}
+	return &model.CategoryNode{
+		Category: category,
+		Children: buildTree(descendants, category.Category_id),
+	}, nil
+}
+
+// UpdateCategory renames, reorders or moves the category. A category cannot
+// be moved below itself.
+func (c *CategoryService) UpdateCategory(idOrSlug string, req *model.CategoryRequest) (*model.Category, error) {
+	category, err := c.findCategory(idOrSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := bson.M{}
+	if req.Name != nil {
+		name, err := categoryName(*req.Name)
+		if err != nil {
+			return nil, err
+		}
+		fields["name"] = name
+	}
+	if req.Slug != nil {
+		if !slugPattern.MatchString(*req.Slug) {
+			return nil, fmt.Errorf("%w: slug must be lower case letters and digits separated by dashes", InvalidCategory)
+		}
+		fields["slug"] = *req.Slug
+	}
+	if req.Position != nil {
+		fields["position"] = *req.Position
+	}
+
+	var descendants []*model.Category
+	ancestors := category.Ancestors
+	moved := req.Parent_id != nil && *req.Parent_id != category.Parent_id
+	if moved {
+		ancestors = []string{}
+		parentID := ""
+		if *req.Parent_id != "" {
+			parent, err := c.findCategory(*req.Parent_id)
+			if err != nil {
+				return nil, err
+			}
+			if parent.Category_id == category.Category_id || slices.Contains(parent.Ancestors, category.Category_id) {
+				return nil, fmt.Errorf("%w: a category cannot be moved below itself", InvalidCategory)
+			}
+			parentID = parent.Category_id
+			ancestors = append(slices.Clone(parent.Ancestors), parent.Category_id)
+		}
+
+		descendants, err = c.catRepo.Descendants(category.Category_id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list subcategories: %w", err)
+		}
+		depth := len(ancestors)
+		for _, d := range descendants {
+			depth = max(depth, len(ancestors)+len(d.Ancestors)-len(category.Ancestors))
+		}
+		if depth >= maxCategoryDepth {
+			return nil, fmt.Errorf("%w: categories nest at most %d levels deep", InvalidCategory, maxCategoryDepth)
+		}
+		fields["parent_id"] = parentID
+		fields["ancestors"] = ancestors
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: nothing to update", InvalidCategory)
+	}
+
+	updated, err := c.catRepo.UpdateCategory(category.Category_id, fields)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, CategoryExist
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, CategoryNotFound
+		}
+		return nil, fmt.Errorf("failed to update category: %w", err)
+	}
+
+	// The subtree moves along: replace the old path above the category
+	for _, d := range descendants {
+		below := d.Ancestors[len(category.Ancestors):]
+		path := append(slices.Clone(ancestors), below...)
+		if _, err := c.catRepo.UpdateCategory(d.Category_id, bson.M{"ancestors": path}); err != nil {
+			return nil, fmt.Errorf("failed to move subcategory %s: %w", d.Category_id, err)
+		}
+	}
+	return updated, nil
+}
+
+// DeleteCategory deletes a category without subcategories and takes it off
+// its products
+func (c *CategoryService) DeleteCategory(idOrSlug string) error {
+	category, err := c.findCategory(idOrSlug)
+	if err != nil {
+		return err
+	}
+	children, err := c.catRepo.CountChildren(category.Category_id)
+	if err != nil {
+		return fmt.Errorf("failed to count subcategories: %w", err)
+	}
+	if children > 0 {
+		return CategoryNotEmpty
+	}
+
+	if err := c.catRepo.DeleteCategory(category.Category_id); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return CategoryNotFound
+		}
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	if err := c.proRepo.RemoveCategory(category.Category_id); err != nil {
+		return fmt.Errorf("failed to remove category from products: %w", err)
+	}
+	return nil
+}
+
+func (c *CategoryService) findCategory(idOrSlug string) (*model.Category, error) {
+	category, err := c.catRepo.FindCategory(idOrSlug)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, CategoryNotFound
+		}
+		return nil, fmt.Errorf("failed to find category: %w", err)
+	}
+	return category, nil
+}
+
+func categoryName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxCategoryName {
+		return "", fmt.Errorf("%w: name is required and limited to %d characters", InvalidCategory, maxCategoryName)
+	}
+	return name, nil
+}
+
+// buildTree nests categories under their parents, starting below root.
+// Siblings keep the order of categories.
+func buildTree(categories []*model.Category, root string) []*model.CategoryNode {
+	children := map[string][]*model.CategoryNode{}
+	for _, category := range categories {
+		node := &model.CategoryNode{Category: category}
+		children[category.Parent_id] = append(children[category.Parent_id], node)
+	}
+	var attach func(parent string) []*model.CategoryNode
+	attach = func(parent string) []*model.CategoryNode {
+		nodes := children[parent]
+		for _, node := range nodes {
+			node.Children = attach(node.Category_id)
+		}
+		if nodes == nil {
+			nodes = []*model.CategoryNode{}
+		}
+		return nodes
+	}
+	return attach(root)
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	DefaultReservationTTL = 15 * time.Minute
+	MaxReservationTTL     = 24 * time.Hour
+	maxReservationItems   = 100
+	maxReservationID      = 128
+	// expiredBatch is how many products one sweep releases reservations of
+	expiredBatch = 100
+)
+
+var (
+	InsufficientStock   = errors.New("insufficient stock")
+	ReservationNotFound = errors.New("reservation not found or expired")
+	InvalidReservation  = errors.New("invalid reservation")
+)
+
+// ReserveStock holds the items for ttl (DefaultReservationTTL when 0). Either
+// all items are reserved or none: on failure the items reserved so far are
+// given back. Reserving again with the same id reserves nothing twice.
+func (p *Productservice) ReserveStock(reservationID string, items []model.StockItem, ttl time.Duration) (time.Time, error) {
+	reservationID = strings.TrimSpace(reservationID)
+	if reservationID == "" || len(reservationID) > maxReservationID {
+		return time.Time{}, fmt.Errorf("%w: reservation_id is required and limited to %d characters", InvalidReservation, maxReservationID)
+	}
+	if len(items) == 0 || len(items) > maxReservationItems {
+		return time.Time{}, fmt.Errorf("%w: between 1 and %d items are required", InvalidReservation, maxReservationItems)
+	}
+	if ttl == 0 {
+		ttl = DefaultReservationTTL
+	}
+	if ttl < 0 || ttl > MaxReservationTTL {
+		return time.Time{}, fmt.Errorf("%w: ttl must be at most %s", InvalidReservation, MaxReservationTTL)
+	}
+
+	// A product or variant is reserved once, for the sum of its quantities
+	merged := []model.StockItem{}
+	index := map[model.StockItem]int{}
+	for _, item := range items {
+		if item.Product_id == "" || item.Quantity <= 0 {
+			return time.Time{}, fmt.Errorf("%w: every item needs a product_id and a positive quantity", InvalidReservation)
+		}
+		key := model.StockItem{Product_id: item.Product_id, Sku: item.Sku}
+		if i, ok := index[key]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, item)
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	for _, item := range merged {
+		err := p.reserveItem(reservationID, item, expiresAt)
+		if err != nil {
+			if _, undoErr := p.release(reservationID); undoErr != nil {
+				log.Printf("Failed to undo reservation %s: %v", reservationID, undoErr)
+			}
+			return time.Time{}, err
+		}
+	}
+	return expiresAt, nil
+}
+
+func (p *Productservice) reserveItem(reservationID string, item model.StockItem, expiresAt time.Time) error {
+	reserved, err := p.proRepo.ReserveStock(reservationID, item, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to reserve product %s: %w", item.Product_id, err)
+	}
+	if reserved {
+		return nil
+	}
+
+	// Nothing changed, find out why
+	product, err := p.proRepo.FindProduct(item.Product_id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("%w: %s", ProductNotFound, item.Product_id)
+		}
+		return fmt.Errorf("failed to find product %s: %w", item.Product_id, err)
+	}
+	if product.Deleted_at != nil {
+		return fmt.Errorf("%w: %s", ProductNotFound, item.Product_id)
+	}
+	for _, reservation := range product.Reservations {
+		if reservation.Reservation_id == reservationID && reservation.Sku == item.Sku {
+			// a retry of a reservation that went through
+			return nil
+		}
+	}
+	available, err := availableStock(product, item.Sku)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s has %d available, %d requested", InsufficientStock, stockName(item.Product_id, item.Sku), available, item.Quantity)
+}
+
+// availableStock returns the stock of the product, or of its variant sku
+func availableStock(product *model.Product, sku string) (int64, error) {
+	if sku == "" {
+		if len(product.Variants) > 0 {
+			return 0, fmt.Errorf("%w: product %s has variants, a sku is required", InvalidReservation, product.Product_id)
+		}
+		return product.Stock, nil
+	}
+	variant := product.FindVariant(sku)
+	if variant == nil {
+		return 0, fmt.Errorf("%w: %s", VariantNotFound, stockName(product.Product_id, sku))
+	}
+	return variant.Stock, nil
+}
+
+func stockName(productID string, sku string) string {
+	if sku == "" {
+		return "product " + productID
+	}
+	return "product " + productID + " sku " + sku
+}
+
+// CommitReservation turns the reservation into a sale: the stock stays taken
+// and the reservation is dropped. Committing it again succeeds, so a caller
+// can retry; it returns ReservationNotFound once the reservation expired or
+// was released.
+func (p *Productservice) CommitReservation(reservationID string) error {
+	if reservationID == "" {
+		return fmt.Errorf("%w: reservation_id is required", InvalidReservation)
+	}
+	committed, err := p.proRepo.CommitReservation(reservationID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to commit reservation: %w", err)
+	}
+	if committed == 0 {
+		done, err := p.proRepo.ReservationCommitted(reservationID)
+		if err != nil {
+			return fmt.Errorf("failed to commit reservation: %w", err)
+		}
+		if !done {
+			return ReservationNotFound
+		}
+	}
+	return nil
+}
+
+// ReleaseReservation gives the reserved stock back
+func (p *Productservice) ReleaseReservation(reservationID string) error {
+	if reservationID == "" {
+		return fmt.Errorf("%w: reservation_id is required", InvalidReservation)
+	}
+	released, err := p.release(reservationID)
+	if err != nil {
+		return fmt.Errorf("failed to release reservation: %w", err)
+	}
+	if released == 0 {
+		return ReservationNotFound
+	}
+	return nil
+}
+
+func (p *Productservice) release(reservationID string) (int, error) {
+	products, err := p.proRepo.ReservedProducts(reservationID)
+	if err != nil {
+		return 0, err
+	}
+	released := 0
+	for _, product := range products {
+		for _, reservation := range product.Reservations {
+			if reservation.Reservation_id != reservationID {
+				continue
+			}
+			ok, err := p.proRepo.ReleaseReservation(product.Product_id, reservation)
+			if err != nil {
+				return released, err
+			}
+			if ok {
+				released++
+			}
+		}
+	}
+	return released, nil
+}
+
+// ReleaseExpiredReservations gives back the stock of reservations that were
+// neither committed nor released in time
+func (p *Productservice) ReleaseExpiredReservations() error {
+	now := time.Now()
+	products, err := p.proRepo.ProductsWithExpiredReservations(now, expiredBatch)
+	if err != nil {
+		return fmt.Errorf("failed to find expired reservations: %w", err)
+	}
+	released := 0
+	for _, product := range products {
+		for _, reservation := range product.Reservations {
+			if reservation.Expires_at.After(now) {
+				continue
+			}
+			ok, err := p.proRepo.ReleaseReservation(product.Product_id, reservation)
+			if err != nil {
+				return fmt.Errorf("failed to release reservation %s: %w", reservation.Reservation_id, err)
+			}
+			if ok {
+				released++
+			}
+		}
+	}
+	if released > 0 {
+		log.Printf("Released %d expired stock reservations", released)
+	}
+	return nil
+}
+
+// AdjustStock adds delta to the stock of the product, or of its variant sku,
+// or takes it away when negative. Stock never drops below zero.
+func (p *Productservice) AdjustStock(id string, sku string, delta int64) (*model.Product, error) {
+	if delta == 0 {
+		return nil, fmt.Errorf("%w: delta must not be 0", InvalidProduct)
+	}
+	product, err := p.proRepo.AdjustStock(id, sku, delta)
+	if err == nil {
+		return product, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to adjust stock: %w", err)
+	}
+
+	current, err := p.proRepo.FindProduct(id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ProductNotFound
+		}
+		return nil, fmt.Errorf("failed to find product: %w", err)
+	}
+	if current.Deleted_at != nil {
+		return nil, ProductNotFound
+	}
+	available, err := availableStock(current, sku)
+	if err != nil {
+		if errors.Is(err, InvalidReservation) {
+			return nil, fmt.Errorf("%w: the product has variants, a sku is required", InvalidProduct)
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: %d available", InsufficientStock, available)
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/media"
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxImages = 20
+	// DefaultMaxImageBytes bounds the size of an uploaded image file
+	DefaultMaxImageBytes int64 = 10 << 20
+)
+
+var (
+	ImageNotFound = errors.New("image not found")
+	InvalidImage  = errors.New("invalid image")
+	ImageTooLarge = errors.New("image file is too large")
+)
+
+type MediaService struct {
+	proRepo  repository.ProductRepository
+	store    media.BlobStore
+	maxBytes int64
+}
+
+func NewMediaService(proRepo repository.ProductRepository, store media.BlobStore, maxBytes int64) *MediaService {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxImageBytes
+	}
+	return &MediaService{
+		proRepo:  proRepo,
+		store:    store,
+		maxBytes: maxBytes,
+	}
+}
+
+// MaxBytes is the largest image file accepted
+func (m *MediaService) MaxBytes() int64 {
+	return m.maxBytes
+}
+
+// AddImage stores the image and its thumbnails and adds it to the product
+// at position, at the end when negative
+func (m *MediaService) AddImage(ctx context.Context, productID string, data []byte, position int) (*model.Product, error) {
+	if int64(len(data)) > m.maxBytes {
+		return nil, fmt.Errorf("%w: at most %d bytes", ImageTooLarge, m.maxBytes)
+	}
+	product, err := m.proRepo.GetProductById(&model.Product{Product_id: productID})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ProductNotFound
+		}
+		return nil, err
+	}
+	if len(product.Images) >= maxImages {
+		return nil, fmt.Errorf("%w: a product has at most %d images", InvalidImage, maxImages)
+	}
+
+	processed, err := media.Process(data)
+	if err != nil {
+		return nil, err
+	}
+
+	imageID := primitive.NewObjectID().Hex()
+	prefix := "products/" + productID + "/" + imageID + "/"
+	image := model.ProductImage{
+		Image_id:     imageID,
+		Key:          prefix + "original" + media.Extension(processed.ContentType),
+		Content_type: processed.ContentType,
+		Width:        processed.Width,
+		Height:       processed.Height,
+		Size:         int64(len(data)),
+		Created_at:   time.Now(),
+	}
+	image.Url = m.store.URL(image.Key)
+	for _, rendition := range processed.Thumbnails {
+		key := prefix + rendition.Name + media.Extension(rendition.ContentType)
+		image.Thumbnails = append(image.Thumbnails, model.Thumbnail{
+			Size:   rendition.Name,
+			Url:    m.store.URL(key),
+			Key:    key,
+			Width:  rendition.Width,
+			Height: rendition.Height,
+		})
+	}
+
+	if err := m.store.Put(ctx, image.Key, image.Content_type, data); err != nil {
+		return nil, fmt.Errorf("failed to store image: %w", err)
+	}
+	for i, rendition := range processed.Thumbnails {
+		if err := m.store.Put(ctx, image.Thumbnails[i].Key, rendition.ContentType, rendition.Data); err != nil {
+			m.deleteFiles(ctx, image)
+			return nil, fmt.Errorf("failed to store thumbnail: %w", err)
+		}
+	}
+
+	updated, err := m.proRepo.AddImage(productID, image, position, maxImages)
+	if err != nil {
+		m.deleteFiles(ctx, image)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// deleted or filled up since it was read
+			return nil, fmt.Errorf("%w: the product is gone or has %d images", InvalidImage, maxImages)
+		}
+		return nil, fmt.Errorf("failed to add image: %w", err)
+	}
+	return updated, nil
+}
+
+// RemoveImage takes the image off the product and deletes its files
+func (m *MediaService) RemoveImage(ctx context.Context, productID string, imageID string) (*model.Product, error) {
+	product, err := m.proRepo.GetProductById(&model.Product{Product_id: productID})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ProductNotFound
+		}
+		return nil, err
+	}
+	image := product.FindImage(imageID)
+	if image == nil {
+		return nil, ImageNotFound
+	}
+
+	updated, err := m.proRepo.RemoveImage(productID, imageID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// removed by someone else in the meantime, who deletes the files
+			return nil, ImageNotFound
+		}
+		return nil, fmt.Errorf("failed to remove image: %w", err)
+	}
+	m.deleteFiles(ctx, *image)
+	return updated, nil
+}
+
+// ReorderImages puts the images in the order of imageIDs, which must list
+// every image of the product once
+func (m *MediaService) ReorderImages(productID string, imageIDs []string) (*model.Product, error) {
+	product, err := m.proRepo.GetProductById(&model.Product{Product_id: productID})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ProductNotFound
+		}
+		return nil, err
+	}
+	if len(imageIDs) != len(product.Images) {
+		return nil, fmt.Errorf("%w: image_ids must list all %d images of the product", InvalidImage, len(product.Images))
+	}
+	images := make([]model.ProductImage, 0, len(imageIDs))
+	seen := map[string]bool{}
+	for _, id := range imageIDs {
+		image := product.FindImage(id)
+		if image == nil || seen[id] {
+			return nil, fmt.Errorf("%w: image_ids must list every image of the product once", InvalidImage)
+		}
+		seen[id] = true
+		images = append(images, *image)
+	}
+
+	updated, err := m.proRepo.SetImages(productID, product.Version, images)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, VersionMismatch
+		}
+		return nil, fmt.Errorf("failed to reorder images: %w", err)
+	}
+	return updated, nil
+}
+
+// deleteFiles removes the files of an image. A file left behind only takes
+// space, so failures are logged rather than returned.
+func (m *MediaService) deleteFiles(ctx context.Context, image model.ProductImage) {
+	keys := []string{image.Key}
+	for _, thumbnail := range image.Thumbnails {
+		keys = append(keys, thumbnail.Key)
+	}
+	for _, key := range keys {
+		if err := m.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete image file %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// maxPriceSchedules bounds the schedules of a product
+	maxPriceSchedules = 50
+	// dueBatch is how many products one scheduler run applies schedules of
+	dueBatch = 100
+	// schedulerActor is the actor of the price changes the scheduler makes
+	schedulerActor = "scheduler"
+)
+
+var ScheduleNotFound = errors.New("price schedule not found")
+
+// SchedulePrice schedules a list price change or, with an end, a sale of
+// the product or of its variant sku. Sales of the same SKU must not
+// overlap.
+func (p *Productservice) SchedulePrice(id string, req *model.PriceScheduleRequest, actor string) (*model.PriceSchedule, error) {
+	now := time.Now()
+	if req.Price == nil {
+		return nil, fmt.Errorf("%w: a price is required", InvalidProduct)
+	}
+	if !req.Starts_at.After(now) {
+		return nil, fmt.Errorf("%w: starts_at must be in the future", InvalidProduct)
+	}
+	if req.Ends_at != nil && !req.Ends_at.After(req.Starts_at) {
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", InvalidProduct)
+	}
+
+	product, err := p.proRepo.GetProductById(&model.Product{Product_id: id})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ProductNotFound
+		}
+		return nil, err
+	}
+	if req.Sku != "" && product.FindVariant(req.Sku) == nil {
+		return nil, fmt.Errorf("%w: %s", VariantNotFound, stockName(id, req.Sku))
+	}
+	if err := checkPrice(req.Price, product.Currency()); err != nil {
+		return nil, err
+	}
+	if len(product.Price_schedules) >= maxPriceSchedules {
+		return nil, fmt.Errorf("%w: a product has at most %d price schedules", InvalidProduct, maxPriceSchedules)
+	}
+	schedule := model.PriceSchedule{
+		Schedule_id: primitive.NewObjectID().Hex(),
+		Sku:         req.Sku,
+		Price:       *req.Price,
+		Starts_at:   req.Starts_at.UTC(),
+		Created_by:  actor,
+		Created_at:  now,
+	}
+	if req.Ends_at != nil {
+		ends := req.Ends_at.UTC()
+		schedule.Ends_at = &ends
+	}
+	if schedule.IsSale() {
+		if err := checkSalePrice(product, schedule); err != nil {
+			return nil, err
+		}
+	}
+	for _, other := range product.Price_schedules {
+		if other.Sku != schedule.Sku || other.IsSale() != schedule.IsSale() {
+			continue
+		}
+		if !schedule.IsSale() && other.Starts_at.Equal(schedule.Starts_at) {
+			return nil, fmt.Errorf("%w: price change %s starts at the same time", InvalidProduct, other.Schedule_id)
+		}
+		if schedule.IsSale() && schedule.Starts_at.Before(*other.Ends_at) && other.Starts_at.Before(*schedule.Ends_at) {
+			return nil, fmt.Errorf("%w: the sale overlaps sale %s", InvalidProduct, other.Schedule_id)
+		}
+	}
+
+	// The version makes sure no schedule was added since the checks
+	if _, err := p.proRepo.AddPriceSchedule(id, product.Version, schedule); err != nil {
+		return nil, p.writeFailed(id, err, false)
+	}
+	return &schedule, nil
+}
+
+// PriceSchedules returns the schedules of the product in the order they start
+func (p *Productservice) PriceSchedules(id string) ([]model.PriceSchedule, error) {
+	product, err := p.proRepo.GetProductById(&model.Product{Product_id: id})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ProductNotFound
+		}
+		return nil, err
+	}
+	schedules := product.Price_schedules
+	if schedules == nil {
+		schedules = []model.PriceSchedule{}
+	}
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].Starts_at.Before(schedules[j].Starts_at)
+	})
+	return schedules, nil
+}
+
+// CancelPriceSchedule removes a schedule. Cancelling a running sale ends it.
+func (p *Productservice) CancelPriceSchedule(id string, scheduleID string, actor string) error {
+	product, err := p.proRepo.GetProductById(&model.Product{Product_id: id})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ProductNotFound
+		}
+		return err
+	}
+	var schedule *model.PriceSchedule
+	for i := range product.Price_schedules {
+		if product.Price_schedules[i].Schedule_id == scheduleID {
+			schedule = &product.Price_schedules[i]
+		}
+	}
+	if schedule == nil {
+		return ScheduleNotFound
+	}
+
+	updated, err := p.proRepo.RemovePriceSchedule(id, scheduleID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ScheduleNotFound
+		}
+		return fmt.Errorf("failed to cancel price schedule: %w", err)
+	}
+	if now := time.Now(); schedule.Active(now) {
+		p.recordPrices(updated, schedule.Sku, model.SaleEnded, scheduleID, actor, now)
+	}
+	return nil
+}
+
+// PriceHistory returns the price changes of the product, or of its variant
+// Query.Sku, newest first. The first change at or before a time holds the
+// prices of that time.
+func (p *Productservice) PriceHistory(query *model.PriceHistoryQuery) ([]*model.PriceChange, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	if query.Limit < 0 || query.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", InvalidQuery, MaxPageSize)
+	}
+	if query.From != nil && query.To != nil && query.From.After(*query.To) {
+		return nil, fmt.Errorf("%w: from is after to", InvalidQuery)
+	}
+	if _, err := p.proRepo.FindProduct(query.Product_id); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ProductNotFound
+		}
+		return nil, err
+	}
+	return p.priceRepo.PriceHistory(query)
+}
+
+// ApplyPriceSchedules makes the list price changes that are due and records
+// sales starting and ending. Prices are computed from the schedules at
+// request time, so running late only delays the history entries, which
+// carry the time the schedule took effect.
+func (p *Productservice) ApplyPriceSchedules() error {
+	now := time.Now()
+	products, err := p.proRepo.ProductsWithDueSchedules(now, dueBatch)
+	if err != nil {
+		return fmt.Errorf("failed to find due price schedules: %w", err)
+	}
+	applied := 0
+	for _, product := range products {
+		// List price changes are applied in order, the last one stays
+		sort.SliceStable(product.Price_schedules, func(i, j int) bool {
+			return product.Price_schedules[i].Starts_at.Before(product.Price_schedules[j].Starts_at)
+		})
+		for _, schedule := range product.Price_schedules {
+			ok, err := p.applySchedule(product, schedule, now)
+			if err != nil {
+				return fmt.Errorf("failed to apply price schedule %s: %w", schedule.Schedule_id, err)
+			}
+			if ok {
+				applied++
+			}
+		}
+	}
+	if applied > 0 {
+		log.Printf("Applied %d price schedules", applied)
+	}
+	return nil
+}
+
+// applySchedule takes the next step of a schedule that is due. It reports
+// false when there is none or another instance took it.
+func (p *Productservice) applySchedule(product *model.Product, schedule model.PriceSchedule, now time.Time) (bool, error) {
+	if schedule.Starts_at.After(now) {
+		return false, nil
+	}
+	if schedule.Sku != "" && product.FindVariant(schedule.Sku) == nil {
+		// the variant was deleted
+		_, err := p.proRepo.RemovePriceSchedule(product.Product_id, schedule.Schedule_id)
+		return false, ignoreGone(err)
+	}
+
+	if !schedule.IsSale() {
+		updated, err := p.proRepo.ApplyListPrice(product.Product_id, schedule)
+		if err != nil {
+			return false, ignoreGone(err)
+		}
+		p.recordPrices(updated, schedule.Sku, model.PriceScheduled, schedule.Schedule_id, schedulerActor, schedule.Starts_at)
+		return true, nil
+	}
+
+	started := false
+	if !schedule.Started {
+		updated, err := p.proRepo.StartSale(product.Product_id, schedule.Schedule_id)
+		if err != nil {
+			return false, ignoreGone(err)
+		}
+		p.recordPrices(updated, schedule.Sku, model.SaleStarted, schedule.Schedule_id, schedulerActor, schedule.Starts_at)
+		started = true
+	}
+	if schedule.Ends_at.After(now) {
+		return started, nil
+	}
+	updated, err := p.proRepo.RemovePriceSchedule(product.Product_id, schedule.Schedule_id)
+	if err != nil {
+		return false, ignoreGone(err)
+	}
+	p.recordPrices(updated, schedule.Sku, model.SaleEnded, schedule.Schedule_id, schedulerActor, *schedule.Ends_at)
+	return true, nil
+}
+
+// MigratePrices converts the prices stored as doubles, before prices were
+// money, to amounts of money.DefaultCurrency. Products are read as money,
+// so it must run before anything else reads them.
+func (p *Productservice) MigratePrices() error {
+	products, err := p.proRepo.MigratePrices()
+	if err != nil {
+		return fmt.Errorf("failed to migrate product prices: %w", err)
+	}
+	changes, err := p.priceRepo.MigratePrices()
+	if err != nil {
+		return fmt.Errorf("failed to migrate price history: %w", err)
+	}
+	if products > 0 || changes > 0 {
+		log.Printf("Migrated the prices of %d products and %d price changes to %s", products, changes, money.DefaultCurrency)
+	}
+	return nil
+}
+
+// checkPrice makes sure a price is not negative and in a known currency,
+// currency when one is given
+func checkPrice(price *money.Money, currency string) error {
+	if err := price.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", InvalidProduct, err)
+	}
+	if currency != "" && price.Currency != currency {
+		return fmt.Errorf("%w: price must be in %s", InvalidProduct, currency)
+	}
+	if price.IsNegative() {
+		return fmt.Errorf("%w: price must not be negative", InvalidProduct)
+	}
+	return nil
+}
+
+// checkSalePrice makes sure a sale lowers the price: it must be positive and
+// no higher than the list price of the product, or of the variant, when the
+// sale starts and after each list price change scheduled while it runs
+func checkSalePrice(product *model.Product, sale model.PriceSchedule) error {
+	if sale.Price.Amount <= 0 {
+		return fmt.Errorf("%w: a sale price must be greater than 0", InvalidProduct)
+	}
+	times := []time.Time{sale.Starts_at}
+	for _, other := range product.Price_schedules {
+		if !other.IsSale() && other.Starts_at.After(sale.Starts_at) && other.Starts_at.Before(*sale.Ends_at) {
+			times = append(times, other.Starts_at)
+		}
+	}
+	for _, t := range times {
+		list, _ := product.Prices(sale.Sku, t)
+		cmp, err := sale.Price.Cmp(list)
+		if err != nil {
+			return fmt.Errorf("%w: %v", InvalidProduct, err)
+		}
+		if cmp > 0 {
+			return fmt.Errorf("%w: the sale price %s is above the list price %s of %s", InvalidProduct, sale.Price, list, t.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// checkPriceList makes sure the fixed prices are in distinct currencies
+// other than the product's own
+func checkPriceList(list []money.Money, currency string) error {
+	seen := map[string]bool{currency: true}
+	for _, price := range list {
+		if err := checkPrice(&price, ""); err != nil {
+			return fmt.Errorf("price_list: %w", err)
+		}
+		if seen[price.Currency] {
+			return fmt.Errorf("%w: price_list needs one price per currency other than %s", InvalidProduct, currency)
+		}
+		seen[price.Currency] = true
+	}
+	return nil
+}
+
+// checkPriceRange makes sure the bounds of a price filter are in one
+// currency and in order
+func checkPriceRange(min *money.Money, max *money.Money) error {
+	if min == nil || max == nil {
+		return nil
+	}
+	cmp, err := min.Cmp(*max)
+	if err != nil {
+		return fmt.Errorf("%w: %v", InvalidQuery, err)
+	}
+	if cmp > 0 {
+		return fmt.Errorf("%w: min_price is above max_price", InvalidQuery)
+	}
+	return nil
+}
+
+// ignoreGone drops the error of a write that matched nothing, because the
+// schedule was taken care of meanwhile
+func ignoreGone(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	return err
+}
+
+// recordPrices adds the prices of the product, or of its variant sku, at
+// to the history. A failure is logged, the change itself is made.
+func (p *Productservice) recordPrices(product *model.Product, sku string, reason string, scheduleID string, actor string, at time.Time) {
+	list, effective := product.Prices(sku, at)
+	change := &model.PriceChange{
+		Change_id:       primitive.NewObjectID().Hex(),
+		Product_id:      product.Product_id,
+		Sku:             sku,
+		List_price:      list,
+		Effective_price: effective,
+		Reason:          reason,
+		Schedule_id:     scheduleID,
+		Actor:           actor,
+		Changed_at:      at,
+	}
+	if err := p.priceRepo.RecordPrice(change); err != nil {
+		log.Printf("Failed to record price change of %s: %v", stockName(product.Product_id, sku), err)
+	}
+}
@@ -2,33 +2,82 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/sachinggsingh/e-comm/internal/intra/db"
 	"github.com/sachinggsingh/e-comm/internal/model"
 	"github.com/sachinggsingh/e-comm/internal/repository"
+	"github.com/sachinggsingh/e-comm/internal/search"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Productservice struct {
-	proRepo repository.ProductRepository
+	proRepo   repository.ProductRepository
+	catRepo   repository.CategoryRepository
+	priceRepo repository.PriceRepository
 }
 
 var (
 	CantCreateProduct = errors.New("cannot create product")
 	ProductExist      = errors.New("product already exist")
+	ProductNotFound   = errors.New("product not found")
+	InvalidProduct    = errors.New("invalid product")
+	// VersionMismatch means the product changed since the caller read it
+	VersionMismatch   = errors.New("product was modified by someone else, reload it and retry")
+	ProductNotDeleted = errors.New("product is not deleted")
+	InvalidQuery      = errors.New("invalid product query")
 )
 
-func NewProductService(proRepo repository.ProductRepository) *Productservice {
+func NewProductService(proRepo repository.ProductRepository, catRepo repository.CategoryRepository, priceRepo repository.PriceRepository) *Productservice {
 	return &Productservice{
-		proRepo: proRepo,
+		proRepo:   proRepo,
+		catRepo:   catRepo,
+		priceRepo: priceRepo,
 	}
 }
 
-func (p *Productservice) CreateProduct(pro *model.Product) (*model.Product, error) {
-	if pro.Name == nil || pro.Description == nil || pro.Price == nil {
+// CreateProduct adds the product; actor is recorded with its prices
+func (p *Productservice) CreateProduct(pro *model.Product, actor string) (*model.Product, error) {
+	if pro.Name == nil || pro.Description == nil || pro.Price == nil || pro.Stock < 0 {
 		return nil, CantCreateProduct
 	}
 
+	if pro.Sku != "" && !skuPattern.MatchString(pro.Sku) {
+		return nil, fmt.Errorf("%w: sku must be letters, digits, dots, dashes or underscores", InvalidProduct)
+	}
+	if err := checkPrice(pro.Price, ""); err != nil {
+		return nil, err
+	}
+	if err := checkPriceList(pro.Price_list, pro.Currency()); err != nil {
+		return nil, err
+	}
+	for _, variant := range pro.Variants {
+		if variant.Price == nil {
+			continue
+		}
+		if err := checkPrice(variant.Price, pro.Currency()); err != nil {
+			return nil, fmt.Errorf("sku %s: %w", variant.Sku, err)
+		}
+	}
+	if err := p.checkCategories(pro.Category_ids); err != nil {
+		return nil, err
+	}
+	if err := checkOptions(pro.Options); err != nil {
+		return nil, err
+	}
+	if len(pro.Variants) > maxVariants {
+		return nil, fmt.Errorf("%w: a product has at most %d variants", InvalidProduct, maxVariants)
+	}
+	if err := checkVariants(pro.Options, pro.Variants); err != nil {
+		return nil, err
+	}
+	if len(pro.Variants) > 0 && pro.Stock != 0 {
+		return nil, fmt.Errorf("%w: a product with variants keeps its stock on the variants", InvalidProduct)
+	}
+
 	// check if the product already exist or not
 	exist, err := p.proRepo.CheckIfPoductExist(pro)
 	if err != nil {
@@ -42,22 +91,271 @@ func (p *Productservice) CreateProduct(pro *model.Product) (*model.Product, erro
 	pro.Created_at = now
 	pro.Updated_at = now
 	pro.Product_id = pro.ID.Hex()
+	pro.Version = 1
+	pro.Deleted_at = nil
+	pro.Search_keys = search.Keys(*pro.Name)
+	pro.Reservations = nil
+	pro.Price_schedules = nil
+
+	created, err := p.proRepo.CreateProduct(pro)
+	if err != nil {
+		return nil, duplicateSku(err)
+	}
+	p.recordPrices(created, "", model.PriceSet, "", actor, now)
+	for _, variant := range created.Variants {
+		if variant.Price != nil {
+			p.recordPrices(created, variant.Sku, model.PriceSet, "", actor, now)
+		}
+	}
+	return created, nil
+}
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ListProducts returns one page of the catalog. Unset fields of query get
+// their defaults: DefaultPageSize products, newest first.
+func (p *Productservice) ListProducts(query *model.ProductQuery) (*model.ProductPage, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	if query.Limit < 0 || query.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", InvalidQuery, MaxPageSize)
+	}
+	if query.Sort == "" {
+		query.Sort, query.Desc = "created_at", true
+	}
+	if _, ok := repository.SortFields[query.Sort]; !ok {
+		return nil, fmt.Errorf("%w: cannot sort by %q, use price, name or created_at", InvalidQuery, query.Sort)
+	}
+	if err := checkPriceRange(query.Min_price, query.Max_price); err != nil {
+		return nil, err
+	}
+	ids, err := p.categoryTree(query.Category)
+	if err != nil {
+		return nil, err
+	}
+	query.Category_ids = ids
 
-	return p.proRepo.CreateProduct(pro)
+	page, err := p.proRepo.ListProducts(query)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return nil, fmt.Errorf("%w: %v", InvalidQuery, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, product := range page.Items {
+		product.SetEffectivePrices(now)
+	}
+	return page, nil
 }
 
-func (p *Productservice) GetAllProducts() ([]*model.Product, error) {
-	return p.proRepo.GetAllProducts()
+// ListAllProducts returns every product matching query in one list, read
+// page by page, for the unpaged listing clients relied on before paging
+func (p *Productservice) ListAllProducts(query *model.ProductQuery) ([]*model.Product, error) {
+	query.Limit = MaxPageSize
+	query.Include_total = false
+	products := []*model.Product{}
+	for {
+		page, err := p.ListProducts(query)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, page.Items...)
+		if page.Next_cursor == "" {
+			return products, nil
+		}
+		query.Cursor = page.Next_cursor
+	}
 }
 
 func (p *Productservice) GetProductById(pro *model.Product) (*model.Product, error) {
-	return p.proRepo.GetProductById(pro)
+	product, err := p.proRepo.GetProductById(pro)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	product.SetEffectivePrices(time.Now())
+	return product, nil
 }
 
-// func (p *Productservice) UpdateProduct(pro *model.Product) (*model.Product, error) {
-// 	return p.proRepo.UpdateProduct(pro)
-// }
+// UpdateProduct changes the product if it is still at version. replace
+// requires every field, as for PUT. A price change is recorded with actor.
+func (p *Productservice) UpdateProduct(id string, version int64, update *model.UpdateProductRequest, replace bool, actor string) (*model.Product, error) {
+	if replace && (update.Name == nil || update.Description == nil || update.Price == nil) {
+		return nil, fmt.Errorf("%w: name, description and price are required", InvalidProduct)
+	}
+	if update.Name == nil && update.Description == nil && update.Price == nil && update.Price_list == nil && update.Category_ids == nil && update.Options == nil && update.Sku == nil {
+		return nil, fmt.Errorf("%w: nothing to update", InvalidProduct)
+	}
+	if update.Sku != nil && *update.Sku != "" && !skuPattern.MatchString(*update.Sku) {
+		return nil, fmt.Errorf("%w: sku must be letters, digits, dots, dashes or underscores", InvalidProduct)
+	}
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name must not be empty", InvalidProduct)
+		}
+		update.Name = &name
+		update.Search_keys = search.Keys(name)
+	}
+	if update.Price != nil {
+		if err := checkPrice(update.Price, ""); err != nil {
+			return nil, err
+		}
+	}
+	if update.Category_ids != nil {
+		if err := p.checkCategories(*update.Category_ids); err != nil {
+			return nil, err
+		}
+	}
+	var current *model.Product
+	if update.Options != nil || update.Price != nil || update.Price_list != nil {
+		var err error
+		if current, err = p.proRepo.GetProductById(&model.Product{Product_id: id}); err != nil {
+			return nil, p.writeFailed(id, err, false)
+		}
+	}
+	if update.Price != nil && update.Price.Currency != current.Currency() && current.PricedInCurrency() {
+		return nil, fmt.Errorf("%w: price must be in %s while variants or price schedules are priced", InvalidProduct, current.Currency())
+	}
+	if update.Price != nil || update.Price_list != nil {
+		currency, list := current.Currency(), current.Price_list
+		if update.Price != nil {
+			currency = update.Price.Currency
+		}
+		if update.Price_list != nil {
+			list = *update.Price_list
+		}
+		if err := checkPriceList(list, currency); err != nil {
+			return nil, err
+		}
+	}
+	if update.Options != nil {
+		if err := checkOptions(*update.Options); err != nil {
+			return nil, err
+		}
+		// The variants must fit the new options. A variant added meanwhile
+		// changes the version, failing the update.
+		if err := checkVariants(*update.Options, current.Variants); err != nil {
+			return nil, err
+		}
+	}
+
+	product, err := p.proRepo.UpdateProduct(id, version, update)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateSku(err)
+		}
+		return nil, p.writeFailed(id, err, false)
+	}
+	if update.Price != nil && !samePrice(current.Price, update.Price) {
+		p.recordPrices(product, "", model.PriceSet, "", actor, product.Updated_at)
+	}
+	return product, nil
+}
 
-// func (p *Productservice) DeleteProduct(id string) error {
-// 	return p.proRepo.DeleteProduct(id)
-// }
+// duplicateSku tells which SKU a duplicate key error is about: the merchant
+// SKU of another product or the SKU of one of its variants
+func duplicateSku(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	if strings.Contains(err.Error(), db.ProductSkuIndex) {
+		return fmt.Errorf("%w: the sku is used by another product", ProductExist)
+	}
+	return VariantExist
+}
+
+// DeleteProduct soft deletes the product if it is still at version. It is
+// hidden from listings and lookups until restored.
+func (p *Productservice) DeleteProduct(id string, version int64) (*model.Product, error) {
+	product, err := p.proRepo.DeleteProduct(id, version)
+	if err != nil {
+		return nil, p.writeFailed(id, err, false)
+	}
+	return product, nil
+}
+
+// RestoreProduct brings back a soft deleted product
+func (p *Productservice) RestoreProduct(id string, version int64) (*model.Product, error) {
+	product, err := p.proRepo.RestoreProduct(id, version)
+	if err != nil {
+		return nil, p.writeFailed(id, err, true)
+	}
+	return product, nil
+}
+
+// writeFailed explains why a versioned write matched no product: it does
+// not exist (in the expected deleted state) or is at another version
+func (p *Productservice) writeFailed(id string, err error, wantDeleted bool) error {
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	product, err := p.proRepo.FindProduct(id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ProductNotFound
+		}
+		return err
+	}
+	deleted := product.Deleted_at != nil
+	if wantDeleted && !deleted {
+		return ProductNotDeleted
+	}
+	if !wantDeleted && deleted {
+		return ProductNotFound
+	}
+	return VersionMismatch
+}
+
+// checkCategories makes sure the categories assigned to a product exist
+func (p *Productservice) checkCategories(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	found, err := p.catRepo.FindCategories(ids)
+	if err != nil {
+		return fmt.Errorf("failed to find categories: %w", err)
+	}
+	known := map[string]bool{}
+	for _, category := range found {
+		known[category.Category_id] = true
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return fmt.Errorf("%w: unknown category %s", InvalidProduct, id)
+		}
+	}
+	return nil
+}
+
+// categoryTree resolves the category filter of a listing, an id or slug, to
+// the ids of the category and its descendants. A value that names no
+// category is matched as is.
+func (p *Productservice) categoryTree(category string) ([]string, error) {
+	if category == "" {
+		return nil, nil
+	}
+	found, err := p.catRepo.FindCategory(category)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find category: %w", err)
+	}
+	descendants, err := p.catRepo.Descendants(found.Category_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subcategories: %w", err)
+	}
+	ids := []string{found.Category_id}
+	for _, d := range descendants {
+		ids = append(ids, d.Category_id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/internal/search"
+	"github.com/sachinggsingh/e-comm/pb/money"
+)
+
+const (
+	// searchCandidates bounds how many products each search strategy
+	// returns; ranking, facets and totals cover at most twice that many,
+	// and are marked inexact when a strategy hit the bound
+	searchCandidates = 500
+	maxQueryLength   = 200
+	// descriptionSnippet is the length the description highlight is cut to
+	descriptionSnippet = 200
+)
+
+// priceBuckets are the upper bounds of the price facet buckets, in major
+// units of the currency of each product
+var priceBuckets = []int64{25, 50, 100, 250}
+
+// Search finds products by name and description. Whole words are found
+// through the MongoDB text index; prefixes and single typos in name words
+// through the search keys. Results are ranked by how well the words match,
+// then by the text score.
+func (p *Productservice) Search(query *model.SearchQuery) (*model.SearchResult, error) {
+	query.Query = strings.TrimSpace(query.Query)
+	terms := search.Tokenize(query.Query)
+	if len(terms) == 0 || len(query.Query) > maxQueryLength {
+		return nil, fmt.Errorf("%w: q must have a word and at most %d characters", InvalidQuery, maxQueryLength)
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	if query.Limit < 0 || query.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", InvalidQuery, MaxPageSize)
+	}
+	if query.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", InvalidQuery)
+	}
+	if err := checkPriceRange(query.Min_price, query.Max_price); err != nil {
+		return nil, err
+	}
+
+	ids, err := p.categoryTree(query.Category)
+	if err != nil {
+		return nil, err
+	}
+	query.Category_ids = ids
+
+	candidates, truncated, err := p.proRepo.SearchProducts(query, search.QueryKeys(query.Query), searchCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search products: %w", err)
+	}
+
+	hits := []*model.SearchHit{}
+	for _, candidate := range candidates {
+		var name, description string
+		if candidate.Name != nil {
+			name = *candidate.Name
+		}
+		if candidate.Description != nil {
+			description = *candidate.Description
+		}
+		match := search.Score(terms, name, description)
+		if match == 0 && candidate.TextScore == 0 {
+			continue
+		}
+		product := candidate.Product
+		hits = append(hits, &model.SearchHit{Product: &product, Score: 2*match + candidate.TextScore})
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+
+	result := &model.SearchResult{
+		Total:       int64(len(hits)),
+		Total_exact: !truncated,
+		Facets:      facets(hits),
+	}
+	start := min(query.Offset, len(hits))
+	end := min(start+query.Limit, len(hits))
+	result.Hits = hits[start:end]
+	now := time.Now()
+	for _, hit := range result.Hits {
+		hit.SetEffectivePrices(now)
+		if hit.Name != nil {
+			hit.Name_highlight = search.Highlight(*hit.Name, terms, 0)
+		}
+		if hit.Description != nil {
+			hit.Description_highlight = search.Highlight(*hit.Description, terms, descriptionSnippet)
+		}
+	}
+	return result, nil
+}
+
+func priceBucket(price money.Money) string {
+	exp, _ := money.Exponent(price.Currency)
+	unit := int64(math.Pow10(exp))
+	for i, upper := range priceBuckets {
+		if price.Amount < upper*unit {
+			return bucketName(i)
+		}
+	}
+	return bucketName(len(priceBuckets))
+}
+
+// bucketName names price bucket i, such as "25-50", or "250+" for the last
+func bucketName(i int) string {
+	var lower int64
+	if i > 0 {
+		lower = priceBuckets[i-1]
+	}
+	if i == len(priceBuckets) {
+		return fmt.Sprintf("%d+", lower)
+	}
+	return fmt.Sprintf("%d-%d", lower, priceBuckets[i])
+}
+
+// facets counts the hits per category, price bucket and availability
+func facets(hits []*model.SearchHit) model.SearchFacets {
+	categories := map[string]int64{}
+	prices := map[string]int64{}
+	result := model.SearchFacets{}
+	for _, hit := range hits {
+		for _, category := range hit.Category_ids {
+			categories[category]++
+		}
+		if hit.Price != nil {
+			prices[priceBucket(*hit.Price)]++
+		}
+		if hit.InStock() {
+			result.In_stock++
+		} else {
+			result.Out_of_stock++
+		}
+	}
+
+	result.Categories = []model.FacetCount{}
+	for value, count := range categories {
+		result.Categories = append(result.Categories, model.FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(result.Categories, func(i, j int) bool {
+		a, b := result.Categories[i], result.Categories[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Value < b.Value
+	})
+
+	// Buckets are listed in price order, empty ones included
+	result.Price_buckets = []model.FacetCount{}
+	for i := 0; i <= len(priceBuckets); i++ {
+		bucket := bucketName(i)
+		result.Price_buckets = append(result.Price_buckets, model.FacetCount{Value: bucket, Count: prices[bucket]})
+	}
+	return result
+}
+
+// BackfillSearchKeys derives the search keys of products created before
+// search existed
+func (p *Productservice) BackfillSearchKeys() error {
+	products, err := p.proRepo.ProductsWithoutSearchKeys()
+	if err != nil {
+		return fmt.Errorf("failed to find products to index: %w", err)
+	}
+	for _, product := range products {
+		var name string
+		if product.Name != nil {
+			name = *product.Name
+		}
+		if err := p.proRepo.SetSearchKeys(product.Product_id, search.Keys(name)); err != nil {
+			return fmt.Errorf("failed to index product %s: %w", product.Product_id, err)
+		}
+	}
+	if len(products) > 0 {
+		log.Printf("Indexed %d products for search", len(products))
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sachinggsingh/e-comm/internal/model"
+	"github.com/sachinggsingh/e-comm/pb/money"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxVariants = 100
+
+var (
+	VariantNotFound = errors.New("variant not found")
+	VariantExist    = errors.New("sku is used by another product")
+	// VariantReserved means stock of the variant is held for pending orders
+	VariantReserved = errors.New("variant has stock reserved for pending orders")
+	// ProductHasStock means the product holds stock of its own, which its
+	// first variant would strand
+	ProductHasStock = errors.New("product has stock of its own, adjust it to 0 before adding variants")
+)
+
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// UpsertVariant changes the attributes and price of the variant with the
+// SKU, or adds it with the stock of the request. A price change is recorded
+// with actor. The first variant is refused while the product holds or has
+// reserved stock of its own.
+func (p *Productservice) UpsertVariant(id string, sku string, req *model.VariantRequest, actor string) (*model.Product, error) {
+	if !skuPattern.MatchString(sku) {
+		return nil, fmt.Errorf("%w: sku must be letters, digits, dots, dashes or underscores", InvalidProduct)
+	}
+	if req.Stock < 0 {
+		return nil, fmt.Errorf("%w: stock must not be negative", InvalidProduct)
+	}
+
+	product, err := p.proRepo.GetProductById(&model.Product{Product_id: id})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ProductNotFound
+		}
+		return nil, err
+	}
+	if req.Price != nil {
+		if err := checkPrice(req.Price, product.Currency()); err != nil {
+			return nil, err
+		}
+	}
+	var previous *money.Money
+	if existing := product.FindVariant(sku); existing != nil {
+		previous = existing.Price
+	}
+	variant := model.Variant{Sku: sku, Attributes: req.Attributes, Price: req.Price, Stock: req.Stock}
+	if variant.Attributes == nil {
+		variant.Attributes = map[string]string{}
+	}
+	variants := slices.DeleteFunc(slices.Clone(product.Variants), func(v model.Variant) bool {
+		return v.Sku == sku
+	})
+	if product.FindVariant(sku) == nil && len(variants) >= maxVariants {
+		return nil, fmt.Errorf("%w: a product has at most %d variants", InvalidProduct, maxVariants)
+	}
+	if err := checkVariants(product.Options, append(variants, variant)); err != nil {
+		return nil, err
+	}
+	if len(product.Variants) == 0 && holdsStock(product) {
+		return nil, ProductHasStock
+	}
+
+	// The version makes sure the variants did not change since the checks
+	updated, err := p.proRepo.UpsertVariant(id, product.Version, variant)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, VariantExist
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, p.variantWriteFailed(id, product.Version)
+		}
+		return nil, fmt.Errorf("failed to save variant: %w", err)
+	}
+	if !samePrice(previous, req.Price) {
+		p.recordPrices(updated, sku, model.PriceSet, "", actor, updated.Updated_at)
+	}
+	return updated, nil
+}
+
+func samePrice(a *money.Money, b *money.Money) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// holdsStock reports whether the product has stock, or reserved stock, of
+// its own rather than of a variant
+func holdsStock(product *model.Product) bool {
+	if product.Stock != 0 {
+		return true
+	}
+	return slices.ContainsFunc(product.Reservations, func(r model.StockReservation) bool {
+		return r.Sku == ""
+	})
+}
+
+// variantWriteFailed explains why adding or changing a variant matched no
+// product: it is gone, gained stock of its own or changed since version
+func (p *Productservice) variantWriteFailed(id string, version int64) error {
+	product, err := p.proRepo.FindProduct(id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ProductNotFound
+		}
+		return err
+	}
+	if product.Deleted_at != nil {
+		return ProductNotFound
+	}
+	if product.Version == version && len(product.Variants) == 0 && holdsStock(product) {
+		return ProductHasStock
+	}
+	return VersionMismatch
+}
+
+// DeleteVariant removes the variant with the SKU, and with it its stock. It
+// is refused while stock of the variant is reserved, releasing the
+// reservation would have no variant to give the stock back to.
+func (p *Productservice) DeleteVariant(id string, sku string) (*model.Product, error) {
+	product, err := p.proRepo.DeleteVariant(id, sku)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed to delete variant: %w", err)
+		}
+		current, err := p.proRepo.FindProduct(id)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed to delete variant: %w", err)
+		}
+		if err != nil || current.Deleted_at != nil || current.FindVariant(sku) == nil {
+			return nil, VariantNotFound
+		}
+		return nil, VariantReserved
+	}
+	return product, nil
+}
+
+// checkOptions makes sure every option has a name and distinct values
+func checkOptions(options []model.ProductOption) error {
+	names := map[string]bool{}
+	for _, option := range options {
+		if strings.TrimSpace(option.Name) == "" || names[option.Name] {
+			return fmt.Errorf("%w: options need distinct names", InvalidProduct)
+		}
+		names[option.Name] = true
+		if len(option.Values) == 0 {
+			return fmt.Errorf("%w: option %s has no values", InvalidProduct, option.Name)
+		}
+		values := map[string]bool{}
+		for _, value := range option.Values {
+			if strings.TrimSpace(value) == "" || values[value] {
+				return fmt.Errorf("%w: option %s needs distinct values", InvalidProduct, option.Name)
+			}
+			values[value] = true
+		}
+	}
+	return nil
+}
+
+// checkVariants makes sure the SKUs are distinct and, when the product has
+// options, that every variant has a value of each option and no two
+// variants the same values
+func checkVariants(options []model.ProductOption, variants []model.Variant) error {
+	skus := map[string]bool{}
+	combinations := map[string]string{}
+	for _, variant := range variants {
+		if !skuPattern.MatchString(variant.Sku) || skus[variant.Sku] {
+			return fmt.Errorf("%w: variants need distinct skus of letters, digits, dots, dashes or underscores", InvalidProduct)
+		}
+		skus[variant.Sku] = true
+		if variant.Stock < 0 {
+			return fmt.Errorf("%w: sku %s has a negative stock", InvalidProduct, variant.Sku)
+		}
+		if len(options) == 0 {
+			continue
+		}
+
+		if len(variant.Attributes) != len(options) {
+			return fmt.Errorf("%w: sku %s needs exactly one value for each option", InvalidProduct, variant.Sku)
+		}
+		pairs := make([]string, 0, len(options))
+		for _, option := range options {
+			value, ok := variant.Attributes[option.Name]
+			if !ok || !slices.Contains(option.Values, value) {
+				return fmt.Errorf("%w: sku %s needs one of %s for %s", InvalidProduct, variant.Sku, strings.Join(option.Values, ", "), option.Name)
+			}
+			pairs = append(pairs, option.Name+"="+value)
+		}
+		sort.Strings(pairs)
+		combination := strings.Join(pairs, ",")
+		if other, ok := combinations[combination]; ok {
+			return fmt.Errorf("%w: skus %s and %s have the same options", InvalidProduct, other, variant.Sku)
+		}
+		combinations[combination] = variant.Sku
+	}
+	return nil
+}